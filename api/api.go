@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/processor"
+	"github.com/rs/zerolog/hlog"
+)
+
+// StatusPath is the path at which the processing status of scans can be polled,
+// given one or multiple id query parameters.
+const StatusPath = "/api/scans/status"
+
+// Processor is implemented by the autoscan processor.
+type Processor interface {
+	Status(ids ...string) ([]processor.ScanStatus, error)
+	Failed() ([]processor.FailedScan, error)
+	Requeue(ids ...int64) (int, error)
+	History(limit int) ([]processor.HistoryEntry, error)
+	AuditLog(event string, limit int) ([]processor.AuditEntry, error)
+	Stats() (processor.Stats, error)
+	RequeueHistory(ids []string, prefix string, targets []string) ([]autoscan.Scan, error)
+	Pause() error
+	Resume() error
+	PauseStatus() (processor.PauseStatus, error)
+	Maintain() (processor.MaintenanceResult, error)
+	Queue() ([]processor.QueueEntry, error)
+	Flush() (int, error)
+	RetryNow(ids ...string) (int, error)
+	Remove(ids ...string) (int, error)
+	Add(scans ...autoscan.Scan) error
+	Scans(f processor.ScanFilter) ([]processor.ScanEntry, error)
+	RemoveScans(f processor.ScanFilter) (int, error)
+}
+
+// New creates the HTTP handler of the autoscan API,
+// which should be added to the autoscan router at /api/.
+func New(p Processor) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(StatusPath, statusHandler{processor: p})
+	mux.Handle(FailedPath, failedHandler{processor: p})
+	mux.Handle(RequeuePath, requeueHandler{processor: p})
+	mux.Handle(HistoryPath, historyHandler{processor: p})
+	mux.Handle(AuditPath, auditHandler{processor: p})
+	mux.Handle(StatsPath, statsHandler{processor: p})
+	mux.Handle(RequeueHistoryPath, requeueHistoryHandler{processor: p})
+	mux.Handle(PausePath, pauseHandler{processor: p})
+	mux.Handle(ResumePath, resumeHandler{pauseHandler{processor: p}})
+	mux.Handle(MaintenancePath, maintenanceHandler{processor: p})
+	mux.Handle(QueuePath, queueHandler{processor: p})
+	mux.Handle(FlushPath, flushHandler{processor: p})
+	mux.Handle(RetryQueuePath, retryQueueHandler{processor: p})
+	mux.Handle(DeleteQueuePath, deleteQueueHandler{processor: p})
+	mux.Handle(ScansPath, scansHandler{processor: p})
+	mux.Handle(ScansPath+"/", scansHandler{processor: p})
+	return mux
+}
+
+type statusHandler struct {
+	processor Processor
+}
+
+func (h statusHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "GET" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ids := r.URL.Query()["id"]
+	if len(ids) == 0 {
+		rlog.Error().Msg("Status request should receive at least one id")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	statuses, err := h.processor.Status(ids...)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving scan status")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(statuses); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
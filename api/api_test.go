@@ -0,0 +1,247 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/processor"
+)
+
+type mockProcessor struct {
+	statuses map[string]processor.ScanStatus
+	failed   []processor.FailedScan
+	history  []processor.HistoryEntry
+	audit    []processor.AuditEntry
+	stats    processor.Stats
+	pause    *processor.PauseStatus
+
+	maintenance processor.MaintenanceResult
+	queue       []processor.QueueEntry
+	scans       []processor.ScanEntry
+	added       *[]autoscan.Scan
+}
+
+func (p mockProcessor) Status(ids ...string) ([]processor.ScanStatus, error) {
+	statuses := make([]processor.ScanStatus, 0)
+	for _, id := range ids {
+		status, ok := p.statuses[id]
+		if !ok {
+			return nil, errors.New("unexpected id")
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (p mockProcessor) Failed() ([]processor.FailedScan, error) {
+	return p.failed, nil
+}
+
+func (p mockProcessor) Requeue(ids ...int64) (int, error) {
+	requeued := 0
+	for _, id := range ids {
+		for _, f := range p.failed {
+			if f.ID == id {
+				requeued++
+			}
+		}
+	}
+
+	return requeued, nil
+}
+
+func (p mockProcessor) AuditLog(event string, limit int) ([]processor.AuditEntry, error) {
+	entries := make([]processor.AuditEntry, 0)
+	for _, e := range p.audit {
+		if len(entries) < limit && (event == "" || e.Event == event) {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+func (p mockProcessor) History(limit int) ([]processor.HistoryEntry, error) {
+	if limit < len(p.history) {
+		return p.history[:limit], nil
+	}
+
+	return p.history, nil
+}
+
+func (p mockProcessor) Stats() (processor.Stats, error) {
+	return p.stats, nil
+}
+
+func (p mockProcessor) RequeueHistory(ids []string, prefix string, targets []string) ([]autoscan.Scan, error) {
+	scans := make([]autoscan.Scan, 0)
+	for _, e := range p.history {
+		if (prefix != "" && strings.HasPrefix(e.Folder, prefix)) || contains(ids, e.ScanID) {
+			scans = append(scans, autoscan.Scan{Folder: e.Folder, Targets: targets})
+		}
+	}
+
+	return scans, nil
+}
+
+func (p mockProcessor) Pause() error {
+	if !p.pause.Paused {
+		since := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		*p.pause = processor.PauseStatus{Paused: true, Since: &since}
+	}
+
+	return nil
+}
+
+func (p mockProcessor) Resume() error {
+	*p.pause = processor.PauseStatus{}
+	return nil
+}
+
+func (p mockProcessor) PauseStatus() (processor.PauseStatus, error) {
+	return *p.pause, nil
+}
+
+func (p mockProcessor) Maintain() (processor.MaintenanceResult, error) {
+	return p.maintenance, nil
+}
+
+func (p mockProcessor) Queue() ([]processor.QueueEntry, error) {
+	return p.queue, nil
+}
+
+func (p mockProcessor) Flush() (int, error) {
+	return len(p.queue), nil
+}
+
+func (p mockProcessor) RetryNow(ids ...string) (int, error) {
+	return p.queued(ids), nil
+}
+
+func (p mockProcessor) Remove(ids ...string) (int, error) {
+	return p.queued(ids), nil
+}
+
+func (p mockProcessor) Add(scans ...autoscan.Scan) error {
+	if p.added == nil {
+		return errors.New("unexpected scans")
+	}
+
+	*p.added = append(*p.added, scans...)
+	return nil
+}
+
+func (p mockProcessor) Scans(f processor.ScanFilter) ([]processor.ScanEntry, error) {
+	scans := make([]processor.ScanEntry, 0)
+	for _, e := range p.scans {
+		if (f.State == "" || f.State == e.State) && strings.HasPrefix(e.Folder, f.Prefix) &&
+			(f.Trigger == "" || f.Trigger == e.Trigger) {
+			scans = append(scans, e)
+		}
+	}
+
+	return scans, nil
+}
+
+func (p mockProcessor) RemoveScans(f processor.ScanFilter) (int, error) {
+	scans, err := p.Scans(f)
+	return len(scans), err
+}
+
+func (p mockProcessor) queued(ids []string) int {
+	n := 0
+	for _, e := range p.queue {
+		if contains(ids, e.ID) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestStatus(t *testing.T) {
+	type Test struct {
+		Name         string
+		URL          string
+		WantCode     int
+		WantStatuses []processor.ScanStatus
+	}
+
+	queued := processor.ScanStatus{ID: "a", Folder: "/mnt/unionfs/Media/Movies", Status: processor.StatusQueued}
+	unknown := processor.ScanStatus{ID: "b", Status: processor.StatusUnknown}
+
+	p := mockProcessor{statuses: map[string]processor.ScanStatus{
+		"a": queued,
+		"b": unknown,
+	}}
+
+	var testCases = []Test{
+		{
+			Name:         "Returns the status of each ID",
+			URL:          StatusPath + "?id=a&id=b",
+			WantCode:     200,
+			WantStatuses: []processor.ScanStatus{queued, unknown},
+		},
+		{
+			Name:     "Returns bad request without IDs",
+			URL:      StatusPath,
+			WantCode: 400,
+		},
+		{
+			Name:     "Returns internal server error when the processor fails",
+			URL:      StatusPath + "?id=c",
+			WantCode: 500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := httptest.NewServer(New(p))
+			defer server.Close()
+
+			res, err := http.Get(server.URL + tc.URL)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.WantStatuses == nil {
+				return
+			}
+
+			statuses := make([]processor.ScanStatus, 0)
+			if err := json.NewDecoder(res.Body).Decode(&statuses); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(statuses, tc.WantStatuses) {
+				t.Logf("want: %v", tc.WantStatuses)
+				t.Logf("got:  %v", statuses)
+				t.Errorf("Statuses do not match")
+			}
+		})
+	}
+}
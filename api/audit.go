@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudbox/autoscan/processor"
+	"github.com/rs/zerolog/hlog"
+)
+
+// AuditPath is the path at which the most recent entries of the audit log can be listed,
+// limited by the optional limit query parameter and filtered by the optional event query parameter.
+const AuditPath = "/api/audit"
+
+type auditHandler struct {
+	processor Processor
+}
+
+func (h auditHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "GET" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if param := r.URL.Query().Get("limit"); param != "" {
+		l, err := strconv.Atoi(param)
+		if err != nil || l < 1 {
+			rlog.Error().Str("limit", param).Msg("Invalid limit")
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		limit = l
+	}
+
+	event := r.URL.Query().Get("event")
+	switch event {
+	case "", processor.AuditAuthFailure, processor.AuditWebhook, processor.AuditAdmin:
+	default:
+		rlog.Error().Str("event", event).Msg("Invalid event")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.processor.AuditLog(event, limit)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving audit log")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(entries); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
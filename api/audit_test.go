@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+func TestAudit(t *testing.T) {
+	type Test struct {
+		Name        string
+		URL         string
+		WantCode    int
+		WantEntries []processor.AuditEntry
+	}
+
+	at := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	entries := []processor.AuditEntry{
+		{ID: 3, Time: at, Event: processor.AuditAdmin, SourceIP: "10.0.0.2", Username: "hello there", Method: "POST", Path: "/api/queue/flush", Status: 200},
+		{ID: 2, Time: at, Event: processor.AuditWebhook, Trigger: "sonarr", SourceIP: "10.0.0.1", Method: "POST", Path: "/triggers/sonarr", Status: 200, Scans: 2},
+		{ID: 1, Time: at, Event: processor.AuditAuthFailure, SourceIP: "10.0.0.3", Username: "obi-wan", Method: "GET", Path: "/api/queue", Status: 401},
+	}
+
+	p := mockProcessor{audit: entries}
+
+	var testCases = []Test{
+		{
+			Name:        "Returns the audit log",
+			URL:         AuditPath,
+			WantCode:    200,
+			WantEntries: entries,
+		},
+		{
+			Name:        "Limits the number of entries",
+			URL:         AuditPath + "?limit=1",
+			WantCode:    200,
+			WantEntries: entries[:1],
+		},
+		{
+			Name:        "Filters the entries by event",
+			URL:         AuditPath + "?event=auth_failure",
+			WantCode:    200,
+			WantEntries: entries[2:],
+		},
+		{
+			Name:     "Returns bad request for an invalid limit",
+			URL:      AuditPath + "?limit=0",
+			WantCode: 400,
+		},
+		{
+			Name:     "Returns bad request for an unknown event",
+			URL:      AuditPath + "?event=login",
+			WantCode: 400,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := httptest.NewServer(New(p))
+			defer server.Close()
+
+			res, err := http.Get(server.URL + tc.URL)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.WantEntries == nil {
+				return
+			}
+
+			got := make([]processor.AuditEntry, 0)
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.WantEntries) {
+				t.Logf("want: %v", tc.WantEntries)
+				t.Logf("got:  %v", got)
+				t.Errorf("Audit log does not match")
+			}
+		})
+	}
+}
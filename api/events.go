@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+	"github.com/rs/zerolog/hlog"
+)
+
+// EventsPath is the path at which the events of the scans are streamed as server-sent events,
+// limited to the given types by the optional type query parameters.
+const EventsPath = "/api/events"
+
+// keepAliveInterval is the interval at which a comment is sent when no events occur,
+// such that proxies do not close the idle stream.
+const keepAliveInterval = 30 * time.Second
+
+// EventSource is implemented by the autoscan processor.
+type EventSource interface {
+	Subscribe() (<-chan processor.ScanEvent, func())
+}
+
+// NewEvents creates the HTTP handler of the event stream,
+// which should be added to the autoscan router at EventsPath.
+// A stream is closed after maxDuration, which should be shorter than the write timeout of the server,
+// after which the client reconnects. Streams are not limited when maxDuration is 0.
+func NewEvents(s EventSource, maxDuration time.Duration) http.Handler {
+	return eventsHandler{source: s, maxDuration: maxDuration}
+}
+
+type eventsHandler struct {
+	source      EventSource
+	maxDuration time.Duration
+}
+
+func (h eventsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "GET" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		rlog.Error().Msg("Response does not support streaming")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	types := make(map[string]bool)
+	for _, t := range r.URL.Query()["type"] {
+		types[t] = true
+	}
+
+	events, unsubscribe := h.source.Subscribe()
+	defer unsubscribe()
+
+	var end <-chan time.Time
+	if h.maxDuration > 0 {
+		timer := time.NewTimer(h.maxDuration)
+		defer timer.Stop()
+		end = timer.C
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	fmt.Fprint(rw, ": connected\n\n")
+	flusher.Flush()
+
+	rlog.Debug().Msg("Event stream opened")
+	for {
+		select {
+		case <-r.Context().Done():
+			rlog.Debug().Msg("Event stream closed by client")
+			return
+
+		case <-end:
+			return
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(rw, ": keep-alive\n\n"); err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if len(types) > 0 && !types[e.Type] {
+				continue
+			}
+
+			data, err := json.Marshal(e)
+			if err != nil {
+				rlog.Error().Err(err).Msg("Failed encoding event")
+				continue
+			}
+
+			if _, err := fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return
+			}
+
+			flusher.Flush()
+		}
+	}
+}
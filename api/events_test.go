@@ -0,0 +1,106 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+type mockEventSource struct {
+	events []processor.ScanEvent
+}
+
+// Subscribe returns a channel with the events, which is closed after the last event.
+func (s mockEventSource) Subscribe() (<-chan processor.ScanEvent, func()) {
+	events := make(chan processor.ScanEvent, len(s.events))
+	for _, e := range s.events {
+		events <- e
+	}
+
+	close(events)
+	return events, func() {}
+}
+
+func TestEvents(t *testing.T) {
+	type Test struct {
+		Name     string
+		Method   string
+		Query    string
+		WantCode int
+		Want     string
+	}
+
+	testTime := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	source := mockEventSource{events: []processor.ScanEvent{
+		{Type: processor.ScanQueued, ID: "a", Folder: "/tv/Westworld", Time: testTime},
+		{Type: processor.ScanDispatched, ID: "a", Folder: "/tv/Westworld", Target: "plex:http://plex", Time: testTime},
+		{Type: processor.ScanFailed, ID: "a", Folder: "/tv/Westworld", Target: "plex:http://plex", Error: "unavailable", Time: testTime},
+	}}
+
+	var testCases = []Test{
+		{
+			Name:     "Streams all events",
+			Method:   "GET",
+			WantCode: 200,
+			Want: ": connected\n\n" +
+				"event: queued\n" +
+				`data: {"type":"queued","id":"a","folder":"/tv/Westworld","time":"2020-06-01T12:00:00Z"}` + "\n\n" +
+				"event: dispatched\n" +
+				`data: {"type":"dispatched","id":"a","folder":"/tv/Westworld","target":"plex:http://plex","time":"2020-06-01T12:00:00Z"}` + "\n\n" +
+				"event: failed\n" +
+				`data: {"type":"failed","id":"a","folder":"/tv/Westworld","target":"plex:http://plex","error":"unavailable","time":"2020-06-01T12:00:00Z"}` + "\n\n",
+		},
+		{
+			Name:     "Streams the events of the given types",
+			Method:   "GET",
+			Query:    "?type=queued&type=failed",
+			WantCode: 200,
+			Want: ": connected\n\n" +
+				"event: queued\n" +
+				`data: {"type":"queued","id":"a","folder":"/tv/Westworld","time":"2020-06-01T12:00:00Z"}` + "\n\n" +
+				"event: failed\n" +
+				`data: {"type":"failed","id":"a","folder":"/tv/Westworld","target":"plex:http://plex","error":"unavailable","time":"2020-06-01T12:00:00Z"}` + "\n\n",
+		},
+		{
+			Name:     "Only allows GET",
+			Method:   "POST",
+			WantCode: 405,
+			Want:     "",
+		},
+	}
+
+	server := httptest.NewServer(NewEvents(source, time.Minute))
+	defer server.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, server.URL+EventsPath+tc.Query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(body) != tc.Want {
+				t.Errorf("Streams do not match:\n%s\nvs\n%s", body, tc.Want)
+			}
+		})
+	}
+}
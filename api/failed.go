@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// FailedPath is the path at which the scans in the dead-letter queue can be listed.
+const FailedPath = "/api/failed"
+
+// RequeuePath is the path at which failed scans can be moved back to the queue,
+// given one or multiple id query parameters, or all=true.
+const RequeuePath = "/api/failed/requeue"
+
+type failedHandler struct {
+	processor Processor
+}
+
+func (h failedHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "GET" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	failed, err := h.processor.Failed()
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving failed scans")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(failed); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+type requeueHandler struct {
+	processor Processor
+}
+
+func (h requeueHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	ids := make([]int64, 0)
+	for _, param := range query["id"] {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			rlog.Error().Str("id", param).Msg("Invalid id")
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		ids = append(ids, id)
+	}
+
+	if query.Get("all") == "true" {
+		failed, err := h.processor.Failed()
+		if err != nil {
+			rlog.Error().Err(err).Msg("Failed retrieving failed scans")
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		for _, f := range failed {
+			ids = append(ids, f.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		rlog.Error().Msg("Requeue request should receive at least one id or all=true")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	requeued, err := h.processor.Requeue(ids...)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed requeueing scans")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rlog.Info().Int("requeued", requeued).Msg("Failed scans moved to processor")
+
+	rw.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(rw).Encode(struct {
+		Requeued int `json:"requeued"`
+	}{requeued})
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
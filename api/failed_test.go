@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+func TestFailed(t *testing.T) {
+	failed := []processor.FailedScan{
+		{
+			ID:       1,
+			Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+			Target:   "plex:http://localhost:32400",
+			Attempts: 6,
+			Error:    "target unavailable",
+			Time:     time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	server := httptest.NewServer(New(mockProcessor{failed: failed}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + FailedPath)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, 200)
+	}
+
+	got := make([]processor.FailedScan, 0)
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, failed) {
+		t.Logf("want: %v", failed)
+		t.Logf("got:  %v", got)
+		t.Errorf("Failed scans do not match")
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	type Test struct {
+		Name         string
+		Method       string
+		URL          string
+		WantCode     int
+		WantRequeued int
+	}
+
+	p := mockProcessor{failed: []processor.FailedScan{{ID: 1}, {ID: 2}, {ID: 3}}}
+
+	var testCases = []Test{
+		{
+			Name:         "Requeues the given IDs",
+			Method:       "POST",
+			URL:          RequeuePath + "?id=1&id=3",
+			WantCode:     200,
+			WantRequeued: 2,
+		},
+		{
+			Name:         "Requeues all failed scans",
+			Method:       "POST",
+			URL:          RequeuePath + "?all=true",
+			WantCode:     200,
+			WantRequeued: 3,
+		},
+		{
+			Name:     "Returns bad request without IDs",
+			Method:   "POST",
+			URL:      RequeuePath,
+			WantCode: 400,
+		},
+		{
+			Name:     "Returns bad request for invalid IDs",
+			Method:   "POST",
+			URL:      RequeuePath + "?id=a",
+			WantCode: 400,
+		},
+		{
+			Name:     "Only allows POST",
+			Method:   "GET",
+			URL:      RequeuePath + "?id=1",
+			WantCode: 405,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := httptest.NewServer(New(p))
+			defer server.Close()
+
+			req, err := http.NewRequest(tc.Method, server.URL+tc.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.WantCode != 200 {
+				return
+			}
+
+			body := struct {
+				Requeued int
+			}{}
+
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			if body.Requeued != tc.WantRequeued {
+				t.Errorf("Requeued scans do not match: %d vs %d", body.Requeued, tc.WantRequeued)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cloudbox/autoscan/processor"
+	"github.com/rs/zerolog/hlog"
+)
+
+// HealthPath is the path of the liveness endpoint, which responds as long as autoscan serves requests.
+const HealthPath = "/healthz"
+
+// ReadyPath is the path of the readiness endpoint,
+// which responds with 503 when the datastore cannot be reached or a target is unavailable.
+const ReadyPath = "/readyz"
+
+// HealthChecker is implemented by the autoscan processor.
+type HealthChecker interface {
+	Ping() error
+	Availability(ids ...string) []processor.TargetAvailability
+}
+
+// NewHealth creates the HTTP handler of the liveness and readiness endpoints,
+// which should be added to the autoscan router at HealthPath and ReadyPath without authentication.
+// The readiness reflects the most recent availability of the targets, which are not checked by the request.
+func NewHealth(h HealthChecker, targets []string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthPath, func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		writeHealth(rw, r, http.StatusOK, struct {
+			Status string `json:"status"`
+		}{"ok"})
+	})
+
+	mux.Handle(ReadyPath, readyHandler{checker: h, targets: targets})
+	return mux
+}
+
+type readiness struct {
+	Ready     bool                           `json:"ready"`
+	Datastore string                         `json:"datastore"`
+	Targets   []processor.TargetAvailability `json:"targets"`
+}
+
+type readyHandler struct {
+	checker HealthChecker
+	targets []string
+}
+
+func (h readyHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := readiness{
+		Ready:     true,
+		Datastore: "ok",
+		Targets:   h.checker.Availability(h.targets...),
+	}
+
+	if err := h.checker.Ping(); err != nil {
+		resp.Ready = false
+		resp.Datastore = err.Error()
+	}
+
+	for _, target := range resp.Targets {
+		if !target.Available {
+			resp.Ready = false
+		}
+	}
+
+	code := http.StatusOK
+	if !resp.Ready {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeHealth(rw, r, code, resp)
+}
+
+func writeHealth(rw http.ResponseWriter, r *http.Request, code int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed encoding response")
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+type mockHealth struct {
+	ping         error
+	availability map[string]bool
+}
+
+func (h mockHealth) Ping() error {
+	return h.ping
+}
+
+func (h mockHealth) Availability(ids ...string) []processor.TargetAvailability {
+	availability := make([]processor.TargetAvailability, 0)
+	for _, id := range ids {
+		availability = append(availability, processor.TargetAvailability{Target: id, Available: h.availability[id]})
+	}
+
+	return availability
+}
+
+func TestHealth(t *testing.T) {
+	type Test struct {
+		Name      string
+		Path      string
+		Health    mockHealth
+		WantCode  int
+		WantReady bool
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Is alive",
+			Path:     HealthPath,
+			Health:   mockHealth{ping: errors.New("database is locked")},
+			WantCode: 200,
+		},
+		{
+			Name: "Is ready",
+			Path: ReadyPath,
+			Health: mockHealth{availability: map[string]bool{
+				"plex:http://plex": true,
+				"emby:http://emby": true,
+			}},
+			WantCode:  200,
+			WantReady: true,
+		},
+		{
+			Name: "Is not ready without the datastore",
+			Path: ReadyPath,
+			Health: mockHealth{ping: errors.New("database is locked"), availability: map[string]bool{
+				"plex:http://plex": true,
+				"emby:http://emby": true,
+			}},
+			WantCode: 503,
+		},
+		{
+			Name: "Is not ready with an unavailable target",
+			Path: ReadyPath,
+			Health: mockHealth{availability: map[string]bool{
+				"plex:http://plex": true,
+			}},
+			WantCode: 503,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := httptest.NewServer(NewHealth(tc.Health, []string{"plex:http://plex", "emby:http://emby"}))
+			defer server.Close()
+
+			res, err := http.Get(server.URL + tc.Path)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.Path != ReadyPath {
+				return
+			}
+
+			got := readiness{}
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Ready != tc.WantReady {
+				t.Errorf("Ready does not match: %t vs %t", got.Ready, tc.WantReady)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/rs/zerolog/hlog"
+)
+
+// HistoryPath is the path at which the most recent history entries can be listed,
+// limited by the optional limit query parameter.
+const HistoryPath = "/api/history"
+
+// StatsPath is the path at which the statistics of the history can be retrieved.
+const StatsPath = "/api/stats"
+
+// RequeueHistoryPath is the path at which scans in the history can be moved back to the queue,
+// given one or multiple id query parameters, or a path prefix.
+// The optional target query parameters limit the scans to the given targets.
+const RequeueHistoryPath = "/api/scans/requeue"
+
+// defaultHistoryLimit is the number of history entries returned without a limit.
+const defaultHistoryLimit = 100
+
+type historyHandler struct {
+	processor Processor
+}
+
+func (h historyHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "GET" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if param := r.URL.Query().Get("limit"); param != "" {
+		l, err := strconv.Atoi(param)
+		if err != nil || l < 1 {
+			rlog.Error().Str("limit", param).Msg("Invalid limit")
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		limit = l
+	}
+
+	entries, err := h.processor.History(limit)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving history")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(entries); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+type statsHandler struct {
+	processor Processor
+}
+
+func (h statsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "GET" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := h.processor.Stats()
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving stats")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(stats); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+type requeueHistoryHandler struct {
+	processor Processor
+}
+
+func (h requeueHistoryHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	ids := query["id"]
+	prefix := query.Get("prefix")
+
+	if len(ids) == 0 && prefix == "" {
+		rlog.Error().Msg("Requeue request should receive at least one id or a prefix")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	scans, err := h.processor.RequeueHistory(ids, prefix, query["target"])
+	switch {
+	case errors.Is(err, autoscan.ErrQueueFull):
+		rlog.Warn().Err(err).Msg("Processor queue is full, rejecting scans")
+		rw.Header().Set("Retry-After", strconv.Itoa(int(autoscan.QueueFullRetry.Seconds())))
+		rw.WriteHeader(http.StatusTooManyRequests)
+		return
+	case err != nil:
+		rlog.Error().Err(err).Msg("Failed requeueing scans")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	type scanResponse struct {
+		ID     string `json:"id"`
+		Folder string `json:"folder"`
+	}
+
+	response := struct {
+		Requeued int            `json:"requeued"`
+		Scans    []scanResponse `json:"scans"`
+	}{
+		Requeued: len(scans),
+		Scans:    make([]scanResponse, 0, len(scans)),
+	}
+
+	for _, scan := range scans {
+		rlog.Info().Str("path", scan.Folder).Msg("Scan moved to processor")
+		response.Scans = append(response.Scans, scanResponse{ID: scan.ID(), Folder: scan.Folder})
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(response); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+func TestHistory(t *testing.T) {
+	type Test struct {
+		Name        string
+		URL         string
+		WantCode    int
+		WantEntries []processor.HistoryEntry
+	}
+
+	scannedAt := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	entries := []processor.HistoryEntry{
+		{ID: 2, Folder: "/mnt/unionfs/Media/TV/Westworld/Season 1", Trigger: "sonarr", Target: "plex:http://localhost:32400", Status: processor.StatusCompleted, ScannedAt: scannedAt, Duration: time.Second},
+		{ID: 1, Folder: "/mnt/unionfs/Media/Movies/Interstellar (2014)", Trigger: "radarr", Target: "plex:http://localhost:32400", Status: processor.StatusFailed, ScannedAt: scannedAt},
+	}
+
+	p := mockProcessor{history: entries}
+
+	var testCases = []Test{
+		{
+			Name:        "Returns the history",
+			URL:         HistoryPath,
+			WantCode:    200,
+			WantEntries: entries,
+		},
+		{
+			Name:        "Limits the number of entries",
+			URL:         HistoryPath + "?limit=1",
+			WantCode:    200,
+			WantEntries: entries[:1],
+		},
+		{
+			Name:     "Returns bad request for an invalid limit",
+			URL:      HistoryPath + "?limit=0",
+			WantCode: 400,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := httptest.NewServer(New(p))
+			defer server.Close()
+
+			res, err := http.Get(server.URL + tc.URL)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.WantEntries == nil {
+				return
+			}
+
+			got := make([]processor.HistoryEntry, 0)
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.WantEntries) {
+				t.Logf("want: %v", tc.WantEntries)
+				t.Logf("got:  %v", got)
+				t.Errorf("History does not match")
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	stats := processor.Stats{
+		ScansPerDay:    []processor.DayStats{{Date: "2020-06-01", Scans: 12}},
+		AverageLatency: 10 * time.Minute,
+		Targets: []processor.TargetStats{
+			{Target: "plex:http://localhost:32400", Completed: 12, Failed: 1, AverageDuration: time.Second},
+		},
+	}
+
+	server := httptest.NewServer(New(mockProcessor{stats: stats}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + StatsPath)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, 200)
+	}
+
+	got := processor.Stats{}
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, stats) {
+		t.Logf("want: %v", stats)
+		t.Logf("got:  %v", got)
+		t.Errorf("Stats do not match")
+	}
+}
+
+func TestRequeueHistory(t *testing.T) {
+	type Test struct {
+		Name        string
+		Method      string
+		URL         string
+		WantCode    int
+		WantFolders []string
+	}
+
+	p := mockProcessor{history: []processor.HistoryEntry{
+		{ScanID: "a", Folder: "/mnt/unionfs/Media/TV/Westworld/Season 1"},
+		{ScanID: "b", Folder: "/mnt/unionfs/Media/TV/Westworld/Season 2"},
+		{ScanID: "c", Folder: "/mnt/unionfs/Media/Movies/Interstellar (2014)"},
+	}}
+
+	var testCases = []Test{
+		{
+			Name:        "Requeues the given IDs",
+			Method:      "POST",
+			URL:         RequeueHistoryPath + "?id=a&id=c",
+			WantCode:    200,
+			WantFolders: []string{"/mnt/unionfs/Media/TV/Westworld/Season 1", "/mnt/unionfs/Media/Movies/Interstellar (2014)"},
+		},
+		{
+			Name:        "Requeues the folders within the prefix",
+			Method:      "POST",
+			URL:         RequeueHistoryPath + "?prefix=/mnt/unionfs/Media/TV/",
+			WantCode:    200,
+			WantFolders: []string{"/mnt/unionfs/Media/TV/Westworld/Season 1", "/mnt/unionfs/Media/TV/Westworld/Season 2"},
+		},
+		{
+			Name:     "Returns bad request without IDs or prefix",
+			Method:   "POST",
+			URL:      RequeueHistoryPath,
+			WantCode: 400,
+		},
+		{
+			Name:     "Only allows POST",
+			Method:   "GET",
+			URL:      RequeueHistoryPath + "?id=a",
+			WantCode: 405,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := httptest.NewServer(New(p))
+			defer server.Close()
+
+			req, err := http.NewRequest(tc.Method, server.URL+tc.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.WantCode != 200 {
+				return
+			}
+
+			body := struct {
+				Requeued int
+				Scans    []struct {
+					Folder string
+				}
+			}{}
+
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			folders := make([]string, 0)
+			for _, scan := range body.Scans {
+				folders = append(folders, scan.Folder)
+			}
+
+			if body.Requeued != len(tc.WantFolders) || !reflect.DeepEqual(folders, tc.WantFolders) {
+				t.Logf("want: %v", tc.WantFolders)
+				t.Logf("got:  %v", folders)
+				t.Errorf("Requeued scans do not match")
+			}
+		})
+	}
+}
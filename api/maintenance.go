@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// MaintenancePath is the path at which the maintenance of the datastore can be started
+// with a POST request, which responds once the maintenance completed.
+const MaintenancePath = "/api/maintenance"
+
+type maintenanceHandler struct {
+	processor Processor
+}
+
+func (h maintenanceHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := h.processor.Maintain()
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed maintaining datastore")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rlog.Info().
+		Int64("history", result.History).
+		Int64("failed", result.Failed).
+		Int64("orphans", result.Orphans).
+		Dur("duration", result.Duration).
+		Msg("Datastore maintained")
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(result); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+func TestMaintenance(t *testing.T) {
+	type Test struct {
+		Name       string
+		Method     string
+		WantCode   int
+		WantResult processor.MaintenanceResult
+	}
+
+	var testCases = []Test{
+		{
+			Name:       "Maintains the datastore",
+			Method:     "POST",
+			WantCode:   200,
+			WantResult: processor.MaintenanceResult{History: 3, Failed: 1},
+		},
+		{
+			Name:     "Only maintains with POST",
+			Method:   "GET",
+			WantCode: 405,
+		},
+	}
+
+	server := httptest.NewServer(New(mockProcessor{
+		maintenance: processor.MaintenanceResult{History: 3, Failed: 1},
+	}))
+
+	defer server.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, server.URL+MaintenancePath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.WantCode != 200 {
+				return
+			}
+
+			result := processor.MaintenanceResult{}
+			if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(result, tc.WantResult) {
+				t.Errorf("Results do not match: %v vs %v", result, tc.WantResult)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// TriggersPath is the path at which the webhook triggers can be listed.
+// The triggers are managed at TriggersPath + /add, /enable, /disable and /delete.
+const TriggersPath = "/api/triggers"
+
+// TargetsPath is the path at which the targets can be listed.
+// The targets are managed at TargetsPath + /add, /enable, /disable and /delete.
+const TargetsPath = "/api/targets"
+
+const (
+	// SectionTriggers is the section of the webhook triggers.
+	SectionTriggers = "triggers"
+	// SectionTargets is the section of the targets.
+	SectionTargets = "targets"
+)
+
+var (
+	// ErrUnknownComponent indicates that no trigger or target has the given name.
+	ErrUnknownComponent = errors.New("unknown trigger or target")
+
+	// ErrComponentConflict indicates that the trigger or target cannot be changed in its current state,
+	// e.g. when adding a trigger with the name of another trigger.
+	ErrComponentConflict = errors.New("conflicting trigger or target")
+
+	// ErrInvalidComponent indicates that the config of the trigger or target is invalid.
+	ErrInvalidComponent = errors.New("invalid trigger or target")
+)
+
+// A Component is a webhook trigger, identified by its name, or a target, identified by its ID.
+// Managed components were added with the API, and can be deleted with the API.
+type Component struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+	Managed bool   `json:"managed"`
+}
+
+// Manager changes the triggers and targets of a running autoscan.
+// The section is SectionTriggers or SectionTargets.
+//
+// Add decodes the YAML, or JSON, config of a trigger or target of the given type, e.g. sonarr or plex,
+// and returns the added component.
+type Manager interface {
+	Components(section string) ([]Component, error)
+	Add(section string, kind string, config []byte) (Component, error)
+	Enable(section string, name string, enabled bool) error
+	Remove(section string, name string) error
+}
+
+// NewManagement creates the HTTP handler which manages the triggers and targets,
+// which should be added to the autoscan router at TriggersPath and TargetsPath, and their subpaths.
+func NewManagement(m Manager) http.Handler {
+	mux := http.NewServeMux()
+	for _, section := range []struct {
+		name string
+		path string
+		key  string
+	}{
+		{SectionTriggers, TriggersPath, "name"},
+		{SectionTargets, TargetsPath, "id"},
+	} {
+		h := manageHandler{manager: m, section: section.name, key: section.key}
+		mux.HandleFunc(section.path, h.list)
+		mux.HandleFunc(section.path+"/add", h.add)
+		mux.HandleFunc(section.path+"/enable", h.enable(true))
+		mux.HandleFunc(section.path+"/disable", h.enable(false))
+		mux.HandleFunc(section.path+"/delete", h.remove)
+	}
+
+	return mux
+}
+
+type manageHandler struct {
+	manager Manager
+	section string
+	// key is the query parameter which identifies a component
+	key string
+}
+
+func (h manageHandler) list(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "GET" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	components, err := h.manager.Components(h.section)
+	if err != nil {
+		rlog.Error().Err(err).Msgf("Failed retrieving %s", h.section)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(components); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+func (h manageHandler) add(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	kind := r.URL.Query().Get("type")
+	if kind == "" {
+		rlog.Error().Msg("Add request should receive a type")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed reading request body")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	component, err := h.manager.Add(h.section, kind, body)
+	if err != nil {
+		h.fail(rw, r, err, "Failed adding "+strings.TrimSuffix(h.section, "s"))
+		return
+	}
+
+	rlog.Info().
+		Str("name", component.Name).
+		Str("type", component.Type).
+		Msgf("Added %s", strings.TrimSuffix(h.section, "s"))
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(component); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+func (h manageHandler) enable(enabled bool) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		rlog := hlog.FromRequest(r)
+
+		if r.Method != "POST" {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := r.URL.Query().Get(h.key)
+		if name == "" {
+			rlog.Error().Msgf("Request should receive the %s parameter", h.key)
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := h.manager.Enable(h.section, name, enabled); err != nil {
+			h.fail(rw, r, err, "Failed changing "+strings.TrimSuffix(h.section, "s"))
+			return
+		}
+
+		rlog.Info().
+			Str("name", name).
+			Bool("enabled", enabled).
+			Msgf("Changed %s", strings.TrimSuffix(h.section, "s"))
+
+		rw.WriteHeader(http.StatusOK)
+	}
+}
+
+func (h manageHandler) remove(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get(h.key)
+	if name == "" {
+		rlog.Error().Msgf("Request should receive the %s parameter", h.key)
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.manager.Remove(h.section, name); err != nil {
+		h.fail(rw, r, err, "Failed deleting "+strings.TrimSuffix(h.section, "s"))
+		return
+	}
+
+	rlog.Info().
+		Str("name", name).
+		Msgf("Deleted %s", strings.TrimSuffix(h.section, "s"))
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// fail responds with the status code of the error,
+// and with the error in the body when the request can be corrected.
+func (h manageHandler) fail(rw http.ResponseWriter, r *http.Request, err error, msg string) {
+	hlog.FromRequest(r).Error().Err(err).Msg(msg)
+
+	var code int
+	switch {
+	case errors.Is(err, ErrUnknownComponent):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrComponentConflict):
+		code = http.StatusConflict
+	case errors.Is(err, ErrInvalidComponent):
+		code = http.StatusBadRequest
+	default:
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	err = json.NewEncoder(rw).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed encoding response")
+	}
+}
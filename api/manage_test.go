@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type mockManager struct {
+	components map[string][]Component
+}
+
+func (m *mockManager) Components(section string) ([]Component, error) {
+	return m.components[section], nil
+}
+
+func (m *mockManager) Add(section string, kind string, config []byte) (Component, error) {
+	name := strings.TrimSpace(string(config))
+	if name == "" {
+		return Component{}, fmt.Errorf("%w: name is required", ErrInvalidComponent)
+	}
+
+	for _, c := range m.components[section] {
+		if c.Name == name {
+			return Component{}, fmt.Errorf("%w: %s already exists", ErrComponentConflict, name)
+		}
+	}
+
+	c := Component{Name: name, Type: kind, Enabled: true, Managed: true}
+	m.components[section] = append(m.components[section], c)
+	return c, nil
+}
+
+func (m *mockManager) Enable(section string, name string, enabled bool) error {
+	for i, c := range m.components[section] {
+		if c.Name == name {
+			m.components[section][i].Enabled = enabled
+			return nil
+		}
+	}
+
+	return ErrUnknownComponent
+}
+
+func (m *mockManager) Remove(section string, name string) error {
+	for i, c := range m.components[section] {
+		if c.Name != name {
+			continue
+		}
+
+		if !c.Managed {
+			return fmt.Errorf("%w: %s is defined in the config file", ErrComponentConflict, name)
+		}
+
+		m.components[section] = append(m.components[section][:i], m.components[section][i+1:]...)
+		return nil
+	}
+
+	return ErrUnknownComponent
+}
+
+func TestManagement(t *testing.T) {
+	type Test struct {
+		Name     string
+		Method   string
+		Path     string
+		Body     string
+		WantCode int
+		Want     []Component
+	}
+
+	sonarr := Component{Name: "sonarr", Type: "sonarr", Enabled: true}
+	plex := Component{Name: "plex:http://localhost:32400", Type: "plex", Enabled: true}
+	sonarr4k := Component{Name: "sonarr-4k", Type: "sonarr", Enabled: true, Managed: true}
+
+	var testCases = []Test{
+		{
+			Name:     "Lists the triggers",
+			Method:   "GET",
+			Path:     TriggersPath,
+			WantCode: 200,
+			Want:     []Component{sonarr},
+		},
+		{
+			Name:     "Lists the targets",
+			Method:   "GET",
+			Path:     TargetsPath,
+			WantCode: 200,
+			Want:     []Component{plex},
+		},
+		{
+			Name:     "Adds a trigger",
+			Method:   "POST",
+			Path:     TriggersPath + "/add?type=sonarr",
+			Body:     "sonarr-4k",
+			WantCode: 200,
+			Want:     []Component{sonarr, sonarr4k},
+		},
+		{
+			Name:     "Rejects a duplicate trigger",
+			Method:   "POST",
+			Path:     TriggersPath + "/add?type=sonarr",
+			Body:     "sonarr-4k",
+			WantCode: 409,
+		},
+		{
+			Name:     "Rejects an invalid trigger",
+			Method:   "POST",
+			Path:     TriggersPath + "/add?type=sonarr",
+			WantCode: 400,
+		},
+		{
+			Name:     "Requires the type",
+			Method:   "POST",
+			Path:     TriggersPath + "/add",
+			Body:     "radarr",
+			WantCode: 400,
+		},
+		{
+			Name:     "Disables a trigger",
+			Method:   "POST",
+			Path:     TriggersPath + "/disable?name=sonarr",
+			WantCode: 200,
+			Want:     []Component{{Name: "sonarr", Type: "sonarr"}, sonarr4k},
+		},
+		{
+			Name:     "Enables a trigger",
+			Method:   "POST",
+			Path:     TriggersPath + "/enable?name=sonarr",
+			WantCode: 200,
+			Want:     []Component{sonarr, sonarr4k},
+		},
+		{
+			Name:     "Disables a target by its ID",
+			Method:   "POST",
+			Path:     TargetsPath + "/disable?id=plex:http://localhost:32400",
+			WantCode: 200,
+		},
+		{
+			Name:     "Rejects an unknown target",
+			Method:   "POST",
+			Path:     TargetsPath + "/enable?id=emby:http://localhost:8096",
+			WantCode: 404,
+		},
+		{
+			Name:     "Rejects deleting a trigger of the config file",
+			Method:   "POST",
+			Path:     TriggersPath + "/delete?name=sonarr",
+			WantCode: 409,
+		},
+		{
+			Name:     "Deletes a managed trigger",
+			Method:   "POST",
+			Path:     TriggersPath + "/delete?name=sonarr-4k",
+			WantCode: 200,
+			Want:     []Component{sonarr},
+		},
+		{
+			Name:     "Only deletes with POST",
+			Method:   "GET",
+			Path:     TriggersPath + "/delete?name=sonarr",
+			WantCode: 405,
+		},
+	}
+
+	m := &mockManager{components: map[string][]Component{
+		SectionTriggers: {sonarr},
+		SectionTargets:  {plex},
+	}}
+
+	server := httptest.NewServer(NewManagement(m))
+	defer server.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, server.URL+tc.Path, strings.NewReader(tc.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.Want == nil {
+				return
+			}
+
+			list := TriggersPath
+			if strings.HasPrefix(tc.Path, TargetsPath) {
+				list = TargetsPath
+			}
+
+			res, err = http.Get(server.URL + list)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			got := make([]Component, 0)
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.Want) {
+				t.Log(got)
+				t.Log(tc.Want)
+				t.Errorf("Components do not match")
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// PausePath is the path at which the processor can be paused with a POST request,
+// and at which its pause status can be retrieved with a GET request.
+const PausePath = "/api/pause"
+
+// ResumePath is the path at which a paused processor can be resumed.
+const ResumePath = "/api/resume"
+
+type pauseHandler struct {
+	processor Processor
+}
+
+func (h pauseHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	switch r.Method {
+	case "GET":
+	case "POST":
+		if err := h.processor.Pause(); err != nil {
+			rlog.Error().Err(err).Msg("Failed pausing processor")
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rlog.Info().Msg("Processor paused, triggers will continue...")
+	default:
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writeStatus(rw, r)
+}
+
+func (h pauseHandler) writeStatus(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	status, err := h.processor.PauseStatus()
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving pause status")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(status); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+type resumeHandler struct {
+	pauseHandler
+}
+
+func (h resumeHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.processor.Resume(); err != nil {
+		rlog.Error().Err(err).Msg("Failed resuming processor")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rlog.Info().Msg("Processor resumed")
+	h.writeStatus(rw, r)
+}
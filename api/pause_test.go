@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+func TestPause(t *testing.T) {
+	type Test struct {
+		Name       string
+		Method     string
+		Path       string
+		WantCode   int
+		WantPaused bool
+	}
+
+	var testCases = []Test{
+		{
+			Name:       "Returns the pause status",
+			Method:     "GET",
+			Path:       PausePath,
+			WantCode:   200,
+			WantPaused: false,
+		},
+		{
+			Name:       "Pauses the processor",
+			Method:     "POST",
+			Path:       PausePath,
+			WantCode:   200,
+			WantPaused: true,
+		},
+		{
+			Name:       "Keeps the processor paused",
+			Method:     "GET",
+			Path:       PausePath,
+			WantCode:   200,
+			WantPaused: true,
+		},
+		{
+			Name:       "Resumes the processor",
+			Method:     "POST",
+			Path:       ResumePath,
+			WantCode:   200,
+			WantPaused: false,
+		},
+		{
+			Name:     "Only resumes with POST",
+			Method:   "GET",
+			Path:     ResumePath,
+			WantCode: 405,
+		},
+	}
+
+	server := httptest.NewServer(New(mockProcessor{pause: &processor.PauseStatus{}}))
+	defer server.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, server.URL+tc.Path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.WantCode != 200 {
+				return
+			}
+
+			got := processor.PauseStatus{}
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Paused != tc.WantPaused {
+				t.Errorf("Paused does not match: %t vs %t", got.Paused, tc.WantPaused)
+			}
+
+			if got.Paused && got.Since == nil {
+				t.Errorf("Expected the time of the pause")
+			}
+		})
+	}
+}
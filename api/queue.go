@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// QueuePath is the path at which the scans in the queue can be listed.
+const QueuePath = "/api/queue"
+
+// FlushPath is the path at which all scans can be removed from the queue.
+const FlushPath = "/api/queue/flush"
+
+// RetryQueuePath is the path at which queued scans can be retried without waiting for their backoff,
+// given one or multiple id query parameters, or all=true.
+const RetryQueuePath = "/api/queue/retry"
+
+// DeleteQueuePath is the path at which scans can be removed from the queue,
+// given one or multiple id query parameters.
+const DeleteQueuePath = "/api/queue/delete"
+
+type queueHandler struct {
+	processor Processor
+}
+
+func (h queueHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "GET" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	queue, err := h.processor.Queue()
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving queued scans")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(queue); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+type flushHandler struct {
+	processor Processor
+}
+
+func (h flushHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	flushed, err := h.processor.Flush()
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed flushing queue")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rlog.Info().Int("flushed", flushed).Msg("Queue flushed")
+
+	rw.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(rw).Encode(struct {
+		Flushed int `json:"flushed"`
+	}{flushed})
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+type retryQueueHandler struct {
+	processor Processor
+}
+
+func (h retryQueueHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	ids := query["id"]
+	if query.Get("all") == "true" {
+		queue, err := h.processor.Queue()
+		if err != nil {
+			rlog.Error().Err(err).Msg("Failed retrieving queued scans")
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		for _, e := range queue {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		rlog.Error().Msg("Retry request should receive at least one id or all=true")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	retried, err := h.processor.RetryNow(ids...)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrying scans")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rlog.Info().Int("retried", retried).Msg("Queued scans scheduled for retry")
+
+	rw.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(rw).Encode(struct {
+		Retried int `json:"retried"`
+	}{retried})
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+type deleteQueueHandler struct {
+	processor Processor
+}
+
+func (h deleteQueueHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ids := r.URL.Query()["id"]
+	if len(ids) == 0 {
+		rlog.Error().Msg("Delete request should receive at least one id")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.processor.Remove(ids...)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed deleting scans")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rlog.Info().Int("deleted", deleted).Msg("Scans removed from queue")
+
+	rw.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(rw).Encode(struct {
+		Deleted int `json:"deleted"`
+	}{deleted})
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+func TestQueue(t *testing.T) {
+	queue := []processor.QueueEntry{
+		{
+			ID: "1f0c2b6f3f0f4e0b",
+			QueuedScan: processor.QueuedScan{
+				Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+				Priority: 2,
+				Trigger:  "radarr",
+				Time:     time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	server := httptest.NewServer(New(mockProcessor{queue: queue}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + QueuePath)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, 200)
+	}
+
+	got := make([]processor.QueueEntry, 0)
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, queue) {
+		t.Logf("want: %v", queue)
+		t.Logf("got:  %v", got)
+		t.Errorf("Queued scans do not match")
+	}
+}
+
+func TestQueueActions(t *testing.T) {
+	type Test struct {
+		Name      string
+		Method    string
+		URL       string
+		WantCode  int
+		WantCount map[string]int
+	}
+
+	p := mockProcessor{queue: []processor.QueueEntry{{ID: "a"}, {ID: "b"}, {ID: "c"}}}
+
+	var testCases = []Test{
+		{
+			Name:      "Flushes the queue",
+			Method:    "POST",
+			URL:       FlushPath,
+			WantCode:  200,
+			WantCount: map[string]int{"flushed": 3},
+		},
+		{
+			Name:      "Retries the given IDs",
+			Method:    "POST",
+			URL:       RetryQueuePath + "?id=a&id=c&id=d",
+			WantCode:  200,
+			WantCount: map[string]int{"retried": 2},
+		},
+		{
+			Name:      "Retries all queued scans",
+			Method:    "POST",
+			URL:       RetryQueuePath + "?all=true",
+			WantCode:  200,
+			WantCount: map[string]int{"retried": 3},
+		},
+		{
+			Name:     "Returns bad request for retry without IDs",
+			Method:   "POST",
+			URL:      RetryQueuePath,
+			WantCode: 400,
+		},
+		{
+			Name:      "Deletes the given IDs",
+			Method:    "POST",
+			URL:       DeleteQueuePath + "?id=b",
+			WantCode:  200,
+			WantCount: map[string]int{"deleted": 1},
+		},
+		{
+			Name:     "Returns bad request for delete without IDs",
+			Method:   "POST",
+			URL:      DeleteQueuePath + "?all=true",
+			WantCode: 400,
+		},
+		{
+			Name:     "Only allows POST",
+			Method:   "GET",
+			URL:      FlushPath,
+			WantCode: 405,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			server := httptest.NewServer(New(p))
+			defer server.Close()
+
+			req, err := http.NewRequest(tc.Method, server.URL+tc.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.WantCode != 200 {
+				return
+			}
+
+			got := make(map[string]int)
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.WantCount) {
+				t.Errorf("Counts do not match: %v vs %v", got, tc.WantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/processor"
+	"github.com/rs/zerolog/hlog"
+)
+
+// ScansPath is the path at which the scans in the queue can be listed with GET,
+// and removed with DELETE, filtered by the optional state, prefix and trigger query parameters.
+// Scans are added with POST, given a JSON array of scans.
+// The listing is paginated by the optional limit and offset query parameters.
+// A single scan is removed with DELETE at ScansPath/{id}.
+const ScansPath = "/api/scans"
+
+// defaultScansLimit is the number of scans returned without a limit.
+const defaultScansLimit = 100
+
+// maxSubmittedScans is the number of scans which can be added with a single request.
+const maxSubmittedScans = 10000
+
+// submitTrigger is the trigger of the scans added with the API.
+const submitTrigger = "api"
+
+type scansHandler struct {
+	processor Processor
+}
+
+func (h scansHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if id := strings.TrimPrefix(r.URL.Path, ScansPath+"/"); id != r.URL.Path {
+		h.deleteScan(rw, r, id)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.list(rw, r)
+	case "POST":
+		h.submit(rw, r)
+	case "DELETE":
+		h.delete(rw, r)
+	default:
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+type scansPage struct {
+	Total  int                   `json:"total"`
+	Offset int                   `json:"offset"`
+	Limit  int                   `json:"limit"`
+	Scans  []processor.ScanEntry `json:"scans"`
+}
+
+func (h scansHandler) list(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	filter, ok := scanFilter(rw, r)
+	if !ok {
+		return
+	}
+
+	page := scansPage{Limit: defaultScansLimit}
+	query := r.URL.Query()
+	if param := query.Get("limit"); param != "" {
+		l, err := strconv.Atoi(param)
+		if err != nil || l < 1 {
+			rlog.Error().Str("limit", param).Msg("Invalid limit")
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		page.Limit = l
+	}
+
+	if param := query.Get("offset"); param != "" {
+		o, err := strconv.Atoi(param)
+		if err != nil || o < 0 {
+			rlog.Error().Str("offset", param).Msg("Invalid offset")
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		page.Offset = o
+	}
+
+	scans, err := h.processor.Scans(filter)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving queued scans")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	page.Total = len(scans)
+	page.Scans = make([]processor.ScanEntry, 0)
+	if page.Offset < len(scans) {
+		scans = scans[page.Offset:]
+		if page.Limit < len(scans) {
+			scans = scans[:page.Limit]
+		}
+
+		page.Scans = scans
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(page); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+// submittedScan is a scan of the JSON body of a submission.
+// The event defaults to added.
+type submittedScan struct {
+	Folder   string `json:"folder"`
+	Priority int    `json:"priority"`
+	Event    string `json:"event"`
+}
+
+func (s submittedScan) scan() (autoscan.Scan, error) {
+	if !strings.HasPrefix(s.Folder, "/") {
+		return autoscan.Scan{}, errors.New("folder must be an absolute path")
+	}
+
+	if s.Priority < 0 {
+		return autoscan.Scan{}, fmt.Errorf("invalid priority: %d", s.Priority)
+	}
+
+	event, err := autoscan.ParseEvent(s.Event)
+	if err != nil {
+		return autoscan.Scan{}, err
+	}
+
+	return autoscan.Scan{
+		Folder:   path.Clean(s.Folder),
+		Priority: s.Priority,
+		Event:    event,
+		Trigger:  submitTrigger,
+		Time:     time.Now(),
+	}, nil
+}
+
+// submitResult is the result of a submitted scan, in the order of the submission.
+// The ID is omitted and the error is set when the scan is invalid.
+type submitResult struct {
+	Folder string `json:"folder"`
+	ID     string `json:"id,omitempty"`
+	Queued bool   `json:"queued"`
+	Error  string `json:"error,omitempty"`
+}
+
+// submit adds the scans of the request to the queue in a single transaction.
+// None of the scans are added when one of them is invalid.
+func (h scansHandler) submit(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	submitted := make([]submittedScan, 0)
+	if err := json.NewDecoder(r.Body).Decode(&submitted); err != nil {
+		rlog.Error().Err(err).Msg("Failed decoding scans")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(submitted) == 0:
+		rlog.Error().Msg("Submit request should receive at least one scan")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	case len(submitted) > maxSubmittedScans:
+		rlog.Error().Int("scans", len(submitted)).Msg("Request exceeds the maximum number of scans")
+		rw.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	scans := make([]autoscan.Scan, 0, len(submitted))
+	results := make([]submitResult, 0, len(submitted))
+	invalid := false
+	for _, s := range submitted {
+		scan, err := s.scan()
+		if err != nil {
+			invalid = true
+			results = append(results, submitResult{Folder: s.Folder, Error: err.Error()})
+			continue
+		}
+
+		scans = append(scans, scan)
+		results = append(results, submitResult{Folder: scan.Folder, ID: scan.ID()})
+	}
+
+	if invalid {
+		rlog.Error().Msg("Submit request contains invalid scans, rejecting all scans")
+		writeSubmitResults(rw, r, http.StatusBadRequest, results)
+		return
+	}
+
+	err := h.processor.Add(scans...)
+	switch {
+	case errors.Is(err, autoscan.ErrQueueFull):
+		rlog.Warn().Err(err).Msg("Processor queue is full, rejecting scans")
+		rw.Header().Set("Retry-After", strconv.Itoa(int(autoscan.QueueFullRetry.Seconds())))
+		rw.WriteHeader(http.StatusTooManyRequests)
+		return
+	case err != nil:
+		rlog.Error().Err(err).Msg("Processor could not process scans")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	for i := range results {
+		results[i].Queued = true
+	}
+
+	rlog.Info().Int("scans", len(scans)).Msg("Scans moved to processor")
+	writeSubmitResults(rw, r, http.StatusOK, results)
+}
+
+func writeSubmitResults(rw http.ResponseWriter, r *http.Request, code int, results []submitResult) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	if err := json.NewEncoder(rw).Encode(results); err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed encoding response")
+	}
+}
+
+func (h scansHandler) delete(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	filter, ok := scanFilter(rw, r)
+	if !ok {
+		return
+	}
+
+	// the whole queue is removed with the flush endpoint instead
+	if filter == (processor.ScanFilter{}) {
+		rlog.Error().Msg("Delete request should receive a state, prefix or trigger")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.processor.RemoveScans(filter)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed deleting scans")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rlog.Info().Int("deleted", deleted).Msg("Scans removed from queue")
+	writeDeleted(rw, r, deleted)
+}
+
+func (h scansHandler) deleteScan(rw http.ResponseWriter, r *http.Request, id string) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "DELETE" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	deleted, err := h.processor.Remove(id)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed deleting scan")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if deleted == 0 {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	rlog.Info().Str("id", id).Msg("Scan removed from queue")
+	writeDeleted(rw, r, deleted)
+}
+
+// scanFilter returns the filter of the query parameters,
+// and responds with 400 when the state is neither queued nor retrying.
+func scanFilter(rw http.ResponseWriter, r *http.Request) (processor.ScanFilter, bool) {
+	query := r.URL.Query()
+	filter := processor.ScanFilter{
+		State:   query.Get("state"),
+		Prefix:  query.Get("prefix"),
+		Trigger: query.Get("trigger"),
+	}
+
+	switch filter.State {
+	case "", processor.StatusQueued, processor.StatusRetrying:
+		return filter, true
+	}
+
+	hlog.FromRequest(r).Error().Str("state", filter.State).Msg("Invalid state")
+	rw.WriteHeader(http.StatusBadRequest)
+	return filter, false
+}
+
+func writeDeleted(rw http.ResponseWriter, r *http.Request, deleted int) {
+	rw.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(rw).Encode(struct {
+		Deleted int `json:"deleted"`
+	}{deleted})
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("Failed encoding response")
+	}
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/processor"
+)
+
+func TestScans(t *testing.T) {
+	type Test struct {
+		Name     string
+		Method   string
+		URL      string
+		WantCode int
+		Want     interface{}
+	}
+
+	scan := func(id string, folder string, trigger string, state string) processor.ScanEntry {
+		return processor.ScanEntry{
+			QueueEntry: processor.QueueEntry{ID: id, QueuedScan: processor.QueuedScan{Folder: folder, Trigger: trigger}},
+			State:      state,
+		}
+	}
+
+	westworld := scan("a", "/tv/Westworld", "sonarr", processor.StatusRetrying)
+	dune := scan("b", "/movies/Dune", "radarr", processor.StatusQueued)
+	severance := scan("c", "/tv/Severance", "sonarr", processor.StatusQueued)
+
+	p := mockProcessor{
+		queue: []processor.QueueEntry{westworld.QueueEntry, dune.QueueEntry, severance.QueueEntry},
+		scans: []processor.ScanEntry{westworld, dune, severance},
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Lists all scans",
+			Method:   "GET",
+			URL:      ScansPath,
+			WantCode: 200,
+			Want:     &scansPage{Total: 3, Limit: 100, Scans: []processor.ScanEntry{westworld, dune, severance}},
+		},
+		{
+			Name:     "Filters by state",
+			Method:   "GET",
+			URL:      ScansPath + "?state=queued",
+			WantCode: 200,
+			Want:     &scansPage{Total: 2, Limit: 100, Scans: []processor.ScanEntry{dune, severance}},
+		},
+		{
+			Name:     "Filters by prefix and trigger",
+			Method:   "GET",
+			URL:      ScansPath + "?prefix=/tv/&trigger=sonarr",
+			WantCode: 200,
+			Want:     &scansPage{Total: 2, Limit: 100, Scans: []processor.ScanEntry{westworld, severance}},
+		},
+		{
+			Name:     "Paginates",
+			Method:   "GET",
+			URL:      ScansPath + "?limit=1&offset=1",
+			WantCode: 200,
+			Want:     &scansPage{Total: 3, Offset: 1, Limit: 1, Scans: []processor.ScanEntry{dune}},
+		},
+		{
+			Name:     "Returns an empty page past the last scan",
+			Method:   "GET",
+			URL:      ScansPath + "?offset=5",
+			WantCode: 200,
+			Want:     &scansPage{Total: 3, Offset: 5, Limit: 100, Scans: []processor.ScanEntry{}},
+		},
+		{
+			Name:     "Returns bad request for an invalid state",
+			Method:   "GET",
+			URL:      ScansPath + "?state=completed",
+			WantCode: 400,
+		},
+		{
+			Name:     "Returns bad request for an invalid limit",
+			Method:   "GET",
+			URL:      ScansPath + "?limit=0",
+			WantCode: 400,
+		},
+		{
+			Name:     "Deletes a scan by its ID",
+			Method:   "DELETE",
+			URL:      ScansPath + "/b",
+			WantCode: 200,
+			Want:     &map[string]int{"deleted": 1},
+		},
+		{
+			Name:     "Returns not found for an unknown ID",
+			Method:   "DELETE",
+			URL:      ScansPath + "/d",
+			WantCode: 404,
+		},
+		{
+			Name:     "Deletes the scans of the filter",
+			Method:   "DELETE",
+			URL:      ScansPath + "?prefix=/tv/",
+			WantCode: 200,
+			Want:     &map[string]int{"deleted": 2},
+		},
+		{
+			Name:     "Returns bad request for delete without a filter",
+			Method:   "DELETE",
+			URL:      ScansPath,
+			WantCode: 400,
+		},
+		{
+			Name:     "Only allows DELETE for a scan",
+			Method:   "GET",
+			URL:      ScansPath + "/a",
+			WantCode: 405,
+		},
+		{
+			Name:     "Keeps serving the status of scans",
+			Method:   "DELETE",
+			URL:      StatusPath + "?id=a",
+			WantCode: 405,
+		},
+	}
+
+	server := httptest.NewServer(New(p))
+	defer server.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, server.URL+tc.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.Want == nil {
+				return
+			}
+
+			got := reflect.New(reflect.TypeOf(tc.Want).Elem()).Interface()
+			if err := json.NewDecoder(res.Body).Decode(got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.Want) {
+				t.Logf("want: %+v", tc.Want)
+				t.Logf("got:  %+v", got)
+				t.Errorf("Responses do not match")
+			}
+		})
+	}
+}
+
+func TestSubmitScans(t *testing.T) {
+	type Test struct {
+		Name      string
+		Body      string
+		WantCode  int
+		Want      []submitResult
+		WantScans []autoscan.Scan
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Adds the scans",
+			Body:     `[{"folder": "/tv/Westworld/Season 1/", "priority": 2}, {"folder": "/movies/Dune", "event": "removed"}]`,
+			WantCode: 200,
+			Want: []submitResult{
+				{Folder: "/tv/Westworld/Season 1", ID: "649e348a24e44bd2", Queued: true},
+				{Folder: "/movies/Dune", ID: "db27b98432194779", Queued: true},
+			},
+			WantScans: []autoscan.Scan{
+				{Folder: "/tv/Westworld/Season 1", Priority: 2, Event: autoscan.EventAdded, Trigger: "api"},
+				{Folder: "/movies/Dune", Event: autoscan.EventRemoved, Trigger: "api"},
+			},
+		},
+		{
+			Name:     "Rejects all scans when one is invalid",
+			Body:     `[{"folder": "/movies/Dune"}, {"folder": "movies/Arrival"}, {"folder": "/movies/Tenet", "event": "renamed"}]`,
+			WantCode: 400,
+			Want: []submitResult{
+				{Folder: "/movies/Dune", ID: "db27b98432194779"},
+				{Folder: "movies/Arrival", Error: "folder must be an absolute path"},
+				{Folder: "/movies/Tenet", Error: "unknown event: renamed"},
+			},
+			WantScans: []autoscan.Scan{},
+		},
+		{
+			Name:      "Returns bad request without scans",
+			Body:      `[]`,
+			WantCode:  400,
+			WantScans: []autoscan.Scan{},
+		},
+		{
+			Name:      "Returns bad request for an invalid body",
+			Body:      `{"folder": "/movies/Dune"}`,
+			WantCode:  400,
+			WantScans: []autoscan.Scan{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			added := make([]autoscan.Scan, 0)
+			server := httptest.NewServer(New(mockProcessor{added: &added}))
+			defer server.Close()
+
+			res, err := http.Post(server.URL+ScansPath, "application/json", strings.NewReader(tc.Body))
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if tc.Want != nil {
+				got := make([]submitResult, 0)
+				if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(got, tc.Want) {
+					t.Logf("want: %+v", tc.Want)
+					t.Logf("got:  %+v", got)
+					t.Errorf("Results do not match")
+				}
+			}
+
+			for i := range added {
+				added[i].Time = time.Time{}
+			}
+
+			if !reflect.DeepEqual(added, tc.WantScans) {
+				t.Errorf("Scans do not match: %+v vs %+v", added, tc.WantScans)
+			}
+		})
+	}
+}
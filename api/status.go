@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+	"github.com/rs/zerolog/hlog"
+)
+
+// ServerStatusPath is the path at which the status of autoscan can be retrieved:
+// its version and uptime, the queue, the availability of the targets,
+// the most recently processed scan and the number of scans added per trigger.
+const ServerStatusPath = "/api/status"
+
+// StatusProvider is implemented by the autoscan processor.
+type StatusProvider interface {
+	DatabaseStats() (processor.DatabaseStats, error)
+	PauseStatus() (processor.PauseStatus, error)
+	Availability(ids ...string) []processor.TargetAvailability
+	History(limit int) ([]processor.HistoryEntry, error)
+	TriggerCounts() map[string]int
+}
+
+// ServerInfo describes the running autoscan.
+type ServerInfo struct {
+	Version string
+	Started time.Time
+	Targets []string
+}
+
+type serverStatus struct {
+	Version   string                         `json:"version"`
+	StartedAt time.Time                      `json:"started_at"`
+	Uptime    string                         `json:"uptime"`
+	Paused    bool                           `json:"paused"`
+	Queue     queueStatus                    `json:"queue"`
+	Targets   []processor.TargetAvailability `json:"targets"`
+	LastScan  *processor.HistoryEntry        `json:"last_scan"`
+	Triggers  map[string]int                 `json:"triggers"`
+}
+
+type queueStatus struct {
+	Queued     int         `json:"queued"`
+	Retrying   int         `json:"retrying"`
+	Failed     int         `json:"failed"`
+	Priorities map[int]int `json:"priorities"`
+}
+
+// NewServerStatus creates the HTTP handler of the status of autoscan,
+// which should be added to the autoscan router at ServerStatusPath.
+func NewServerStatus(p StatusProvider, info ServerInfo) http.Handler {
+	return serverStatusHandler{provider: p, info: info}
+}
+
+type serverStatusHandler struct {
+	provider StatusProvider
+	info     ServerInfo
+}
+
+func (h serverStatusHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rlog := hlog.FromRequest(r)
+
+	if r.Method != "GET" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := h.provider.DatabaseStats()
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving database stats")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	pause, err := h.provider.PauseStatus()
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving pause status")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// the history is empty when it is disabled
+	history, err := h.provider.History(1)
+	if err != nil {
+		rlog.Error().Err(err).Msg("Failed retrieving history")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status := serverStatus{
+		Version:   h.info.Version,
+		StartedAt: h.info.Started,
+		Uptime:    time.Since(h.info.Started).Truncate(time.Second).String(),
+		Paused:    pause.Paused,
+		Queue: queueStatus{
+			Queued:     stats.Queued,
+			Retrying:   stats.Retrying,
+			Failed:     stats.Failed,
+			Priorities: stats.Priorities,
+		},
+		Targets:  h.provider.Availability(h.info.Targets...),
+		Triggers: h.provider.TriggerCounts(),
+	}
+
+	if len(history) > 0 {
+		status.LastScan = &history[0]
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(status); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+}
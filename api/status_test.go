@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+type mockStatus struct {
+	mockHealth
+	history []processor.HistoryEntry
+}
+
+func (s mockStatus) DatabaseStats() (processor.DatabaseStats, error) {
+	return processor.DatabaseStats{Queued: 3, Retrying: 1, Failed: 2, Priorities: map[int]int{1: 1, 2: 2}}, nil
+}
+
+func (s mockStatus) PauseStatus() (processor.PauseStatus, error) {
+	return processor.PauseStatus{Paused: true}, nil
+}
+
+func (s mockStatus) History(limit int) ([]processor.HistoryEntry, error) {
+	if limit < len(s.history) {
+		return s.history[:limit], nil
+	}
+
+	return s.history, nil
+}
+
+func (s mockStatus) TriggerCounts() map[string]int {
+	return map[string]int{"sonarr": 5}
+}
+
+func TestServerStatus(t *testing.T) {
+	type Test struct {
+		Name     string
+		History  []processor.HistoryEntry
+		WantLast *processor.HistoryEntry
+	}
+
+	testTime := time.Date(2020, 8, 1, 12, 0, 0, 0, time.UTC)
+	entries := []processor.HistoryEntry{
+		{ID: 2, Folder: "/tv/Westworld", Trigger: "sonarr", Target: "plex:http://plex", Status: processor.StatusCompleted, ScannedAt: testTime},
+		{ID: 1, Folder: "/tv/Dexter", Trigger: "sonarr", Target: "plex:http://plex", Status: processor.StatusCompleted, ScannedAt: testTime},
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Returns the most recently processed scan",
+			History:  entries,
+			WantLast: &entries[0],
+		},
+		{
+			Name: "Returns no scan without history",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			p := mockStatus{
+				mockHealth: mockHealth{availability: map[string]bool{"plex:http://plex": true}},
+				history:    tc.History,
+			}
+
+			server := httptest.NewServer(NewServerStatus(p, ServerInfo{
+				Version: "1.0.0",
+				Started: time.Now().Add(-time.Hour),
+				Targets: []string{"plex:http://plex"},
+			}))
+			defer server.Close()
+
+			res, err := http.Get(server.URL + ServerStatusPath)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != 200 {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, 200)
+			}
+
+			got := serverStatus{}
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			want := queueStatus{Queued: 3, Retrying: 1, Failed: 2, Priorities: map[int]int{1: 1, 2: 2}}
+			switch {
+			case got.Version != "1.0.0" || got.Uptime != "1h0m0s" || !got.Paused:
+				t.Errorf("Status does not match: %+v", got)
+			case !reflect.DeepEqual(got.Queue, want):
+				t.Errorf("Queue does not match: %+v vs %+v", got.Queue, want)
+			case len(got.Targets) != 1 || !got.Targets[0].Available:
+				t.Errorf("Targets do not match: %+v", got.Targets)
+			case got.Triggers["sonarr"] != 5:
+				t.Errorf("Triggers do not match: %v", got.Triggers)
+			case !reflect.DeepEqual(got.LastScan, tc.WantLast):
+				t.Errorf("Last scans do not match: %+v vs %+v", got.LastScan, tc.WantLast)
+			}
+		})
+	}
+}
@@ -1,26 +1,109 @@
 package autoscan
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"path"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 )
 
 // A Scan is at the core of Autoscan.
 // It defines which path to scan and with which (trigger-given) priority.
+// Event describes the change of the files in the folder.
+// Targets optionally limits the scan to the matching targets.
+// CheckExists defers the scan until its folder exists on the file system.
+// Trigger holds the name of the trigger which added the scan.
 //
 // The Scan is used across Triggers, Targets and the Processor.
 type Scan struct {
-	Folder   string
-	Priority int
-	Time     time.Time
+	Folder      string
+	Priority    int
+	Event       Event
+	Targets     []string
+	CheckExists bool
+	Trigger     string
+	Time        time.Time
+}
+
+// An Event describes the change of the files which caused a scan.
+type Event string
+
+const (
+	// EventAdded indicates that files were added to the folder.
+	EventAdded Event = "added"
+
+	// EventModified indicates that files in the folder were changed.
+	EventModified Event = "modified"
+
+	// EventRemoved indicates that files were removed from the folder,
+	// or that the folder itself was removed.
+	EventRemoved Event = "removed"
+)
+
+// ParseEvent returns the event of the given name.
+// An empty name is parsed as EventAdded.
+func ParseEvent(name string) (Event, error) {
+	switch e := Event(strings.ToLower(name)); e {
+	case "":
+		return EventAdded, nil
+	case EventAdded, EventModified, EventRemoved:
+		return e, nil
+	default:
+		return "", fmt.Errorf("unknown event: %s", name)
+	}
+}
+
+// Merge returns the event of a folder which received both events.
+// The folder is modified when the events differ,
+// such that it is only a removal when all its events were removals.
+func (e Event) Merge(other Event) Event {
+	if e == other {
+		return e
+	}
+
+	return EventModified
+}
+
+// ID returns the ID of the scan, which is derived from its folder.
+// Scans of the same folder share their ID, as the processor merges them.
+func (s Scan) ID() string {
+	sum := sha1.Sum([]byte(s.Folder))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ForTarget returns whether the scan should be sent to the target with the given ID.
+// A target matches when the scan has no targets,
+// or when one of the scan's targets equals the ID or the target's type, e.g. plex.
+func (s Scan) ForTarget(id string) bool {
+	if len(s.Targets) == 0 {
+		return true
+	}
+
+	kind := id
+	if i := strings.Index(id, ":"); i >= 0 {
+		kind = id[:i]
+	}
+
+	for _, t := range s.Targets {
+		if strings.EqualFold(t, id) || strings.EqualFold(t, kind) {
+			return true
+		}
+	}
+
+	return false
 }
 
 type ProcessorFunc func(...Scan) error
 
-type Trigger func(ProcessorFunc)
+// A Trigger runs in the background until the stop channel is closed,
+// after which it releases its resources and returns.
+type Trigger func(ProcessorFunc, <-chan struct{})
 
 // A HTTPTrigger is a Trigger which does not run in the background,
 // and instead returns a http.Handler.
@@ -30,9 +113,60 @@ type HTTPTrigger func(ProcessorFunc) http.Handler
 
 // A Target receives a Scan from the Processor and translates the Scan
 // into a format understood by the target.
+//
+// ID identifies the target as type:url, e.g. plex:http://localhost:32400
+//
+// Capabilities declares which scans the target handles.
+// Scans the target does not handle are skipped by the processor.
 type Target interface {
+	ID() string
 	Scan(Scan) error
 	Available() error
+	Capabilities() Capabilities
+}
+
+// Capabilities describes the scans a Target handles.
+type Capabilities struct {
+	// Removals indicates that the target handles scans of removed folders.
+	Removals bool
+}
+
+// A Describer is a Target which describes its media server,
+// to troubleshoot the config of the target.
+//
+// Resolve rewrites the folder like a Scan, and returns the libraries containing the rewritten folder.
+type Describer interface {
+	Describe() (TargetInfo, error)
+	Resolve(folder string) (string, []Library)
+}
+
+// TargetInfo describes the media server of a Target.
+type TargetInfo struct {
+	Version   string
+	Libraries []Library
+}
+
+// Library is a folder of a library of a media server.
+type Library struct {
+	Name string
+	Path string
+}
+
+// A PreScanHook prepares a Scan before the targets receive it,
+// e.g. by refreshing the directory cache of a remote mount.
+//
+// The processor runs the hooks once for every Scan,
+// before the first target receives it.
+type PreScanHook interface {
+	PreScan(Scan) error
+}
+
+// A PostScanHook is notified once all targets processed a Scan,
+// e.g. to send a notification.
+//
+// Failed indicates that the Scan failed for at least one of the targets.
+type PostScanHook interface {
+	PostScan(scan Scan, failed bool) error
 }
 
 var (
@@ -44,6 +178,16 @@ var (
 	// ErrFatal indicates a severe problem related to development.
 	ErrFatal = errors.New("fatal error")
 
+	// ErrScanFailed indicates that a Target failed to scan a folder,
+	// e.g. with a server error or a timeout. The processor retries
+	// the Scan later, and counts the attempt towards the maximum number of retries.
+	ErrScanFailed = errors.New("scan failed")
+
+	// ErrScanRejected indicates that a Target refused to scan a folder,
+	// e.g. of a library which no longer exists. Retrying the Scan does not help,
+	// so the processor moves it to the dead-letter queue of the Target.
+	ErrScanRejected = errors.New("scan rejected")
+
 	// ErrNoScans is not an error. It only indicates whether the CLI
 	// should sleep longer depending on the processor output.
 	ErrNoScans = errors.New("no scans currently available")
@@ -52,8 +196,20 @@ var (
 	// not available on the file system. Processing should halt
 	// until all anchors are available.
 	ErrAnchorUnavailable = errors.New("anchor file is unavailable")
+
+	// ErrPaused indicates that the processor is paused
+	// and does not send scans to the targets until it is resumed.
+	ErrPaused = errors.New("processor is paused")
+
+	// ErrQueueFull indicates that the processor queue has reached its maximum size.
+	// Triggers should retry adding the scans after QueueFullRetry.
+	ErrQueueFull = errors.New("processor queue is full")
 )
 
+// QueueFullRetry is the time triggers wait before retrying scans
+// which were rejected with ErrQueueFull.
+const QueueFullRetry = time.Minute
+
 type Rewrite struct {
 	From string `yaml:"from"`
 	To   string `yaml:"to"`
@@ -85,6 +241,35 @@ func NewRewriter(rewriteRules []Rewrite) (Rewriter, error) {
 	return rewriter, nil
 }
 
+// RewriteStep is the outcome of a rewrite rule for a path.
+type RewriteStep struct {
+	Rule    Rewrite
+	Matched bool
+	Result  string
+}
+
+// TraceRewrite rewrites the input like the Rewriter of the rules,
+// and returns the outcome of every rule up to the first matching rule, which is the only rule applied.
+func TraceRewrite(rewriteRules []Rewrite, input string) ([]RewriteStep, error) {
+	steps := make([]RewriteStep, 0, len(rewriteRules))
+	for _, rule := range rewriteRules {
+		re, err := regexp.Compile(rule.From)
+		if err != nil {
+			return nil, err
+		}
+
+		if !re.MatchString(input) {
+			steps = append(steps, RewriteStep{Rule: rule, Result: input})
+			continue
+		}
+
+		steps = append(steps, RewriteStep{Rule: rule, Matched: true, Result: re.ReplaceAllString(input, rule.To)})
+		break
+	}
+
+	return steps, nil
+}
+
 type Filterer func(string) bool
 
 func NewFilterer(includes []string, excludes []string) (Filterer, error) {
@@ -142,3 +327,82 @@ func NewFilterer(includes []string, excludes []string) (Filterer, error) {
 
 	return fn, nil
 }
+
+// NewGlobFilterer creates a Filterer from shell patterns, such as `*.partial`.
+//
+// Patterns without a slash are matched against every element of the path.
+// Patterns ending with a slash (e.g. `@eaDir/`) only match directories within the path.
+// All other patterns are matched against the full path.
+//
+// A path ending with a slash is considered to be a directory.
+func NewGlobFilterer(includes []string, excludes []string) (Filterer, error) {
+	for _, pattern := range append(includes, excludes...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("compiling glob: %v: %w", pattern, err)
+		}
+	}
+
+	fn := func(p string) bool {
+		// check excludes
+		for _, pattern := range excludes {
+			if globMatch(pattern, p) {
+				return false
+			}
+		}
+
+		// no includes (but excludes did not match)
+		if len(includes) == 0 {
+			return true
+		}
+
+		// check includes
+		for _, pattern := range includes {
+			if globMatch(pattern, p) {
+				return true
+			}
+		}
+
+		// no includes passed
+		return false
+	}
+
+	return fn, nil
+}
+
+func globMatch(pattern string, p string) bool {
+	p = filepath.ToSlash(p)
+
+	switch {
+	case strings.HasSuffix(pattern, "/"):
+		// directories only
+		pattern = strings.TrimSuffix(pattern, "/")
+		elements := strings.Split(strings.Trim(p, "/"), "/")
+		if !strings.HasSuffix(p, "/") {
+			// the last element is a file
+			elements = elements[:len(elements)-1]
+		}
+
+		for _, element := range elements {
+			if ok, _ := path.Match(pattern, element); ok {
+				return true
+			}
+		}
+
+		return false
+
+	case !strings.Contains(pattern, "/"):
+		// every element of the path
+		for _, element := range strings.Split(strings.Trim(p, "/"), "/") {
+			if ok, _ := path.Match(pattern, element); ok {
+				return true
+			}
+		}
+
+		return false
+
+	default:
+		// full path
+		ok, _ := path.Match(pattern, strings.TrimSuffix(p, "/"))
+		return ok
+	}
+}
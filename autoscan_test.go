@@ -1,6 +1,7 @@
 package autoscan
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -95,7 +96,258 @@ func TestRewriter(t *testing.T) {
 			if result != tc.Expected {
 				t.Errorf("%s does not equal %s", result, tc.Expected)
 			}
+
+			steps, err := TraceRewrite(tc.Rewrites, tc.Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the trace ends with the result of the rewriter
+			if len(steps) > 0 && steps[len(steps)-1].Result != tc.Expected {
+				t.Errorf("Trace %s does not equal %s", steps[len(steps)-1].Result, tc.Expected)
+			}
+		})
+	}
+
+}
+
+func TestTraceRewrite(t *testing.T) {
+	rules := []Rewrite{
+		{From: "^/movies/", To: "/mnt/unionfs/movies/"},
+		{From: "^/movies4k/", To: "/mnt/unionfs/movies4k/"},
+		{From: "^/mnt/", To: "/data/"},
+	}
+
+	steps, err := TraceRewrite(rules, "/movies4k/example.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []RewriteStep{
+		{Rule: rules[0], Result: "/movies4k/example.mp4"},
+		{Rule: rules[1], Matched: true, Result: "/mnt/unionfs/movies4k/example.mp4"},
+	}
+
+	if !reflect.DeepEqual(steps, expected) {
+		t.Logf("want: %+v", expected)
+		t.Logf("got:  %+v", steps)
+		t.Errorf("Steps do not match")
+	}
+}
+
+func TestGlobFilterer(t *testing.T) {
+	type Test struct {
+		Name     string
+		Includes []string
+		Excludes []string
+		Input    string
+		Expected bool
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Allows everything without patterns",
+			Input:    "/mnt/unionfs/Media/TV/Westworld/Season 1/episode.mkv",
+			Expected: true,
+		},
+		{
+			Name:     "Excludes file extension",
+			Excludes: []string{"*.partial"},
+			Input:    "/downloads/TV/Westworld/episode.mkv.partial",
+			Expected: false,
+		},
+		{
+			Name:     "Excludes qBittorrent temp files",
+			Excludes: []string{"*.!qB"},
+			Input:    "/downloads/TV/Westworld/episode.mkv.!qB",
+			Expected: false,
+		},
+		{
+			Name:     "Excludes directory anywhere in the path",
+			Excludes: []string{"@eaDir/"},
+			Input:    "/volume1/Media/@eaDir/episode.mkv",
+			Expected: false,
+		},
+		{
+			Name:     "Directory pattern does not match the file name",
+			Excludes: []string{"@eaDir/"},
+			Input:    "/volume1/Media/TV/@eaDir",
+			Expected: true,
+		},
+		{
+			Name:     "Directory pattern matches directory paths",
+			Excludes: []string{"@eaDir/"},
+			Input:    "/volume1/Media/TV/@eaDir/",
+			Expected: false,
+		},
+		{
+			Name:     "Includes file extension",
+			Includes: []string{"*.mkv"},
+			Input:    "/mnt/unionfs/Media/Movies/movie.mkv",
+			Expected: true,
+		},
+		{
+			Name:     "Denies paths not matching an include",
+			Includes: []string{"*.mkv"},
+			Input:    "/mnt/unionfs/Media/Movies/movie.nfo",
+			Expected: false,
+		},
+		{
+			Name:     "Matches full path patterns",
+			Includes: []string{"/mnt/*/Media/*/*"},
+			Input:    "/mnt/unionfs/Media/Movies/movie.mkv",
+			Expected: true,
+		},
+		{
+			Name:     "Excludes take precedence over includes",
+			Includes: []string{"*.mkv"},
+			Excludes: []string{"sample*"},
+			Input:    "/mnt/unionfs/Media/Movies/sample.mkv",
+			Expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			filterer, err := NewGlobFilterer(tc.Includes, tc.Excludes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result := filterer(tc.Input)
+			if result != tc.Expected {
+				t.Errorf("%t does not equal %t", result, tc.Expected)
+			}
 		})
 	}
+}
+
+func TestScanForTarget(t *testing.T) {
+	type Test struct {
+		Name     string
+		Targets  []string
+		Input    string
+		Expected bool
+	}
 
+	var testCases = []Test{
+		{
+			Name:     "Matches all targets without filter",
+			Input:    "plex:http://localhost:32400",
+			Expected: true,
+		},
+		{
+			Name:     "Matches target type",
+			Targets:  []string{"emby", "plex"},
+			Input:    "plex:http://localhost:32400",
+			Expected: true,
+		},
+		{
+			Name:     "Matches target ID",
+			Targets:  []string{"plex:http://localhost:32400"},
+			Input:    "plex:http://localhost:32400",
+			Expected: true,
+		},
+		{
+			Name:     "Does not match other targets",
+			Targets:  []string{"emby", "plex:http://remote:32400"},
+			Input:    "plex:http://localhost:32400",
+			Expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			scan := Scan{Targets: tc.Targets}
+
+			result := scan.ForTarget(tc.Input)
+			if result != tc.Expected {
+				t.Errorf("%t does not equal %t", result, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	type Test struct {
+		Name     string
+		Input    string
+		Expected Event
+		Err      bool
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Defaults to added",
+			Input:    "",
+			Expected: EventAdded,
+		},
+		{
+			Name:     "Ignores the case of the name",
+			Input:    "Removed",
+			Expected: EventRemoved,
+		},
+		{
+			Name:     "Parses modified",
+			Input:    "modified",
+			Expected: EventModified,
+		},
+		{
+			Name:  "Returns an error on unknown events",
+			Input: "deleted",
+			Err:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			result, err := ParseEvent(tc.Input)
+			if (err != nil) != tc.Err {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if result != tc.Expected {
+				t.Errorf("%s does not equal %s", result, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestEventMerge(t *testing.T) {
+	type Test struct {
+		Name     string
+		Events   []Event
+		Expected Event
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Keeps equal events",
+			Events:   []Event{EventRemoved, EventRemoved},
+			Expected: EventRemoved,
+		},
+		{
+			Name:     "Modified when the events differ",
+			Events:   []Event{EventAdded, EventRemoved},
+			Expected: EventModified,
+		},
+		{
+			Name:     "Modified when any event differs",
+			Events:   []Event{EventRemoved, EventAdded, EventRemoved},
+			Expected: EventModified,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			result := tc.Events[0]
+			for _, e := range tc.Events[1:] {
+				result = result.Merge(e)
+			}
+
+			if result != tc.Expected {
+				t.Errorf("%s does not equal %s", result, tc.Expected)
+			}
+		})
+	}
 }
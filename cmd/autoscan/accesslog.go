@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/natefinch/lumberjack"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// accessLogConfig holds the file to which the requests are logged as JSON,
+// separate from the activity log, and the level of the requests which are logged.
+type accessLogConfig struct {
+	Path  string `yaml:"path"`
+	Level string `yaml:"level"`
+}
+
+// accessLogger logs the requests to the routes of the triggers and the API,
+// which is disabled without access log.
+var accessLogger = zerolog.Nop()
+
+func newAccessLogger(c accessLogConfig) (zerolog.Logger, error) {
+	if c.Path == "" {
+		return zerolog.Nop(), nil
+	}
+
+	level := zerolog.InfoLevel
+	if c.Level != "" {
+		l, err := zerolog.ParseLevel(c.Level)
+		if err != nil {
+			return zerolog.Nop(), fmt.Errorf("invalid access log level: %v: %w", err, autoscan.ErrFatal)
+		}
+
+		level = l
+	}
+
+	return zerolog.New(&lumberjack.Logger{
+		Filename:   c.Path,
+		MaxSize:    5,
+		MaxAge:     14,
+		MaxBackups: 5,
+	}).Level(level).With().Timestamp().Logger(), nil
+}
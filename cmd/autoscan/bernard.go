@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/cloudbox/autoscan/triggers/bernard"
+)
+
+type bernardAuthCmd struct {
+	ClientID     string `required:"" name:"client-id" help:"OAuth client ID"`
+	ClientSecret string `required:"" name:"client-secret" help:"OAuth client secret"`
+	Port         int    `default:"0" help:"Local port to receive the authorisation code on (random by default)"`
+}
+
+// run performs the OAuth flow for a Google user account and prints
+// the bernard config needed to use the resulting refresh token.
+func (c bernardAuthCmd) run() error {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", c.Port))
+	if err != nil {
+		return fmt.Errorf("listening for authorisation code: %w", err)
+	}
+
+	redirectURL := fmt.Sprintf("http://%s", ln.Addr().String())
+	codes := make(chan string, 1)
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			code := r.URL.Query().Get("code")
+			if code == "" {
+				rw.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(rw, "Authorisation failed: %s", r.URL.Query().Get("error"))
+				return
+			}
+
+			fmt.Fprint(rw, "Authorisation received, you can close this window.")
+			select {
+			case codes <- code:
+			default:
+			}
+		}),
+	}
+
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	fmt.Println("Open the following URL in your browser and grant autoscan access:")
+	fmt.Println()
+	fmt.Println(bernard.OAuthURL(c.ClientID, redirectURL))
+	fmt.Println()
+	fmt.Printf("Waiting for the authorisation code on %s...\n", redirectURL)
+
+	refreshToken, err := bernard.OAuthExchange(c.ClientID, c.ClientSecret, redirectURL, <-codes)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Add the following to your bernard trigger:")
+	fmt.Println()
+	fmt.Println("    oauth:")
+	fmt.Printf("      client-id: %s\n", c.ClientID)
+	fmt.Printf("      client-secret: %s\n", c.ClientSecret)
+	fmt.Printf("      refresh-token: %s\n", refreshToken)
+	return nil
+}
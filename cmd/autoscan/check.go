@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/hooks/exec"
+	"github.com/cloudbox/autoscan/hooks/rclone"
+	"github.com/cloudbox/autoscan/targets/emby"
+	"github.com/cloudbox/autoscan/targets/plex"
+	"github.com/cloudbox/autoscan/triggers/bernard"
+	"github.com/cloudbox/autoscan/triggers/inotify"
+	"github.com/cloudbox/autoscan/triggers/lidarr"
+	"github.com/cloudbox/autoscan/triggers/manual"
+	"github.com/cloudbox/autoscan/triggers/radarr"
+	"github.com/cloudbox/autoscan/triggers/sonarr"
+)
+
+type checkConfigCmd struct {
+	Reachable bool `help:"Check whether the targets are reachable"`
+	JSON      bool `name:"json" help:"Print the report as JSON"`
+}
+
+// A configCheck is the outcome of validating a section of the config,
+// e.g. triggers.sonarr[0].
+type configCheck struct {
+	Section string `json:"section"`
+	Error   string `json:"error,omitempty"`
+}
+
+// run validates every section of the config file and prints a report.
+// It returns an error when any of the sections is invalid.
+func (c checkConfigCmd) run() error {
+	checks := c.check()
+
+	invalid := 0
+	for _, check := range checks {
+		if check.Error != "" {
+			invalid++
+		}
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(checks); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STATUS\tSECTION\tERROR")
+		for _, check := range checks {
+			status := "ok"
+			if check.Error != "" {
+				status = "invalid"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\n", status, check.Section, check.Error)
+		}
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d sections are invalid", invalid, len(checks))
+	}
+
+	return nil
+}
+
+func (c checkConfigCmd) check() []configCheck {
+	checks := make([]configCheck, 0)
+	add := func(section string, err error) {
+		check := configCheck{Section: section}
+		if err != nil {
+			check.Error = err.Error()
+		}
+
+		checks = append(checks, check)
+	}
+
+	config, err := loadConfig(cli.Config)
+	add("config", err)
+	if err != nil {
+		return checks
+	}
+
+	// hooks
+	for i, h := range config.Hooks.Rclone {
+		_, err := rclone.New(h)
+		add(fmt.Sprintf("hooks.rclone[%d]", i), err)
+	}
+
+	for i, h := range config.Hooks.PreScan {
+		_, err := exec.New(h)
+		add(fmt.Sprintf("hooks.pre-scan[%d]", i), err)
+	}
+
+	for i, h := range config.Hooks.PostScan {
+		_, err := exec.New(h)
+		add(fmt.Sprintf("hooks.post-scan[%d]", i), err)
+	}
+
+	// triggers
+	_, err = manual.New(config.Triggers.Manual)
+	add("triggers.manual", err)
+
+	for i, t := range config.Triggers.Bernard {
+		add(fmt.Sprintf("triggers.bernard[%d]", i), bernard.Check(t))
+	}
+
+	for i, t := range config.Triggers.Inotify {
+		_, err := inotify.New(t)
+		for _, p := range t.Paths {
+			if err == nil {
+				_, err = os.Stat(p.Path)
+			}
+		}
+
+		add(fmt.Sprintf("triggers.inotify[%d]", i), err)
+	}
+
+	// the webhooks share the routes at /triggers/<name>
+	names := map[string]bool{"manual": true}
+	webhook := func(name string, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case name == "":
+			return errors.New("missing name")
+		case names[name]:
+			return fmt.Errorf("duplicate trigger name: %s", name)
+		}
+
+		names[name] = true
+		return nil
+	}
+
+	for i, t := range config.Triggers.Lidarr {
+		_, err := lidarr.New(t)
+		add(fmt.Sprintf("triggers.lidarr[%d]", i), webhook(t.Name, err))
+	}
+
+	for i, t := range config.Triggers.Radarr {
+		_, err := radarr.New(t)
+		add(fmt.Sprintf("triggers.radarr[%d]", i), webhook(t.Name, err))
+	}
+
+	for i, t := range config.Triggers.Sonarr {
+		_, err := sonarr.New(t)
+		add(fmt.Sprintf("triggers.sonarr[%d]", i), webhook(t.Name, err))
+	}
+
+	// targets are only contacted when checking whether they are reachable
+	for i, t := range config.Targets.Plex {
+		err := checkTarget(t.URL, t.Rewrite)
+		if err == nil && c.Reachable {
+			_, err = plex.New(t)
+		}
+
+		add(fmt.Sprintf("targets.plex[%d]", i), err)
+	}
+
+	for i, t := range config.Targets.Emby {
+		err := checkTarget(t.URL, t.Rewrite)
+		if err == nil && c.Reachable {
+			_, err = emby.New(t)
+		}
+
+		add(fmt.Sprintf("targets.emby[%d]", i), err)
+	}
+
+	return checks
+}
+
+// checkTarget validates the URL and the rewrites of a target.
+func checkTarget(targetURL string, rewrites []autoscan.Rewrite) error {
+	u, err := url.Parse(targetURL)
+	switch {
+	case err != nil:
+		return err
+	case u.Scheme != "http" && u.Scheme != "https":
+		return fmt.Errorf("invalid url: %s", targetURL)
+	case u.Host == "":
+		return fmt.Errorf("invalid url: %s", targetURL)
+	}
+
+	_, err = autoscan.NewRewriter(rewrites)
+	return err
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudbox/autoscan/api"
+	"github.com/cloudbox/autoscan/processor"
+	"github.com/cloudbox/autoscan/triggers"
+)
+
+// apiClient talks to the API of a running autoscan on the same machine.
+type apiClient struct {
+	scheme   string
+	addr     string
+	admin    string
+	socket   string
+	basePath string
+	username string
+	password string
+	client   *http.Client
+
+	// the api key and signature of the requests to the manual trigger
+	apiKey    string
+	signature triggers.Signature
+}
+
+// newAPIClient creates a client for the socket or host and port, base path and authentication of the config file.
+// The API is requested on the admin listener when there is one.
+// Without a config file, the client uses the default port without authentication.
+func newAPIClient(path string) (*apiClient, error) {
+	c := config{Port: 3030}
+	if _, err := os.Stat(path); err == nil {
+		c, err = loadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client := &apiClient{
+		scheme:   "http",
+		addr:     net.JoinHostPort(clientHost(c.Host), strconv.Itoa(c.Port)),
+		basePath: c.BasePath,
+		username: c.Auth.Username,
+		password: c.Auth.Password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+
+		apiKey:    c.Triggers.Manual.APIKey,
+		signature: c.Triggers.Manual.Signature,
+	}
+
+	// the password of a user with a password hash is not known to the config
+	if cli.Username != "" || cli.Password != "" {
+		client.username = cli.Username
+		client.password = cli.Password
+	}
+
+	client.admin = client.addr
+	if c.Admin.Port > 0 {
+		client.admin = net.JoinHostPort(clientHost(c.Admin.Host), strconv.Itoa(c.Admin.Port))
+	}
+
+	switch {
+	case c.Socket.Path != "":
+		// the socket is preferred over the port, and is served without TLS
+		client.socket = c.Socket.Path
+		client.addr = "localhost"
+		client.admin = "localhost"
+		client.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", client.socket)
+			},
+		}
+
+	case c.TLS.Cert != "" || c.TLS.ACME.Hostname != "":
+		hosts := []string{clientHost(c.Host)}
+		if c.Admin.Port > 0 {
+			hosts = append(hosts, clientHost(c.Admin.Host))
+		}
+
+		tlsConf, err := clientTLSConfig(c, hosts...)
+		if err != nil {
+			return nil, err
+		}
+
+		client.scheme = "https"
+		client.client.Transport = &http.Transport{
+			TLSClientConfig: tlsConf,
+		}
+	}
+
+	return client, nil
+}
+
+// clientHost returns the host to reach a server listening on the host,
+// which is localhost when the server listens on all interfaces.
+func clientHost(host string) string {
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		return "localhost"
+	}
+
+	return host
+}
+
+// addrOf returns the address at which the path is served,
+// which is the admin listener for the API and the port for the triggers.
+func (c *apiClient) addrOf(path string) string {
+	if strings.HasPrefix(path, "/triggers/") {
+		return c.addr
+	}
+
+	return c.admin
+}
+
+// running returns whether autoscan is listening on the socket, or on the port at which the path is served.
+func (c *apiClient) running(path string) bool {
+	network, addr := "tcp", c.addrOf(path)
+	if c.socket != "" {
+		network, addr = "unix", c.socket
+	}
+
+	conn, err := net.DialTimeout(network, addr, time.Second)
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+	return true
+}
+
+// newRequest creates a request to the path with the authentication of the config file.
+// A request to the manual trigger sends the api key of the trigger,
+// and its body is signed when the trigger requires a signature.
+func (c *apiClient) newRequest(method string, path string, query url.Values, body []byte) (*http.Request, error) {
+	u := url.URL{Scheme: c.scheme, Host: c.addrOf(path), Path: c.basePath + path, RawQuery: query.Encode()}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	if path == manualPath && c.apiKey != "" {
+		req.Header.Set(triggers.APIKeyHeader, c.apiKey)
+	}
+
+	if path == manualPath && c.signature.Secret != "" {
+		if err := c.signature.Sign(req, path, body); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// do sends the request and decodes the JSON response into v.
+func (c *apiClient) do(req *http.Request, v interface{}) error {
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", req.URL.Path, res.Status)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (c *apiClient) call(method string, path string, query url.Values, v interface{}) error {
+	req, err := c.newRequest(method, path, query, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, v)
+}
+
+func (c *apiClient) Queue() ([]processor.QueueEntry, error) {
+	queue := make([]processor.QueueEntry, 0)
+	err := c.call("GET", api.QueuePath, nil, &queue)
+	return queue, err
+}
+
+func (c *apiClient) Flush() (int, error) {
+	var body struct {
+		Flushed int `json:"flushed"`
+	}
+
+	err := c.call("POST", api.FlushPath, nil, &body)
+	return body.Flushed, err
+}
+
+func (c *apiClient) RetryNow(ids ...string) (int, error) {
+	var body struct {
+		Retried int `json:"retried"`
+	}
+
+	err := c.call("POST", api.RetryQueuePath, url.Values{"id": ids}, &body)
+	return body.Retried, err
+}
+
+func (c *apiClient) Remove(ids ...string) (int, error) {
+	var body struct {
+		Deleted int `json:"deleted"`
+	}
+
+	err := c.call("POST", api.DeleteQueuePath, url.Values{"id": ids}, &body)
+	return body.Deleted, err
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
-	"github.com/kirsle/configdir"
-	"golang.org/x/sys/unix"
+	"errors"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"time"
+
+	"github.com/kirsle/configdir"
+	"github.com/rs/zerolog/log"
+
+	"github.com/cloudbox/autoscan/processor"
+	"github.com/cloudbox/autoscan/triggers"
 )
 
 func defaultConfigPath() string {
@@ -38,7 +46,141 @@ func getBinaryPath() string {
 	return dir
 }
 
-func dirIsWriteable(dir string) error {
-	// credits: https://stackoverflow.com/questions/20026320/how-to-tell-if-folder-exists-and-is-writable
-	return unix.Access(dir, unix.W_OK)
+// loadConfig decodes the config file, its includes and its managed file on top of the default values,
+// after which the keys are overridden by the environment and secret files.
+func loadConfig(path string) (config, error) {
+	// set default values
+	c := config{
+		MinimumAge:       10 * time.Minute,
+		ScanDelay:        5 * time.Second,
+		PollInterval:     15 * time.Second,
+		AnchorInterval:   15 * time.Second,
+		PriorityAging:    time.Hour,
+		MaxRetries:       5,
+		HistoryRetention: 30 * 24 * time.Hour,
+		AuditRetention:   90 * 24 * time.Hour,
+		ClaimLease:       15 * time.Minute,
+		Port:             3030,
+	}
+
+	c.Availability.Interval = 15 * time.Second
+	c.Availability.Timeout = 30 * time.Second
+	c.Maintenance.Interval = 24 * time.Hour
+	c.Server.ReadHeaderTimeout = 10 * time.Second
+	c.Server.ReadTimeout = time.Minute
+	c.Server.WriteTimeout = time.Minute
+	c.Server.IdleTimeout = 2 * time.Minute
+	c.Server.ShutdownTimeout = 30 * time.Second
+	c.Pprof.Host = "localhost"
+	c.Lockout = triggers.Lockout{MaxFailures: 10, Delay: 500 * time.Millisecond, MaxDelay: 10 * time.Second, Ban: 15 * time.Minute}
+
+	if err := decodeConfig(path, &c); err != nil {
+		return c, fmt.Errorf("decode config: %w", err)
+	}
+
+	if err := includeConfig(&c, path); err != nil {
+		return c, err
+	}
+
+	if err := includeManaged(&c, path); err != nil {
+		return c, err
+	}
+
+	if err := applyEnv(&c); err != nil {
+		return c, fmt.Errorf("environment: %w", err)
+	}
+
+	if err := applySecretFiles(&c); err != nil {
+		return c, fmt.Errorf("secret: %w", err)
+	}
+
+	c.BasePath = cleanBasePath(c.BasePath)
+
+	if c.Port <= 0 && c.Socket.Path == "" {
+		return c, errors.New("the port must be positive when no socket is set")
+	}
+
+	if c.Admin.Port > 0 && c.Admin.Port == c.Port && c.Admin.Host == c.Host {
+		return c, errors.New("the admin listener must listen on another port or host than the triggers")
+	}
+
+	usernames := make(map[string]bool)
+	for _, u := range authUsers(c) {
+		if err := u.Validate(); err != nil {
+			return c, fmt.Errorf("authentication: %w", err)
+		}
+
+		if usernames[u.Username] {
+			return c, fmt.Errorf("authentication: duplicate user %s", u.Username)
+		}
+
+		usernames[u.Username] = true
+	}
+
+	// the failures are forgotten after the ban, such that no source IP would ever be banned without one
+	if c.Lockout.MaxFailures > 0 && c.Lockout.Ban <= 0 {
+		return c, errors.New("lockout: the ban must be positive when max-failures is set")
+	}
+
+	// polling without a pause would keep the datastore busy
+	if c.PollInterval <= 0 || c.AnchorInterval <= 0 || c.Availability.Interval <= 0 {
+		return c, errors.New("the poll-interval, anchor-interval and availability interval must be positive")
+	}
+
+	return c, nil
+}
+
+// cleanBasePath returns the base path with a leading slash and without a trailing slash,
+// such that the routes can be appended to it. The root is an empty base path.
+func cleanBasePath(base string) string {
+	if base = path.Clean("/" + base); base == "/" {
+		return ""
+	}
+
+	return base
+}
+
+// encryptionKey returns the encryption key of the environment, or else the key of the config file.
+func encryptionKey(config string) string {
+	if cli.EncryptionKey != "" {
+		return cli.EncryptionKey
+	}
+
+	return config
+}
+
+// datastoreConfig returns the datastore of the processor for the commands,
+// which is the PostgreSQL or bolt database of the config file when given.
+// The SQLite database is opened, and the datastore is pruned, with the settings of the config file.
+func datastoreConfig() processor.Config {
+	c := processor.Config{
+		DatastorePath:    cli.Database,
+		HistoryRetention: 30 * 24 * time.Hour,
+	}
+
+	if _, err := os.Stat(cli.Config); err != nil {
+		return c
+	}
+
+	config, err := loadConfig(cli.Config)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Failed loading config")
+	}
+
+	// the datastore of a running autoscan is not accessible from other processes
+	if config.Ephemeral {
+		log.Fatal().
+			Msg("The datastore is ephemeral, use the API instead")
+	}
+
+	c.DatastoreDSN = config.DatabaseDSN
+	c.DatastoreBolt = config.DatabaseBolt
+	c.EncryptionKey = encryptionKey(config.EncryptionKey)
+	c.SQLite = config.SQLite
+	c.HistoryRetention = config.HistoryRetention
+	c.FailedRetention = config.Maintenance.FailedRetention
+
+	return c
 }
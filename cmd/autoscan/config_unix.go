@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func dirIsWriteable(dir string) error {
+	// credits: https://stackoverflow.com/questions/20026320/how-to-tell-if-folder-exists-and-is-writable
+	return unix.Access(dir, unix.W_OK)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+func dirIsWriteable(dir string) error {
+	// windows has no access(2), try creating a file instead
+	f, err := ioutil.TempFile(dir, ".autoscan")
+	if err != nil {
+		return err
+	}
+
+	f.Close()
+	return os.Remove(f.Name())
+}
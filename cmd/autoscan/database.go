@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+type databaseVacuumCmd struct{}
+
+// run checks the integrity of the datastore, and vacuums the datastore when it is intact.
+func (c databaseVacuumCmd) run(datastore processor.Config) error {
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	problems, err := proc.Check()
+	if err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+
+		return fmt.Errorf("integrity check found %d problems, the datastore was not vacuumed", len(problems))
+	}
+
+	start := time.Now()
+	if err := proc.Vacuum(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Integrity check passed, vacuumed the datastore in %s\n", time.Since(start))
+	return nil
+}
+
+type databaseMigrateCmd struct{}
+
+// run applies the pending migrations of the datastore, which happens when the datastore is opened,
+// and prints the status of every migration.
+func (c databaseMigrateCmd) run(datastore processor.Config) error {
+	start := time.Now()
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := proc.Migrations()
+	if err != nil {
+		return err
+	}
+
+	if len(migrations) == 0 {
+		fmt.Println("The datastore has no migrations")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, m := range migrations {
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Local().Format(time.RFC3339)
+			if !m.AppliedAt.Before(start) {
+				applied += " (now)"
+			}
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, applied)
+	}
+
+	return w.Flush()
+}
+
+type databaseStatsCmd struct{}
+
+// run prints the number of entries in the datastore per state.
+func (c databaseStatsCmd) run(datastore processor.Config) error {
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	stats, err := proc.DatabaseStats()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tCOUNT")
+	fmt.Fprintf(w, "queued\t%d\n", stats.Queued)
+	fmt.Fprintf(w, "retrying\t%d\n", stats.Retrying)
+	fmt.Fprintf(w, "delivered\t%d\n", stats.Delivered)
+	fmt.Fprintf(w, "claimed\t%d\n", stats.Claimed)
+	fmt.Fprintf(w, "failed\t%d\n", stats.Failed)
+
+	statuses := make([]string, 0, len(stats.History))
+	for status := range stats.History {
+		statuses = append(statuses, status)
+	}
+
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "history %s\t%d\n", status, stats.History[status])
+	}
+
+	return w.Flush()
+}
+
+type databasePruneCmd struct {
+	HistoryRetention time.Duration `help:"Remove history entries older than the duration, overrides the history-retention of the config"`
+	FailedRetention  time.Duration `help:"Remove failed scans older than the duration, overrides the failed-retention of the config"`
+}
+
+// run removes the history entries and failed scans older than their retention period,
+// and the orphaned entries of scans which are no longer queued.
+func (c databasePruneCmd) run(datastore processor.Config) error {
+	_, err := c.prune(datastore)
+	return err
+}
+
+// prune removes the entries older than the retention periods of the flags or the config file,
+// and returns the processor of the datastore for the steps which follow the pruning.
+func (c databasePruneCmd) prune(datastore processor.Config) (*processor.Processor, error) {
+	if c.HistoryRetention > 0 {
+		datastore.HistoryRetention = c.HistoryRetention
+	}
+
+	if c.FailedRetention > 0 {
+		datastore.FailedRetention = c.FailedRetention
+	}
+
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := proc.Prune(datastore.HistoryRetention, datastore.FailedRetention)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Removed %d history entries, %d failed scans and %d orphaned entries\n",
+		result.History, result.Failed, result.Orphans)
+	return proc, nil
+}
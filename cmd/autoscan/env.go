@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix is the prefix of the environment variables which override the keys of the config.
+// The key of a variable is the path of the YAML key in upper case, with dashes replaced by underscores
+// and with the index of list items, e.g. AUTOSCAN_TARGETS_PLEX_0_TOKEN is the token of the first Plex target.
+const envPrefix = "AUTOSCAN"
+
+var yamlUnmarshaler = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// applyEnv overrides the keys of the config, a pointer to a struct with YAML tags,
+// with the environment variables.
+// Values are parsed as YAML, except for strings, which are used as is.
+// List items beyond the end of a list are appended.
+func applyEnv(config interface{}) error {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 && strings.HasPrefix(kv, envPrefix+"_") {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	return overrideEnv(reflect.ValueOf(config).Elem(), envPrefix, env)
+}
+
+func overrideEnv(v reflect.Value, key string, env map[string]string) error {
+	if value, ok := env[key]; ok {
+		if v.Kind() == reflect.String {
+			v.SetString(value)
+			return nil
+		}
+
+		if err := yaml.Unmarshal([]byte(value), v.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		return nil
+	}
+
+	if reflect.PtrTo(v.Type()).Implements(yamlUnmarshaler) {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			switch name {
+			case "-":
+				continue
+			case "":
+				name = strings.ToLower(field.Name)
+			}
+
+			name = strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+			if err := overrideEnv(v.Field(i), key+"_"+name, env); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		// items can be appended, as long as they do not leave gaps in the list
+		for hasEnvPrefix(env, key+"_"+strconv.Itoa(v.Len())) {
+			v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if err := overrideEnv(v.Index(i), key+"_"+strconv.Itoa(i), env); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasEnvPrefix returns whether the environment contains the key, or a key nested within it.
+func hasEnvPrefix(env map[string]string, key string) bool {
+	for k := range env {
+		if k == key || strings.HasPrefix(k, key+"_") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+type exportCmd struct {
+	Output string `short:"o" help:"File to write the export to, defaults to stdout"`
+}
+
+// run writes the queue, dead-letter queue and history of the database as JSON.
+func (c exportCmd) run(datastore processor.Config) error {
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	export, err := proc.Export()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if c.Output != "" {
+		f, err := os.Create(c.Output)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return err
+	}
+
+	if c.Output != "" {
+		fmt.Printf("Exported %d scans, %d failed scans and %d history entries\n",
+			len(export.Scans), len(export.Failed), len(export.History))
+	}
+
+	return nil
+}
+
+type importCmd struct {
+	File string `arg:"" type:"existingfile" help:"File created with autoscan export"`
+}
+
+// run adds the scans, failed scans and history entries of an export to the database.
+func (c importCmd) run(datastore processor.Config) error {
+	f, err := os.Open(c.File)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	export := processor.Export{}
+	if err := json.NewDecoder(f).Decode(&export); err != nil {
+		return fmt.Errorf("decoding %s: %w", c.File, err)
+	}
+
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	if err := proc.Import(export); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d scans, %d failed scans and %d history entries\n",
+		len(export.Scans), len(export.Failed), len(export.History))
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+type failedListCmd struct{}
+
+// run prints the scans in the dead-letter queue of the database.
+func (c failedListCmd) run(datastore processor.Config) error {
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	failed, err := proc.Failed()
+	if err != nil {
+		return err
+	}
+
+	if len(failed) == 0 {
+		fmt.Println("No failed scans")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTARGET\tATTEMPTS\tFAILED AT\tFOLDER\tERROR")
+	for _, f := range failed {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\n",
+			f.ID, f.Target, f.Attempts, f.Time.Local().Format(time.Stamp), f.Folder, f.Error)
+	}
+
+	return w.Flush()
+}
+
+type failedRequeueCmd struct {
+	IDs []int64 `arg:"" optional:"" name:"id" help:"IDs of the failed scans to requeue"`
+	All bool    `help:"Requeue all failed scans"`
+}
+
+// run moves failed scans of the database back to the queue.
+func (c failedRequeueCmd) run(datastore processor.Config) error {
+	if len(c.IDs) == 0 && !c.All {
+		return errors.New("no ids given, use --all to requeue all failed scans")
+	}
+
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	ids := c.IDs
+	if c.All {
+		failed, err := proc.Failed()
+		if err != nil {
+			return err
+		}
+
+		for _, f := range failed {
+			ids = append(ids, f.ID)
+		}
+	}
+
+	requeued, err := proc.Requeue(ids...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Requeued %d failed scans\n", requeued)
+	return nil
+}
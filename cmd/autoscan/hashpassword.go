@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type hashPasswordCmd struct {
+	Cost int `default:"10" help:"Cost of the bcrypt hash, between 4 and 31"`
+}
+
+// run reads a password from stdin and prints its bcrypt hash,
+// which is the password-hash of a user of the authentication.
+func (c hashPasswordCmd) run() error {
+	if c.Cost < bcrypt.MinCost || c.Cost > bcrypt.MaxCost {
+		return fmt.Errorf("cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("read password: %w", err)
+	}
+
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return errors.New("empty password")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), c.Cost)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(hash))
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+type historyListCmd struct {
+	Limit int `default:"100" help:"Number of entries to list"`
+}
+
+// run prints the most recent history entries of the database.
+func (c historyListCmd) run(datastore processor.Config) error {
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	entries, err := proc.History(c.Limit)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No history")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCAN ID\tSTATUS\tTRIGGER\tTARGET\tSCANNED AT\tDURATION\tFOLDER")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.ScanID, e.Status, e.Trigger, e.Target, e.ScannedAt.Local().Format(time.Stamp), e.Duration, e.Folder)
+	}
+
+	return w.Flush()
+}
+
+type historyRequeueCmd struct {
+	IDs     []string `arg:"" optional:"" name:"id" help:"Scan IDs of the history entries to requeue"`
+	Prefix  string   `help:"Requeue all folders in the history within this path"`
+	Targets []string `name:"target" help:"Limit the scans to these targets, e.g. plex"`
+}
+
+// run moves folders in the history of the database back to the queue.
+func (c historyRequeueCmd) run(datastore processor.Config) error {
+	if len(c.IDs) == 0 && c.Prefix == "" {
+		return errors.New("no ids given, use --prefix to requeue the folders within a path")
+	}
+
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	scans, err := proc.RequeueHistory(c.IDs, c.Prefix, c.Targets)
+	if err != nil {
+		return err
+	}
+
+	for _, scan := range scans {
+		fmt.Println(scan.Folder)
+	}
+
+	fmt.Printf("Requeued %d scans\n", len(scans))
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+// includeConfig merges the config files of the include patterns into the config,
+// in the order of the patterns and of the file names matching a pattern.
+// Relative patterns are relative to the directory of the config file.
+func includeConfig(c *config, path string) error {
+	includes := c.Include
+	c.Include = nil
+
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("include: %v: %w", pattern, err)
+		}
+
+		for _, file := range files {
+			// an empty file includes nothing
+			var fragment config
+			if err := decodeConfig(file, &fragment); err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("include: %v: %w", file, err)
+			}
+
+			if len(fragment.Include) > 0 {
+				return fmt.Errorf("include: %v: included files cannot include other files", file)
+			}
+
+			mergeConfig(reflect.ValueOf(c).Elem(), reflect.ValueOf(fragment))
+		}
+	}
+
+	return nil
+}
+
+// decodeConfig decodes the config file into c, rejecting unknown keys.
+// The keys and values of the file are validated first, to report all problems of the file at once.
+func decodeConfig(path string, c *config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := validateConfig(data, c); err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.SetStrict(true)
+	return decoder.Decode(c)
+}
+
+// mergeConfig appends the lists of src to those of dst,
+// and overrides the other keys of dst which are set in src.
+func mergeConfig(dst reflect.Value, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).PkgPath == "" {
+				mergeConfig(dst.Field(i), src.Field(i))
+			}
+		}
+
+	case reflect.Slice:
+		dst.Set(reflect.AppendSlice(dst, src))
+
+	case reflect.Map:
+		if src.Len() > 0 && dst.IsNil() {
+			dst.Set(reflect.MakeMap(src.Type()))
+		}
+
+		for _, k := range src.MapKeys() {
+			dst.SetMapIndex(k, src.MapIndex(k))
+		}
+
+	default:
+		if !src.IsZero() {
+			dst.Set(src)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/targets/emby"
+	"github.com/cloudbox/autoscan/targets/plex"
+	"gopkg.in/yaml.v2"
+)
+
+// The commented config written by autoscan init.
+//
+//go:embed init.yml
+var initTemplate string
+
+type initCmd struct {
+	Output      string `type:"path" short:"o" help:"File to write the config to, defaults to the config file path"`
+	Force       bool   `help:"Overwrite the file when it exists"`
+	Interactive bool   `short:"i" help:"Ask which triggers and targets to enable, and check the connection to the targets"`
+}
+
+type initWebhook struct {
+	Name     string
+	Priority int
+}
+
+type initTarget struct {
+	URL   string
+	Token string
+}
+
+// initConfig holds the triggers and targets of the generated config.
+type initConfig struct {
+	Port   int
+	Sonarr []initWebhook
+	Radarr []initWebhook
+	Lidarr []initWebhook
+	Plex   []initTarget
+	Emby   []initTarget
+}
+
+// run writes a commented config file with a Sonarr and Radarr webhook and a Plex target,
+// or with the triggers and targets chosen interactively.
+func (c initCmd) run() error {
+	path := c.Output
+	if path == "" {
+		path = cli.Config
+	}
+
+	if _, err := os.Stat(path); err == nil && !c.Force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+
+	conf := initConfig{
+		Port:   3030,
+		Sonarr: []initWebhook{{Name: "sonarr", Priority: 2}},
+		Radarr: []initWebhook{{Name: "radarr", Priority: 2}},
+		Plex:   []initTarget{{URL: "http://localhost:32400", Token: "XXXX"}},
+	}
+
+	if c.Interactive {
+		var err error
+		conf, err = c.ask(&prompter{r: bufio.NewReader(os.Stdin), w: os.Stdout})
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := renderInitConfig(conf)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// the config may contain the tokens of the targets
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote the config to %s, run autoscan check-config after editing it\n", path)
+	return nil
+}
+
+// renderInitConfig returns the config file of the triggers and targets,
+// which is validated like a config file written by hand.
+func renderInitConfig(conf initConfig) ([]byte, error) {
+	tmpl, err := template.New("config").Funcs(template.FuncMap{
+		"yaml": func(s string) (string, error) {
+			b, err := yaml.Marshal(s)
+			return strings.TrimSuffix(string(b), "\n"), err
+		},
+	}).Parse(initTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, conf); err != nil {
+		return nil, err
+	}
+
+	if err := validateConfig(buf.Bytes(), &config{}); err != nil {
+		return nil, fmt.Errorf("generated config: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ask asks for the port, the names of the webhooks and the targets.
+// The connection to every target is checked before it is added.
+func (c initCmd) ask(p *prompter) (conf initConfig, err error) {
+	for conf.Port == 0 {
+		answer, err := p.ask("Port for the webhooks to listen on", "3030")
+		if err != nil {
+			return conf, err
+		}
+
+		if conf.Port, err = strconv.Atoi(answer); err != nil || conf.Port <= 0 || conf.Port > 65535 {
+			fmt.Fprintf(p.w, "  %s is not a valid port\n", answer)
+			conf.Port = 0
+		}
+	}
+
+	webhooks := []struct {
+		name     string
+		def      string
+		priority int
+		hooks    *[]initWebhook
+	}{
+		{"Sonarr", "sonarr", 2, &conf.Sonarr},
+		{"Radarr", "radarr", 2, &conf.Radarr},
+		{"Lidarr", "", 1, &conf.Lidarr},
+	}
+
+	for _, w := range webhooks {
+		answer, err := p.ask(fmt.Sprintf("Names of the %s webhooks, separated by commas, or - for none", w.name), w.def)
+		if err != nil {
+			return conf, err
+		}
+
+		for _, name := range strings.Split(answer, ",") {
+			if name = strings.TrimSpace(name); name != "" && name != "-" {
+				*w.hooks = append(*w.hooks, initWebhook{Name: name, Priority: w.priority})
+			}
+		}
+	}
+
+	if conf.Plex, err = c.askTargets(p, "Plex", "http://localhost:32400", "Plex token", true, func(t initTarget) (autoscan.Target, error) {
+		return plex.New(plex.Config{URL: t.URL, Token: t.Token, Timeout: 10 * time.Second})
+	}); err != nil {
+		return conf, err
+	}
+
+	if conf.Emby, err = c.askTargets(p, "Emby", "http://localhost:8096", "Emby API key", false, func(t initTarget) (autoscan.Target, error) {
+		return emby.New(emby.Config{URL: t.URL, Token: t.Token, Timeout: 10 * time.Second})
+	}); err != nil {
+		return conf, err
+	}
+
+	return conf, nil
+}
+
+// askTargets asks for the URL and token of targets until no more targets are added.
+// Targets which cannot be reached are only added when confirmed.
+func (c initCmd) askTargets(p *prompter, name string, url string, token string, def bool,
+	newTarget func(initTarget) (autoscan.Target, error)) ([]initTarget, error) {
+	targets := make([]initTarget, 0)
+	for {
+		question := fmt.Sprintf("Add a target for %s?", name)
+		if len(targets) > 0 {
+			question = fmt.Sprintf("Add another target for %s?", name)
+			def = false
+		}
+
+		add, err := p.confirm(question, def)
+		if err != nil || !add {
+			return targets, err
+		}
+
+		t := initTarget{}
+		if t.URL, err = p.ask(fmt.Sprintf("URL of the %s server", name), url); err != nil {
+			return targets, err
+		}
+
+		if t.Token, err = p.ask(token, ""); err != nil {
+			return targets, err
+		}
+
+		if err := probeTarget(p.w, t, newTarget); err != nil {
+			fmt.Fprintf(p.w, "  could not connect to %s: %v\n", t.URL, err)
+
+			keep, err := p.confirm("Add the target anyway?", false)
+			if err != nil {
+				return targets, err
+			}
+
+			if !keep {
+				continue
+			}
+		}
+
+		targets = append(targets, t)
+	}
+}
+
+// probeTarget connects to the target and prints the version and the libraries of its media server.
+func probeTarget(w io.Writer, t initTarget, newTarget func(initTarget) (autoscan.Target, error)) error {
+	target, err := newTarget(t)
+	if err != nil {
+		return err
+	}
+
+	if err := target.Available(); err != nil {
+		return err
+	}
+
+	d, ok := target.(autoscan.Describer)
+	if !ok {
+		fmt.Fprintln(w, "  connected")
+		return nil
+	}
+
+	info, err := d.Describe()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "  connected to version %s with %d library folders\n", info.Version, len(info.Libraries))
+	return nil
+}
+
+// A prompter asks questions on the terminal.
+type prompter struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// ask returns the answer to the question, or def when the answer is empty.
+// It returns an error when the input ends before the question is answered.
+func (p *prompter) ask(question string, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.w, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.w, "%s: ", question)
+	}
+
+	line, err := p.r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		fmt.Fprintln(p.w)
+		return "", fmt.Errorf("init aborted: %w", err)
+	}
+
+	if line = strings.TrimSpace(line); line != "" {
+		return line, nil
+	}
+
+	return def, nil
+}
+
+// confirm asks a yes or no question, of which def is the answer when the answer is empty.
+func (p *prompter) confirm(question string, def bool) (bool, error) {
+	options := "y/N"
+	if def {
+		options = "Y/n"
+	}
+
+	for {
+		answer, err := p.ask(question+" ("+options+")", "")
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
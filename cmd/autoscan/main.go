@@ -1,21 +1,28 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/natefinch/lumberjack"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"gopkg.in/yaml.v2"
 
 	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/hooks/exec"
+	"github.com/cloudbox/autoscan/hooks/rclone"
 	"github.com/cloudbox/autoscan/processor"
 	"github.com/cloudbox/autoscan/targets/emby"
 	"github.com/cloudbox/autoscan/targets/plex"
@@ -29,16 +36,132 @@ import (
 )
 
 type config struct {
+	// Config files merged into this config
+	Include []string `yaml:"include"`
+
 	// General configuration
-	Port       int           `yaml:"port"`
-	MinimumAge time.Duration `yaml:"minimum-age"`
-	ScanDelay  time.Duration `yaml:"scan-delay"`
-	Anchors    []string      `yaml:"anchors"`
+	Host              string        `yaml:"host"`
+	Port              int           `yaml:"port"`
+	BasePath          string        `yaml:"base-path"`
+	DatabaseDSN       string        `yaml:"database-dsn"`
+	DatabaseDSNFile   string        `yaml:"database-dsn-file"`
+	DatabaseBolt      string        `yaml:"database-bolt"`
+	EncryptionKey     string        `yaml:"encryption-key"`
+	EncryptionKeyFile string        `yaml:"encryption-key-file"`
+	Ephemeral         bool          `yaml:"ephemeral"`
+	MinimumAge        time.Duration `yaml:"minimum-age"`
+	MaximumAge        time.Duration `yaml:"maximum-age"`
+	ScanDelay         time.Duration `yaml:"scan-delay"`
+	PollInterval      time.Duration `yaml:"poll-interval"`
+	AnchorInterval    time.Duration `yaml:"anchor-interval"`
+	PriorityAging     time.Duration `yaml:"priority-aging"`
+	MaxRetries        int           `yaml:"max-retries"`
+	MaxQueue          int           `yaml:"max-queue"`
+	HistoryRetention  time.Duration `yaml:"history-retention"`
+	AuditRetention    time.Duration `yaml:"audit-retention"`
+	SettleTime        time.Duration `yaml:"settle-time"`
+	CoalesceWindow    time.Duration `yaml:"coalesce-window"`
+	InstanceID        string        `yaml:"instance-id"`
+	ClaimLease        time.Duration `yaml:"claim-lease"`
+	ScanWorkers       int           `yaml:"scan-workers"`
+	BatchSiblings     int           `yaml:"batch-siblings"`
+	Anchors           []string      `yaml:"anchors"`
+
+	// Connection to the SQLite database
+	SQLite autoscan.SQLite `yaml:"sqlite"`
+
+	// Availability checks of the targets
+	Availability struct {
+		Interval time.Duration `yaml:"interval"`
+		Timeout  time.Duration `yaml:"timeout"`
+		Serial   bool          `yaml:"serial"`
+	} `yaml:"availability"`
+
+	// Maintenance of the datastore
+	Maintenance struct {
+		Interval        time.Duration `yaml:"interval"`
+		FailedRetention time.Duration `yaml:"failed-retention"`
+	} `yaml:"maintenance"`
+
+	// Timeouts of the web server, 0 disables a timeout
+	Server struct {
+		ReadHeaderTimeout time.Duration `yaml:"read-header-timeout"`
+		ReadTimeout       time.Duration `yaml:"read-timeout"`
+		WriteTimeout      time.Duration `yaml:"write-timeout"`
+		IdleTimeout       time.Duration `yaml:"idle-timeout"`
+		ShutdownTimeout   time.Duration `yaml:"shutdown-timeout"`
+	} `yaml:"server"`
+
+	// Profiles of net/http/pprof, served on a separate port when set
+	Pprof struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	} `yaml:"pprof"`
+
+	// Listener of the API and the dashboard, separate from the triggers when set
+	Admin struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	} `yaml:"admin"`
+
+	// Unix socket of the web server, alongside the port or instead of it with port 0
+	Socket struct {
+		Path string `yaml:"path"`
+		Mode string `yaml:"mode"`
+	} `yaml:"socket"`
+
+	// HTTPS for the web server
+	TLS struct {
+		Cert     string `yaml:"cert"`
+		Key      string `yaml:"key"`
+		ClientCA string `yaml:"client-ca"`
+		ACME     struct {
+			Hostname string `yaml:"hostname"`
+			Email    string `yaml:"email"`
+			Cache    string `yaml:"cache"`
+		} `yaml:"acme"`
+
+		// Certificate of the CLI commands for the client-ca, and the CA of the certificate of the server
+		Client struct {
+			Cert string `yaml:"cert"`
+			Key  string `yaml:"key"`
+			CA   string `yaml:"ca"`
+		} `yaml:"client"`
+	} `yaml:"tls"`
+
+	// Access log of the routes of autoscan.HTTPTrigger and the API
+	AccessLog accessLogConfig `yaml:"access-log"`
+
+	// Proxies of which the source IP of requests is taken from their headers
+	TrustedProxies []string `yaml:"trusted-proxies"`
+
+	// CORS headers of the API
+	CORS triggers.CORS `yaml:"cors"`
+
+	// Source IPs which are allowed to and denied from the routes of autoscan.HTTPTrigger
+	IPFilter ipFilterConfig `yaml:"ip-filter"`
+
+	// Throttling of the source IPs which fail to authenticate
+	Lockout triggers.Lockout `yaml:"lockout"`
+
+	// Rate limits of the routes of autoscan.HTTPTrigger
+	RateLimit rateLimitConfig `yaml:"rate-limit"`
+
+	// Request limits of the routes of autoscan.HTTPTrigger
+	RequestLimits requestLimitsConfig `yaml:"request-limits"`
+
+	// Deduplication of the requests to the routes of autoscan.HTTPTrigger
+	Deduplication deduplicationConfig `yaml:"deduplication"`
 
 	// Authentication for autoscan.HTTPTrigger
 	Auth struct {
-		Username string `yaml:"username"`
-		Password string `yaml:"password"`
+		Username     string `yaml:"username"`
+		Password     string `yaml:"password"`
+		PasswordFile string `yaml:"password-file"`
+		Role         string `yaml:"role"`
+
+		// users with bcrypt password hashes
+		Users []triggers.User `yaml:"users"`
 	} `yaml:"authentication"`
 
 	// autoscan.HTTPTrigger
@@ -51,11 +174,21 @@ type config struct {
 		Sonarr  []sonarr.Config  `yaml:"sonarr"`
 	} `yaml:"triggers"`
 
+	// autoscan.PreScanHook and autoscan.PostScanHook
+	Hooks struct {
+		Rclone   []rclone.Config `yaml:"rclone"`
+		PreScan  []exec.Config   `yaml:"pre-scan"`
+		PostScan []exec.Config   `yaml:"post-scan"`
+	} `yaml:"hooks"`
+
 	// autoscan.Target
 	Targets struct {
 		Plex []plex.Config `yaml:"plex"`
 		Emby []emby.Config `yaml:"emby"`
 	} `yaml:"targets"`
+
+	// Webhook triggers and targets which are not started
+	Disabled disabledConfig `yaml:"disabled"`
 }
 
 var (
@@ -64,6 +197,9 @@ var (
 	Timestamp string
 	GitCommit string
 
+	// started is the start time of the process, of which the status API reports the uptime
+	started = time.Now()
+
 	// CLI
 	cli struct {
 		globals
@@ -73,6 +209,61 @@ var (
 		Database  string `type:"path" default:"${database_file}" env:"AUTOSCAN_DATABASE" help:"Database file path"`
 		Log       string `type:"path" default:"${log_file}" env:"AUTOSCAN_LOG" help:"Log file path"`
 		Verbosity int    `type:"counter" default:"0" short:"v" env:"AUTOSCAN_VERBOSITY" help:"Log level verbosity"`
+
+		EncryptionKey string `env:"AUTOSCAN_ENCRYPTION_KEY" help:"Key to encrypt the bolt datastore, overrides the encryption-key of the config"`
+		DryRun        bool   `env:"AUTOSCAN_DRY_RUN" help:"Log the scans instead of sending them to the targets"`
+		Once          bool   `env:"AUTOSCAN_ONCE" help:"Process the queue without starting the triggers, and exit once no scans are available"`
+		Username      string `env:"AUTOSCAN_USERNAME" help:"Username with which the commands authenticate to the API, overrides the authentication of the config"`
+		Password      string `env:"AUTOSCAN_PASSWORD" help:"Password with which the commands authenticate to the API, overrides the authentication of the config"`
+
+		// commands
+		Run     struct{} `cmd:"" default:"1" help:"Run autoscan"`
+		Bernard struct {
+			Auth bernardAuthCmd `cmd:"" help:"Authorise a Google account for use with the bernard trigger"`
+		} `cmd:"" help:"Bernard (Google Drive) helpers"`
+		Failed struct {
+			List    failedListCmd    `cmd:"" help:"List scans which failed after the maximum number of retries"`
+			Requeue failedRequeueCmd `cmd:"" help:"Move failed scans back to the queue"`
+		} `cmd:"" help:"Dead-letter queue helpers"`
+		Queue struct {
+			List   queueListCmd   `cmd:"" help:"List the scans in the queue"`
+			Flush  queueFlushCmd  `cmd:"" help:"Remove all scans from the queue"`
+			Retry  queueRetryCmd  `cmd:"" help:"Retry queued scans without waiting for their backoff"`
+			Delete queueDeleteCmd `cmd:"" help:"Remove scans from the queue"`
+		} `cmd:"" help:"Queue helpers, using the API of a running autoscan"`
+		History struct {
+			List    historyListCmd    `cmd:"" help:"List the most recent outcomes of scans"`
+			Requeue historyRequeueCmd `cmd:"" help:"Move scans in the history back to the queue"`
+		} `cmd:"" help:"Scan history helpers"`
+		Trigger struct {
+			Simulate triggerSimulateCmd `cmd:"" help:"Replay a webhook payload through a trigger and print the resulting scans"`
+		} `cmd:"" help:"Trigger helpers"`
+		Rewrite struct {
+			Test rewriteTestCmd `cmd:"" help:"Print how the rewrite rules of a trigger and targets rewrite a path"`
+		} `cmd:"" help:"Rewrite helpers"`
+		Targets struct {
+			Test targetsTestCmd `cmd:"" help:"Connect to the targets and check which libraries the paths resolve to"`
+		} `cmd:"" help:"Target helpers"`
+		Scan   scanCmd   `cmd:"" help:"Scan paths with the manual trigger of the running autoscan"`
+		Pause  pauseCmd  `cmd:"" help:"Pause sending scans to the targets, triggers keep queueing scans"`
+		Resume resumeCmd `cmd:"" help:"Resume sending scans to the targets"`
+
+		DB struct {
+			Vacuum  databaseVacuumCmd  `cmd:"" help:"Check the integrity of the datastore and vacuum it"`
+			Migrate databaseMigrateCmd `cmd:"" help:"Apply pending migrations and print the migration status of the datastore"`
+			Stats   databaseStatsCmd   `cmd:"" help:"Print the number of entries in the datastore per state"`
+			Prune   databasePruneCmd   `cmd:"" help:"Remove history entries and failed scans older than their retention"`
+		} `cmd:"" name:"database" help:"Datastore helpers"`
+		Service struct {
+			Install   serviceInstallCmd   `cmd:"" help:"Install autoscan as a Windows service"`
+			Uninstall serviceUninstallCmd `cmd:"" help:"Stop and remove the Windows service"`
+		} `cmd:"" help:"Windows service helpers"`
+		Maintenance  maintenanceCmd  `cmd:"" help:"Prune the datastore like database prune, and vacuum it afterwards"`
+		Init         initCmd         `cmd:"" help:"Write a commented config file to get started"`
+		CheckConfig  checkConfigCmd  `cmd:"" name:"check-config" help:"Validate the triggers, targets and hooks of the config file"`
+		HashPassword hashPasswordCmd `cmd:"" name:"hash-password" help:"Print the bcrypt hash of a password read from stdin, for the password-hash of a user"`
+		Export       exportCmd       `cmd:"" help:"Export the queue, failed scans and history as JSON"`
+		Import       importCmd       `cmd:"" help:"Import the queue, failed scans and history of an export"`
 	}
 )
 
@@ -118,6 +309,7 @@ func main() {
 	logger := log.Output(io.MultiWriter(zerolog.ConsoleWriter{
 		TimeFormat: time.Stamp,
 		Out:        os.Stderr,
+		NoColor:    !consoleColors(),
 	}, zerolog.ConsoleWriter{
 		TimeFormat: time.Stamp,
 		Out: &lumberjack.Logger{
@@ -138,37 +330,309 @@ func main() {
 		log.Logger = logger.Level(zerolog.InfoLevel)
 	}
 
-	// run
-	mux := http.NewServeMux()
+	switch ctx.Command() {
+	case "bernard auth":
+		if err := cli.Bernard.Auth.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed authorising Google account")
+		}
+		return
+
+	case "service install":
+		if err := cli.Service.Install.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed installing service")
+		}
+		return
+
+	case "service uninstall":
+		if err := cli.Service.Uninstall.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed uninstalling service")
+		}
+		return
+
+	case "init":
+		if err := cli.Init.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed writing config")
+		}
+		return
+
+	case "hash-password":
+		if err := cli.HashPassword.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed hashing password")
+		}
+		return
+
+	case "check-config":
+		if err := cli.CheckConfig.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Invalid config")
+		}
+		return
+
+	case "trigger simulate <trigger>":
+		if err := cli.Trigger.Simulate.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed simulating trigger")
+		}
+		return
+
+	case "rewrite test <path>":
+		if err := cli.Rewrite.Test.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed testing rewrites")
+		}
+		return
+
+	case "targets test":
+		if err := cli.Targets.Test.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed testing targets")
+		}
+		return
+
+	case "scan <path>":
+		if err := cli.Scan.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed submitting scans")
+		}
+		return
 
-	file, err := os.Open(cli.Config)
+	case "pause":
+		if err := cli.Pause.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed pausing processor")
+		}
+		return
+
+	case "resume":
+		if err := cli.Resume.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed resuming processor")
+		}
+		return
+
+	case "maintenance":
+		if err := cli.Maintenance.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed maintaining datastore")
+		}
+		return
+
+	case "database vacuum":
+		if err := cli.DB.Vacuum.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed vacuuming datastore")
+		}
+		return
+
+	case "database migrate":
+		if err := cli.DB.Migrate.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed migrating datastore")
+		}
+		return
+
+	case "database stats":
+		if err := cli.DB.Stats.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed counting datastore entries")
+		}
+		return
+
+	case "database prune":
+		if err := cli.DB.Prune.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed pruning datastore")
+		}
+		return
+
+	case "export":
+		if err := cli.Export.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed exporting datastore")
+		}
+		return
+
+	case "import <file>":
+		if err := cli.Import.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed importing datastore")
+		}
+		return
+
+	case "failed list":
+		if err := cli.Failed.List.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed listing failed scans")
+		}
+		return
+
+	case "failed requeue", "failed requeue <id>":
+		if err := cli.Failed.Requeue.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed requeueing failed scans")
+		}
+		return
+
+	case "queue list":
+		if err := cli.Queue.List.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed listing queued scans")
+		}
+		return
+
+	case "queue flush":
+		if err := cli.Queue.Flush.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed flushing queue")
+		}
+		return
+
+	case "queue retry", "queue retry <id>":
+		if err := cli.Queue.Retry.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed retrying queued scans")
+		}
+		return
+
+	case "queue delete <id>":
+		if err := cli.Queue.Delete.run(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed deleting queued scans")
+		}
+		return
+
+	case "history list":
+		if err := cli.History.List.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed listing history")
+		}
+		return
+
+	case "history requeue", "history requeue <id>":
+		if err := cli.History.Requeue.run(datastoreConfig()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Failed requeueing scans")
+		}
+		return
+	}
+
+	// run
+	c, err := loadConfig(cli.Config)
 	if err != nil {
 		log.Fatal().
 			Err(err).
-			Msg("Failed opening config")
+			Msg("Failed loading config")
 	}
-	defer file.Close()
 
-	// set default values
-	c := config{
-		MinimumAge: 10 * time.Minute,
-		ScanDelay:  5 * time.Second,
-		Port:       3030,
-	}
-
-	decoder := yaml.NewDecoder(file)
-	decoder.SetStrict(true)
-	err = decoder.Decode(&c)
+	accessLogger, err = newAccessLogger(c.AccessLog)
 	if err != nil {
 		log.Fatal().
 			Err(err).
-			Msg("Failed decoding config")
+			Msg("Failed initialising access log")
+	}
+
+	// hooks
+	hooks := make([]autoscan.PreScanHook, 0)
+
+	for _, h := range c.Hooks.Rclone {
+		hook, err := rclone.New(h)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Str("hook", "rclone").
+				Str("hook_url", h.URL).
+				Msg("Failed initialising hook")
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	for _, h := range c.Hooks.PreScan {
+		hook, err := exec.New(h)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Str("hook", "pre-scan").
+				Msg("Failed initialising hook")
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	postHooks := make([]autoscan.PostScanHook, 0)
+
+	for _, h := range c.Hooks.PostScan {
+		hook, err := exec.New(h)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Str("hook", "post-scan").
+				Msg("Failed initialising hook")
+		}
+
+		postHooks = append(postHooks, hook)
 	}
 
 	proc, err := processor.New(processor.Config{
-		Anchors:       c.Anchors,
-		DatastorePath: cli.Database,
-		MinimumAge:    c.MinimumAge,
+		Anchors:          c.Anchors,
+		DatastorePath:    cli.Database,
+		DatastoreDSN:     c.DatabaseDSN,
+		DatastoreBolt:    c.DatabaseBolt,
+		EncryptionKey:    encryptionKey(c.EncryptionKey),
+		SQLite:           c.SQLite,
+		Ephemeral:        c.Ephemeral,
+		MinimumAge:       c.MinimumAge,
+		MaximumAge:       c.MaximumAge,
+		PriorityAging:    c.PriorityAging,
+		MaxRetries:       c.MaxRetries,
+		MaxQueue:         c.MaxQueue,
+		HistoryRetention: c.HistoryRetention,
+		AuditRetention:   c.AuditRetention,
+		FailedRetention:  c.Maintenance.FailedRetention,
+		SettleTime:       c.SettleTime,
+		CoalesceWindow:   c.CoalesceWindow,
+		Instance:         c.InstanceID,
+		ClaimLease:       c.ClaimLease,
+		Workers:          c.ScanWorkers,
+		BatchSiblings:    c.BatchSiblings,
+		PreScanHooks:     hooks,
+		PostScanHooks:    postHooks,
+
+		AvailabilityTimeout: c.Availability.Timeout,
+		AvailabilitySerial:  c.Availability.Serial,
+		DryRun:              cli.DryRun,
 	})
 
 	if err != nil {
@@ -178,219 +642,399 @@ func main() {
 	}
 
 	log.Info().
+		Bool("ephemeral", c.Ephemeral).
+		Bool("encrypted", encryptionKey(c.EncryptionKey) != "").
+		Bool("dry_run", cli.DryRun).
 		Stringer("min_age", c.MinimumAge).
+		Stringer("max_age", c.MaximumAge).
+		Stringer("priority_aging", c.PriorityAging).
+		Int("max_retries", c.MaxRetries).
+		Int("max_queue", c.MaxQueue).
+		Stringer("history_retention", c.HistoryRetention).
+		Stringer("audit_retention", c.AuditRetention).
+		Stringer("settle_time", c.SettleTime).
+		Stringer("coalesce_window", c.CoalesceWindow).
+		Stringer("claim_lease", c.ClaimLease).
+		Int("scan_workers", c.ScanWorkers).
+		Int("batch_siblings", c.BatchSiblings).
 		Strs("anchors", c.Anchors).
 		Msg("Initialised processor")
 
-	// Set authentication. If none and running at least one webhook -> warn user.
-	authHandler := triggers.WithAuth(c.Auth.Username, c.Auth.Password)
-	if (c.Auth.Username == "" || c.Auth.Password == "") &&
-		len(c.Triggers.Radarr)+len(c.Triggers.Sonarr) > 0 {
-		log.Warn().Msg("Webhooks running without authentication")
+	if cli.DryRun {
+		log.Warn().Msg("Dry run, scans are not sent to the targets and hooks do not run")
 	}
 
-	// Daemon Triggers
-	for _, t := range c.Triggers.Bernard {
-		if t.DatastorePath == "" {
-			t.DatastorePath = cli.Database
-		}
-
-		trigger, err := bernard.New(t)
+	if cli.Once {
+		svc, err := newServices(c, proc, nil, triggers.NewLockoutState())
 		if err != nil {
 			log.Fatal().
 				Err(err).
-				Str("trigger", "bernard").
-				Msg("Failed initialising trigger")
+				Msg("Failed initialising triggers and targets")
 		}
 
-		go trigger(proc.Add)
+		os.Exit(runOnce(proc, svc))
 	}
 
-	for _, t := range c.Triggers.Inotify {
-		trigger, err := inotify.New(t)
-		if err != nil {
-			log.Fatal().
-				Err(err).
-				Str("trigger", "inotify").
-				Msg("Failed initialising trigger")
-		}
+	// the failures and bans of the lockout are kept when the config is reloaded
+	mgr := newManager(cli.Config)
+	svc, err := newServices(c, proc, mgr, triggers.NewLockoutState())
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Failed initialising triggers and targets")
+	}
 
-		go trigger(proc.Add)
+	tlsConf, err := tlsConfig(c)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Failed initialising TLS")
 	}
 
-	// HTTP Triggers
-	manualTrigger, err := manual.New(c.Triggers.Manual)
+	proxies, err := triggers.ParseNetworks(c.TrustedProxies)
 	if err != nil {
 		log.Fatal().
 			Err(err).
-			Str("trigger", "manual").
-			Msg("Failed initialising trigger")
+			Msg("Failed parsing trusted proxies")
 	}
 
-	logHandler := triggers.WithLogger(autoscan.GetLogger(c.Triggers.Manual.Verbosity))
-	mux.Handle("/triggers/manual", logHandler(authHandler(manualTrigger(proc.Add))))
+	newServer := func(addr string, h http.Handler) *http.Server {
+		srv := &http.Server{
+			Addr:      addr,
+			Handler:   withBasePath(c.BasePath, triggers.WithTrustedProxies(proxies)(h)),
+			TLSConfig: tlsConf,
 
-	for _, t := range c.Triggers.Lidarr {
-		trigger, err := lidarr.New(t)
-		if err != nil {
-			log.Fatal().
-				Err(err).
-				Str("trigger", t.Name).
-				Msg("Failed initialising trigger")
+			// slow clients cannot hold on to a connection
+			ReadHeaderTimeout: c.Server.ReadHeaderTimeout,
+			ReadTimeout:       c.Server.ReadTimeout,
+			WriteTimeout:      c.Server.WriteTimeout,
+			IdleTimeout:       c.Server.IdleTimeout,
 		}
 
-		logHandler := triggers.WithLogger(autoscan.GetLogger(t.Verbosity))
-		mux.Handle("/triggers/"+t.Name, logHandler(authHandler(trigger(proc.Add))))
+		// event streams only end once their subscription is closed
+		srv.RegisterOnShutdown(proc.CloseSubscriptions)
+		return srv
 	}
 
-	for _, t := range c.Triggers.Radarr {
-		trigger, err := radarr.New(t)
-		if err != nil {
+	serve := func(srv *http.Server, listen func() error) {
+		if err := listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal().
 				Err(err).
-				Str("trigger", t.Name).
-				Msg("Failed initialising trigger")
+				Str("addr", srv.Addr).
+				Msg("Failed starting web server")
 		}
+	}
 
-		logHandler := triggers.WithLogger(autoscan.GetLogger(t.Verbosity))
-		mux.Handle("/triggers/"+t.Name, logHandler(authHandler(trigger(proc.Add))))
+	// the certificate is provided by the TLS config
+	listenAndServe := func(srv *http.Server) func() error {
+		if tlsConf != nil {
+			return func() error { return srv.ListenAndServeTLS("", "") }
+		}
+
+		return srv.ListenAndServe
+	}
+
+	handler := &routes{mux: svc.mux}
+	srv := newServer(net.JoinHostPort(c.Host, strconv.Itoa(c.Port)), handler)
+	servers := []*http.Server{srv}
+
+	if c.Port > 0 {
+		log.Info().
+			Bool("tls", tlsConf != nil).
+			Str("base_path", c.BasePath).
+			Msgf("Starting server on %s", srv.Addr)
+
+		go serve(srv, listenAndServe(srv))
+	}
+
+	// the admin server serves the API and the dashboard apart from the triggers
+	admin := &routes{mux: svc.admin}
+	if c.Admin.Port > 0 {
+		adminSrv := newServer(net.JoinHostPort(c.Admin.Host, strconv.Itoa(c.Admin.Port)), admin)
+		servers = append(servers, adminSrv)
+
+		log.Info().
+			Bool("tls", tlsConf != nil).
+			Str("base_path", c.BasePath).
+			Msgf("Starting admin server on %s", adminSrv.Addr)
+
+		go serve(adminSrv, listenAndServe(adminSrv))
 	}
 
-	for _, t := range c.Triggers.Sonarr {
-		trigger, err := sonarr.New(t)
+	// the socket is only reachable from the same host, is served without TLS,
+	// and also serves the API and the dashboard of the admin listener
+	if c.Socket.Path != "" {
+		ln, err := listenSocket(c.Socket.Path, c.Socket.Mode)
 		if err != nil {
 			log.Fatal().
 				Err(err).
-				Str("trigger", t.Name).
-				Msg("Failed initialising trigger")
+				Msg("Failed listening on socket")
+		}
+
+		log.Info().
+			Str("base_path", c.BasePath).
+			Msgf("Starting server on socket %s", c.Socket.Path)
+
+		socketSrv := srv
+		if c.Admin.Port > 0 {
+			socketSrv = newServer(c.Socket.Path, withAdmin(handler, admin))
+			servers = append(servers, socketSrv)
 		}
 
-		logHandler := triggers.WithLogger(autoscan.GetLogger(t.Verbosity))
-		mux.Handle("/triggers/"+t.Name, logHandler(authHandler(trigger(proc.Add))))
+		go serve(socketSrv, func() error { return socketSrv.Serve(ln) })
 	}
 
-	go func() {
-		log.Info().Msgf("Starting server on port %d", c.Port)
-		if err := http.ListenAndServe(fmt.Sprintf(":%d", c.Port), mux); err != nil {
-			log.Fatal().
-				Err(err).
-				Msg("Failed starting web server")
+	pprofSrv := startPprof(c)
+
+	svc.start(proc)
+	log.Info().Msg("Processor started")
+
+	// wait for a shutdown signal, the config is reloaded on SIGHUP and when the API changes the triggers or targets
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	stopped := notifyService(signals)
+
+	var sig os.Signal
+	for sig == nil {
+		select {
+		case s := <-signals:
+			if s != syscall.SIGHUP {
+				sig = s
+				continue
+			}
+
+			log.Info().Stringer("signal", s).Msg("Reloading config")
+			c, svc, _ = reload(c, proc, svc, handler, admin)
+
+		case done := <-mgr.reloads:
+			log.Info().Msg("Reloading config with the changed triggers and targets")
+			c, svc, err = reload(c, proc, svc, handler, admin)
+			done <- err
 		}
+	}
+
+	mgr.stop()
+	log.Info().Stringer("signal", sig).Msg("Shutting down, press Ctrl+C again to force")
+
+	go func() {
+		<-signals
+		log.Fatal().Msg("Forced shutdown")
 	}()
 
-	log.Info().
-		Int("manual", 1).
-		Int("bernard", len(c.Triggers.Bernard)).
-		Int("inotify", len(c.Triggers.Inotify)).
-		Int("lidarr", len(c.Triggers.Lidarr)).
-		Int("sonarr", len(c.Triggers.Sonarr)).
-		Int("radarr", len(c.Triggers.Radarr)).
-		Msg("Initialised triggers")
-
-	// targets
-	targets := make([]autoscan.Target, 0)
-
-	for _, t := range c.Targets.Plex {
-		tp, err := plex.New(t)
-		if err != nil {
-			log.Fatal().
+	if pprofSrv != nil {
+		pprofSrv.Close()
+	}
+
+	shutdown(servers, proc, svc, c.Server.ShutdownTimeout)
+	stopped()
+}
+
+// withTrigger records the name of the trigger in its scans.
+func withTrigger(name string, add autoscan.ProcessorFunc) autoscan.ProcessorFunc {
+	return func(scans ...autoscan.Scan) error {
+		named := make([]autoscan.Scan, 0, len(scans))
+		for _, scan := range scans {
+			scan.Trigger = name
+			named = append(named, scan)
+		}
+
+		return add(named...)
+	}
+}
+
+// withBasePath serves the handler at the base path, such that autoscan can live at a sub-path of a reverse proxy.
+// Requests outside of the base path are not found.
+func withBasePath(base string, h http.Handler) http.Handler {
+	if base == "" {
+		return h
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(base+"/", http.StripPrefix(base, h))
+	return mux
+}
+
+// withAdmin serves the triggers with the routes of the triggers, and other paths with the routes of the admin listener.
+func withAdmin(trigger http.Handler, admin http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/triggers/") {
+			trigger.ServeHTTP(rw, r)
+			return
+		}
+
+		admin.ServeHTTP(rw, r)
+	})
+}
+
+// shutdown stops the web servers, which stops the HTTP triggers,
+// stops the daemon triggers, waits for the in-flight scans of the targets to finish and closes the processor.
+// Scans which did not reach all targets remain queued for the next run.
+// The timeout is the time given to in-flight requests and scans to finish, without a timeout they are awaited.
+func shutdown(servers []*http.Server, proc *processor.Processor, svc *services, timeout time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+
+	defer cancel()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Error().
 				Err(err).
-				Str("target", "plex").
-				Str("target_url", t.URL).
-				Msg("Failed initialising target")
+				Str("addr", srv.Addr).
+				Msg("Failed shutting down web server gracefully")
 		}
+	}
+
+	if err := svc.shutdown(ctx); err != nil {
+		log.Warn().Msg("Timed out waiting for in-flight scans, these will be retried on the next run")
+	} else {
+		log.Info().Msg("Processor stopped")
+	}
 
-		targets = append(targets, tp)
+	if err := proc.Close(); err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed closing processor")
+	}
+
+	log.Info().Msg("Shutdown complete")
+}
+
+// sleep waits for the given duration and returns false when the processor was stopped in the meantime.
+func sleep(d time.Duration, stop <-chan struct{}) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-stop:
+		return false
+	case <-t.C:
+		return true
 	}
+}
 
-	for _, t := range c.Targets.Emby {
-		tp, err := emby.New(t)
+// maintain maintains the datastore of the processor every interval until stopped.
+func maintain(proc *processor.Processor, interval time.Duration, stop <-chan struct{}) {
+	for sleep(interval, stop) {
+		result, err := proc.Maintain()
 		if err != nil {
-			log.Fatal().
+			log.Error().
 				Err(err).
-				Str("target", "emby").
-				Str("target_url", t.URL).
-				Msg("Failed initialising target")
+				Msg("Failed maintaining datastore")
+
+			continue
 		}
 
-		targets = append(targets, tp)
+		log.Info().
+			Int64("history", result.History).
+			Int64("failed", result.Failed).
+			Int64("orphans", result.Orphans).
+			Stringer("duration", result.Duration).
+			Msg("Datastore maintained")
 	}
+}
 
-	log.Info().
-		Int("plex", len(c.Targets.Plex)).
-		Int("emby", len(c.Targets.Emby)).
-		Msg("Initialised targets")
+// loopIntervals are the times processTarget waits before processing the next scan.
+type loopIntervals struct {
+	// scanDelay is the time between scans sent to the target
+	scanDelay time.Duration
+	// noScans is the time to wait when no scans are available
+	noScans time.Duration
+	// anchors is the time to wait when not all anchor files are available
+	anchors time.Duration
+	// availability is the time to wait when the target is unavailable
+	availability time.Duration
+}
 
-	// processor
-	log.Info().Msg("Processor started")
+// processTarget processes the queue of the target until stopped.
+// An unavailable target is checked again every availability interval,
+// without affecting the other targets.
+func processTarget(proc *processor.Processor, target autoscan.Target, targets []autoscan.Target, intervals loopIntervals, stop <-chan struct{}) {
+	l := log.With().Str("target", target.ID()).Logger()
 
-	targetsAvailable := false
+	targetAvailable := false
 
 	for {
-		if !targetsAvailable {
-			err = proc.CheckAvailability(targets)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if !targetAvailable {
+			err := proc.CheckAvailability([]autoscan.Target{target})
 			switch {
 			case err == nil:
-				targetsAvailable = true
+				targetAvailable = true
 			case errors.Is(err, autoscan.ErrFatal):
-				log.Error().
+				l.Error().
 					Err(err).
-					Msg("Fatal error occurred while checking target availability, processor stopped, triggers will continue...")
+					Msg("Fatal error occurred while checking target availability, processor stopped for target, triggers will continue...")
 
-				// sleep indefinitely
-				select {}
+				return
 			default:
-				log.Error().
+				l.Error().
 					Err(err).
-					Msg("Not all targets are available, retrying in 15 seconds...")
+					Msgf("Target is not available, retrying in %s...", intervals.availability)
+
+				if !sleep(intervals.availability, stop) {
+					return
+				}
 
-				time.Sleep(15 * time.Second)
 				continue
 			}
 		}
 
-		err = proc.Process(targets)
+		err := proc.Process(target, targets)
 		switch {
 		case err == nil:
 			// Sleep scan-delay between successful requests to reduce the load on targets.
-			time.Sleep(c.ScanDelay)
+			sleep(intervals.scanDelay, stop)
 
 		case errors.Is(err, autoscan.ErrNoScans):
 			// No scans currently available, let's wait a couple of seconds
-			log.Trace().
-				Msg("No scans are available, retrying in 15 seconds...")
+			l.Trace().
+				Msgf("No scans are available, retrying in %s...", intervals.noScans)
+
+			sleep(intervals.noScans, stop)
+
+		case errors.Is(err, autoscan.ErrPaused):
+			// Scans are held until the processor is resumed
+			l.Trace().
+				Msgf("Processor is paused, retrying in %s...", intervals.noScans)
 
-			time.Sleep(15 * time.Second)
+			sleep(intervals.noScans, stop)
 
 		case errors.Is(err, autoscan.ErrAnchorUnavailable):
-			log.Error().
+			l.Error().
 				Err(err).
-				Msg("Not all anchor files are available, retrying in 15 seconds...")
+				Msgf("Not all anchor files are available, retrying in %s...", intervals.anchors)
 
-			time.Sleep(15 * time.Second)
+			sleep(intervals.anchors, stop)
 
 		case errors.Is(err, autoscan.ErrTargetUnavailable):
-			targetsAvailable = false
-			log.Error().
+			targetAvailable = false
+			l.Error().
 				Err(err).
-				Msg("Not all targets are available, retrying in 15 seconds...")
+				Msgf("Target is not available, retrying in %s...", intervals.availability)
 
-			time.Sleep(15 * time.Second)
+			sleep(intervals.availability, stop)
 
 		case errors.Is(err, autoscan.ErrFatal):
 			// fatal error occurred, processor must stop (however, triggers must not)
-			log.Error().
+			l.Error().
 				Err(err).
-				Msg("Fatal error occurred while processing targets, processor stopped, triggers will continue...")
+				Msg("Fatal error occurred while processing target, processor stopped for target, triggers will continue...")
 
-			// sleep indefinitely
-			select {}
+			return
 
 		default:
 			// unexpected error
-			log.Fatal().
+			l.Fatal().
 				Err(err).
-				Msg("Failed processing targets")
+				Msg("Failed processing target")
 		}
 	}
 }
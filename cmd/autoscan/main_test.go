@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/processor"
+)
+
+// flakyTarget fails to scan the folders once, and scans them on the next attempt.
+type flakyTarget struct {
+	mtx     sync.Mutex
+	failed  map[string]bool
+	scanned []string
+}
+
+func (t *flakyTarget) ID() string                          { return "flaky" }
+func (t *flakyTarget) Available() error                    { return nil }
+func (t *flakyTarget) Capabilities() autoscan.Capabilities { return autoscan.Capabilities{} }
+
+func (t *flakyTarget) Scan(scan autoscan.Scan) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if !t.failed[scan.Folder] {
+		t.failed[scan.Folder] = true
+		return autoscan.ErrScanFailed
+	}
+
+	t.scanned = append(t.scanned, scan.Folder)
+	return nil
+}
+
+func (t *flakyTarget) Scanned() []string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return append([]string(nil), t.scanned...)
+}
+
+func TestProcessTargetRetries(t *testing.T) {
+	proc, err := processor.New(processor.Config{DatastorePath: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := &flakyTarget{failed: map[string]bool{"/tv/Wednesday": true}}
+	targets := []autoscan.Target{target}
+
+	// the first scan fails and is retried later, after which the loop continues with the next scan
+	testTime := time.Now().Add(-time.Minute)
+	err = proc.Add(
+		autoscan.Scan{Folder: "/tv/Westworld", Time: testTime},
+		autoscan.Scan{Folder: "/tv/Wednesday", Time: testTime.Add(time.Second)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intervals := loopIntervals{
+		scanDelay:    time.Millisecond,
+		noScans:      time.Millisecond,
+		anchors:      time.Millisecond,
+		availability: time.Millisecond,
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		processTarget(proc, target, targets, intervals, stop)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(target.Scanned()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(stop)
+	<-done
+
+	scanned := target.Scanned()
+	if len(scanned) != 1 || scanned[0] != "/tv/Wednesday" {
+		t.Errorf("Scanned folders do not match: %v vs %v", scanned, []string{"/tv/Wednesday"})
+	}
+
+	failed, err := proc.Failed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failed) != 0 {
+		t.Errorf("Expected the failed scan to be retried: %+v", failed)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+// maintenanceCmd is database prune followed by a vacuum of the datastore,
+// which is the maintenance autoscan runs on its interval.
+type maintenanceCmd struct {
+	databasePruneCmd
+}
+
+// run prunes the datastore like database prune, and vacuums it afterwards.
+func (c maintenanceCmd) run(datastore processor.Config) error {
+	proc, err := c.prune(datastore)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err := proc.Vacuum(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Vacuumed the datastore in %s\n", time.Since(start))
+	return nil
+}
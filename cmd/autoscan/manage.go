@@ -0,0 +1,434 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/cloudbox/autoscan/api"
+	"github.com/cloudbox/autoscan/targets/emby"
+	"github.com/cloudbox/autoscan/targets/plex"
+	"github.com/cloudbox/autoscan/triggers/lidarr"
+	"github.com/cloudbox/autoscan/triggers/radarr"
+	"github.com/cloudbox/autoscan/triggers/sonarr"
+)
+
+// managedFile is the file, next to the config file, which holds the triggers and targets managed with the API.
+// It is merged into the config like an included file.
+const managedFile = "managed.yml"
+
+// disabledConfig holds the names of the webhook triggers and the IDs of the targets which are not started.
+type disabledConfig struct {
+	Triggers []string `yaml:"triggers,omitempty"`
+	Targets  []string `yaml:"targets,omitempty"`
+}
+
+// managedConfig is the content of the managed file.
+// The triggers and targets are kept as they were added, keyed by their section and type.
+type managedConfig struct {
+	Triggers map[string][]yaml.MapSlice `yaml:"triggers,omitempty"`
+	Targets  map[string][]yaml.MapSlice `yaml:"targets,omitempty"`
+	Disabled disabledConfig             `yaml:"disabled,omitempty"`
+}
+
+// managedKinds are the types of triggers and targets which can be managed with the API,
+// with the config key which identifies them.
+var managedKinds = map[string]map[string]struct {
+	config func() interface{}
+	key    string
+}{
+	api.SectionTriggers: {
+		"lidarr": {func() interface{} { return &lidarr.Config{} }, "name"},
+		"radarr": {func() interface{} { return &radarr.Config{} }, "name"},
+		"sonarr": {func() interface{} { return &sonarr.Config{} }, "name"},
+	},
+	api.SectionTargets: {
+		"plex": {func() interface{} { return &plex.Config{} }, "url"},
+		"emby": {func() interface{} { return &emby.Config{} }, "url"},
+	},
+}
+
+// managedPath returns the path of the managed file of the config file.
+func managedPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), managedFile)
+}
+
+// includeManaged merges the managed file of the config file into the config, when it exists.
+func includeManaged(c *config, path string) error {
+	path = managedPath(path)
+
+	var fragment config
+	if err := decodeConfig(path, &fragment); err != nil && !os.IsNotExist(err) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%v: %w", managedFile, err)
+	}
+
+	if len(fragment.Include) > 0 {
+		return fmt.Errorf("%v: the managed file cannot include other files", managedFile)
+	}
+
+	mergeConfig(reflect.ValueOf(c).Elem(), reflect.ValueOf(fragment))
+	return nil
+}
+
+// A manager adds, disables and removes webhook triggers and targets at runtime.
+// Every change is written to the managed file, after which the config is reloaded.
+// The change is reverted when the config fails to reload.
+type manager struct {
+	mtx  sync.Mutex
+	path string
+
+	// reloads receives the reload requests, which are answered with the error of the reload
+	reloads chan chan error
+	stopped chan struct{}
+}
+
+func newManager(configPath string) *manager {
+	return &manager{
+		path:    configPath,
+		reloads: make(chan chan error),
+		stopped: make(chan struct{}),
+	}
+}
+
+// stop rejects the changes which are made after autoscan started shutting down.
+func (m *manager) stop() {
+	close(m.stopped)
+}
+
+func (m *manager) Components(section string) ([]api.Component, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	c, managed, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return components(c, managed, section)
+}
+
+func (m *manager) Add(section string, kind string, data []byte) (api.Component, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	k, ok := managedKinds[section][kind]
+	if !ok {
+		return api.Component{}, fmt.Errorf("%w: unsupported type %q", api.ErrInvalidComponent, kind)
+	}
+
+	// the component is validated on its own, to report the problems without the path of the managed file
+	if err := validateConfig(data, k.config()); err != nil {
+		return api.Component{}, fmt.Errorf("%w: %v", api.ErrInvalidComponent, err)
+	}
+
+	item := yaml.MapSlice{}
+	if err := yaml.Unmarshal(data, &item); err != nil {
+		return api.Component{}, fmt.Errorf("%w: %v", api.ErrInvalidComponent, err)
+	}
+
+	name := componentName(section, kind, item)
+	if name == "" || name == kind+":" {
+		return api.Component{}, fmt.Errorf("%w: %s is required", api.ErrInvalidComponent, k.key)
+	}
+
+	c, managed, err := m.load()
+	if err != nil {
+		return api.Component{}, err
+	}
+
+	current, err := components(c, managed, section)
+	if err != nil {
+		return api.Component{}, err
+	}
+
+	for _, component := range current {
+		if component.Name == name {
+			return api.Component{}, fmt.Errorf("%w: %s already exists", api.ErrComponentConflict, name)
+		}
+	}
+
+	// the manual trigger is served at /triggers/manual
+	if section == api.SectionTriggers && name == "manual" {
+		return api.Component{}, fmt.Errorf("%w: %s is reserved", api.ErrComponentConflict, name)
+	}
+
+	items := managed.section(section)
+	if *items == nil {
+		*items = make(map[string][]yaml.MapSlice)
+	}
+
+	(*items)[kind] = append((*items)[kind], item)
+	if err := m.apply(managed); err != nil {
+		return api.Component{}, err
+	}
+
+	return api.Component{Name: name, Type: kind, Enabled: true, Managed: true}, nil
+}
+
+func (m *manager) Enable(section string, name string, enabled bool) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	c, managed, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	if _, err := findComponent(c, managed, section, name); err != nil {
+		return err
+	}
+
+	disabled := managed.Disabled.section(section)
+	if enabled && containsString(*c.Disabled.section(section), name) && !containsString(*disabled, name) {
+		// the managed file cannot enable what the config file disables
+		return fmt.Errorf("%w: %s is disabled in the config file", api.ErrComponentConflict, name)
+	}
+
+	*disabled = removeString(*disabled, name)
+	if !enabled {
+		*disabled = append(*disabled, name)
+	}
+
+	return m.apply(managed)
+}
+
+func (m *manager) Remove(section string, name string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	c, managed, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	component, err := findComponent(c, managed, section, name)
+	if err != nil {
+		return err
+	}
+
+	if !component.Managed {
+		return fmt.Errorf("%w: %s is defined in the config file", api.ErrComponentConflict, name)
+	}
+
+	items := *managed.section(section)
+	for i, item := range items[component.Type] {
+		if componentName(section, component.Type, item) == name {
+			items[component.Type] = append(items[component.Type][:i], items[component.Type][i+1:]...)
+			break
+		}
+	}
+
+	if len(items[component.Type]) == 0 {
+		delete(items, component.Type)
+	}
+
+	disabled := managed.Disabled.section(section)
+	*disabled = removeString(*disabled, name)
+
+	return m.apply(managed)
+}
+
+// load returns the config, including the managed file, and the managed file on its own.
+func (m *manager) load() (config, managedConfig, error) {
+	var managed managedConfig
+
+	c, err := loadConfig(m.path)
+	if err != nil {
+		return c, managed, err
+	}
+
+	data, err := ioutil.ReadFile(managedPath(m.path))
+	if err != nil && !os.IsNotExist(err) {
+		return c, managed, err
+	}
+
+	if err := yaml.Unmarshal(data, &managed); err != nil {
+		return c, managed, fmt.Errorf("%v: %w", managedFile, err)
+	}
+
+	return c, managed, nil
+}
+
+// apply writes the managed file and reloads the config.
+// The previous managed file is restored when the config fails to reload.
+func (m *manager) apply(managed managedConfig) error {
+	path := managedPath(m.path)
+
+	previous, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	data, err := yaml.Marshal(managed)
+	if err != nil {
+		return err
+	}
+
+	// the managed file may contain the tokens of the targets
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+
+	if err := m.reload(); err != nil {
+		if previous == nil {
+			_ = os.Remove(path)
+		} else {
+			_ = ioutil.WriteFile(path, previous, 0600)
+		}
+
+		return fmt.Errorf("%w: %v", api.ErrInvalidComponent, err)
+	}
+
+	return nil
+}
+
+// reload requests a reload of the config, and waits for its result.
+func (m *manager) reload() error {
+	done := make(chan error, 1)
+	select {
+	case m.reloads <- done:
+		return <-done
+	case <-m.stopped:
+		return errors.New("autoscan is shutting down")
+	}
+}
+
+func (m *managedConfig) section(section string) *map[string][]yaml.MapSlice {
+	if section == api.SectionTargets {
+		return &m.Targets
+	}
+
+	return &m.Triggers
+}
+
+func (d *disabledConfig) section(section string) *[]string {
+	if section == api.SectionTargets {
+		return &d.Targets
+	}
+
+	return &d.Triggers
+}
+
+// components returns the webhook triggers or the targets of the config.
+func components(c config, managed managedConfig, section string) ([]api.Component, error) {
+	type entry struct {
+		kind string
+		name string
+	}
+
+	entries := make([]entry, 0)
+	switch section {
+	case api.SectionTriggers:
+		for _, t := range c.Triggers.Lidarr {
+			entries = append(entries, entry{"lidarr", t.Name})
+		}
+		for _, t := range c.Triggers.Radarr {
+			entries = append(entries, entry{"radarr", t.Name})
+		}
+		for _, t := range c.Triggers.Sonarr {
+			entries = append(entries, entry{"sonarr", t.Name})
+		}
+
+	case api.SectionTargets:
+		for _, t := range c.Targets.Plex {
+			entries = append(entries, entry{"plex", "plex:" + t.URL})
+		}
+		for _, t := range c.Targets.Emby {
+			entries = append(entries, entry{"emby", "emby:" + t.URL})
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: unknown section %q", api.ErrUnknownComponent, section)
+	}
+
+	result := make([]api.Component, 0, len(entries))
+	for _, e := range entries {
+		component := api.Component{
+			Name:    e.name,
+			Type:    e.kind,
+			Enabled: !containsString(*c.Disabled.section(section), e.name),
+		}
+
+		for _, item := range (*managed.section(section))[e.kind] {
+			if componentName(section, e.kind, item) == e.name {
+				component.Managed = true
+			}
+		}
+
+		result = append(result, component)
+	}
+
+	return result, nil
+}
+
+// findComponent returns the webhook trigger or target with the name.
+func findComponent(c config, managed managedConfig, section string, name string) (api.Component, error) {
+	current, err := components(c, managed, section)
+	if err != nil {
+		return api.Component{}, err
+	}
+
+	for _, component := range current {
+		if component.Name == name {
+			return component, nil
+		}
+	}
+
+	return api.Component{}, fmt.Errorf("%w: %s", api.ErrUnknownComponent, name)
+}
+
+// componentName returns the name of a webhook trigger, or the ID of a target, of the managed file.
+func componentName(section string, kind string, item yaml.MapSlice) string {
+	key := managedKinds[section][kind].key
+
+	var value string
+	for _, field := range item {
+		if field.Key == key {
+			value = fmt.Sprint(field.Value)
+		}
+	}
+
+	if section == api.SectionTargets {
+		return kind + ":" + value
+	}
+
+	return value
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func removeString(list []string, s string) []string {
+	result := list[:0]
+	for _, item := range list {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// set returns the disabled names of the section as a set.
+func (d disabledConfig) set(section string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range *d.section(section) {
+		set[name] = true
+	}
+
+	return set
+}
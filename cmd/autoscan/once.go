@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/processor"
+)
+
+// The exit codes of autoscan --once.
+// Failures to start exit with 1, like the other commands.
+const (
+	// exitProcessed indicates that the targets processed all available scans.
+	exitProcessed = 0
+	// exitTargetFailed indicates that a target was unavailable or failed, and its scans remain queued.
+	exitTargetFailed = 2
+	// exitPaused indicates that the processor is paused, and the scans remain queued.
+	exitPaused = 3
+)
+
+// errInterrupted indicates that processing was interrupted by a signal.
+var errInterrupted = errors.New("interrupted")
+
+// runOnce processes the queue of every target until no scans are available, or until the target fails.
+// The triggers are not started, such that the queue only shrinks.
+// Scans which are not yet available, e.g. those younger than the minimum age, remain queued for the next run.
+// It closes the processor and returns the exit code.
+func runOnce(proc *processor.Processor, svc *services) int {
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+		sig := <-signals
+		log.Info().Stringer("signal", sig).Msg("Stopping after the in-flight scans")
+		close(stop)
+	}()
+
+	var mtx sync.Mutex
+	errs := make([]error, 0)
+	wg := new(sync.WaitGroup)
+
+	for _, target := range svc.targets {
+		intervals := svc.intervals
+		if delay, ok := svc.scanDelays[target.ID()]; ok {
+			intervals.scanDelay = delay
+		}
+
+		wg.Add(1)
+		go func(target autoscan.Target) {
+			defer wg.Done()
+			if err := processTargetOnce(proc, target, svc.targets, intervals, stop); err != nil {
+				mtx.Lock()
+				errs = append(errs, err)
+				mtx.Unlock()
+			}
+		}(target)
+	}
+
+	wg.Wait()
+
+	code := exitProcessed
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, autoscan.ErrPaused):
+			if code == exitProcessed {
+				code = exitPaused
+			}
+		default:
+			code = exitTargetFailed
+		}
+	}
+
+	if stats, err := proc.DatabaseStats(); err == nil {
+		log.Info().
+			Int("remaining", stats.Queued).
+			Int("exit_code", code).
+			Msg("Processed the queue")
+	}
+
+	if err := proc.Close(); err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed closing processor")
+	}
+
+	return code
+}
+
+// processTargetOnce processes the queue of the target until no scans are available for the target.
+// It returns the error which stopped the target before its queue was processed.
+func processTargetOnce(proc *processor.Processor, target autoscan.Target, targets []autoscan.Target, intervals loopIntervals, stop <-chan struct{}) error {
+	l := log.With().Str("target", target.ID()).Logger()
+
+	if err := proc.CheckAvailability([]autoscan.Target{target}); err != nil {
+		l.Error().
+			Err(err).
+			Msg("Target is not available, its scans remain queued")
+
+		return err
+	}
+
+	for {
+		select {
+		case <-stop:
+			return errInterrupted
+		default:
+		}
+
+		err := proc.Process(target, targets)
+		switch {
+		case err == nil:
+			// Sleep scan-delay between successful requests to reduce the load on targets.
+			if !sleep(intervals.scanDelay, stop) {
+				return errInterrupted
+			}
+
+		case errors.Is(err, autoscan.ErrNoScans):
+			l.Debug().Msg("No more scans are available")
+			return nil
+
+		case errors.Is(err, autoscan.ErrPaused):
+			l.Warn().Msg("Processor is paused, scans remain queued")
+			return err
+
+		default:
+			l.Error().
+				Err(err).
+				Msg("Failed processing target, its scans remain queued")
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+type pauseCmd struct{}
+
+// run pauses the processor of the database.
+// A running autoscan picks up the pause before sending its next scan.
+func (c pauseCmd) run(datastore processor.Config) error {
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	if err := proc.Pause(); err != nil {
+		return err
+	}
+
+	status, err := proc.PauseStatus()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Processor paused since %s\n", status.Since.Local().Format(time.Stamp))
+	return nil
+}
+
+type resumeCmd struct{}
+
+// run resumes the processor of the database.
+func (c resumeCmd) run(datastore processor.Config) error {
+	proc, err := processor.New(datastore)
+	if err != nil {
+		return err
+	}
+
+	if err := proc.Resume(); err != nil {
+		return err
+	}
+
+	fmt.Println("Processor resumed")
+	return nil
+}
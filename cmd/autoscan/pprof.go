@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// startPprof serves the profiles of net/http/pprof on the pprof port, separate from the webhooks and the API.
+// It returns nil when pprof is disabled.
+func startPprof(c config) *http.Server {
+	if c.Pprof.Port <= 0 {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	srv := &http.Server{
+		Addr:    net.JoinHostPort(c.Pprof.Host, strconv.Itoa(c.Pprof.Port)),
+		Handler: mux,
+	}
+
+	go func() {
+		log.Warn().Msgf("Serving pprof on %s, do not expose it publicly", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().
+				Err(err).
+				Msg("Failed serving pprof")
+		}
+	}()
+
+	return srv
+}
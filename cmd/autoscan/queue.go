@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudbox/autoscan/api"
+	"github.com/cloudbox/autoscan/processor"
+	"github.com/rs/zerolog/log"
+)
+
+// queue is implemented by the processor of the database,
+// and by the API of a running autoscan.
+type queue interface {
+	Queue() ([]processor.QueueEntry, error)
+	Flush() (int, error)
+	RetryNow(ids ...string) (int, error)
+	Remove(ids ...string) (int, error)
+}
+
+// openQueue uses the API of autoscan when it is running,
+// as the datastore might not be accessible while autoscan is running.
+// Otherwise, the queue is read from the database.
+func openQueue() (queue, error) {
+	client, err := newAPIClient(cli.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.running(api.QueuePath) {
+		log.Debug().Str("addr", client.addrOf(api.QueuePath)).Msg("Using the API of the running autoscan")
+		return client, nil
+	}
+
+	proc, err := processor.New(datastoreConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return proc, nil
+}
+
+type queueListCmd struct{}
+
+// run prints the scans in the queue.
+func (c queueListCmd) run() error {
+	q, err := openQueue()
+	if err != nil {
+		return err
+	}
+
+	entries, err := q.Queue()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No queued scans")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPRIORITY\tTRIGGER\tTARGETS\tQUEUED AT\tFOLDER")
+	for _, e := range entries {
+		targets := strings.Join(e.Targets, ",")
+		if targets == "" {
+			targets = "*"
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n",
+			e.ID, e.Priority, e.Trigger, targets, e.Time.Local().Format(time.Stamp), e.Folder)
+	}
+
+	return w.Flush()
+}
+
+type queueFlushCmd struct{}
+
+// run removes all scans from the queue.
+func (c queueFlushCmd) run() error {
+	q, err := openQueue()
+	if err != nil {
+		return err
+	}
+
+	flushed, err := q.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %d queued scans\n", flushed)
+	return nil
+}
+
+type queueRetryCmd struct {
+	IDs []string `arg:"" optional:"" name:"id" help:"IDs of the queued scans to retry"`
+	All bool     `help:"Retry all queued scans"`
+}
+
+// run retries queued scans without waiting for the backoff of their targets.
+func (c queueRetryCmd) run() error {
+	if len(c.IDs) == 0 && !c.All {
+		return errors.New("no ids given, use --all to retry all queued scans")
+	}
+
+	q, err := openQueue()
+	if err != nil {
+		return err
+	}
+
+	ids := c.IDs
+	if c.All {
+		entries, err := q.Queue()
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		fmt.Println("No queued scans")
+		return nil
+	}
+
+	retried, err := q.RetryNow(ids...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Retrying %d queued scans\n", retried)
+	return nil
+}
+
+type queueDeleteCmd struct {
+	IDs []string `arg:"" name:"id" help:"IDs of the queued scans to delete"`
+}
+
+// run removes scans from the queue.
+func (c queueDeleteCmd) run() error {
+	q, err := openQueue()
+	if err != nil {
+		return err
+	}
+
+	deleted, err := q.Remove(c.IDs...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %d queued scans\n", deleted)
+	return nil
+}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/api"
+	"github.com/cloudbox/autoscan/processor"
+	"github.com/cloudbox/autoscan/targets/emby"
+	"github.com/cloudbox/autoscan/targets/plex"
+	"github.com/cloudbox/autoscan/triggers"
+	"github.com/cloudbox/autoscan/triggers/bernard"
+	"github.com/cloudbox/autoscan/triggers/inotify"
+	"github.com/cloudbox/autoscan/triggers/lidarr"
+	"github.com/cloudbox/autoscan/triggers/manual"
+	"github.com/cloudbox/autoscan/triggers/radarr"
+	"github.com/cloudbox/autoscan/triggers/sonarr"
+	"github.com/cloudbox/autoscan/ui"
+)
+
+// services are the triggers, targets and routes of a config,
+// which are replaced when the config is reloaded.
+// The processor, and thereby the queue, outlives the services.
+type services struct {
+	mux         *http.ServeMux
+	admin       *http.ServeMux
+	daemons     []func(stop <-chan struct{})
+	targets     []autoscan.Target
+	scanDelays  map[string]time.Duration
+	intervals   loopIntervals
+	maintenance time.Duration
+	manager     api.Manager
+	lockouts    *triggers.LockoutState
+
+	stop chan struct{}
+	wg   *sync.WaitGroup
+}
+
+// newServices initialises the triggers and targets of the config without starting them.
+// The triggers and targets are managed with the API when the manager is not nil.
+// The failures and bans of the lockout are recorded in the lockouts, which outlive the services.
+func newServices(c config, proc *processor.Processor, mgr api.Manager, lockouts *triggers.LockoutState) (*services, error) {
+	s := &services{
+		mux:        http.NewServeMux(),
+		targets:    make([]autoscan.Target, 0),
+		scanDelays: make(map[string]time.Duration),
+		intervals: loopIntervals{
+			scanDelay:    c.ScanDelay,
+			noScans:      c.PollInterval,
+			anchors:      c.AnchorInterval,
+			availability: c.Availability.Interval,
+		},
+		maintenance: c.Maintenance.Interval,
+		manager:     mgr,
+		lockouts:    lockouts,
+		stop:        make(chan struct{}),
+		wg:          new(sync.WaitGroup),
+	}
+
+	// the API and the dashboard are served by the admin listener when there is one
+	s.admin = s.mux
+	if c.Admin.Port > 0 {
+		s.admin = http.NewServeMux()
+	}
+
+	accessLog := func(route string, trigger string) func(http.Handler) http.Handler {
+		return triggers.WithAccessLog(accessLogger, route, trigger)
+	}
+
+	// Set authentication. If none and running at least one webhook without API key or signature -> warn user.
+	// The failures to authenticate count for every route, of which the responses are delayed.
+	// The failures, accepted webhooks and changes through the API are recorded in the audit log.
+	users := authUsers(c)
+	lockout := triggers.WithLockout(c.Lockout, lockouts)
+	audit := func(trigger string) func(http.Handler) http.Handler {
+		return triggers.WithAudit(trigger, func(e processor.AuditEntry) {
+			if err := proc.Audit(e); err != nil {
+				log.Error().
+					Err(err).
+					Str("event", e.Event).
+					Msg("Failed recording audit entry")
+			}
+		})
+	}
+
+	authHandler := func(next http.Handler) http.Handler {
+		return audit("")(lockout(triggers.WithAuth(triggers.Credentials{Users: users, Admin: true})(next)))
+	}
+	triggerAuth := func(trigger string, apiKey string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return audit(trigger)(lockout(triggers.WithAuth(triggers.Credentials{Users: users, APIKey: apiKey})(next)))
+		}
+	}
+
+	if c.Auth.Password != "" {
+		log.Warn().Msg("The password of the authentication is not hashed, consider a user with a password-hash instead")
+	}
+
+	if len(users) > 0 && !hasAdmin(users) {
+		log.Warn().Msg("The API and the dashboard cannot be used without a user of the admin role")
+	}
+
+	if len(users) == 0 && unauthenticatedWebhooks(c) {
+		log.Warn().Msg("Webhooks running without authentication")
+	}
+
+	// Daemon Triggers
+	for _, t := range c.Triggers.Bernard {
+		if t.DatastorePath == "" {
+			t.DatastorePath = cli.Database
+			if c.Ephemeral {
+				t.DatastorePath = ":memory:"
+			}
+		}
+
+		t.SQLite = c.SQLite
+
+		trigger, err := bernard.New(t)
+		if err != nil {
+			return nil, fmt.Errorf("trigger bernard: %w", err)
+		}
+
+		s.daemons = append(s.daemons, func(stop <-chan struct{}) {
+			trigger(withTrigger("bernard", proc.Add), stop)
+		})
+	}
+
+	for _, t := range c.Triggers.Inotify {
+		trigger, err := inotify.New(t)
+		if err != nil {
+			return nil, fmt.Errorf("trigger inotify: %w", err)
+		}
+
+		s.daemons = append(s.daemons, func(stop <-chan struct{}) {
+			trigger(withTrigger("inotify", proc.Add), stop)
+		})
+	}
+
+	// HTTP Triggers
+	manualConfig := c.Triggers.Manual
+	manualConfig.BasePath = c.BasePath
+
+	manualTrigger, err := manual.New(manualConfig)
+	if err != nil {
+		return nil, fmt.Errorf("trigger manual: %w", err)
+	}
+
+	logHandler := triggers.WithLogger(autoscan.GetLogger(c.Triggers.Manual.Verbosity))
+	rateLimit := triggers.WithRateLimit(c.RateLimit.limits("manual"))
+	requestLimits := triggers.WithLimits(c.RequestLimits.limits("manual"))
+	dedup := triggers.WithDeduplication(c.Deduplication.window("manual"))
+	ipFilter, err := triggers.WithIPFilter(c.IPFilter.filter("manual"))
+	if err != nil {
+		return nil, fmt.Errorf("trigger manual: ip-filter: %w", err)
+	}
+
+	signature, err := triggers.WithSignature(c.Triggers.Manual.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("trigger manual: signature: %w", err)
+	}
+
+	s.mux.Handle("/triggers/manual", logHandler(accessLog("/triggers/manual", "manual")(ipFilter(rateLimit(triggerAuth("manual", c.Triggers.Manual.APIKey)(requestLimits(signature(dedup(manualTrigger(withTrigger("manual", proc.Add)))))))))))
+
+	// API, of which the preflight requests of browsers are not authenticated
+	cors := triggers.WithCORS(c.CORS)
+	s.admin.Handle("/api/", logHandler(accessLog("/api/", "")(cors(authHandler(api.New(proc))))))
+
+	// the triggers and targets can only be managed by authenticated clients
+	if mgr != nil && hasAdmin(users) {
+		manage := api.NewManagement(mgr)
+		for _, route := range []string{api.TriggersPath, api.TriggersPath + "/", api.TargetsPath, api.TargetsPath + "/"} {
+			s.admin.Handle(route, logHandler(accessLog(route, "")(cors(authHandler(manage)))))
+		}
+	} else if mgr != nil {
+		log.Debug().Msg("Managing triggers and targets requires authentication")
+	}
+
+	disabledTriggers := c.Disabled.set(api.SectionTriggers)
+	disabledTargets := c.Disabled.set(api.SectionTargets)
+
+	for _, t := range c.Triggers.Lidarr {
+		if disabledTriggers[t.Name] {
+			log.Info().Str("trigger", t.Name).Msg("Trigger disabled")
+			continue
+		}
+
+		trigger, err := lidarr.New(t)
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: %w", t.Name, err)
+		}
+
+		logHandler := triggers.WithLogger(autoscan.GetLogger(t.Verbosity))
+		rateLimit := triggers.WithRateLimit(c.RateLimit.limits(t.Name))
+		requestLimits := triggers.WithLimits(c.RequestLimits.limits(t.Name))
+		dedup := triggers.WithDeduplication(c.Deduplication.window(t.Name))
+		ipFilter, err := triggers.WithIPFilter(c.IPFilter.filter(t.Name))
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: ip-filter: %w", t.Name, err)
+		}
+
+		signature, err := triggers.WithSignature(t.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: signature: %w", t.Name, err)
+		}
+
+		s.mux.Handle("/triggers/"+t.Name, logHandler(accessLog("/triggers/"+t.Name, t.Name)(ipFilter(rateLimit(triggerAuth(t.Name, t.APIKey)(requestLimits(signature(dedup(trigger(withTrigger(t.Name, proc.Add)))))))))))
+	}
+
+	for _, t := range c.Triggers.Radarr {
+		if disabledTriggers[t.Name] {
+			log.Info().Str("trigger", t.Name).Msg("Trigger disabled")
+			continue
+		}
+
+		trigger, err := radarr.New(t)
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: %w", t.Name, err)
+		}
+
+		logHandler := triggers.WithLogger(autoscan.GetLogger(t.Verbosity))
+		rateLimit := triggers.WithRateLimit(c.RateLimit.limits(t.Name))
+		requestLimits := triggers.WithLimits(c.RequestLimits.limits(t.Name))
+		dedup := triggers.WithDeduplication(c.Deduplication.window(t.Name))
+		ipFilter, err := triggers.WithIPFilter(c.IPFilter.filter(t.Name))
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: ip-filter: %w", t.Name, err)
+		}
+
+		signature, err := triggers.WithSignature(t.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: signature: %w", t.Name, err)
+		}
+
+		s.mux.Handle("/triggers/"+t.Name, logHandler(accessLog("/triggers/"+t.Name, t.Name)(ipFilter(rateLimit(triggerAuth(t.Name, t.APIKey)(requestLimits(signature(dedup(trigger(withTrigger(t.Name, proc.Add)))))))))))
+	}
+
+	for _, t := range c.Triggers.Sonarr {
+		if disabledTriggers[t.Name] {
+			log.Info().Str("trigger", t.Name).Msg("Trigger disabled")
+			continue
+		}
+
+		trigger, err := sonarr.New(t)
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: %w", t.Name, err)
+		}
+
+		logHandler := triggers.WithLogger(autoscan.GetLogger(t.Verbosity))
+		rateLimit := triggers.WithRateLimit(c.RateLimit.limits(t.Name))
+		requestLimits := triggers.WithLimits(c.RequestLimits.limits(t.Name))
+		dedup := triggers.WithDeduplication(c.Deduplication.window(t.Name))
+		ipFilter, err := triggers.WithIPFilter(c.IPFilter.filter(t.Name))
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: ip-filter: %w", t.Name, err)
+		}
+
+		signature, err := triggers.WithSignature(t.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: signature: %w", t.Name, err)
+		}
+
+		s.mux.Handle("/triggers/"+t.Name, logHandler(accessLog("/triggers/"+t.Name, t.Name)(ipFilter(rateLimit(triggerAuth(t.Name, t.APIKey)(requestLimits(signature(dedup(trigger(withTrigger(t.Name, proc.Add)))))))))))
+	}
+
+	log.Info().
+		Int("manual", 1).
+		Int("bernard", len(c.Triggers.Bernard)).
+		Int("inotify", len(c.Triggers.Inotify)).
+		Int("lidarr", len(c.Triggers.Lidarr)).
+		Int("sonarr", len(c.Triggers.Sonarr)).
+		Int("radarr", len(c.Triggers.Radarr)).
+		Int("disabled", len(disabledTriggers)).
+		Msg("Initialised triggers")
+
+	// targets may override the global scan delay
+	for _, t := range c.Targets.Plex {
+		if disabledTargets["plex:"+t.URL] {
+			log.Info().Str("target", "plex:"+t.URL).Msg("Target disabled")
+			continue
+		}
+
+		tp, err := plex.New(t)
+		if err != nil {
+			return nil, fmt.Errorf("target plex: %v: %w", t.URL, err)
+		}
+
+		if t.ScanDelay != nil {
+			s.scanDelays[tp.ID()] = *t.ScanDelay
+		}
+
+		s.targets = append(s.targets, tp)
+	}
+
+	for _, t := range c.Targets.Emby {
+		if disabledTargets["emby:"+t.URL] {
+			log.Info().Str("target", "emby:"+t.URL).Msg("Target disabled")
+			continue
+		}
+
+		tp, err := emby.New(t)
+		if err != nil {
+			return nil, fmt.Errorf("target emby: %v: %w", t.URL, err)
+		}
+
+		if t.ScanDelay != nil {
+			s.scanDelays[tp.ID()] = *t.ScanDelay
+		}
+
+		s.targets = append(s.targets, tp)
+	}
+
+	log.Info().
+		Int("plex", len(c.Targets.Plex)).
+		Int("emby", len(c.Targets.Emby)).
+		Int("disabled", len(disabledTargets)).
+		Msg("Initialised targets")
+
+	if len(s.targets) == 0 {
+		log.Warn().Msg("No targets configured, scans will remain queued")
+	}
+
+	// the probes of Docker and Kubernetes do not authenticate
+	ids := make([]string, 0, len(s.targets))
+	for _, t := range s.targets {
+		ids = append(ids, t.ID())
+	}
+
+	health := api.NewHealth(proc, ids)
+	s.mux.Handle(api.HealthPath, health)
+	s.mux.Handle(api.ReadyPath, health)
+	if s.admin != s.mux {
+		s.admin.Handle(api.HealthPath, health)
+		s.admin.Handle(api.ReadyPath, health)
+	}
+
+	status := api.NewServerStatus(proc, api.ServerInfo{Version: Version, Started: started, Targets: ids})
+	s.admin.Handle(api.ServerStatusPath, logHandler(accessLog(api.ServerStatusPath, "")(cors(authHandler(status)))))
+
+	events := api.NewEvents(proc, streamDuration(c.Server.WriteTimeout))
+	s.admin.Handle(api.EventsPath, logHandler(accessLog(api.EventsPath, "")(cors(authHandler(events)))))
+
+	// the dashboard also redirects the root, and is therefore not limited to its path
+	s.admin.Handle("/", logHandler(authHandler(ui.New())))
+
+	return s, nil
+}
+
+// rateLimitConfig holds the rate limits of every trigger route,
+// which are replaced by the limits of the trigger of the same name.
+type rateLimitConfig struct {
+	Route    triggers.RateLimit             `yaml:"route"`
+	IP       triggers.RateLimit             `yaml:"ip"`
+	Triggers map[string]triggers.RateLimits `yaml:"triggers"`
+}
+
+func (c rateLimitConfig) limits(trigger string) triggers.RateLimits {
+	if limits, ok := c.Triggers[trigger]; ok {
+		return limits
+	}
+
+	return triggers.RateLimits{Route: c.Route, IP: c.IP}
+}
+
+// authUsers returns the users of the authentication,
+// including the username and password which predate the users.
+func authUsers(c config) []triggers.User {
+	users := make([]triggers.User, 0, len(c.Auth.Users)+1)
+	if c.Auth.Username != "" && c.Auth.Password != "" {
+		users = append(users, triggers.User{Username: c.Auth.Username, Password: c.Auth.Password, Role: c.Auth.Role})
+	}
+
+	return append(users, c.Auth.Users...)
+}
+
+// hasAdmin returns whether one of the users has the admin role.
+func hasAdmin(users []triggers.User) bool {
+	for _, u := range users {
+		if u.Admin() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unauthenticatedWebhooks returns whether one of the Radarr and Sonarr webhooks
+// has neither an API key nor a signature secret.
+func unauthenticatedWebhooks(c config) bool {
+	for _, t := range c.Triggers.Radarr {
+		if t.APIKey == "" && t.Signature.Secret == "" {
+			return true
+		}
+	}
+
+	for _, t := range c.Triggers.Sonarr {
+		if t.APIKey == "" && t.Signature.Secret == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestLimitsConfig holds the request limits of every trigger route,
+// of which the limits set by the trigger of the same name take precedence.
+type requestLimitsConfig struct {
+	MaxBodySize int64                      `yaml:"max-body-size"`
+	Timeout     time.Duration              `yaml:"timeout"`
+	Triggers    map[string]triggers.Limits `yaml:"triggers"`
+}
+
+func (c requestLimitsConfig) limits(trigger string) triggers.Limits {
+	limits := c.Triggers[trigger]
+	if limits.MaxBodySize <= 0 {
+		limits.MaxBodySize = c.MaxBodySize
+	}
+
+	if limits.Timeout <= 0 {
+		limits.Timeout = c.Timeout
+	}
+
+	return limits
+}
+
+// ipFilterConfig holds the IP filter of every trigger route,
+// which is replaced by the filter of the trigger of the same name.
+type ipFilterConfig struct {
+	Allow    []string                     `yaml:"allow"`
+	Deny     []string                     `yaml:"deny"`
+	Triggers map[string]triggers.IPFilter `yaml:"triggers"`
+}
+
+func (c ipFilterConfig) filter(trigger string) triggers.IPFilter {
+	if f, ok := c.Triggers[trigger]; ok {
+		return f
+	}
+
+	return triggers.IPFilter{Allow: c.Allow, Deny: c.Deny}
+}
+
+// deduplicationConfig holds the window in which identical requests to a trigger route are deduplicated,
+// which is replaced by the window of the trigger of the same name.
+type deduplicationConfig struct {
+	Window   time.Duration            `yaml:"window"`
+	Triggers map[string]time.Duration `yaml:"triggers"`
+}
+
+func (c deduplicationConfig) window(trigger string) time.Duration {
+	if window, ok := c.Triggers[trigger]; ok {
+		return window
+	}
+
+	return c.Window
+}
+
+// streamDuration returns the duration after which an event stream is closed,
+// before the write timeout of the server would break off the connection.
+func streamDuration(writeTimeout time.Duration) time.Duration {
+	if writeTimeout <= 0 {
+		return 0
+	}
+
+	return writeTimeout - writeTimeout/10
+}
+
+// start starts the daemon triggers, the queues of the targets and the maintenance of the datastore.
+func (s *services) start(proc *processor.Processor) {
+	for _, daemon := range s.daemons {
+		s.wg.Add(1)
+		go func(daemon func(<-chan struct{})) {
+			defer s.wg.Done()
+			daemon(s.stop)
+		}(daemon)
+	}
+
+	// only the unavailable targets wait for their next availability check
+	if err := proc.CheckAvailability(s.targets); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("Not all targets are available")
+	}
+
+	// every target processes its own queue, such that an unavailable target does not block the others
+	for _, target := range s.targets {
+		intervals := s.intervals
+		if delay, ok := s.scanDelays[target.ID()]; ok {
+			intervals.scanDelay = delay
+		}
+
+		s.wg.Add(1)
+		go func(target autoscan.Target) {
+			defer s.wg.Done()
+			processTarget(proc, target, s.targets, intervals, s.stop)
+		}(target)
+	}
+
+	// the datastore is maintained alongside the targets, and disabled without an interval
+	if s.maintenance > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			maintain(proc, s.maintenance, s.stop)
+		}()
+	}
+}
+
+// shutdown stops the daemon triggers and the targets,
+// and waits for the in-flight scans to finish until the context is done.
+func (s *services) shutdown(ctx context.Context) error {
+	close(s.stop)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// routes serves the HTTP triggers and the API of the most recently loaded config.
+type routes struct {
+	mtx sync.RWMutex
+	mux http.Handler
+}
+
+func (r *routes) set(mux http.Handler) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.mux = mux
+}
+
+func (r *routes) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	r.mtx.RLock()
+	mux := r.mux
+	r.mtx.RUnlock()
+
+	mux.ServeHTTP(rw, req)
+}
+
+// reload replaces the services with those of the config file.
+// The current services keep running when the config file is invalid, of which the error is returned.
+func reload(current config, proc *processor.Processor, svc *services, r *routes, admin *routes) (config, *services, error) {
+	c, err := loadConfig(cli.Config)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed reloading config, keeping the current config")
+		return current, svc, err
+	}
+
+	next, err := newServices(c, proc, svc.manager, svc.lockouts)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed reloading config, keeping the current config")
+		return current, svc, err
+	}
+
+	if restartRequired(current, c) {
+		log.Warn().Msg("Only triggers, targets, authentication, limits and intervals are reloaded, other changes require a restart")
+	}
+
+	// the targets finish their in-flight scans before the new targets take over their queues
+	if err := svc.shutdown(context.Background()); err != nil {
+		log.Error().
+			Err(err).
+			Msg("Failed stopping the current triggers and targets")
+	}
+
+	r.set(next.mux)
+	admin.set(next.admin)
+	next.start(proc)
+
+	log.Info().Msg("Config reloaded")
+	return c, next, nil
+}
+
+// restartRequired returns whether the configs differ in settings which are not reloaded,
+// such as the port, the datastore, the processor and the hooks.
+func restartRequired(current config, next config) bool {
+	reloadable := func(c config) config {
+		c.Triggers = next.Triggers
+		c.Targets = next.Targets
+		c.Auth = next.Auth
+		c.CORS = next.CORS
+		c.RateLimit = next.RateLimit
+		c.RequestLimits = next.RequestLimits
+		c.Deduplication = next.Deduplication
+		c.IPFilter = next.IPFilter
+		c.Lockout = next.Lockout
+		c.Disabled = next.Disabled
+		c.ScanDelay = next.ScanDelay
+		c.PollInterval = next.PollInterval
+		c.AnchorInterval = next.AnchorInterval
+		c.Availability.Interval = next.Availability.Interval
+		c.Maintenance.Interval = next.Maintenance.Interval
+		return c
+	}
+
+	return !reflect.DeepEqual(reloadable(current), reloadable(next))
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudbox/autoscan"
+)
+
+type rewriteTestCmd struct {
+	Path    string `arg:"" name:"path" help:"Path as received by the trigger, or as sent to the target without a trigger"`
+	Trigger string `help:"Name of the webhook trigger, manual, or inotify[i] and bernard[i] for the i-th daemon trigger"`
+	Drive   string `help:"ID of the drive of a bernard trigger, to apply the rewrites of the drive"`
+	Target  string `help:"ID or type of the targets, e.g. plex:http://localhost:32400 or plex"`
+}
+
+// A rewriteChain is the list of rewrite rules of a trigger or target,
+// of which the first matching rule is applied.
+type rewriteChain struct {
+	name  string
+	rules []autoscan.Rewrite
+}
+
+// run prints the outcome of every rewrite rule of the trigger, followed by those of the targets,
+// which rewrite the path as rewritten by the trigger.
+func (c rewriteTestCmd) run() error {
+	if c.Trigger == "" && c.Target == "" {
+		return errors.New("no trigger or target given, use --trigger and/or --target")
+	}
+
+	conf, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	path := c.Path
+	if c.Trigger != "" {
+		chain, err := c.triggerChain(conf)
+		if err != nil {
+			return err
+		}
+
+		if path, err = c.trace(chain, path); err != nil {
+			return err
+		}
+	}
+
+	if c.Target == "" {
+		return nil
+	}
+
+	chains := c.targetChains(conf)
+	if len(chains) == 0 {
+		return fmt.Errorf("no targets matching %s", c.Target)
+	}
+
+	for i, chain := range chains {
+		if i > 0 || c.Trigger != "" {
+			fmt.Println()
+		}
+
+		if _, err := c.trace(chain, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// triggerChain returns the rewrite rules of the trigger of the command.
+// The rules of a watched path or of a drive precede the rules of the daemon trigger.
+func (c rewriteTestCmd) triggerChain(conf config) (rewriteChain, error) {
+	name := "trigger " + c.Trigger
+	if c.Trigger == "manual" {
+		return rewriteChain{name, conf.Triggers.Manual.Rewrite}, nil
+	}
+
+	for i, t := range conf.Triggers.Inotify {
+		if c.Trigger != fmt.Sprintf("inotify[%d]", i) {
+			continue
+		}
+
+		for _, p := range t.Paths {
+			if within(c.Path, p.Path) {
+				return rewriteChain{name + " (" + p.Path + ")", append(p.Rewrite, t.Rewrite...)}, nil
+			}
+		}
+
+		return rewriteChain{}, fmt.Errorf("%s is not within the paths of %s", c.Path, c.Trigger)
+	}
+
+	for i, t := range conf.Triggers.Bernard {
+		if c.Trigger != fmt.Sprintf("bernard[%d]", i) {
+			continue
+		}
+
+		if c.Drive == "" {
+			return rewriteChain{name, t.Rewrite}, nil
+		}
+
+		for _, d := range t.Drives {
+			if d.ID == c.Drive {
+				return rewriteChain{name + " (drive " + d.ID + ")", append(d.Rewrite, t.Rewrite...)}, nil
+			}
+		}
+
+		return rewriteChain{}, fmt.Errorf("no drive %s in %s", c.Drive, c.Trigger)
+	}
+
+	for _, t := range conf.Triggers.Lidarr {
+		if t.Name == c.Trigger {
+			return rewriteChain{name, t.Rewrite}, nil
+		}
+	}
+
+	for _, t := range conf.Triggers.Radarr {
+		if t.Name == c.Trigger {
+			return rewriteChain{name, t.Rewrite}, nil
+		}
+	}
+
+	for _, t := range conf.Triggers.Sonarr {
+		if t.Name == c.Trigger {
+			return rewriteChain{name, t.Rewrite}, nil
+		}
+	}
+
+	return rewriteChain{}, fmt.Errorf("no trigger named %s", c.Trigger)
+}
+
+func within(path string, dir string) bool {
+	path, dir = filepath.Clean(path), filepath.Clean(dir)
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// targetChains returns the rewrite rules of the targets matching the target of the command,
+// like the targets of a scan.
+func (c rewriteTestCmd) targetChains(conf config) []rewriteChain {
+	filter := autoscan.Scan{Targets: []string{c.Target}}
+
+	chains := make([]rewriteChain, 0)
+	for _, t := range conf.Targets.Plex {
+		if id := "plex:" + t.URL; filter.ForTarget(id) {
+			chains = append(chains, rewriteChain{"target " + id, t.Rewrite})
+		}
+	}
+
+	for _, t := range conf.Targets.Emby {
+		if id := "emby:" + t.URL; filter.ForTarget(id) {
+			chains = append(chains, rewriteChain{"target " + id, t.Rewrite})
+		}
+	}
+
+	return chains
+}
+
+// trace prints the outcome of every rule of the chain for the path,
+// and returns the rewritten path.
+func (c rewriteTestCmd) trace(chain rewriteChain, path string) (string, error) {
+	steps, err := autoscan.TraceRewrite(chain.rules, path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", chain.name, err)
+	}
+
+	fmt.Println(chain.name)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  input\t%s\n", path)
+	for i, s := range steps {
+		outcome := "no match"
+		if s.Matched {
+			outcome = s.Result
+		}
+
+		fmt.Fprintf(w, "  %d. %s -> %s\t%s\n", i+1, s.Rule.From, s.Rule.To, outcome)
+	}
+
+	result := path
+	if len(steps) > 0 {
+		result = steps[len(steps)-1].Result
+	}
+
+	switch {
+	case len(chain.rules) == 0:
+		fmt.Fprintf(w, "  no rewrite rules\t\n")
+	case len(steps) < len(chain.rules):
+		fmt.Fprintf(w, "  skipped %d rules after the first match\t\n", len(chain.rules)-len(steps))
+	}
+
+	fmt.Fprintf(w, "  result\t%s\n", result)
+	return result, w.Flush()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/alecthomas/kong"
+)
+
+// manualPath is the path of the manual trigger of a running autoscan.
+const manualPath = "/triggers/manual"
+
+type scanCmd struct {
+	Paths    []string `arg:"" name:"path" help:"Paths to scan, or - to read newline-delimited paths from stdin"`
+	Priority priority `help:"Priority of the scans, overrides the priority of the manual trigger"`
+	Targets  []string `name:"target" help:"Limit the scans to the given target types or targets"`
+	Event    string   `enum:"added,modified,removed" default:"added" help:"Change of the paths (added, modified or removed)"`
+}
+
+// priority is an optional priority flag, which is nil when not given.
+type priority struct {
+	value *int
+}
+
+func (p *priority) Decode(ctx *kong.DecodeContext) error {
+	token, err := ctx.Scan.PopValue("priority")
+	if err != nil {
+		return err
+	}
+
+	v, err := strconv.Atoi(fmt.Sprint(token.Value))
+	if err != nil {
+		return fmt.Errorf("invalid priority: %v", token.Value)
+	}
+
+	p.value = &v
+	return nil
+}
+
+// run submits the paths to the manual trigger of the running autoscan,
+// which rewrites the paths like any other manual request.
+func (c scanCmd) run() error {
+	client, err := newAPIClient(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	if !client.running(manualPath) {
+		return fmt.Errorf("autoscan is not running on %s", client.addrOf(manualPath))
+	}
+
+	if len(c.Paths) == 1 && c.Paths[0] == "-" {
+		return c.submitStdin(client)
+	}
+
+	body, err := json.Marshal(struct {
+		Paths    []string `json:"paths"`
+		Priority *int     `json:"priority,omitempty"`
+		Targets  []string `json:"targets,omitempty"`
+		Event    string   `json:"event"`
+	}{c.Paths, c.Priority.value, c.Targets, c.Event})
+	if err != nil {
+		return err
+	}
+
+	req, err := client.newRequest("POST", manualPath, nil, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp struct {
+		Scans []struct {
+			ID     string `json:"id"`
+			Folder string `json:"folder"`
+		} `json:"scans"`
+	}
+
+	if err := client.do(req, &resp); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFOLDER")
+	for _, s := range resp.Scans {
+		fmt.Fprintf(w, "%s\t%s\n", s.ID, s.Folder)
+	}
+
+	return w.Flush()
+}
+
+// submitStdin submits the newline-delimited paths of stdin as a single request,
+// e.g. piped from find.
+func (c scanCmd) submitStdin(client *apiClient) error {
+	// the priority of a newline-delimited request is the priority of the trigger
+	if c.Priority.value != nil {
+		return errors.New("the priority cannot be given with paths from stdin")
+	}
+
+	query := url.Values{
+		"type":   {c.Event},
+		"target": c.Targets,
+	}
+
+	// the paths are read at once, so the body can be signed
+	body, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	req, err := client.newRequest("POST", manualPath, query, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/plain")
+
+	var resp struct {
+		Paths int `json:"paths"`
+		Scans int `json:"scans"`
+	}
+
+	if err := client.do(req, &resp); err != nil {
+		return err
+	}
+
+	fmt.Printf("Submitted %d paths as %d scans\n", resp.Paths, resp.Scans)
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudbox/autoscan/triggers"
+)
+
+func TestScanAuth(t *testing.T) {
+	type Test struct {
+		Name      string
+		APIKey    string
+		Signature triggers.Signature
+		Paths     []string
+		Stdin     string
+		WantErr   bool
+	}
+
+	var testCases = []Test{
+		{
+			Name:      "Signs paths",
+			Signature: triggers.Signature{Secret: "general kenobi"},
+			Paths:     []string{"/mnt/unionfs/Media/Movies/Interstellar (2014)"},
+		},
+		{
+			Name:      "Signs paths from stdin",
+			Signature: triggers.Signature{Secret: "general kenobi", Header: "X-Hub-Signature", Algorithm: "sha1"},
+			Paths:     []string{"-"},
+			Stdin:     "/mnt/unionfs/Media/Movies/Interstellar (2014)\n/mnt/unionfs/Media/Movies/Parasite (2019)\n",
+		},
+		{
+			Name:      "Sends the api key",
+			APIKey:    "hello there",
+			Signature: triggers.Signature{Secret: "general kenobi"},
+			Paths:     []string{"/mnt/unionfs/Media/Movies/Interstellar (2014)"},
+		},
+		{
+			Name:      "Unsigned without a secret",
+			Signature: triggers.Signature{},
+			Paths:     []string{"/mnt/unionfs/Media/Movies/Interstellar (2014)"},
+			WantErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// the trigger always requires the secret, which the client only knows from its config,
+			// and the api key when it is configured
+			serverSignature := tc.Signature
+			serverSignature.Secret = "general kenobi"
+
+			signature, err := triggers.WithSignature(serverSignature)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			auth := triggers.WithAuth(triggers.Credentials{APIKey: tc.APIKey})
+
+			server := httptest.NewServer(auth(signature(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Type") == "text/plain" {
+					fmt.Fprint(rw, `{"paths": 2, "scans": 2}`)
+					return
+				}
+
+				fmt.Fprint(rw, `{"scans": [{"id": "1", "folder": "/mnt/unionfs/Media/Movies/Interstellar (2014)"}]}`)
+			}))))
+			defer server.Close()
+
+			host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dir, err := ioutil.TempDir("", "autoscan")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			config := fmt.Sprintf(`host: %s
+port: %s
+triggers:
+  manual:
+    api-key: %q
+    signature:
+      secret: %q
+      header: %q
+      algorithm: %q
+`, host, port, tc.APIKey, tc.Signature.Secret, tc.Signature.Header, tc.Signature.Algorithm)
+
+			cli.Config = filepath.Join(dir, "config.yml")
+			if err := ioutil.WriteFile(cli.Config, []byte(config), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			stdin := os.Stdin
+			defer func() {
+				os.Stdin = stdin
+			}()
+
+			if tc.Stdin != "" {
+				f, err := ioutil.TempFile(dir, "stdin")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+
+				if _, err := f.WriteString(tc.Stdin); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := f.Seek(0, 0); err != nil {
+					t.Fatal(err)
+				}
+
+				os.Stdin = f
+			}
+
+			err = scanCmd{Paths: tc.Paths, Event: "added"}.run()
+			if (err != nil) != tc.WantErr {
+				t.Errorf("Errors do not match: %v vs %v", err, tc.WantErr)
+			}
+		})
+	}
+}
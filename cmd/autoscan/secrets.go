@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// secretSuffix marks the keys which contain the path to a file with the value of another key,
+// e.g. token-file instead of token, for secrets mounted by Docker or Kubernetes.
+const secretSuffix = "-file"
+
+// applySecretFiles sets the keys of the config which have a file key
+// to the contents of the file, without the trailing newline.
+func applySecretFiles(config interface{}) error {
+	return readSecretFiles(reflect.ValueOf(config).Elem(), "")
+}
+
+func readSecretFiles(v reflect.Value, key string) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+
+		fields := make(map[string]int)
+		for i := 0; i < t.NumField(); i++ {
+			if name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]; name != "" && name != "-" {
+				fields[name] = i
+			}
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+
+			field := v.Field(i)
+			if err := readSecretFiles(field, joinKey(key, name)); err != nil {
+				return err
+			}
+
+			secret, ok := fields[strings.TrimSuffix(name, secretSuffix)]
+			if !strings.HasSuffix(name, secretSuffix) || !ok || field.Kind() != reflect.String || field.String() == "" {
+				continue
+			}
+
+			if v.Field(secret).String() != "" {
+				return fmt.Errorf("%s: cannot be set together with %s", joinKey(key, name), strings.TrimSuffix(name, secretSuffix))
+			}
+
+			b, err := ioutil.ReadFile(field.String())
+			if err != nil {
+				return fmt.Errorf("%s: %w", joinKey(key, name), err)
+			}
+
+			v.Field(secret).SetString(strings.TrimRight(string(b), "\r\n"))
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := readSecretFiles(v.Index(i), key+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func joinKey(key string, name string) string {
+	if key == "" {
+		return name
+	}
+
+	return key + "." + name
+}
@@ -0,0 +1,10 @@
+package main
+
+type serviceInstallCmd struct {
+	Name  string `default:"autoscan" help:"Name of the service"`
+	Start bool   `help:"Start the service once it is installed"`
+}
+
+type serviceUninstallCmd struct {
+	Name string `default:"autoscan" help:"Name of the service"`
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errServiceUnsupported = errors.New("services can only be installed on Windows, use systemd or launchd instead")
+
+func (c serviceInstallCmd) run() error {
+	return errServiceUnsupported
+}
+
+func (c serviceUninstallCmd) run() error {
+	return errServiceUnsupported
+}
+
+// notifyService does nothing, as autoscan only runs as a service on Windows.
+func notifyService(signals chan<- os.Signal) func() {
+	return func() {}
+}
+
+// consoleColors reports whether the console supports colors.
+func consoleColors() bool {
+	return true
+}
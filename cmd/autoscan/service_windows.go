@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceName is the name given to the service manager when autoscan runs as a service,
+// which is ignored as autoscan is the only service of its process.
+const serviceName = "autoscan"
+
+// run registers autoscan as a service which starts with Windows and restarts when it fails.
+// The service uses the config, database and log paths of the command,
+// as the paths of the account running the service differ from those of the user.
+func (c serviceInstallCmd) run() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := make([]string, 0)
+	for _, f := range []struct {
+		flag string
+		path string
+	}{
+		{"--config", cli.Config},
+		{"--database", cli.Database},
+		{"--log", cli.Log},
+	} {
+		path, err := filepath.Abs(f.path)
+		if err != nil {
+			return err
+		}
+
+		args = append(args, f.flag, path)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(c.Name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed", c.Name)
+	}
+
+	s, err := m.CreateService(c.Name, exe, mgr.Config{
+		DisplayName: "Autoscan",
+		Description: "Scans media into Plex and Emby",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("create service: %w", err)
+	}
+	defer s.Close()
+
+	err = s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+	}, uint32((24 * time.Hour).Seconds()))
+	if err != nil {
+		return fmt.Errorf("set recovery actions: %w", err)
+	}
+
+	fmt.Printf("Installed service %s with config %s\n", c.Name, args[1])
+	if !c.Start {
+		return nil
+	}
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("start service: %w", err)
+	}
+
+	fmt.Printf("Started service %s\n", c.Name)
+	return nil
+}
+
+// run stops and removes the service.
+func (c serviceUninstallCmd) run() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(c.Name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", c.Name)
+	}
+	defer s.Close()
+
+	if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+		if _, err := s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("stop service: %w", err)
+		}
+	}
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("delete service: %w", err)
+	}
+
+	fmt.Printf("Uninstalled service %s\n", c.Name)
+	return nil
+}
+
+// notifyService sends an interrupt to the signals when the service manager stops autoscan,
+// if autoscan runs as a Windows service.
+// The returned function reports to the service manager that autoscan stopped.
+func notifyService(signals chan<- os.Signal) func() {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil || interactive {
+		return func() {}
+	}
+
+	h := &serviceHandler{signals: signals, done: make(chan struct{})}
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+		if err := svc.Run(serviceName, h); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Failed running as a service")
+		}
+	}()
+
+	return func() {
+		close(h.done)
+		<-exited
+	}
+}
+
+type serviceHandler struct {
+	signals chan<- os.Signal
+	done    chan struct{}
+}
+
+func (h *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+
+				select {
+				case h.signals <- os.Interrupt:
+				case <-h.done:
+					return false, 0
+				}
+			}
+
+		case <-h.done:
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+}
+
+// consoleColors enables the escape codes for colors in the console,
+// and reports whether the console supports them.
+func consoleColors() bool {
+	handle := windows.Handle(os.Stderr.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
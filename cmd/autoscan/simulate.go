@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/triggers"
+	"github.com/cloudbox/autoscan/triggers/lidarr"
+	"github.com/cloudbox/autoscan/triggers/manual"
+	"github.com/cloudbox/autoscan/triggers/radarr"
+	"github.com/cloudbox/autoscan/triggers/sonarr"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// The built-in webhook payloads of the -arrs are named after their event,
+// e.g. samples/sonarr/download.json.
+//
+//go:embed samples
+var samples embed.FS
+
+type triggerSimulateCmd struct {
+	Trigger string `arg:"" name:"trigger" help:"Name of the webhook trigger, or manual"`
+	Payload string `type:"path" help:"File with the JSON body of a webhook request, instead of the built-in samples"`
+	Sample  string `help:"Name of the built-in sample to replay, e.g. download (all samples by default)"`
+}
+
+type payload struct {
+	name string
+	body []byte
+}
+
+// run replays the payloads through the trigger of the config file,
+// and prints the scans which the trigger would add to the queue.
+// The trigger does not verify the paths with the API of the -arr.
+// It returns an error when the trigger rejects any of the payloads.
+func (c triggerSimulateCmd) run() error {
+	conf, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	kind, trigger, err := c.trigger(conf)
+	if err != nil {
+		return err
+	}
+
+	payloads, err := c.payloads(kind)
+	if err != nil {
+		return err
+	}
+
+	rejected := 0
+	for i, p := range payloads {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		ok, err := c.simulate(trigger, p)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			rejected++
+		}
+	}
+
+	if rejected > 0 {
+		return fmt.Errorf("%d of %d payloads were rejected", rejected, len(payloads))
+	}
+
+	return nil
+}
+
+// trigger returns the type and the trigger of the webhook with the name of the command.
+func (c triggerSimulateCmd) trigger(conf config) (string, autoscan.HTTPTrigger, error) {
+	if c.Trigger == "manual" {
+		trigger, err := manual.New(conf.Triggers.Manual)
+		return "manual", trigger, err
+	}
+
+	for _, t := range conf.Triggers.Lidarr {
+		if t.Name == c.Trigger {
+			trigger, err := lidarr.New(t)
+			return "lidarr", trigger, err
+		}
+	}
+
+	for _, t := range conf.Triggers.Radarr {
+		if t.Name == c.Trigger {
+			t.Verify = radarr.VerifyConfig{}
+			trigger, err := radarr.New(t)
+			return "radarr", trigger, err
+		}
+	}
+
+	for _, t := range conf.Triggers.Sonarr {
+		if t.Name == c.Trigger {
+			t.Verify = sonarr.VerifyConfig{}
+			trigger, err := sonarr.New(t)
+			return "sonarr", trigger, err
+		}
+	}
+
+	return "", nil, fmt.Errorf("no webhook trigger named %s", c.Trigger)
+}
+
+// payloads returns the payload file of the command,
+// or the built-in samples of the type of trigger.
+func (c triggerSimulateCmd) payloads(kind string) ([]payload, error) {
+	if c.Payload != "" {
+		body, err := ioutil.ReadFile(c.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return []payload{{name: filepath.Base(c.Payload), body: body}}, nil
+	}
+
+	entries, err := fs.ReadDir(samples, path.Join("samples", kind))
+	if err != nil {
+		return nil, fmt.Errorf("no built-in samples for the %s trigger, use --payload instead", kind)
+	}
+
+	payloads := make([]payload, 0)
+	names := make([]string, 0)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".json")
+		names = append(names, name)
+		if c.Sample != "" && c.Sample != name {
+			continue
+		}
+
+		body, err := samples.ReadFile(path.Join("samples", kind, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		payloads = append(payloads, payload{name: name, body: body})
+	}
+
+	if len(payloads) == 0 {
+		return nil, fmt.Errorf("unknown sample %s, expected one of: %s", c.Sample, strings.Join(names, ", "))
+	}
+
+	return payloads, nil
+}
+
+// simulate sends the payload to the trigger, and prints the response and the scans.
+// It returns whether the trigger accepted the payload.
+func (c triggerSimulateCmd) simulate(trigger autoscan.HTTPTrigger, p payload) (bool, error) {
+	scans := make([]autoscan.Scan, 0)
+	handler := trigger(func(s ...autoscan.Scan) error {
+		scans = append(scans, s...)
+		return nil
+	})
+
+	req := httptest.NewRequest("POST", "/triggers/"+c.Trigger, bytes.NewReader(p.body))
+	req.Header.Set("Content-Type", "application/json")
+
+	// the scans are not moved to the processor, only log why payloads are rejected
+	logger := log.Logger
+	if cli.Verbosity == 0 {
+		logger = logger.Level(zerolog.WarnLevel)
+	}
+
+	rec := httptest.NewRecorder()
+	triggers.WithLogger(logger)(handler).ServeHTTP(rec, req)
+
+	fmt.Printf("%s: %d %s\n", p.name, rec.Code, http.StatusText(rec.Code))
+	if len(scans) == 0 {
+		fmt.Println("  no scans")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  EVENT\tPRIORITY\tFOLDER")
+		for _, s := range scans {
+			fmt.Fprintf(w, "  %s\t%d\t%s\n", s.Event, s.Priority, s.Folder)
+		}
+
+		if err := w.Flush(); err != nil {
+			return false, err
+		}
+	}
+
+	return rec.Code >= 200 && rec.Code < 300, nil
+}
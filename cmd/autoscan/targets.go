@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/targets/emby"
+	"github.com/cloudbox/autoscan/targets/plex"
+)
+
+type targetsTestCmd struct {
+	Paths []string `name:"path" help:"Paths as received from the triggers, which should resolve to a library of every target"`
+}
+
+// run connects to the targets of the config file and prints their version and libraries,
+// and the libraries to which the paths resolve after the rewrites of the target.
+// It returns an error when any of the targets fails.
+func (c targetsTestCmd) run() error {
+	conf, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	type section struct {
+		name      string
+		url       string
+		newTarget func() (autoscan.Target, error)
+	}
+
+	sections := make([]section, 0)
+	for i, t := range conf.Targets.Plex {
+		t := t
+		sections = append(sections, section{fmt.Sprintf("targets.plex[%d]", i), t.URL, func() (autoscan.Target, error) {
+			return plex.New(t)
+		}})
+	}
+
+	for i, t := range conf.Targets.Emby {
+		t := t
+		sections = append(sections, section{fmt.Sprintf("targets.emby[%d]", i), t.URL, func() (autoscan.Target, error) {
+			return emby.New(t)
+		}})
+	}
+
+	if len(sections) == 0 {
+		return errors.New("no targets configured")
+	}
+
+	failed := 0
+	for i, s := range sections {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		fmt.Printf("%s (%s)\n", s.name, s.url)
+		if err := c.test(s.newTarget); err != nil {
+			fmt.Printf("  error:     %v\n", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed", failed, len(sections))
+	}
+
+	return nil
+}
+
+func (c targetsTestCmd) test(newTarget func() (autoscan.Target, error)) error {
+	target, err := newTarget()
+	if err != nil {
+		return err
+	}
+
+	if err := target.Available(); err != nil {
+		return fmt.Errorf("unavailable: %w", err)
+	}
+
+	fmt.Println("  status:    available")
+
+	d, ok := target.(autoscan.Describer)
+	if !ok {
+		return nil
+	}
+
+	info, err := d.Describe()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("  version:   %s\n", info.Version)
+	if len(info.Libraries) == 0 {
+		fmt.Println("  libraries: none")
+	}
+
+	for i, lib := range info.Libraries {
+		label := ""
+		if i == 0 {
+			label = "libraries:"
+		}
+
+		fmt.Printf("  %-10s %s (%s)\n", label, lib.Name, lib.Path)
+	}
+
+	unresolved := 0
+	for _, path := range c.Paths {
+		folder, libs := d.Resolve(path)
+		if len(libs) == 0 {
+			fmt.Printf("  path:      %s -> %s (no library)\n", path, folder)
+			unresolved++
+			continue
+		}
+
+		for _, lib := range libs {
+			fmt.Printf("  path:      %s -> %s (%s)\n", path, folder, lib.Name)
+		}
+	}
+
+	if unresolved > 0 {
+		return fmt.Errorf("%d of %d paths do not resolve to a library", unresolved, len(c.Paths))
+	}
+
+	return nil
+}
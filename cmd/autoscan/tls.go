@@ -0,0 +1,235 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsConfig returns the TLS config of the web server, or nil when the server does not use TLS.
+// The certificate is either read from the cert and key files,
+// or requested from Let's Encrypt for the hostname.
+// Clients must present a certificate issued by the client CA when it is set.
+func tlsConfig(c config) (*tls.Config, error) {
+	conf, err := serverTLSConfig(c)
+	if err != nil || c.TLS.ClientCA == "" {
+		return conf, err
+	}
+
+	if conf == nil {
+		return nil, errors.New("tls: the client-ca requires a cert and key or an acme hostname")
+	}
+
+	pool, err := readCertPool(c.TLS.ClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("tls: client-ca: %w", err)
+	}
+
+	requireClientCerts(conf, pool)
+	return conf, nil
+}
+
+func serverTLSConfig(c config) (*tls.Config, error) {
+	files := c.TLS.Cert != "" || c.TLS.Key != ""
+	acme := c.TLS.ACME.Hostname != ""
+
+	switch {
+	case files && acme:
+		return nil, errors.New("tls: either the cert and key or an acme hostname can be set")
+
+	case files:
+		if c.TLS.Cert == "" || c.TLS.Key == "" {
+			return nil, errors.New("tls: both the cert and the key must be set")
+		}
+
+		loader := &certLoader{cert: c.TLS.Cert, key: c.TLS.Key}
+		if _, err := loader.GetCertificate(nil); err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+
+		return &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: loader.GetCertificate,
+		}, nil
+
+	case acme:
+		cache := c.TLS.ACME.Cache
+		if cache == "" {
+			cache = filepath.Join(filepath.Dir(cli.Config), "acme")
+		}
+
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(c.TLS.ACME.Hostname),
+			Email:      c.TLS.ACME.Email,
+			Cache:      autocert.DirCache(cache),
+		}
+
+		conf := m.TLSConfig()
+		conf.MinVersion = tls.VersionTLS12
+		return conf, nil
+	}
+
+	return nil, nil
+}
+
+// clientTLSConfig returns the TLS config with which the CLI commands connect to the server at the hosts.
+// The client certificate is presented to a server which requires a certificate of the client-ca.
+// The certificate of the server is verified with the CA, or with the CAs of the system without one,
+// except on the loopback interface, for which a certificate of the cert and key files is rarely issued.
+func clientTLSConfig(c config, hosts ...string) (*tls.Config, error) {
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the certificate of Let's Encrypt is issued for the hostname, wherever it is reached
+		ServerName: c.TLS.ACME.Hostname,
+	}
+
+	if c.TLS.Client.Cert != "" || c.TLS.Client.Key != "" {
+		certificate, err := tls.LoadX509KeyPair(c.TLS.Client.Cert, c.TLS.Client.Key)
+		if err != nil {
+			return nil, fmt.Errorf("tls: client: %w", err)
+		}
+
+		conf.Certificates = []tls.Certificate{certificate}
+	}
+
+	if c.TLS.Client.CA != "" {
+		pool, err := readCertPool(c.TLS.Client.CA)
+		if err != nil {
+			return nil, fmt.Errorf("tls: client: ca: %w", err)
+		}
+
+		conf.RootCAs = pool
+		return conf, nil
+	}
+
+	if conf.ServerName != "" {
+		return conf, nil
+	}
+
+	for _, host := range hosts {
+		if !isLoopback(host) {
+			return conf, nil
+		}
+	}
+
+	conf.InsecureSkipVerify = true
+	return conf, nil
+}
+
+// isLoopback returns whether the host is on the loopback interface.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// readCertPool reads the PEM encoded certificates of the file.
+func readCertPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return pool, nil
+}
+
+// requireClientCerts requires the clients to present a certificate issued by a CA of the pool,
+// except for the TLS-ALPN challenges of Let's Encrypt, which do not present a certificate.
+func requireClientCerts(conf *tls.Config, pool *x509.CertPool) {
+	mtls := conf.Clone()
+	mtls.ClientAuth = tls.RequireAndVerifyClientCert
+	mtls.ClientCAs = pool
+
+	conf.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		for _, proto := range hello.SupportedProtos {
+			if proto == acme.ALPNProto {
+				return nil, nil
+			}
+		}
+
+		return mtls, nil
+	}
+}
+
+// A certLoader reads the certificate and key files,
+// and reads them again once they are modified, such that renewed certificates are served without a restart.
+type certLoader struct {
+	cert string
+	key  string
+
+	mtx         sync.Mutex
+	modified    time.Time
+	certificate *tls.Certificate
+}
+
+func (l *certLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	modified, err := l.modTime()
+	if err != nil && l.certificate == nil {
+		return nil, err
+	}
+
+	if err != nil || !modified.After(l.modified) {
+		return l.certificate, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(l.cert, l.key)
+	if err != nil {
+		if l.certificate == nil {
+			return nil, err
+		}
+
+		// the files may be read while they are being renewed
+		log.Warn().
+			Err(err).
+			Msg("Failed reading the renewed certificate, keeping the current certificate")
+		return l.certificate, nil
+	}
+
+	if l.certificate != nil {
+		log.Info().Str("cert", l.cert).Msg("Certificate renewed")
+	}
+
+	l.modified = modified
+	l.certificate = &certificate
+	return l.certificate, nil
+}
+
+// modTime returns the most recent modification time of the cert and key files.
+func (l *certLoader) modTime() (time.Time, error) {
+	var modified time.Time
+	for _, path := range []string{l.cert, l.key} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return modified, err
+		}
+
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+
+	return modified, nil
+}
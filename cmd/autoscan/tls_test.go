@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate and its key to the directory.
+func writeCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "autoscan"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	if err := ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certPath, keyPath
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	type Test struct {
+		Name           string
+		Hosts          []string
+		Hostname       string
+		ClientCert     bool
+		CA             bool
+		Missing        bool
+		WantSkipVerify bool
+		WantErr        bool
+	}
+
+	var testCases = []Test{
+		{
+			Name:           "Skips the verification on the loopback interface",
+			Hosts:          []string{"localhost", "127.0.0.1", "::1"},
+			WantSkipVerify: true,
+		},
+		{
+			Name:  "Verifies the certificate of other hosts",
+			Hosts: []string{"localhost", "192.168.1.10"},
+		},
+		{
+			Name:     "Verifies the certificate of the acme hostname",
+			Hosts:    []string{"localhost"},
+			Hostname: "autoscan.example.com",
+		},
+		{
+			Name:  "Verifies the certificate with the ca",
+			Hosts: []string{"localhost"},
+			CA:    true,
+		},
+		{
+			Name:           "Presents the client certificate",
+			Hosts:          []string{"localhost"},
+			ClientCert:     true,
+			WantSkipVerify: true,
+		},
+		{
+			Name:       "Fails on a missing client certificate",
+			Hosts:      []string{"localhost"},
+			ClientCert: true,
+			Missing:    true,
+			WantErr:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			cert, key := writeCert(t, t.TempDir())
+			if tc.Missing {
+				cert = filepath.Join(t.TempDir(), "cert.pem")
+			}
+
+			var c config
+			c.TLS.ACME.Hostname = tc.Hostname
+			if tc.ClientCert {
+				c.TLS.Client.Cert = cert
+				c.TLS.Client.Key = key
+			}
+
+			if tc.CA {
+				c.TLS.Client.CA = cert
+			}
+
+			conf, err := clientTLSConfig(c, tc.Hosts...)
+			if (err != nil) != tc.WantErr {
+				t.Fatalf("Errors do not match: %v vs %v", err, tc.WantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if conf.InsecureSkipVerify != tc.WantSkipVerify {
+				t.Errorf("Skipping the verification does not match: %v vs %v", conf.InsecureSkipVerify, tc.WantSkipVerify)
+			}
+
+			if conf.ServerName != tc.Hostname {
+				t.Errorf("Server names do not match: %s vs %s", conf.ServerName, tc.Hostname)
+			}
+
+			if tc.ClientCert && len(conf.Certificates) != 1 {
+				t.Errorf("Expected the client certificate: %d", len(conf.Certificates))
+			}
+
+			if tc.CA && conf.RootCAs == nil {
+				t.Error("Expected the ca")
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// configErrors are the problems of a config file, which are reported at once.
+type configErrors []string
+
+func (e configErrors) Error() string {
+	if len(e) == 1 {
+		return e[0]
+	}
+
+	return fmt.Sprintf("%d errors:\n  %s", len(e), strings.Join(e, "\n  "))
+}
+
+// validateConfig checks the keys and values of the YAML document against the YAML tags of v,
+// a pointer to a struct, and returns the problems with the path of their key, e.g. targets.plex[0].token.
+// Unknown keys are reported with the closest known key of their parent.
+func validateConfig(data []byte, v interface{}) error {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	errs := make(configErrors, 0)
+	validateValue(reflect.TypeOf(v).Elem(), doc, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validateValue(t reflect.Type, value interface{}, path string, errs *configErrors) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if value == nil || reflect.PtrTo(t).Implements(yamlUnmarshaler) {
+		validateScalar(t, value, path, errs)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := value.(yaml.MapSlice)
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: expected keys, got %s", path, yamlKind(value)))
+			return
+		}
+
+		fields := yamlFields(t)
+		for _, item := range m {
+			key := fmt.Sprint(item.Key)
+			field, ok := fields[key]
+			if !ok {
+				*errs = append(*errs, unknownKey(joinKey(path, key), key, fields))
+				continue
+			}
+
+			validateValue(field.Type, item.Value, joinKey(path, key), errs)
+		}
+
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: expected a list, got %s", path, yamlKind(value)))
+			return
+		}
+
+		for i, item := range items {
+			validateValue(t.Elem(), item, path+"["+strconv.Itoa(i)+"]", errs)
+		}
+
+	case reflect.Map:
+		m, ok := value.(yaml.MapSlice)
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: expected keys, got %s", path, yamlKind(value)))
+			return
+		}
+
+		for _, item := range m {
+			validateScalar(t.Key(), item.Key, path, errs)
+			validateValue(t.Elem(), item.Value, joinKey(path, fmt.Sprint(item.Key)), errs)
+		}
+
+	default:
+		validateScalar(t, value, path, errs)
+	}
+}
+
+// validateScalar decodes the value into the type, as the decoder of the config would.
+func validateScalar(t reflect.Type, value interface{}, path string, errs *configErrors) {
+	data, err := yaml.Marshal(value)
+	if err == nil {
+		err = yaml.Unmarshal(data, reflect.New(t).Interface())
+	}
+
+	if err == nil {
+		return
+	}
+
+	// the line of the re-encoded value is meaningless
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		for _, e := range typeErr.Errors {
+			*errs = append(*errs, fmt.Sprintf("%s: %s", path, strings.TrimPrefix(e, "line 1: ")))
+		}
+
+		return
+	}
+
+	*errs = append(*errs, fmt.Sprintf("%s: %s", path, err))
+}
+
+func yamlKind(value interface{}) string {
+	switch value.(type) {
+	case yaml.MapSlice:
+		return "keys"
+	case []interface{}:
+		return "a list"
+	default:
+		return fmt.Sprintf("`%v`", value)
+	}
+}
+
+// yamlFields returns the fields of the struct by their YAML key.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+
+		fields[name] = field
+	}
+
+	return fields
+}
+
+// unknownKey describes the unknown key, suggesting the known key with the least edits when it is close enough.
+func unknownKey(path string, key string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", -1
+	for name := range fields {
+		d := editDistance(strings.ToLower(key), name)
+		if bestDistance < 0 || d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+
+	if bestDistance >= 0 && (bestDistance <= 2 || bestDistance <= len(key)/3) {
+		return fmt.Sprintf("%s: unknown key, did you mean `%s`?", path, best)
+	}
+
+	return fmt.Sprintf("%s: unknown key", path)
+}
+
+// editDistance returns the Levenshtein distance of the strings.
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < curr[j] {
+				curr[j] = d
+			}
+
+			if d := curr[j-1] + 1; d < curr[j] {
+				curr[j] = d
+			}
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,271 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	osexec "os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/rs/zerolog"
+)
+
+// Config configures a hook which runs a command or sends an HTTP request.
+//
+// The arguments of the command, and the URL, headers and body of the request
+// are templates with access to the scan, e.g. {{.Folder}}.
+// A hook either runs a command or sends a request.
+// Timeout limits the time the hook may take, which defaults to 30 seconds.
+type Config struct {
+	Command   []string          `yaml:"command"`
+	URL       string            `yaml:"url"`
+	Method    string            `yaml:"method"`
+	Headers   map[string]string `yaml:"headers"`
+	Body      string            `yaml:"body"`
+	Timeout   time.Duration     `yaml:"timeout"`
+	Verbosity string            `yaml:"verbosity"`
+}
+
+// Data is passed to the templates of a hook.
+// Status is empty before the scan, and completed or failed after the scan.
+type Data struct {
+	ID       string
+	Folder   string
+	Priority int
+	Event    string
+	Targets  []string
+	Trigger  string
+	Time     time.Time
+	Status   string
+}
+
+// Hook runs a command or sends an HTTP request before or after a scan.
+type Hook struct {
+	command []*template.Template
+	url     *template.Template
+	method  string
+	headers map[string]*template.Template
+	body    *template.Template
+	timeout time.Duration
+
+	client *http.Client
+	log    zerolog.Logger
+}
+
+var funcs = template.FuncMap{
+	// json encodes the value, e.g. to quote a string in a JSON body
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func parse(name string, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %v: %w", name, err, autoscan.ErrFatal)
+	}
+
+	return t, nil
+}
+
+// New creates a hook from the config.
+// The returned hook can be used as both a pre-scan and a post-scan hook.
+func New(c Config) (*Hook, error) {
+	l := autoscan.GetLogger(c.Verbosity).With().
+		Str("hook", "exec").
+		Logger()
+
+	switch {
+	case len(c.Command) == 0 && c.URL == "":
+		return nil, fmt.Errorf("hook requires a command or url: %w", autoscan.ErrFatal)
+	case len(c.Command) > 0 && c.URL != "":
+		return nil, fmt.Errorf("hook requires either a command or url, not both: %w", autoscan.ErrFatal)
+	}
+
+	h := &Hook{
+		method:  strings.ToUpper(c.Method),
+		headers: make(map[string]*template.Template),
+		timeout: c.Timeout,
+
+		client: &http.Client{},
+		log:    l,
+	}
+
+	if h.timeout <= 0 {
+		h.timeout = 30 * time.Second
+	}
+
+	for _, arg := range c.Command {
+		t, err := parse("command", arg)
+		if err != nil {
+			return nil, err
+		}
+
+		h.command = append(h.command, t)
+	}
+
+	if c.URL == "" {
+		h.log = l.With().Str("command", c.Command[0]).Logger()
+		return h, nil
+	}
+
+	h.log = l.With().Str("url", c.URL).Logger()
+
+	var err error
+	if h.url, err = parse("url", c.URL); err != nil {
+		return nil, err
+	}
+
+	if h.body, err = parse("body", c.Body); err != nil {
+		return nil, err
+	}
+
+	for name, value := range c.Headers {
+		if h.headers[name], err = parse("header", value); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.method == "" {
+		h.method = "GET"
+		if c.Body != "" {
+			h.method = "POST"
+		}
+	}
+
+	return h, nil
+}
+
+func (h Hook) PreScan(scan autoscan.Scan) error {
+	return h.run(newData(scan, ""))
+}
+
+func (h Hook) PostScan(scan autoscan.Scan, failed bool) error {
+	status := "completed"
+	if failed {
+		status = "failed"
+	}
+
+	return h.run(newData(scan, status))
+}
+
+func newData(scan autoscan.Scan, status string) Data {
+	return Data{
+		ID:       scan.ID(),
+		Folder:   scan.Folder,
+		Priority: scan.Priority,
+		Event:    string(scan.Event),
+		Targets:  scan.Targets,
+		Trigger:  scan.Trigger,
+		Time:     scan.Time,
+		Status:   status,
+	}
+}
+
+func execute(t *template.Template, data Data) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func (h Hook) run(data Data) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	var err error
+	if h.url != nil {
+		err = h.request(ctx, data)
+	} else {
+		err = h.exec(ctx, data)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	h.log.Debug().
+		Str("path", data.Folder).
+		Str("status", data.Status).
+		Msg("Hook completed")
+
+	return nil
+}
+
+func (h Hook) exec(ctx context.Context, data Data) error {
+	args := make([]string, 0, len(h.command))
+	for _, t := range h.command {
+		arg, err := execute(t, data)
+		if err != nil {
+			return fmt.Errorf("command: %w", err)
+		}
+
+		args = append(args, arg)
+	}
+
+	output, err := osexec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("command %s: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+
+	h.log.Trace().
+		Str("output", string(output)).
+		Msg("Command output")
+
+	return nil
+}
+
+func (h Hook) request(ctx context.Context, data Data) error {
+	reqURL, err := execute(h.url, data)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+
+	body, err := execute(h.body, data)
+	if err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, h.method, reqURL, r)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	for name, t := range h.headers {
+		value, err := execute(t, data)
+		if err != nil {
+			return fmt.Errorf("header %s: %w", name, err)
+		}
+
+		req.Header.Set(name, value)
+	}
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("request: %s", res.Status)
+	}
+
+	return nil
+}
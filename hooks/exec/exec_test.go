@@ -0,0 +1,133 @@
+package exec
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestRequest(t *testing.T) {
+	type Test struct {
+		Name       string
+		Config     Config
+		Failed     bool
+		WantMethod string
+		WantPath   string
+		WantHeader string
+		WantBody   string
+	}
+
+	var testCases = []Test{
+		{
+			Name: "Sends a GET request with the templated URL",
+			Config: Config{
+				URL: "/warm?path={{urlquery .Folder}}",
+			},
+			WantMethod: "GET",
+			WantPath:   "/warm?path=%2Ftv%2FWestworld",
+		},
+		{
+			Name: "Sends a POST request with the templated body and headers",
+			Config: Config{
+				URL:     "/notify",
+				Headers: map[string]string{"X-Event": "{{.Event}}"},
+				Body:    `{"folder": {{json .Folder}}, "status": "{{.Status}}"}`,
+			},
+			Failed:     true,
+			WantMethod: "POST",
+			WantPath:   "/notify",
+			WantHeader: "removed",
+			WantBody:   `{"folder": "/tv/Westworld", "status": "failed"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var method, path, header, body string
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				b, _ := ioutil.ReadAll(r.Body)
+				method, path, header, body = r.Method, r.URL.RequestURI(), r.Header.Get("X-Event"), string(b)
+			}))
+
+			defer server.Close()
+
+			tc.Config.URL = server.URL + tc.Config.URL
+			hook, err := New(tc.Config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			scan := autoscan.Scan{Folder: "/tv/Westworld", Event: autoscan.EventRemoved, Time: time.Now()}
+			if err := hook.PostScan(scan, tc.Failed); err != nil {
+				t.Fatal(err)
+			}
+
+			if method != tc.WantMethod {
+				t.Errorf("Methods do not match: %s vs %s", method, tc.WantMethod)
+			}
+
+			if path != tc.WantPath {
+				t.Errorf("Paths do not match: %s vs %s", path, tc.WantPath)
+			}
+
+			if header != tc.WantHeader {
+				t.Errorf("Headers do not match: %s vs %s", header, tc.WantHeader)
+			}
+
+			if body != tc.WantBody {
+				t.Errorf("Bodies do not match: %s vs %s", body, tc.WantBody)
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	dir, err := ioutil.TempDir("", "autoscan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+	hook, err := New(Config{
+		Command: []string{"sh", "-c", `printf '%s' "$1" > "$2"`, "sh", "{{.Folder}}", output},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hook.PreScan(autoscan.Scan{Folder: "/tv/Westworld/Season 1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "/tv/Westworld/Season 1" {
+		t.Errorf("Arguments do not match: %s", b)
+	}
+
+	// failing commands return their output
+	hook, err = New(Config{Command: []string{"sh", "-c", "echo broken >&2; exit 1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hook.PreScan(autoscan.Scan{Folder: "/tv/Westworld"}); err == nil {
+		t.Error("Expected the command to fail")
+	}
+}
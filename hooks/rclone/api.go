@@ -0,0 +1,169 @@
+package rclone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/rs/zerolog"
+)
+
+type apiClient struct {
+	client   *http.Client
+	log      zerolog.Logger
+	baseURL  string
+	username string
+	password string
+}
+
+// newAPIClient creates a client for the remote control at the base URL.
+// Requests fail when they take longer than the timeout.
+func newAPIClient(baseURL string, username string, password string, timeout time.Duration, log zerolog.Logger) apiClient {
+	return apiClient{
+		client:   &http.Client{Timeout: timeout},
+		log:      log,
+		baseURL:  baseURL,
+		username: username,
+		password: password,
+	}
+}
+
+// send sends the request with the credentials of the remote control.
+func (c apiClient) send(req *http.Request) (*http.Response, error) {
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	return c.client.Do(req)
+}
+
+// do sends the request, of which every failure makes the hook unavailable.
+// Invalid credentials and a remote control without vfs/refresh are reported by Validate at startup,
+// such that a remote control which is reconfigured later postpones the scans instead of stopping the processor.
+func (c apiClient) do(req *http.Request) (*http.Response, error) {
+	res, err := c.send(req)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, autoscan.ErrTargetUnavailable)
+	}
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+
+	c.log.Trace().
+		Stringer("request_url", res.Request.URL).
+		Int("response_status", res.StatusCode).
+		Msg("Request failed")
+
+	// statusCode not in the 2xx range, close response
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case 401, 403:
+		return nil, fmt.Errorf("invalid rclone credentials: %s: %w", res.Status, autoscan.ErrTargetUnavailable)
+	case 404:
+		return nil, fmt.Errorf("rclone remote control does not support vfs/refresh: %s: %w", res.Status, autoscan.ErrTargetUnavailable)
+	default:
+		return nil, fmt.Errorf("%s: %w", res.Status, autoscan.ErrTargetUnavailable)
+	}
+}
+
+// Validate checks whether the credentials are valid and whether the remote control supports vfs/refresh,
+// which fail with autoscan.ErrFatal. A remote control which cannot be reached is unavailable.
+func (c apiClient) Validate() error {
+	reqURL := autoscan.JoinURL(c.baseURL, "rc", "list")
+	req, err := http.NewRequest("POST", reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed creating list request: %v: %w", err, autoscan.ErrFatal)
+	}
+
+	res, err := c.send(req)
+	if err != nil {
+		return fmt.Errorf("list: %v: %w", err, autoscan.ErrTargetUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == 401 || res.StatusCode == 403:
+		return fmt.Errorf("invalid rclone credentials: %s: %w", res.Status, autoscan.ErrFatal)
+	case res.StatusCode < 200 || res.StatusCode >= 300:
+		return fmt.Errorf("list: %s: %w", res.Status, autoscan.ErrTargetUnavailable)
+	}
+
+	type Response struct {
+		Commands []struct {
+			Path string `json:"Path"`
+		} `json:"commands"`
+	}
+
+	resp := new(Response)
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return fmt.Errorf("failed decoding list response: %v: %w", err, autoscan.ErrTargetUnavailable)
+	}
+
+	for _, command := range resp.Commands {
+		if command.Path == "vfs/refresh" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("rclone remote control does not support vfs/refresh: %w", autoscan.ErrFatal)
+}
+
+type refreshRequest struct {
+	Dir       string `json:"dir,omitempty"`
+	Recursive bool   `json:"recursive,omitempty"`
+	FS        string `json:"fs,omitempty"`
+}
+
+// Refresh refreshes the directory cache of the VFS for the directory,
+// which is relative to the root of the remote.
+// The root of the remote is refreshed when the directory is empty.
+//
+// Rclone reports the outcome for every directory in the result,
+// which is "OK" or the reason the directory could not be refreshed.
+func (c apiClient) Refresh(dir string, recursive bool, fs string) (string, error) {
+	b, err := json.Marshal(refreshRequest{Dir: dir, Recursive: recursive, FS: fs})
+	if err != nil {
+		return "", fmt.Errorf("failed encoding refresh request payload: %v: %w", err, autoscan.ErrFatal)
+	}
+
+	// create request
+	reqURL := autoscan.JoinURL(c.baseURL, "vfs", "refresh")
+	req, err := http.NewRequest("POST", reqURL, bytes.NewBuffer(b))
+	if err != nil {
+		return "", fmt.Errorf("failed creating refresh request: %v: %w", err, autoscan.ErrFatal)
+	}
+
+	// send request
+	res, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("refresh: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	// decode response
+	type Response struct {
+		Result map[string]string `json:"result"`
+	}
+
+	resp := new(Response)
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return "", fmt.Errorf("failed decoding refresh request response: %v: %w", err, autoscan.ErrTargetUnavailable)
+	}
+
+	// the root of the remote is reported as an empty directory
+	for _, result := range resp.Result {
+		return result, nil
+	}
+
+	return "", fmt.Errorf("refresh: empty result: %w", autoscan.ErrTargetUnavailable)
+}
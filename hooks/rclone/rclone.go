@@ -0,0 +1,178 @@
+package rclone
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/rs/zerolog"
+)
+
+// Config configures the refresh of the VFS directory cache of an rclone mount
+// through its remote control (rclone rcd or rclone mount --rc).
+//
+// Rewrite translates the folder of a scan into a directory relative to the root of the remote.
+// Recursive also refreshes all subdirectories of the folder.
+// Fallback refreshes the closest parent directory known to rclone
+// when the folder itself is not in the directory cache yet.
+// Retries is the number of times a failed request is retried.
+// Timeout limits the time a request may take, which defaults to 30 seconds.
+type Config struct {
+	URL          string             `yaml:"url"`
+	Username     string             `yaml:"username"`
+	Password     string             `yaml:"password"`
+	PasswordFile string             `yaml:"password-file"`
+	FS           string             `yaml:"fs"`
+	Rewrite      []autoscan.Rewrite `yaml:"rewrite"`
+	Recursive    bool               `yaml:"recursive"`
+	Fallback     bool               `yaml:"fallback"`
+	Retries      int                `yaml:"retries"`
+	Timeout      time.Duration      `yaml:"timeout"`
+	Verbosity    string             `yaml:"verbosity"`
+}
+
+type hook struct {
+	fs        string
+	recursive bool
+	fallback  bool
+	retries   int
+
+	log     zerolog.Logger
+	rewrite autoscan.Rewriter
+	api     apiClient
+}
+
+// retryBackoff is the time to wait before the first retry of a failed request,
+// every next retry waits one backoff longer.
+var retryBackoff = time.Second
+
+// New creates a hook which refreshes the VFS directory cache of an rclone mount
+// before the targets scan a folder.
+func New(c Config) (autoscan.PreScanHook, error) {
+	l := autoscan.GetLogger(c.Verbosity).With().
+		Str("hook", "rclone").
+		Str("url", c.URL).
+		Logger()
+
+	if c.URL == "" {
+		return nil, fmt.Errorf("rclone: missing url: %w", autoscan.ErrFatal)
+	}
+
+	rewriter, err := autoscan.NewRewriter(c.Rewrite)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	api := newAPIClient(c.URL, c.Username, c.Password, timeout, l)
+
+	// the remote control may be started after autoscan, its configuration is only checked when it is reachable
+	err = api.Validate()
+	switch {
+	case errors.Is(err, autoscan.ErrFatal):
+		return nil, fmt.Errorf("rclone: %w", err)
+	case err != nil:
+		l.Warn().
+			Err(err).
+			Msg("Remote control is not available, not checking its credentials")
+	}
+
+	return &hook{
+		fs:        c.FS,
+		recursive: c.Recursive,
+		fallback:  c.Fallback,
+		retries:   c.Retries,
+
+		log:     l,
+		rewrite: rewriter,
+		api:     api,
+	}, nil
+}
+
+func (h hook) PreScan(scan autoscan.Scan) error {
+	dir := strings.Trim(h.rewrite(scan.Folder), "/")
+
+	// the folder of a removal no longer exists, so the closest parent rclone knows
+	// is refreshed instead, after which the mount no longer lists the folder
+	fallback := h.fallback
+	if scan.Event == autoscan.EventRemoved {
+		dir = parentDir(dir)
+		fallback = true
+	}
+
+	for {
+		result, err := h.refresh(dir)
+		if err != nil {
+			return err
+		}
+
+		if result == "OK" {
+			h.log.Debug().
+				Str("path", scan.Folder).
+				Str("dir", dir).
+				Msg("VFS directory cache refreshed")
+
+			return nil
+		}
+
+		// rclone does not know the directory yet, e.g. a new season of a show
+		if !fallback || dir == "" {
+			return fmt.Errorf("refresh %q: %s: %w", dir, result, autoscan.ErrTargetUnavailable)
+		}
+
+		parent := parentDir(dir)
+
+		h.log.Debug().
+			Str("dir", dir).
+			Str("parent", parent).
+			Str("result", result).
+			Msg("Directory not refreshed, falling back to its parent")
+
+		dir = parent
+	}
+}
+
+// parentDir returns the parent of the directory, which is empty for the root of the remote.
+func parentDir(dir string) string {
+	parent := path.Dir(dir)
+	if parent == "." || parent == "/" {
+		return ""
+	}
+
+	return parent
+}
+
+// refresh refreshes the directory and retries failed requests.
+func (h hook) refresh(dir string) (string, error) {
+	var err error
+	for attempt := 0; attempt <= h.retries; attempt++ {
+		if attempt > 0 {
+			h.log.Warn().
+				Err(err).
+				Str("dir", dir).
+				Int("attempt", attempt).
+				Msg("Failed refreshing VFS directory cache, retrying")
+
+			time.Sleep(time.Duration(attempt) * retryBackoff)
+		}
+
+		var result string
+		result, err = h.api.Refresh(dir, h.recursive, h.fs)
+		if err == nil {
+			return result, nil
+		}
+
+		if errors.Is(err, autoscan.ErrFatal) {
+			return "", err
+		}
+	}
+
+	return "", err
+}
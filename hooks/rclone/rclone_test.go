@@ -0,0 +1,214 @@
+package rclone
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestPreScan(t *testing.T) {
+	type Test struct {
+		Name     string
+		Config   Config
+		Event    autoscan.Event
+		Known    []string
+		Failures int
+		Status   int
+		Refresh  int
+		Delay    time.Duration
+		Commands []string
+		Want     []string
+		Err      error
+	}
+
+	var testCases = []Test{
+		{
+			Name: "Refreshes the rewritten folder",
+			Config: Config{
+				Rewrite: []autoscan.Rewrite{{From: "^/mnt/unionfs/", To: ""}},
+			},
+			Known: []string{"Media/TV/Westworld/Season 1"},
+			Want:  []string{"Media/TV/Westworld/Season 1"},
+		},
+		{
+			Name: "Falls back to the closest known parent",
+			Config: Config{
+				Rewrite:  []autoscan.Rewrite{{From: "^/mnt/unionfs/", To: ""}},
+				Fallback: true,
+			},
+			Known: []string{"Media/TV"},
+			Want:  []string{"Media/TV/Westworld/Season 1", "Media/TV/Westworld", "Media/TV"},
+		},
+		{
+			Name: "Falls back to the root of the remote",
+			Config: Config{
+				Rewrite:  []autoscan.Rewrite{{From: "^/mnt/unionfs/", To: ""}},
+				Fallback: true,
+			},
+			Known: []string{""},
+			Want:  []string{"Media/TV/Westworld/Season 1", "Media/TV/Westworld", "Media/TV", "Media", ""},
+		},
+		{
+			Name: "Fails on unknown folders without fallback",
+			Config: Config{
+				Rewrite: []autoscan.Rewrite{{From: "^/mnt/unionfs/", To: ""}},
+			},
+			Want: []string{"Media/TV/Westworld/Season 1"},
+			Err:  autoscan.ErrTargetUnavailable,
+		},
+		{
+			Name: "Refreshes the parent of a removed folder",
+			Config: Config{
+				Rewrite: []autoscan.Rewrite{{From: "^/mnt/unionfs/", To: ""}},
+			},
+			Event: autoscan.EventRemoved,
+			Known: []string{"Media/TV/Westworld"},
+			Want:  []string{"Media/TV/Westworld"},
+		},
+		{
+			Name: "Refreshes the closest known parent of a removed folder without fallback",
+			Config: Config{
+				Rewrite: []autoscan.Rewrite{{From: "^/mnt/unionfs/", To: ""}},
+			},
+			Event: autoscan.EventRemoved,
+			Known: []string{"Media/TV"},
+			Want:  []string{"Media/TV/Westworld", "Media/TV"},
+		},
+		{
+			Name: "Retries failed requests",
+			Config: Config{
+				Rewrite: []autoscan.Rewrite{{From: "^/mnt/unionfs/", To: ""}},
+				Retries: 2,
+			},
+			Known:    []string{"Media/TV/Westworld/Season 1"},
+			Failures: 2,
+			Want:     []string{"Media/TV/Westworld/Season 1", "Media/TV/Westworld/Season 1", "Media/TV/Westworld/Season 1"},
+		},
+		{
+			Name: "Times out hung requests",
+			Config: Config{
+				Timeout: 10 * time.Millisecond,
+			},
+			Delay: 100 * time.Millisecond,
+			Err:   autoscan.ErrTargetUnavailable,
+		},
+		{
+			Name:   "Rejects invalid credentials at startup",
+			Status: 401,
+			Err:    autoscan.ErrFatal,
+		},
+		{
+			Name:     "Rejects a remote control without vfs/refresh at startup",
+			Commands: []string{"rc/noop"},
+			Err:      autoscan.ErrFatal,
+		},
+		{
+			Name: "Unavailable when the credentials are rejected later on",
+			Config: Config{
+				Rewrite: []autoscan.Rewrite{{From: "^/mnt/unionfs/", To: ""}},
+			},
+			Refresh: 403,
+			Err:     autoscan.ErrTargetUnavailable,
+		},
+	}
+
+	retryBackoff = time.Millisecond
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			known := make(map[string]bool)
+			for _, dir := range tc.Known {
+				known[dir] = true
+			}
+
+			refreshed := make([]string, 0)
+			failures := tc.Failures
+
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				time.Sleep(tc.Delay)
+
+				if tc.Status != 0 {
+					rw.WriteHeader(tc.Status)
+					return
+				}
+
+				if r.URL.Path == "/rc/list" {
+					commands := tc.Commands
+					if commands == nil {
+						commands = []string{"rc/list", "vfs/refresh"}
+					}
+
+					resp := map[string][]map[string]string{"commands": {}}
+					for _, c := range commands {
+						resp["commands"] = append(resp["commands"], map[string]string{"Path": c})
+					}
+
+					json.NewEncoder(rw).Encode(resp)
+					return
+				}
+
+				if r.URL.Path != "/vfs/refresh" {
+					rw.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				if tc.Refresh != 0 {
+					rw.WriteHeader(tc.Refresh)
+					return
+				}
+
+				req := refreshRequest{}
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				refreshed = append(refreshed, req.Dir)
+
+				if failures > 0 {
+					failures--
+					rw.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				result := "OK"
+				if !known[req.Dir] {
+					result = "file does not exist"
+				}
+
+				json.NewEncoder(rw).Encode(map[string]map[string]string{
+					"result": {req.Dir: result},
+				})
+			}))
+
+			defer server.Close()
+
+			tc.Config.URL = server.URL
+			hook, err := New(tc.Config)
+			if errors.Is(err, autoscan.ErrFatal) && errors.Is(err, tc.Err) {
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = hook.PreScan(autoscan.Scan{Folder: "/mnt/unionfs/Media/TV/Westworld/Season 1", Event: tc.Event})
+			if !errors.Is(err, tc.Err) {
+				t.Fatalf("Errors do not match: %v vs %v", err, tc.Err)
+			}
+
+			if tc.Want != nil && !reflect.DeepEqual(refreshed, tc.Want) {
+				t.Log(refreshed)
+				t.Log(tc.Want)
+				t.Errorf("Refreshed directories do not match")
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+const (
+	// AuditAuthFailure is a request which failed to authenticate,
+	// or of which the user lacks the required role.
+	AuditAuthFailure = "auth_failure"
+
+	// AuditWebhook is a request which a HTTP trigger accepted.
+	AuditWebhook = "webhook"
+
+	// AuditAdmin is a request which changed the state of autoscan through the API,
+	// e.g. to flush the queue or to pause the processor.
+	AuditAdmin = "admin"
+)
+
+// AuditEntry records a request to the HTTP triggers or the API.
+// The scans are the number of scans which a webhook added to the queue.
+type AuditEntry struct {
+	ID       int64     `json:"id"`
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Trigger  string    `json:"trigger"`
+	SourceIP string    `json:"source_ip"`
+	Username string    `json:"username"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	Scans    int       `json:"scans"`
+}
+
+const sqlInsertAudit = `
+INSERT INTO audit (time, event, trigger, source_ip, username, method, path, status, scans)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+const sqlPruneAudit = `
+DELETE FROM audit WHERE time < ?
+`
+
+// AddAudit records the entry at the current time
+// and removes the entries which are older than the retention period.
+func (store *datastore) AddAudit(e AuditEntry, retention time.Duration) error {
+	t := now()
+
+	_, err := store.Exec(sqlInsertAudit, t, e.Event, e.Trigger, e.SourceIP, e.Username, e.Method, e.Path, e.Status, e.Scans)
+	if err != nil {
+		return fmt.Errorf("add audit: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	_, err = store.Exec(sqlPruneAudit, t.Add(-1*retention))
+	if err != nil {
+		return fmt.Errorf("prune audit: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+const sqlGetAudit = `
+SELECT id, time, event, trigger, source_ip, username, method, path, status, scans FROM audit
+WHERE CAST(? AS TEXT) = '' OR event = ?
+ORDER BY id DESC
+LIMIT ?
+`
+
+// GetAudit returns the most recent audit entries of the event, or of every event when empty.
+func (store *datastore) GetAudit(event string, limit int) ([]AuditEntry, error) {
+	rows, err := store.Query(sqlGetAudit, event, event, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get audit: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		e := AuditEntry{}
+		err = rows.Scan(&e.ID, &e.Time, &e.Event, &e.Trigger, &e.SourceIP, &e.Username, &e.Method, &e.Path, &e.Status, &e.Scans)
+		if err != nil {
+			return nil, fmt.Errorf("get audit: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Audit records the entry in the audit log.
+// The audit log is disabled when the retention period is zero.
+func (p *Processor) Audit(e AuditEntry) error {
+	if p.auditRetention <= 0 {
+		return nil
+	}
+
+	return p.store.AddAudit(e, p.auditRetention)
+}
+
+// AuditLog returns the most recent audit entries of the event, or of every event when empty.
+func (p *Processor) AuditLog(event string, limit int) ([]AuditEntry, error) {
+	return p.store.GetAudit(event, limit)
+}
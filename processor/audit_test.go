@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	testTime := time.Date(2020, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	store, err := newDatastore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	add := func(at time.Time, e AuditEntry) {
+		now = func() time.Time {
+			return at
+		}
+
+		if err := store.AddAudit(e, 48*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add(testTime.Add(-72*time.Hour), AuditEntry{Event: AuditWebhook, Trigger: "sonarr", Method: "POST", Path: "/triggers/sonarr", Status: 200, Scans: 1})
+	add(testTime.Add(-time.Hour), AuditEntry{Event: AuditAuthFailure, SourceIP: "10.0.0.1", Username: "obi-wan", Method: "GET", Path: "/api/queue", Status: 401})
+	add(testTime, AuditEntry{Event: AuditAdmin, SourceIP: "10.0.0.2", Username: "hello there", Method: "POST", Path: "/api/queue/flush", Status: 200})
+
+	entries, err := store.GetAudit("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the entry of the webhook exceeded the retention period
+	if len(entries) != 2 {
+		t.Fatalf("Number of entries does not match: %d vs %d", len(entries), 2)
+	}
+
+	if entries[0].Event != AuditAdmin || entries[0].Path != "/api/queue/flush" || !entries[0].Time.Equal(testTime) {
+		t.Errorf("Most recent entry does not match: %+v", entries[0])
+	}
+
+	failures, err := store.GetAudit(AuditAuthFailure, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failures) != 1 || failures[0].Username != "obi-wan" || failures[0].Status != 401 {
+		t.Errorf("Authentication failures do not match: %+v", failures)
+	}
+}
+
+func TestAuditDisabled(t *testing.T) {
+	store, err := newDatastore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proc := &Processor{store: store}
+	if err := proc.Audit(AuditEntry{Event: AuditAdmin}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := proc.AuditLog("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("Number of entries does not match: %d vs %d", len(entries), 0)
+	}
+}
@@ -0,0 +1,1434 @@
+package processor
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltDatastore keeps the datastore in a bbolt database, a key-value store written in pure Go,
+// such that autoscan can be built without cgo.
+// Its records are stored as JSON, and are filtered and sorted in memory,
+// which is fast enough for the size of a queue.
+type boltDatastore struct {
+	*bolt.DB
+
+	// aead encrypts the values, and mac hashes the keys of an encrypted datastore.
+	aead cipher.AEAD
+	mac  []byte
+}
+
+var (
+	bucketScan       = []byte("scan")
+	bucketDelivered  = []byte("delivered")
+	bucketRetry      = []byte("retry")
+	bucketDeadLetter = []byte("dead_letter")
+	bucketHistory    = []byte("history")
+	bucketAudit      = []byte("audit")
+	bucketPause      = []byte("pause")
+	bucketClaim      = []byte("claim")
+	bucketMeta       = []byte("meta")
+)
+
+var (
+	keyPause      = []byte("pause")
+	keyEncryption = []byte("encryption")
+)
+
+// newBoltDatastore opens the bolt datastore at path,
+// which is encrypted with the encryption key when given.
+func newBoltDatastore(path string, encryptionKey string) (*boltDatastore, error) {
+	store := &boltDatastore{}
+	if encryptionKey != "" {
+		var err error
+		store.aead, store.mac, err = newBoltCipher(encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// a bbolt database is locked by the process which opened it,
+	// other processes give up instead of waiting for autoscan to stop.
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	store.DB = db
+	err = db.Update(func(tx *bolt.Tx) error {
+		created := tx.Bucket(bucketScan) == nil
+
+		buckets := [][]byte{bucketScan, bucketDelivered, bucketRetry, bucketDeadLetter, bucketHistory, bucketAudit, bucketPause, bucketClaim, bucketMeta}
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		// an encrypted datastore holds an encrypted value to check the encryption key
+		meta := tx.Bucket(bucketMeta)
+		check := meta.Get(keyEncryption)
+		switch {
+		case created && store.aead != nil:
+			return store.put(meta, keyEncryption, "autoscan")
+		case check == nil && store.aead != nil:
+			return errors.New("bolt database is not encrypted")
+		case check != nil && store.aead == nil:
+			return errors.New("bolt database is encrypted, but no encryption key is given")
+		case check != nil:
+			var value string
+			if err := store.decode(check, &value); err != nil {
+				return errors.New("invalid encryption key")
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// boltScan is a queued scan, keyed by its folder.
+type boltScan struct {
+	Folder      string         `json:"folder"`
+	ID          string         `json:"id"`
+	Priority    int            `json:"priority"`
+	Event       autoscan.Event `json:"event"`
+	Targets     string         `json:"targets"`
+	CheckExists bool           `json:"check_exists"`
+	Trigger     string         `json:"trigger"`
+	Time        time.Time      `json:"time"`
+	Updated     time.Time      `json:"updated"`
+}
+
+func (s boltScan) scan() autoscan.Scan {
+	return autoscan.Scan{
+		Folder:      s.Folder,
+		Priority:    s.Priority,
+		Event:       s.Event,
+		Targets:     splitTargets(s.Targets),
+		CheckExists: s.CheckExists,
+		Trigger:     s.Trigger,
+		Time:        s.Time,
+	}
+}
+
+// boltRetry is a failed attempt to deliver a scan to a target.
+type boltRetry struct {
+	Attempts int       `json:"attempts"`
+	RetryAt  time.Time `json:"retry_at"`
+}
+
+func itob(id int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+func btoi(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (store *boltDatastore) get(b *bolt.Bucket, key []byte, v interface{}) (bool, error) {
+	data := b.Get(key)
+	if data == nil {
+		return false, nil
+	}
+
+	return true, store.decode(data, v)
+}
+
+func (store *boltDatastore) put(b *bolt.Bucket, key []byte, v interface{}) error {
+	data, err := store.encode(v)
+	if err != nil {
+		return err
+	}
+
+	return b.Put(key, data)
+}
+
+// boltDelete removes the keys of the bucket for which remove returns true.
+// Keys are removed after iterating, as removing keys moves the cursor.
+func boltDelete(b *bolt.Bucket, remove func(k, v []byte) (bool, error)) (int64, error) {
+	var keys [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		ok, err := remove(k, v)
+		if ok {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(keys)), nil
+}
+
+func boltDeletePrefix(b *bolt.Bucket, prefix []byte) error {
+	_, err := boltDelete(b, func(k, v []byte) (bool, error) {
+		return bytes.HasPrefix(k, prefix), nil
+	})
+
+	return err
+}
+
+func (store *boltDatastore) upsert(tx *bolt.Tx, scan autoscan.Scan) error {
+	b := tx.Bucket(bucketScan)
+	s := boltScan{
+		Folder:      scan.Folder,
+		ID:          scan.ID(),
+		Priority:    scan.Priority,
+		Event:       scan.Event,
+		Targets:     joinTargets(scan.Targets),
+		CheckExists: scan.CheckExists,
+		Trigger:     scan.Trigger,
+		Time:        scan.Time,
+		Updated:     now(),
+	}
+
+	// merged as in sqlUpsert
+	existing := boltScan{}
+	ok, err := store.get(b, store.key(scan.Folder), &existing)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		if existing.Priority > s.Priority {
+			s.Priority = existing.Priority
+		}
+
+		s.Event = existing.Event.Merge(s.Event)
+		s.CheckExists = s.CheckExists && existing.CheckExists
+
+		switch {
+		case s.Targets == "" || existing.Targets == "":
+			s.Targets = ""
+		case s.Targets == existing.Targets:
+		default:
+			s.Targets = existing.Targets + "," + s.Targets
+		}
+	}
+
+	if err := store.put(b, store.key(scan.Folder), s); err != nil {
+		return err
+	}
+
+	// a new scan of a folder must be delivered to all targets again
+	if err := boltDeletePrefix(tx.Bucket(bucketDelivered), store.folderKey(scan.Folder)); err != nil {
+		return err
+	}
+
+	return boltDeletePrefix(tx.Bucket(bucketRetry), store.folderKey(scan.Folder))
+}
+
+func (store *boltDatastore) Upsert(scans []autoscan.Scan) error {
+	return store.Update(func(tx *bolt.Tx) error {
+		for _, scan := range scans {
+			if err := store.upsert(tx, scan); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// available returns the scans which are older than minAge, have not been upserted during the last hold,
+// have not yet been delivered to the target, and are not waiting to be retried or claimed for the target.
+// The scans are ordered by folder.
+func (store *boltDatastore) available(tx *bolt.Tx, target string, minAge time.Duration, hold time.Duration) ([]boltScan, error) {
+	t := now()
+	delivered := tx.Bucket(bucketDelivered)
+	retry := tx.Bucket(bucketRetry)
+	claim := tx.Bucket(bucketClaim)
+
+	scans := make([]boltScan, 0)
+	err := tx.Bucket(bucketScan).ForEach(func(k, v []byte) error {
+		s := boltScan{}
+		if err := store.decode(v, &s); err != nil {
+			return err
+		}
+
+		if !s.Time.Before(t.Add(-1*minAge)) || s.Updated.After(t.Add(-1*hold)) {
+			return nil
+		}
+
+		key := store.targetKey(s.Folder, target)
+		if delivered.Get(key) != nil {
+			return nil
+		}
+
+		r := boltRetry{}
+		retried, err := store.get(retry, key, &r)
+		if err != nil {
+			return err
+		}
+
+		c := boltClaim{}
+		claimed, err := store.get(claim, key, &c)
+		if err != nil {
+			return err
+		}
+
+		if (!retried || !r.RetryAt.After(t)) && (!claimed || !c.ExpiresAt.After(t)) {
+			scans = append(scans, s)
+		}
+
+		return nil
+	})
+
+	// the hashed keys of an encrypted datastore are not ordered by folder
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].Folder < scans[j].Folder
+	})
+
+	return scans, err
+}
+
+// GetAvailableScan returns the scan with the highest priority which is older than minAge,
+// has not been upserted during the last hold, and has not yet been delivered to the target.
+// Priorities are aged as in sqlGetAvailableScanAging when aging is larger than zero.
+func (store *boltDatastore) GetAvailableScan(target string, minAge time.Duration, hold time.Duration, aging time.Duration) (autoscan.Scan, error) {
+	scan := autoscan.Scan{}
+	err := store.View(func(tx *bolt.Tx) error {
+		scans, err := store.available(tx, target, minAge, hold)
+		if err != nil {
+			return err
+		}
+
+		if len(scans) == 0 {
+			return autoscan.ErrNoScans
+		}
+
+		t := now()
+		priority := func(s boltScan) int {
+			if aging <= 0 {
+				return s.Priority
+			}
+
+			return s.Priority + int(t.Sub(s.Time).Seconds()/aging.Seconds())
+		}
+
+		sort.SliceStable(scans, func(i, j int) bool {
+			pi, pj := priority(scans[i]), priority(scans[j])
+			if pi != pj {
+				return pi > pj
+			}
+
+			return scans[i].Time.Before(scans[j].Time)
+		})
+
+		scan = scans[0].scan()
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, autoscan.ErrNoScans):
+		return scan, err
+	case err != nil:
+		return scan, fmt.Errorf("get matching: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return scan, nil
+}
+
+// GetAvailableSiblings returns the scans of the direct subfolders of parent
+// which are older than minAge, have not been upserted during the last hold,
+// and have not yet been delivered to the target.
+func (store *boltDatastore) GetAvailableSiblings(target string, parent string, minAge time.Duration, hold time.Duration) ([]autoscan.Scan, error) {
+	prefix := strings.TrimSuffix(parent, "/") + "/"
+
+	scans := make([]autoscan.Scan, 0)
+	err := store.View(func(tx *bolt.Tx) error {
+		available, err := store.available(tx, target, minAge, hold)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range available {
+			if strings.HasPrefix(s.Folder, prefix) && !strings.Contains(s.Folder[len(prefix):], "/") {
+				scans = append(scans, s.scan())
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("get siblings: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return scans, nil
+}
+
+// GetScanByID returns the queued scan with the given ID,
+// or autoscan.ErrNoScans when the scan is not queued.
+func (store *boltDatastore) GetScanByID(id string) (autoscan.Scan, error) {
+	scan := autoscan.Scan{}
+	found := false
+
+	err := store.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketScan).ForEach(func(k, v []byte) error {
+			s := boltScan{}
+			if err := store.decode(v, &s); err != nil {
+				return err
+			}
+
+			if s.ID == id {
+				scan = s.scan()
+				found = true
+			}
+
+			return nil
+		})
+	})
+
+	switch {
+	case err != nil:
+		return scan, fmt.Errorf("get by id: %s: %w", err, autoscan.ErrFatal)
+	case !found:
+		return scan, autoscan.ErrNoScans
+	}
+
+	return scan, nil
+}
+
+// Count returns the number of queued scans.
+func (store *boltDatastore) Count() (int, error) {
+	count := 0
+	err := store.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(bucketScan).Stats().KeyN
+		return nil
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("count: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return count, nil
+}
+
+func (store *boltDatastore) GetAll() (scans []autoscan.Scan, err error) {
+	err = store.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketScan).ForEach(func(k, v []byte) error {
+			s := boltScan{}
+			if err := store.decode(v, &s); err != nil {
+				return err
+			}
+
+			scans = append(scans, s.scan())
+			return nil
+		})
+	})
+
+	return scans, err
+}
+
+// delete removes the scan of the folder with its deliveries, retries and claims.
+func (store *boltDatastore) delete(tx *bolt.Tx, folder string) error {
+	if err := tx.Bucket(bucketScan).Delete(store.key(folder)); err != nil {
+		return err
+	}
+
+	for _, name := range [][]byte{bucketDelivered, bucketRetry, bucketClaim} {
+		if err := boltDeletePrefix(tx.Bucket(name), store.folderKey(folder)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *boltDatastore) Delete(scan autoscan.Scan) error {
+	err := store.Update(func(tx *bolt.Tx) error {
+		return store.delete(tx, scan.Folder)
+	})
+
+	if err != nil {
+		return fmt.Errorf("delete: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// Deliver marks the scan as delivered to the target, and releases the claim of the scan for the target.
+// The scan is deleted once it has been delivered to all the given targets it is meant for,
+// in which case Deliver returns true.
+func (store *boltDatastore) Deliver(scan autoscan.Scan, target string, targets []string) (bool, error) {
+	done := false
+	err := store.Update(func(tx *bolt.Tx) (err error) {
+		done, err = store.deliver(tx, scan, target, targets)
+		return err
+	})
+
+	if err != nil {
+		return false, fmt.Errorf("deliver: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return done, nil
+}
+
+func (store *boltDatastore) deliver(tx *bolt.Tx, scan autoscan.Scan, target string, targets []string) (bool, error) {
+	queued := boltScan{}
+	ok, err := store.get(tx.Bucket(bucketScan), store.key(scan.Folder), &queued)
+	if err != nil {
+		return false, err
+	}
+
+	// only scans which have not been updated since they were retrieved are marked as delivered
+	b := tx.Bucket(bucketDelivered)
+	if ok && queued.Time.Equal(scan.Time) {
+		if err := store.put(b, store.targetKey(scan.Folder, target), target); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Bucket(bucketClaim).Delete(store.targetKey(scan.Folder, target)); err != nil {
+		return false, err
+	}
+
+	delivered := make(map[string]bool)
+	prefix := store.folderKey(scan.Folder)
+	c := b.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var t string
+		if err := store.decode(v, &t); err != nil {
+			return false, err
+		}
+
+		delivered[t] = true
+	}
+
+	for _, t := range targets {
+		if scan.ForTarget(t) && !delivered[t] {
+			return false, nil
+		}
+	}
+
+	// all targets received the scan
+	return true, store.delete(tx, scan.Folder)
+}
+
+// GetAttempts returns the number of failed attempts to deliver the scan to the target.
+func (store *boltDatastore) GetAttempts(scan autoscan.Scan, target string) (int, error) {
+	r := boltRetry{}
+	err := store.View(func(tx *bolt.Tx) error {
+		_, err := store.get(tx.Bucket(bucketRetry), store.targetKey(scan.Folder, target), &r)
+		return err
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("get attempts: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return r.Attempts, nil
+}
+
+// Retry records a failed attempt to deliver the scan to the target,
+// and releases the claim of the scan for the target.
+// The scan is retried for the target after retryAt.
+func (store *boltDatastore) Retry(scan autoscan.Scan, target string, attempts int, retryAt time.Time) error {
+	err := store.Update(func(tx *bolt.Tx) error {
+		key := store.targetKey(scan.Folder, target)
+		if err := store.put(tx.Bucket(bucketRetry), key, boltRetry{Attempts: attempts, RetryAt: retryAt}); err != nil {
+			return err
+		}
+
+		return tx.Bucket(bucketClaim).Delete(key)
+	})
+
+	if err != nil {
+		return fmt.Errorf("retry: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// Reschedule moves the next attempt of the scan for all targets to retryAt,
+// without resetting the number of attempts.
+func (store *boltDatastore) Reschedule(scan autoscan.Scan, retryAt time.Time) error {
+	err := store.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRetry)
+		prefix := store.folderKey(scan.Folder)
+
+		// keys are updated after iterating, as updating keys moves the cursor
+		retries := make(map[string]boltRetry)
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			r := boltRetry{}
+			if err := store.decode(v, &r); err != nil {
+				return err
+			}
+
+			r.RetryAt = retryAt
+			retries[string(k)] = r
+		}
+
+		for k, r := range retries {
+			if err := store.put(b, []byte(k), r); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("reschedule: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// GetRetrying returns the folders of the scans which are waiting to be retried for at least one target.
+func (store *boltDatastore) GetRetrying() (map[string]bool, error) {
+	folders := make(map[string]bool)
+	t := now()
+
+	err := store.View(func(tx *bolt.Tx) error {
+		retrying := make(map[string]bool)
+		err := tx.Bucket(bucketRetry).ForEach(func(k, v []byte) error {
+			r := boltRetry{}
+			if err := store.decode(v, &r); err != nil {
+				return err
+			}
+
+			if r.RetryAt.After(t) {
+				retrying[string(store.scanKey(k))] = true
+			}
+
+			return nil
+		})
+
+		if err != nil || len(retrying) == 0 {
+			return err
+		}
+
+		// the keys of the retries do not reveal the folders when the datastore is encrypted
+		return tx.Bucket(bucketScan).ForEach(func(k, v []byte) error {
+			s := boltScan{}
+			if err := store.decode(v, &s); err != nil {
+				return err
+			}
+
+			if retrying[string(store.scanKey(store.folderKey(s.Folder)))] {
+				folders[s.Folder] = true
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("get retrying: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return folders, nil
+}
+
+// boltClaim is the claim of an instance on a scan for a target.
+type boltClaim struct {
+	ClaimedBy string    `json:"claimed_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Claim claims the scan for the target on behalf of the instance until the lease expires.
+// It returns false when the scan is claimed by another instance.
+func (store *boltDatastore) Claim(scan autoscan.Scan, target string, instance string, lease time.Duration) (bool, error) {
+	t := now()
+	claimed := false
+
+	err := store.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketClaim)
+		key := store.targetKey(scan.Folder, target)
+
+		c := boltClaim{}
+		ok, err := store.get(b, key, &c)
+		if err != nil {
+			return err
+		}
+
+		if ok && c.ClaimedBy != instance && c.ExpiresAt.After(t) {
+			return nil
+		}
+
+		claimed = true
+		return store.put(b, key, boltClaim{ClaimedBy: instance, ExpiresAt: t.Add(lease)})
+	})
+
+	if err != nil {
+		return false, fmt.Errorf("claim: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return claimed, nil
+}
+
+// Release removes the claim of the instance on the scan for the target.
+func (store *boltDatastore) Release(scan autoscan.Scan, target string, instance string) error {
+	err := store.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketClaim)
+		key := store.targetKey(scan.Folder, target)
+
+		c := boltClaim{}
+		ok, err := store.get(b, key, &c)
+		if err != nil || !ok || c.ClaimedBy != instance {
+			return err
+		}
+
+		return b.Delete(key)
+	})
+
+	if err != nil {
+		return fmt.Errorf("release: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// insertFailed adds the failed scan to the dead-letter queue with a new ID.
+func (store *boltDatastore) insertFailed(tx *bolt.Tx, f FailedScan) error {
+	b := tx.Bucket(bucketDeadLetter)
+	id, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	f.ID = int64(id)
+	return store.put(b, itob(f.ID), f)
+}
+
+// DeadLetter moves the scan of the target to the dead-letter queue.
+// The scan is no longer sent to the target, and is treated as delivered to the target.
+// It returns true when the scan has been delivered to all targets, as in Deliver.
+func (store *boltDatastore) DeadLetter(scan autoscan.Scan, target string, attempts int, reason string, targets []string) (bool, error) {
+	done := false
+	err := store.Update(func(tx *bolt.Tx) (err error) {
+		err = store.insertFailed(tx, FailedScan{
+			Folder:   scan.Folder,
+			Target:   target,
+			Priority: scan.Priority,
+			Event:    scan.Event,
+			Attempts: attempts,
+			Error:    reason,
+			Time:     now(),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(bucketRetry).Delete(store.targetKey(scan.Folder, target)); err != nil {
+			return err
+		}
+
+		done, err = store.deliver(tx, scan, target, targets)
+		return err
+	})
+
+	if err != nil {
+		return false, fmt.Errorf("dead letter: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return done, nil
+}
+
+// GetFailed returns the scans in the dead-letter queue.
+func (store *boltDatastore) GetFailed() ([]FailedScan, error) {
+	failed := make([]FailedScan, 0)
+	err := store.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketDeadLetter).ForEach(func(k, v []byte) error {
+			f := FailedScan{}
+			if err := store.decode(v, &f); err != nil {
+				return err
+			}
+
+			failed = append(failed, f)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("get failed: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return failed, nil
+}
+
+// Requeue moves the scans with the given IDs from the dead-letter queue back to the queue,
+// limited to the target the scan failed for.
+// It returns the number of requeued scans.
+func (store *boltDatastore) Requeue(ids []int64) (int, error) {
+	requeued := 0
+	err := store.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketDeadLetter)
+		for _, id := range ids {
+			f := FailedScan{}
+			ok, err := store.get(b, itob(id), &f)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				continue
+			}
+
+			scan := autoscan.Scan{
+				Folder:   f.Folder,
+				Priority: f.Priority,
+				Event:    f.Event,
+				Targets:  []string{f.Target},
+				Time:     now(),
+			}
+
+			if err := store.upsert(tx, scan); err != nil {
+				return err
+			}
+
+			if err := b.Delete(itob(id)); err != nil {
+				return err
+			}
+
+			requeued++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("requeue: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return requeued, nil
+}
+
+// DeleteFailed removes the scans of the folder from the dead-letter queue.
+func (store *boltDatastore) DeleteFailed(folder string) error {
+	err := store.Update(func(tx *bolt.Tx) error {
+		_, err := boltDelete(tx.Bucket(bucketDeadLetter), func(k, v []byte) (bool, error) {
+			f := FailedScan{}
+			err := store.decode(v, &f)
+			return f.Folder == folder, err
+		})
+
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("delete failed: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// HasFailed returns whether the scan was moved to the dead-letter queue of any target.
+func (store *boltDatastore) HasFailed(scan autoscan.Scan) (bool, error) {
+	failed, err := store.GetFailed()
+	if err != nil {
+		return false, fmt.Errorf("has failed: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	for _, f := range failed {
+		if f.Folder == scan.Folder && !f.Time.Before(scan.Time) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// insertHistory adds the entry to the history with a new ID.
+func (store *boltDatastore) insertHistory(tx *bolt.Tx, e HistoryEntry) error {
+	b := tx.Bucket(bucketHistory)
+	id, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	e.ID = int64(id)
+	return store.put(b, itob(e.ID), e)
+}
+
+func (store *boltDatastore) pruneHistory(tx *bolt.Tx, before time.Time) (int64, error) {
+	return boltDelete(tx.Bucket(bucketHistory), func(k, v []byte) (bool, error) {
+		e := HistoryEntry{}
+		err := store.decode(v, &e)
+		return e.ScannedAt.Before(before), err
+	})
+}
+
+// AddHistory records the outcome of the scan for the target
+// and removes the entries which are older than the retention period.
+func (store *boltDatastore) AddHistory(scan autoscan.Scan, target string, status string, duration time.Duration, retention time.Duration) error {
+	t := now()
+
+	err := store.Update(func(tx *bolt.Tx) error {
+		return store.insertHistory(tx, HistoryEntry{
+			ScanID:    scan.ID(),
+			Folder:    scan.Folder,
+			Trigger:   scan.Trigger,
+			Target:    target,
+			Status:    status,
+			QueuedAt:  scan.Time,
+			ScannedAt: t,
+			Duration:  duration,
+		})
+	})
+
+	if err != nil {
+		return fmt.Errorf("add history: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	err = store.Update(func(tx *bolt.Tx) error {
+		_, err := store.pruneHistory(tx, t.Add(-1*retention))
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("prune history: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// history returns all history entries, ordered by ID.
+func (store *boltDatastore) history() ([]HistoryEntry, error) {
+	entries := make([]HistoryEntry, 0)
+	err := store.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketHistory).ForEach(func(k, v []byte) error {
+			e := HistoryEntry{}
+			if err := store.decode(v, &e); err != nil {
+				return err
+			}
+
+			entries = append(entries, e)
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+// GetHistory returns the most recent history entries.
+func (store *boltDatastore) GetHistory(limit int) ([]HistoryEntry, error) {
+	entries := make([]HistoryEntry, 0)
+	err := store.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketHistory).Cursor()
+		for k, v := c.Last(); k != nil && (limit < 0 || len(entries) < limit); k, v = c.Prev() {
+			e := HistoryEntry{}
+			if err := store.decode(v, &e); err != nil {
+				return err
+			}
+
+			entries = append(entries, e)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("get history: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return entries, nil
+}
+
+// AddAudit records the entry at the current time
+// and removes the entries which are older than the retention period.
+func (store *boltDatastore) AddAudit(e AuditEntry, retention time.Duration) error {
+	t := now()
+
+	err := store.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAudit)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		e.ID = int64(id)
+		e.Time = t
+		return store.put(b, itob(e.ID), e)
+	})
+
+	if err != nil {
+		return fmt.Errorf("add audit: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	err = store.Update(func(tx *bolt.Tx) error {
+		_, err := boltDelete(tx.Bucket(bucketAudit), func(k, v []byte) (bool, error) {
+			e := AuditEntry{}
+			err := store.decode(v, &e)
+			return e.Time.Before(t.Add(-1 * retention)), err
+		})
+
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("prune audit: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// GetAudit returns the most recent audit entries of the event, or of every event when empty.
+func (store *boltDatastore) GetAudit(event string, limit int) ([]AuditEntry, error) {
+	entries := make([]AuditEntry, 0)
+	err := store.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketAudit).Cursor()
+		for k, v := c.Last(); k != nil && len(entries) < limit; k, v = c.Prev() {
+			e := AuditEntry{}
+			if err := store.decode(v, &e); err != nil {
+				return err
+			}
+
+			if event == "" || e.Event == event {
+				entries = append(entries, e)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("get audit: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return entries, nil
+}
+
+// GetHistoryStatus returns the status of the last time the scan with the given ID was processed,
+// or StatusUnknown when the scan has no history.
+// A scan failed when it failed for any of the targets.
+func (store *boltDatastore) GetHistoryStatus(id string) (string, error) {
+	entries, err := store.history()
+	if err != nil {
+		return "", fmt.Errorf("get history status: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	// the latest outcomes of a scan share the time at which the scan was queued
+	var latest time.Time
+	for _, e := range entries {
+		if e.ScanID == id && e.QueuedAt.After(latest) {
+			latest = e.QueuedAt
+		}
+	}
+
+	status := StatusUnknown
+	for _, e := range entries {
+		if e.ScanID == id && e.QueuedAt.Equal(latest) {
+			status = mergeStatus(status, e.Status)
+		}
+	}
+
+	return status, nil
+}
+
+// GetStats aggregates the history entries.
+func (store *boltDatastore) GetStats() (Stats, error) {
+	stats := Stats{
+		ScansPerDay: make([]DayStats, 0),
+		Targets:     make([]TargetStats, 0),
+	}
+
+	entries, err := store.history()
+	if err != nil {
+		return stats, fmt.Errorf("get stats: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	days := make(map[string]map[string]bool)
+	targets := make(map[string]*TargetStats)
+	durations := make(map[string]float64)
+	var latency float64
+	completed := 0
+
+	for _, e := range entries {
+		ts, ok := targets[e.Target]
+		if !ok {
+			ts = &TargetStats{Target: e.Target}
+			targets[e.Target] = ts
+		}
+
+		switch e.Status {
+		case StatusCompleted:
+			ts.Completed++
+			durations[e.Target] += float64(e.Duration)
+
+			// days are in UTC, as with date() in SQLite
+			day := e.ScannedAt.UTC().Format("2006-01-02")
+			if days[day] == nil {
+				days[day] = make(map[string]bool)
+			}
+
+			days[day][e.ScanID] = true
+			latency += e.ScannedAt.Sub(e.QueuedAt).Seconds()
+			completed++
+		case StatusFailed:
+			ts.Failed++
+		case StatusExpired:
+			ts.Expired++
+		}
+	}
+
+	for day, scans := range days {
+		stats.ScansPerDay = append(stats.ScansPerDay, DayStats{Date: day, Scans: len(scans)})
+	}
+
+	sort.Slice(stats.ScansPerDay, func(i, j int) bool {
+		return stats.ScansPerDay[i].Date < stats.ScansPerDay[j].Date
+	})
+
+	if completed > 0 {
+		stats.AverageLatency = time.Duration(latency / float64(completed) * float64(time.Second))
+	}
+
+	for _, ts := range targets {
+		if ts.Completed > 0 {
+			ts.AverageDuration = time.Duration(durations[ts.Target] / float64(ts.Completed))
+		}
+
+		stats.Targets = append(stats.Targets, *ts)
+	}
+
+	sort.Slice(stats.Targets, func(i, j int) bool {
+		return stats.Targets[i].Target < stats.Targets[j].Target
+	})
+
+	return stats, nil
+}
+
+// GetHistoryFolders returns the folders in the history with the given scan IDs,
+// or the folders within the path prefix when given.
+func (store *boltDatastore) GetHistoryFolders(ids []string, prefix string) ([]string, error) {
+	entries, err := store.history()
+	if err != nil {
+		return nil, fmt.Errorf("get history folders: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	folders := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, id := range ids {
+		for _, e := range entries {
+			if e.ScanID == id && !seen[e.Folder] {
+				seen[e.Folder] = true
+				folders = append(folders, e.Folder)
+			}
+		}
+	}
+
+	if prefix != "" {
+		// a folder matches the prefix when it equals the prefix or is one of its subfolders
+		prefix = strings.TrimSuffix(prefix, "/")
+
+		matched := make([]string, 0)
+		for _, e := range entries {
+			if (e.Folder == prefix || strings.HasPrefix(e.Folder, prefix+"/")) && !seen[e.Folder] {
+				seen[e.Folder] = true
+				matched = append(matched, e.Folder)
+			}
+		}
+
+		sort.Strings(matched)
+		folders = append(folders, matched...)
+	}
+
+	return folders, nil
+}
+
+// Pause records that the processor is paused.
+// Pausing twice keeps the time of the first pause.
+func (store *boltDatastore) Pause() error {
+	err := store.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketPause)
+		if b.Get(keyPause) != nil {
+			return nil
+		}
+
+		return store.put(b, keyPause, now())
+	})
+
+	if err != nil {
+		return fmt.Errorf("pause: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// Resume removes the pause of the processor.
+func (store *boltDatastore) Resume() error {
+	err := store.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketPause).Delete(keyPause)
+	})
+
+	if err != nil {
+		return fmt.Errorf("resume: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// GetPause returns whether the processor is paused, and since when.
+func (store *boltDatastore) GetPause() (PauseStatus, error) {
+	var since time.Time
+	paused := false
+
+	err := store.View(func(tx *bolt.Tx) (err error) {
+		paused, err = store.get(tx.Bucket(bucketPause), keyPause, &since)
+		return err
+	})
+
+	switch {
+	case err != nil:
+		return PauseStatus{}, fmt.Errorf("get pause: %s: %w", err, autoscan.ErrFatal)
+	case !paused:
+		return PauseStatus{}, nil
+	}
+
+	return PauseStatus{Paused: true, Since: &since}, nil
+}
+
+// Prune removes the history entries and failed scans which are older than their retention period,
+// and the deliveries, retries and claims of scans which are no longer queued.
+// Entries are kept when their retention period is zero.
+func (store *boltDatastore) Prune(historyRetention time.Duration, failedRetention time.Duration) (MaintenanceResult, error) {
+	result := MaintenanceResult{}
+	t := now()
+
+	err := store.Update(func(tx *bolt.Tx) (err error) {
+		if historyRetention > 0 {
+			result.History, err = store.pruneHistory(tx, t.Add(-1*historyRetention))
+			if err != nil {
+				return err
+			}
+		}
+
+		if failedRetention > 0 {
+			result.Failed, err = boltDelete(tx.Bucket(bucketDeadLetter), func(k, v []byte) (bool, error) {
+				f := FailedScan{}
+				err := store.decode(v, &f)
+				return f.Time.Before(t.Add(-1 * failedRetention)), err
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		scans := tx.Bucket(bucketScan)
+		for _, name := range [][]byte{bucketDelivered, bucketRetry, bucketClaim} {
+			orphans, err := boltDelete(tx.Bucket(name), func(k, v []byte) (bool, error) {
+				return scans.Get(store.scanKey(k)) == nil, nil
+			})
+
+			if err != nil {
+				return err
+			}
+
+			result.Orphans += orphans
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return result, fmt.Errorf("prune: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return result, nil
+}
+
+// Vacuum does nothing, as bbolt reuses the space of removed entries.
+func (store *boltDatastore) Vacuum() error {
+	return nil
+}
+
+// Check returns the problems found by the consistency check of the bolt database.
+func (store *boltDatastore) Check() ([]string, error) {
+	problems := make([]string, 0)
+	err := store.View(func(tx *bolt.Tx) error {
+		for err := range tx.Check() {
+			problems = append(problems, err.Error())
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("check: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return problems, nil
+}
+
+// Counts returns the number of entries in the datastore per state, and the queued scans per priority.
+func (store *boltDatastore) Counts() (DatabaseStats, error) {
+	stats := DatabaseStats{History: make(map[string]int), Priorities: make(map[int]int)}
+	t := now()
+
+	err := store.View(func(tx *bolt.Tx) error {
+		stats.Queued = tx.Bucket(bucketScan).Stats().KeyN
+		stats.Delivered = tx.Bucket(bucketDelivered).Stats().KeyN
+		stats.Failed = tx.Bucket(bucketDeadLetter).Stats().KeyN
+
+		retrying := make(map[string]bool)
+		err := tx.Bucket(bucketRetry).ForEach(func(k, v []byte) error {
+			r := boltRetry{}
+			if err := store.decode(v, &r); err != nil {
+				return err
+			}
+
+			if r.RetryAt.After(t) {
+				retrying[string(store.scanKey(k))] = true
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		stats.Retrying = len(retrying)
+
+		err = tx.Bucket(bucketScan).ForEach(func(k, v []byte) error {
+			s := boltScan{}
+			if err := store.decode(v, &s); err != nil {
+				return err
+			}
+
+			stats.Priorities[s.Priority]++
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(bucketClaim).ForEach(func(k, v []byte) error {
+			c := boltClaim{}
+			if err := store.decode(v, &c); err != nil {
+				return err
+			}
+
+			if c.ExpiresAt.After(t) {
+				stats.Claimed++
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(bucketHistory).ForEach(func(k, v []byte) error {
+			e := HistoryEntry{}
+			if err := store.decode(v, &e); err != nil {
+				return err
+			}
+
+			stats.History[e.Status]++
+			return nil
+		})
+	})
+
+	if err != nil {
+		return stats, fmt.Errorf("counts: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return stats, nil
+}
+
+// Migrations returns no migrations, as the buckets of the bolt database are created when it is opened.
+func (store *boltDatastore) Migrations() ([]Migration, error) {
+	return []Migration{}, nil
+}
+
+// Ping checks whether the database is open.
+func (store *boltDatastore) Ping() error {
+	return store.View(func(tx *bolt.Tx) error {
+		return nil
+	})
+}
+
+// Export returns the queue, the dead-letter queue and the history of the datastore.
+func (store *boltDatastore) Export() (Export, error) {
+	e := Export{
+		Scans: make([]QueuedScan, 0),
+	}
+
+	scans, err := store.GetAll()
+	if err != nil {
+		return e, fmt.Errorf("export: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	for _, s := range scans {
+		e.Scans = append(e.Scans, queuedScan(s))
+	}
+
+	if e.Failed, err = store.GetFailed(); err != nil {
+		return e, err
+	}
+
+	if e.History, err = store.history(); err != nil {
+		return e, fmt.Errorf("export: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return e, nil
+}
+
+// Import adds the exported scans to the queue, and the failed scans and history entries
+// to the dead-letter queue and history of the datastore.
+// Queued scans of the same folder are merged, as in Upsert.
+func (store *boltDatastore) Import(e Export) error {
+	err := store.Update(func(tx *bolt.Tx) error {
+		for _, s := range e.Scans {
+			if err := store.upsert(tx, s.scan()); err != nil {
+				return err
+			}
+		}
+
+		for _, f := range e.Failed {
+			if err := store.insertFailed(tx, f); err != nil {
+				return err
+			}
+		}
+
+		for _, h := range e.History {
+			if err := store.insertHistory(tx, h); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("import: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
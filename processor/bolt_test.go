@@ -0,0 +1,428 @@
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// TestBoltDatastore runs the same operations on the SQLite datastore and on the bolt datastore,
+// with and without encryption, and checks whether their results match.
+func TestBoltDatastore(t *testing.T) {
+	type Test struct {
+		Name string
+		Run  func(store storage) []interface{}
+	}
+
+	testTime := time.Now().UTC()
+	scan := func(folder string, priority int, age time.Duration, targets ...string) autoscan.Scan {
+		return autoscan.Scan{
+			Folder:   folder,
+			Priority: priority,
+			Event:    autoscan.EventAdded,
+			Targets:  targets,
+			Trigger:  "sonarr",
+			Time:     testTime.Add(-1 * age),
+		}
+	}
+
+	var testCases = []Test{
+		{
+			Name: "Upserts are merged",
+			Run: func(store storage) []interface{} {
+				err := store.Upsert([]autoscan.Scan{
+					scan("/tv/Westworld", 2, time.Hour, "plex"),
+					scan("/tv/Westworld", 5, time.Minute, "emby"),
+					scan("/tv/Dexter", 1, time.Hour),
+					scan("/tv/Dexter", 1, time.Minute, "plex"),
+				})
+
+				scans, getErr := store.GetAll()
+				sortScans(scans)
+				count, countErr := store.Count()
+				return []interface{}{err, scans, getErr, count, countErr}
+			},
+		},
+		{
+			Name: "Available scans",
+			Run: func(store storage) []interface{} {
+				store.Upsert([]autoscan.Scan{
+					scan("/tv/Westworld", 2, time.Hour),
+					scan("/tv/Dexter", 5, time.Minute),
+					scan("/tv/Wednesday", 5, 2*time.Minute),
+					scan("/tv/Friends", 1, 5*time.Hour),
+				})
+
+				store.Retry(scan("/tv/Wednesday", 5, 0), "plex", 1, testTime.Add(time.Hour))
+				store.Deliver(scan("/tv/Dexter", 5, time.Minute), "emby", []string{"plex", "emby"})
+
+				results := make([]interface{}, 0)
+				for _, target := range []string{"plex", "emby"} {
+					for _, aging := range []time.Duration{0, time.Hour} {
+						s, err := store.GetAvailableScan(target, 0, 0, aging)
+						results = append(results, s, err)
+					}
+
+					s, err := store.GetAvailableScan(target, 30*time.Minute, 0, 0)
+					results = append(results, s, err)
+				}
+
+				s, err := store.GetAvailableScan("plex", 10*time.Hour, 0, 0)
+				return append(results, s, err)
+			},
+		},
+		{
+			Name: "Available siblings",
+			Run: func(store storage) []interface{} {
+				store.Upsert([]autoscan.Scan{
+					scan("/tv/Westworld/Season 1", 2, time.Hour),
+					scan("/tv/Westworld/Season 2", 5, time.Minute),
+					scan("/tv/Westworld/Season 2/Extras", 5, time.Minute),
+					scan("/tv/Westworld", 5, time.Minute),
+					scan("/tv/Dexter/Season 1", 1, time.Hour),
+				})
+
+				store.Deliver(scan("/tv/Westworld/Season 1", 2, time.Hour), "plex", []string{"plex", "emby"})
+
+				plex, plexErr := store.GetAvailableSiblings("plex", "/tv/Westworld", 0, 0)
+				emby, embyErr := store.GetAvailableSiblings("emby", "/tv/Westworld/", 0, 0)
+				return []interface{}{plex, plexErr, emby, embyErr}
+			},
+		},
+		{
+			Name: "Deliveries",
+			Run: func(store storage) []interface{} {
+				westworld := scan("/tv/Westworld", 2, time.Hour, "plex")
+				store.Upsert([]autoscan.Scan{westworld, scan("/tv/Dexter", 1, time.Hour)})
+
+				byID, byIDErr := store.GetScanByID(westworld.ID())
+				stale, staleErr := store.Deliver(scan("/tv/Dexter", 1, time.Minute), "plex", []string{"plex", "emby"})
+				plex, plexErr := store.Deliver(scan("/tv/Dexter", 1, time.Hour), "plex", []string{"plex", "emby"})
+				emby, embyErr := store.Deliver(scan("/tv/Dexter", 1, time.Hour), "emby", []string{"plex", "emby"})
+				limited, limitedErr := store.Deliver(westworld, "plex", []string{"plex", "emby"})
+				_, missingErr := store.GetScanByID(westworld.ID())
+				count, countErr := store.Count()
+
+				return []interface{}{byID, byIDErr, stale, staleErr, plex, plexErr, emby, embyErr, limited, limitedErr, missingErr, count, countErr}
+			},
+		},
+		{
+			Name: "Reschedule",
+			Run: func(store storage) []interface{} {
+				now = func() time.Time {
+					return testTime
+				}
+
+				westworld := scan("/tv/Westworld", 2, time.Hour)
+				store.Upsert([]autoscan.Scan{westworld, scan("/tv/Westworld/Season 1", 2, time.Hour)})
+				store.Retry(westworld, "plex", 3, testTime.Add(time.Hour))
+				store.Retry(westworld, "emby", 1, testTime.Add(time.Hour))
+
+				waiting, waitingErr := store.GetAvailableScan("plex", 0, 0, 0)
+				rescheduleErr := store.Reschedule(westworld, testTime)
+				plex, plexErr := store.GetAvailableScan("plex", 0, 0, 0)
+				emby, embyErr := store.GetAvailableScan("emby", 0, 0, 0)
+				attempts, attemptsErr := store.GetAttempts(westworld, "plex")
+
+				return []interface{}{waiting, waitingErr, rescheduleErr, plex, plexErr, emby, embyErr, attempts, attemptsErr}
+			},
+		},
+		{
+			Name: "Dead-letter queue",
+			Run: func(store storage) []interface{} {
+				now = func() time.Time {
+					return testTime
+				}
+
+				westworld := scan("/tv/Westworld", 2, time.Hour)
+				store.Upsert([]autoscan.Scan{westworld, scan("/tv/Dexter", 1, time.Hour)})
+				store.Retry(westworld, "plex", 3, testTime)
+
+				done, err := store.DeadLetter(westworld, "plex", 5, "unavailable", []string{"plex", "emby"})
+				attempts, attemptsErr := store.GetAttempts(westworld, "plex")
+				store.DeadLetter(scan("/tv/Dexter", 1, time.Hour), "emby", 5, "unavailable", []string{"emby"})
+				failed, failedErr := store.GetFailed()
+				hasFailed, hasFailedErr := store.HasFailed(westworld)
+				requeued, requeuedErr := store.Requeue([]int64{1, 42})
+				deleteErr := store.DeleteFailed("/tv/Dexter")
+				remaining, remainingErr := store.GetFailed()
+				scans, scansErr := store.GetAll()
+				sortScans(scans)
+
+				return []interface{}{done, err, attempts, attemptsErr, failed, failedErr, hasFailed, hasFailedErr,
+					requeued, requeuedErr, deleteErr, remaining, remainingErr, scans, scansErr}
+			},
+		},
+		{
+			Name: "History",
+			Run: func(store storage) []interface{} {
+				now = func() time.Time {
+					return testTime
+				}
+
+				westworld := scan("/tv/Westworld", 2, time.Hour)
+				store.AddHistory(westworld, "plex", StatusCompleted, time.Second, 24*time.Hour)
+				store.AddHistory(westworld, "emby", StatusFailed, 3*time.Second, 24*time.Hour)
+				store.AddHistory(scan("/tv/Westworld/Season 1", 1, 2*time.Hour), "plex", StatusExpired, 0, 24*time.Hour)
+				store.AddHistory(scan("/tv/Dexter", 1, 48*time.Hour), "plex", StatusCompleted, 5*time.Second, 24*time.Hour)
+
+				history, historyErr := store.GetHistory(3)
+				status, statusErr := store.GetHistoryStatus(westworld.ID())
+				unknown, unknownErr := store.GetHistoryStatus("unknown")
+				stats, statsErr := store.GetStats()
+
+				// julianday in SQLite is precise to the millisecond
+				stats.AverageLatency = stats.AverageLatency.Round(time.Millisecond)
+				folders, foldersErr := store.GetHistoryFolders([]string{scan("/tv/Dexter", 0, 0).ID()}, "/tv/Westworld/")
+
+				return []interface{}{history, historyErr, status, statusErr, unknown, unknownErr, stats, statsErr, folders, foldersErr}
+			},
+		},
+		{
+			Name: "Audit",
+			Run: func(store storage) []interface{} {
+				audit := func(at time.Duration, event string, status int, scans int) error {
+					now = func() time.Time {
+						return testTime.Add(at)
+					}
+
+					return store.AddAudit(AuditEntry{
+						Event:    event,
+						Trigger:  "sonarr",
+						SourceIP: "10.0.0.1",
+						Username: "hello there",
+						Method:   "POST",
+						Path:     "/triggers/sonarr",
+						Status:   status,
+						Scans:    scans,
+					}, 24*time.Hour)
+				}
+
+				oldErr := audit(-48*time.Hour, AuditWebhook, 200, 1)
+				failureErr := audit(-time.Hour, AuditAuthFailure, 401, 0)
+				webhookErr := audit(0, AuditWebhook, 200, 2)
+
+				entries, entriesErr := store.GetAudit("", 10)
+				failures, failuresErr := store.GetAudit(AuditAuthFailure, 10)
+				limited, limitedErr := store.GetAudit("", 1)
+
+				return []interface{}{oldErr, failureErr, webhookErr, entries, entriesErr, failures, failuresErr, limited, limitedErr}
+			},
+		},
+		{
+			Name: "Pause",
+			Run: func(store storage) []interface{} {
+				now = func() time.Time {
+					return testTime
+				}
+
+				store.Pause()
+
+				now = func() time.Time {
+					return testTime.Add(time.Hour)
+				}
+
+				store.Pause()
+				paused, pausedErr := store.GetPause()
+				resumeErr := store.Resume()
+				resumed, resumedErr := store.GetPause()
+
+				return []interface{}{paused, pausedErr, resumeErr, resumed, resumedErr}
+			},
+		},
+		{
+			Name: "Prune",
+			Run: func(store storage) []interface{} {
+				now = func() time.Time {
+					return testTime.Add(-48 * time.Hour)
+				}
+
+				westworld := scan("/tv/Westworld", 2, 48*time.Hour)
+				store.AddHistory(westworld, "plex", StatusCompleted, time.Second, 7*24*time.Hour)
+				store.Upsert([]autoscan.Scan{westworld})
+				store.DeadLetter(westworld, "plex", 5, "unavailable", []string{"plex"})
+
+				now = func() time.Time {
+					return testTime
+				}
+
+				store.Retry(scan("/tv/Dexter", 1, 0), "emby", 1, testTime)
+				result, err := store.Prune(24*time.Hour, 24*time.Hour)
+				return []interface{}{result, err, store.Vacuum()}
+			},
+		},
+		{
+			Name: "Counts",
+			Run: func(store storage) []interface{} {
+				now = func() time.Time {
+					return testTime
+				}
+
+				westworld := scan("/tv/Westworld", 2, time.Hour)
+				dexter := scan("/tv/Dexter", 1, time.Hour)
+				store.Upsert([]autoscan.Scan{westworld, dexter, scan("/tv/Wednesday", 1, time.Hour)})
+				store.Deliver(westworld, "plex", []string{"plex", "emby"})
+				store.Retry(westworld, "emby", 1, testTime.Add(time.Hour))
+				store.Retry(dexter, "plex", 2, testTime.Add(time.Hour))
+				store.Retry(dexter, "emby", 1, testTime.Add(-1*time.Minute))
+				store.Claim(dexter, "emby", "instance", time.Minute)
+				store.DeadLetter(scan("/tv/Wednesday", 1, time.Hour), "plex", 5, "unavailable", []string{"plex"})
+				store.AddHistory(westworld, "plex", StatusCompleted, time.Second, 24*time.Hour)
+				store.AddHistory(dexter, "plex", StatusFailed, time.Second, 24*time.Hour)
+				store.AddHistory(dexter, "emby", StatusFailed, time.Second, 24*time.Hour)
+
+				stats, statsErr := store.Counts()
+				problems, checkErr := store.Check()
+
+				return []interface{}{stats, statsErr, problems, checkErr}
+			},
+		},
+		{
+			Name: "Retrying scans",
+			Run: func(store storage) []interface{} {
+				now = func() time.Time {
+					return testTime
+				}
+
+				westworld := scan("/tv/Westworld", 2, time.Hour)
+				dexter := scan("/tv/Dexter", 1, time.Hour)
+				store.Upsert([]autoscan.Scan{westworld, dexter, scan("/tv/Wednesday", 1, time.Hour)})
+				store.Retry(westworld, "emby", 1, testTime.Add(time.Hour))
+				store.Retry(westworld, "plex", 1, testTime.Add(time.Hour))
+				store.Retry(dexter, "plex", 2, testTime.Add(-1*time.Minute))
+
+				retrying, err := store.GetRetrying()
+				return []interface{}{retrying, err}
+			},
+		},
+		{
+			Name: "Export and import",
+			Run: func(store storage) []interface{} {
+				now = func() time.Time {
+					return testTime
+				}
+
+				westworld := scan("/tv/Westworld", 2, time.Hour, "plex")
+				importErr := store.Import(Export{
+					Scans: []QueuedScan{queuedScan(westworld), {Folder: "/tv/Dexter", Time: testTime}},
+					Failed: []FailedScan{
+						{ID: 7, Folder: "/tv/Friends", Target: "plex", Event: autoscan.EventAdded, Attempts: 5, Time: testTime},
+					},
+					History: []HistoryEntry{
+						{ID: 3, ScanID: westworld.ID(), Folder: westworld.Folder, Target: "plex", Status: StatusCompleted, QueuedAt: testTime, ScannedAt: testTime},
+					},
+				})
+
+				e, exportErr := store.Export()
+				sort.Slice(e.Scans, func(i, j int) bool {
+					return e.Scans[i].Folder < e.Scans[j].Folder
+				})
+
+				return []interface{}{importErr, e, exportErr}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "autoscan")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer os.RemoveAll(dir)
+
+			now = func() time.Time {
+				return testTime
+			}
+
+			sqlStore, err := newDatastore(":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer sqlStore.Close()
+
+			want := tc.Run(sqlStore)
+
+			for _, key := range []string{"", "secret"} {
+				now = func() time.Time {
+					return testTime
+				}
+
+				boltStore, err := newBoltDatastore(filepath.Join(dir, "autoscan"+key+".bolt"), key)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got := tc.Run(boltStore)
+				boltStore.Close()
+
+				for i := range want {
+					if !reflect.DeepEqual(got[i], want[i]) {
+						t.Errorf("Result %d with key %q does not match: %+v vs %+v", i, key, got[i], want[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+// The order of the queued scans differs between the datastores.
+func sortScans(scans []autoscan.Scan) {
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].Folder < scans[j].Folder
+	})
+}
+
+func TestBoltReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autoscan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	path := filepath.Join(dir, "autoscan.bolt")
+	want := autoscan.Scan{
+		Folder:   "/tv/Westworld",
+		Priority: 5,
+		Event:    autoscan.EventAdded,
+		Time:     testTime.Add(-1 * time.Hour),
+	}
+
+	for i := 0; i < 2; i++ {
+		proc, err := New(Config{DatastoreBolt: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 0 {
+			if err := proc.Add(want); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		scan, err := proc.store.GetAvailableScan("plex", 0, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(scan, want) {
+			t.Errorf("Scan does not match: %+v vs %+v", scan, want)
+		}
+
+		proc.Close()
+	}
+}
@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// A scan is claimed for a target when it has no claim for the target,
+// when the claim belongs to the same instance, or when the claim expired.
+const sqlClaim = `
+INSERT INTO claim (folder, target, claimed_by, expires_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (folder, target) DO UPDATE SET
+	claimed_by = excluded.claimed_by,
+	expires_at = excluded.expires_at
+WHERE claim.claimed_by = excluded.claimed_by OR claim.expires_at <= ?
+`
+
+const sqlRelease = `
+DELETE FROM claim WHERE folder = ? AND target = ? AND claimed_by = ?
+`
+
+const sqlReleaseClaim = `
+DELETE FROM claim WHERE folder = ? AND target = ?
+`
+
+const sqlDeleteClaims = `
+DELETE FROM claim WHERE folder = ?
+`
+
+// Claim claims the scan for the target on behalf of the instance until the lease expires,
+// such that other instances sharing the datastore skip the scan for the target.
+// It returns false when the scan is claimed by another instance.
+func (store *datastore) Claim(scan autoscan.Scan, target string, instance string, lease time.Duration) (bool, error) {
+	t := now()
+
+	res, err := store.Exec(sqlClaim, scan.Folder, target, instance, t.Add(lease), t)
+	if err != nil {
+		return false, fmt.Errorf("claim: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("claim: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return claimed > 0, nil
+}
+
+// Release removes the claim of the instance on the scan for the target.
+func (store *datastore) Release(scan autoscan.Scan, target string, instance string) error {
+	if _, err := store.Exec(sqlRelease, scan.Folder, target, instance); err != nil {
+		return fmt.Errorf("release: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// defaultInstance identifies the instance by its host and process.
+func defaultInstance() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "autoscan"
+	}
+
+	return host + ":" + strconv.Itoa(os.Getpid())
+}
+
+// claim claims the scan for the target when claims are enabled.
+func (p *Processor) claim(scan autoscan.Scan, target autoscan.Target) (bool, error) {
+	if p.claimLease <= 0 {
+		return true, nil
+	}
+
+	return p.store.Claim(scan, target.ID(), p.instance, p.claimLease)
+}
+
+// release removes the claims of the scans for the target which are no longer processed.
+func (p *Processor) release(scans []autoscan.Scan, target autoscan.Target) error {
+	if p.claimLease <= 0 {
+		return nil
+	}
+
+	for _, s := range scans {
+		if err := p.store.Release(s, target.ID(), p.instance); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
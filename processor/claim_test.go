@@ -0,0 +1,231 @@
+package processor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestClaim(t *testing.T) {
+	type Test struct {
+		Name          string
+		Instance      string
+		Elapsed       time.Duration
+		Release       bool
+		Retry         bool
+		WantAvailable bool
+		WantClaimed   bool
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Claimed by another instance",
+			Instance: "b",
+		},
+		{
+			Name:        "Claimed by the same instance",
+			Instance:    "a",
+			WantClaimed: true,
+		},
+		{
+			Name:          "Claim of another instance expired",
+			Instance:      "b",
+			Elapsed:       2 * time.Minute,
+			WantAvailable: true,
+			WantClaimed:   true,
+		},
+		{
+			Name:          "Released claim",
+			Instance:      "b",
+			Release:       true,
+			WantAvailable: true,
+			WantClaimed:   true,
+		},
+		{
+			Name:          "Retry releases the claim",
+			Instance:      "b",
+			Retry:         true,
+			WantAvailable: true,
+			WantClaimed:   true,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "autoscan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for i, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testTime := time.Now().UTC()
+			now = func() time.Time {
+				return testTime
+			}
+
+			sqlStore, err := newDatastore(":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer sqlStore.Close()
+
+			boltStore, err := newBoltDatastore(filepath.Join(dir, string(rune('a'+i))+".bolt"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer boltStore.Close()
+
+			for _, store := range []storage{sqlStore, boltStore} {
+				now = func() time.Time {
+					return testTime
+				}
+
+				scan := autoscan.Scan{Folder: "/tv/Westworld", Event: autoscan.EventAdded, Time: testTime.Add(-1 * time.Hour)}
+				if err := store.Upsert([]autoscan.Scan{scan}); err != nil {
+					t.Fatal(err)
+				}
+
+				claimed, err := store.Claim(scan, "plex", "a", time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !claimed {
+					t.Fatal("Scan was not claimed")
+				}
+
+				if tc.Release {
+					if err := store.Release(scan, "plex", "a"); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				// the retry is due immediately
+				if tc.Retry {
+					if err := store.Retry(scan, "plex", 1, testTime); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				now = func() time.Time {
+					return testTime.Add(tc.Elapsed)
+				}
+
+				_, err = store.GetAvailableScan("plex", 0, 0, 0)
+				switch {
+				case err == nil && !tc.WantAvailable:
+					t.Errorf("%T: Claimed scan is available", store)
+				case errors.Is(err, autoscan.ErrNoScans) && tc.WantAvailable:
+					t.Errorf("%T: Scan is not available", store)
+				case err != nil && !errors.Is(err, autoscan.ErrNoScans):
+					t.Fatal(err)
+				}
+
+				// other targets are not claimed
+				if _, err := store.GetAvailableScan("emby", 0, 0, 0); err != nil {
+					t.Errorf("%T: Scan is not available for another target: %v", store, err)
+				}
+
+				claimed, err = store.Claim(scan, "plex", tc.Instance, time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if claimed != tc.WantClaimed {
+					t.Errorf("%T: Claims do not match: %v vs %v", store, claimed, tc.WantClaimed)
+				}
+			}
+		})
+	}
+}
+
+type blockingTarget struct {
+	scans   *int32
+	started chan struct{}
+	done    chan struct{}
+}
+
+func (t blockingTarget) ID() string                          { return "plex:http://plex" }
+func (t blockingTarget) Available() error                    { return nil }
+func (t blockingTarget) Capabilities() autoscan.Capabilities { return autoscan.Capabilities{} }
+
+// Scan blocks the first scan until done is closed.
+func (t blockingTarget) Scan(autoscan.Scan) error {
+	if atomic.AddInt32(t.scans, 1) == 1 {
+		t.started <- struct{}{}
+		<-t.done
+	}
+
+	return nil
+}
+
+func TestSharedDatastore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autoscan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	now = time.Now
+	path := filepath.Join(dir, "autoscan.db")
+
+	procs := make([]*Processor, 0)
+	for _, instance := range []string{"a", "b"} {
+		proc, err := New(Config{DatastorePath: path, Instance: instance, ClaimLease: time.Minute})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer proc.Close()
+		procs = append(procs, proc)
+	}
+
+	err = procs[0].Add(autoscan.Scan{Folder: "/tv/Westworld", Time: time.Now().Add(-1 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var scans int32
+	target := blockingTarget{scans: &scans, started: make(chan struct{}), done: make(chan struct{})}
+	targets := []autoscan.Target{target}
+
+	result := make(chan error)
+	go func() {
+		result <- procs[0].Process(target, targets)
+	}()
+
+	<-target.started
+
+	// the scan is claimed by the first instance while it is being scanned
+	if err := procs[1].Process(target, targets); !errors.Is(err, autoscan.ErrNoScans) {
+		t.Errorf("Second instance did not skip the claimed scan: %v", err)
+	}
+
+	close(target.done)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	if scans := atomic.LoadInt32(&scans); scans != 1 {
+		t.Errorf("Number of scans does not match: %d vs 1", scans)
+	}
+
+	count, err := procs[1].store.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("Number of queued scans does not match: %d vs 0", count)
+	}
+}
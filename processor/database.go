@@ -0,0 +1,232 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// DatabaseStats holds the number of entries in the datastore per state,
+// and the number of queued scans per priority.
+type DatabaseStats struct {
+	Queued     int            `json:"queued"`
+	Retrying   int            `json:"retrying"`
+	Delivered  int            `json:"delivered"`
+	Claimed    int            `json:"claimed"`
+	Failed     int            `json:"failed"`
+	History    map[string]int `json:"history"`
+	Priorities map[int]int    `json:"priorities"`
+}
+
+// Migration is a schema migration of the SQL datastore.
+// AppliedAt is nil when the migration has not been applied.
+type Migration struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// PostgreSQL checks its own integrity, the check only verifies the connection.
+const sqlIntegrityCheck = `
+PRAGMA integrity_check
+`
+
+const sqlCountRetrying = `
+SELECT COUNT(DISTINCT folder) FROM retry WHERE retry_at > ?
+`
+
+const sqlCountDelivered = `
+SELECT COUNT(*) FROM delivered
+`
+
+const sqlCountClaimed = `
+SELECT COUNT(*) FROM claim WHERE expires_at > ?
+`
+
+const sqlCountFailed = `
+SELECT COUNT(*) FROM dead_letter
+`
+
+const sqlCountHistory = `
+SELECT status, COUNT(*) FROM history GROUP BY status
+`
+
+const sqlCountPriorities = `
+SELECT priority, COUNT(*) FROM scan GROUP BY priority
+`
+
+const sqlGetAppliedMigrations = `
+SELECT version, applied_at FROM migration
+`
+
+// Check returns the problems found by the integrity check of the database,
+// which is empty when the database is intact.
+func (store *datastore) Check() ([]string, error) {
+	rows, err := store.Query(sqlIntegrityCheck)
+	if err != nil {
+		return nil, fmt.Errorf("check: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+
+	problems := make([]string, 0)
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return nil, fmt.Errorf("check: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("check: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return problems, nil
+}
+
+// Counts returns the number of queued scans, the number of scans waiting to be retried by a target,
+// the deliveries of queued scans, the unexpired claims, the failed scans, the history entries per status
+// and the queued scans per priority.
+func (store *datastore) Counts() (DatabaseStats, error) {
+	stats := DatabaseStats{History: make(map[string]int), Priorities: make(map[int]int)}
+	t := now()
+
+	counts := []struct {
+		query string
+		count *int
+		args  []interface{}
+	}{
+		{sqlCount, &stats.Queued, nil},
+		{sqlCountRetrying, &stats.Retrying, []interface{}{t}},
+		{sqlCountDelivered, &stats.Delivered, nil},
+		{sqlCountClaimed, &stats.Claimed, []interface{}{t}},
+		{sqlCountFailed, &stats.Failed, nil},
+	}
+
+	for _, c := range counts {
+		if err := store.QueryRow(c.query, c.args...).Scan(c.count); err != nil {
+			return stats, fmt.Errorf("counts: %s: %w", err, autoscan.ErrFatal)
+		}
+	}
+
+	rows, err := store.Query(sqlCountHistory)
+	if err != nil {
+		return stats, fmt.Errorf("counts: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return stats, fmt.Errorf("counts: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		stats.History[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("counts: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	if err := store.countPriorities(stats.Priorities); err != nil {
+		return stats, fmt.Errorf("counts: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return stats, nil
+}
+
+func (store *datastore) countPriorities(priorities map[int]int) error {
+	rows, err := store.Query(sqlCountPriorities)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var priority, count int
+		if err := rows.Scan(&priority, &count); err != nil {
+			return err
+		}
+
+		priorities[priority] = count
+	}
+
+	return rows.Err()
+}
+
+// Migrations returns the migrations of the database, ordered by version.
+func (store *datastore) Migrations() ([]Migration, error) {
+	ms, err := loadMigrations(store.dialect.migrations())
+	if err != nil {
+		return nil, fmt.Errorf("migrations: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	rows, err := store.Query(sqlGetAppliedMigrations)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("migrations: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		applied[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("migrations: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	migrations := make([]Migration, 0, len(ms))
+	for _, m := range ms {
+		migration := Migration{Version: m.version, Name: m.name}
+		if t, ok := applied[m.version]; ok {
+			migration.AppliedAt = &t
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	return migrations, nil
+}
+
+// Check returns the problems found by the integrity check of the datastore.
+func (p *Processor) Check() ([]string, error) {
+	return p.store.Check()
+}
+
+// DatabaseStats returns the number of entries in the datastore per state.
+func (p *Processor) DatabaseStats() (DatabaseStats, error) {
+	return p.store.Counts()
+}
+
+// Migrations returns the schema migrations of the datastore,
+// which are applied when the processor is created.
+// The bolt datastore has no migrations.
+func (p *Processor) Migrations() ([]Migration, error) {
+	return p.store.Migrations()
+}
+
+// Prune removes the history entries and failed scans which are older than the given retention periods,
+// and the orphaned entries of scans which are no longer queued.
+// Entries are kept when their retention period is zero.
+func (p *Processor) Prune(historyRetention time.Duration, failedRetention time.Duration) (MaintenanceResult, error) {
+	return p.store.Prune(historyRetention, failedRetention)
+}
+
+// Vacuum reclaims the space of removed entries of the datastore.
+func (p *Processor) Vacuum() error {
+	return p.store.Vacuum()
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cloudbox/autoscan"
@@ -12,47 +13,173 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// storage keeps the queue, the dead-letter queue, the history, the audit log and the pause of the processor.
+// It is implemented by the SQL datastore and the bolt datastore.
+type storage interface {
+	Upsert(scans []autoscan.Scan) error
+	GetAvailableScan(target string, minAge time.Duration, hold time.Duration, aging time.Duration) (autoscan.Scan, error)
+	GetAvailableSiblings(target string, parent string, minAge time.Duration, hold time.Duration) ([]autoscan.Scan, error)
+	GetScanByID(id string) (autoscan.Scan, error)
+	Count() (int, error)
+	GetAll() ([]autoscan.Scan, error)
+	Delete(scan autoscan.Scan) error
+	Deliver(scan autoscan.Scan, target string, targets []string) (bool, error)
+	GetAttempts(scan autoscan.Scan, target string) (int, error)
+	Retry(scan autoscan.Scan, target string, attempts int, retryAt time.Time) error
+	Reschedule(scan autoscan.Scan, retryAt time.Time) error
+	GetRetrying() (map[string]bool, error)
+	DeadLetter(scan autoscan.Scan, target string, attempts int, reason string, targets []string) (bool, error)
+	GetFailed() ([]FailedScan, error)
+	Requeue(ids []int64) (int, error)
+	Claim(scan autoscan.Scan, target string, instance string, lease time.Duration) (bool, error)
+	Release(scan autoscan.Scan, target string, instance string) error
+	DeleteFailed(folder string) error
+	HasFailed(scan autoscan.Scan) (bool, error)
+
+	AddHistory(scan autoscan.Scan, target string, status string, duration time.Duration, retention time.Duration) error
+	GetHistory(limit int) ([]HistoryEntry, error)
+	GetHistoryStatus(id string) (string, error)
+	GetHistoryFolders(ids []string, prefix string) ([]string, error)
+	GetStats() (Stats, error)
+
+	AddAudit(e AuditEntry, retention time.Duration) error
+	GetAudit(event string, limit int) ([]AuditEntry, error)
+
+	Pause() error
+	Resume() error
+	GetPause() (PauseStatus, error)
+
+	Prune(historyRetention time.Duration, failedRetention time.Duration) (MaintenanceResult, error)
+	Vacuum() error
+	Check() ([]string, error)
+	Counts() (DatabaseStats, error)
+	Migrations() ([]Migration, error)
+	Export() (Export, error)
+	Import(e Export) error
+	Ping() error
+	Close() error
+}
+
+// The queries of the datastore are written for SQLite.
+// Other databases translate the queries with their dialect.
 type datastore struct {
 	*sql.DB
+	dialect dialect
 }
 
-const sqlSchema = `
-CREATE TABLE IF NOT EXISTS scan (
-	"folder" TEXT NOT NULL,
-	"priority" INTEGER NOT NULL,
-	"time" DATETIME NOT NULL,
-	PRIMARY KEY(folder)
-)
-`
+// dialect translates the queries of the datastore to the database.
+type dialect interface {
+	query(q string) string
+	migrations() string
+}
 
-func newDatastore(path string) (*datastore, error) {
-	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?%s", path, "cache=shared&mode=rwc&_busy_timeout=5000"))
+type sqlite struct{}
+
+func (sqlite) query(q string) string {
+	return q
+}
+
+func (sqlite) migrations() string {
+	return "migrations/sqlite"
+}
+
+func newDatastore(dsn string) (*datastore, error) {
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
 	}
 
 	db.SetMaxOpenConns(1)
 
-	_, err = db.Exec(sqlSchema)
+	store := &datastore{db, sqlite{}}
+	if err := store.migrate(store.dialect.migrations()); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (store *datastore) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return store.DB.Exec(store.dialect.query(query), args...)
+}
+
+func (store *datastore) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return store.DB.Query(store.dialect.query(query), args...)
+}
+
+func (store *datastore) QueryRow(query string, args ...interface{}) *sql.Row {
+	return store.DB.QueryRow(store.dialect.query(query), args...)
+}
+
+func (store *datastore) Begin() (*tx, error) {
+	t, err := store.DB.Begin()
 	if err != nil {
 		return nil, err
 	}
 
-	store := &datastore{db}
+	return &tx{t, store.dialect}, nil
+}
 
-	return store, nil
+// tx is a transaction which translates its queries with the dialect of the datastore.
+type tx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+func (t *tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.dialect.query(query), args...)
+}
+
+func (t *tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.dialect.query(query), args...)
+}
+
+func (t *tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.dialect.query(query), args...)
 }
 
+// The events of a scan are merged as in autoscan.Event.Merge.
+// The targets of a scan are only limited when all upserted scans were limited.
+// The existence of the folder is only checked when all upserted scans requested the check.
+// Updated is the time at which the last scan of the folder was upserted.
 const sqlUpsert = `
-INSERT INTO scan (folder, priority, time)
-VALUES (?, ?, ?)
+INSERT INTO scan (folder, id, priority, event, targets, check_exists, trigger, time, updated)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT (folder) DO UPDATE SET
 	priority = MAX(excluded.priority, scan.priority),
-	time = excluded.time
+	event = CASE WHEN excluded.event = scan.event THEN scan.event ELSE 'modified' END,
+	check_exists = MIN(excluded.check_exists, scan.check_exists),
+	trigger = excluded.trigger,
+	targets = CASE
+		WHEN excluded.targets = '' OR scan.targets = '' THEN ''
+		WHEN excluded.targets = scan.targets THEN scan.targets
+		ELSE scan.targets || ',' || excluded.targets
+	END,
+	time = excluded.time,
+	updated = excluded.updated
 `
 
-func (store *datastore) upsert(tx *sql.Tx, scan autoscan.Scan) error {
-	_, err := tx.Exec(sqlUpsert, scan.Folder, scan.Priority, scan.Time)
+// A new scan of a folder must be delivered to all targets again.
+const sqlResetDelivered = `
+DELETE FROM delivered WHERE folder = ?
+`
+
+const sqlResetRetry = `
+DELETE FROM retry WHERE folder = ?
+`
+
+func (store *datastore) upsert(tx *tx, scan autoscan.Scan) error {
+	_, err := tx.Exec(sqlUpsert, scan.Folder, scan.ID(), scan.Priority, scan.Event, joinTargets(scan.Targets), scan.CheckExists, scan.Trigger, scan.Time, now())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(sqlResetDelivered, scan.Folder)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(sqlResetRetry, scan.Folder)
 	return err
 }
 
@@ -76,17 +203,47 @@ func (store *datastore) Upsert(scans []autoscan.Scan) error {
 }
 
 const sqlGetAvailableScan = `
-SELECT folder, priority, time FROM scan
+SELECT folder, priority, event, targets, check_exists, trigger, time FROM scan
 WHERE time < ?
+	AND updated <= ?
+	AND folder NOT IN (SELECT folder FROM delivered WHERE target = ?)
+	AND folder NOT IN (SELECT folder FROM retry WHERE target = ? AND retry_at > ?)
+	AND folder NOT IN (SELECT folder FROM claim WHERE target = ? AND expires_at > ?)
 ORDER BY priority DESC, time ASC
 LIMIT 1
 `
 
-func (store *datastore) GetAvailableScan(minAge time.Duration) (autoscan.Scan, error) {
-	row := store.QueryRow(sqlGetAvailableScan, now().Add(-1*minAge))
+// The priority of a scan increases by one for every aging interval it has been waiting,
+// such that low priority scans are not starved by a steady stream of high priority scans.
+const sqlGetAvailableScanAging = `
+SELECT folder, priority, event, targets, check_exists, trigger, time FROM scan
+WHERE time < ?
+	AND updated <= ?
+	AND folder NOT IN (SELECT folder FROM delivered WHERE target = ?)
+	AND folder NOT IN (SELECT folder FROM retry WHERE target = ? AND retry_at > ?)
+	AND folder NOT IN (SELECT folder FROM claim WHERE target = ? AND expires_at > ?)
+ORDER BY priority + CAST((julianday(?) - julianday(time)) * 86400 / ? AS INTEGER) DESC, time ASC
+LIMIT 1
+`
+
+// GetAvailableScan returns the scan with the highest priority which is older than minAge,
+// has not been upserted during the last hold, and has not yet been delivered to the target.
+// Scans which are waiting to be retried or are claimed for the target are skipped.
+// Priorities are aged when aging is larger than zero.
+func (store *datastore) GetAvailableScan(target string, minAge time.Duration, hold time.Duration, aging time.Duration) (autoscan.Scan, error) {
+	t := now()
+
+	var row *sql.Row
+	if aging > 0 {
+		row = store.QueryRow(sqlGetAvailableScanAging, t.Add(-1*minAge), t.Add(-1*hold), target, target, t, target, t, t, aging.Seconds())
+	} else {
+		row = store.QueryRow(sqlGetAvailableScan, t.Add(-1*minAge), t.Add(-1*hold), target, target, t, target, t)
+	}
 
 	scan := autoscan.Scan{}
-	err := row.Scan(&scan.Folder, &scan.Priority, &scan.Time)
+	var targets string
+	err := row.Scan(&scan.Folder, &scan.Priority, &scan.Event, &targets, &scan.CheckExists, &scan.Trigger, &scan.Time)
+	scan.Targets = splitTargets(targets)
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
 		return scan, autoscan.ErrNoScans
@@ -97,8 +254,88 @@ func (store *datastore) GetAvailableScan(minAge time.Duration) (autoscan.Scan, e
 	return scan, nil
 }
 
+const sqlGetAvailableSiblings = `
+SELECT folder, priority, event, targets, check_exists, trigger, time FROM scan
+WHERE time < ?
+	AND updated <= ?
+	AND folder NOT IN (SELECT folder FROM delivered WHERE target = ?)
+	AND folder NOT IN (SELECT folder FROM retry WHERE target = ? AND retry_at > ?)
+	AND folder NOT IN (SELECT folder FROM claim WHERE target = ? AND expires_at > ?)
+	AND substr(folder, 1, length(?)) = ?
+	AND instr(substr(folder, length(?) + 1), '/') = 0
+ORDER BY folder ASC
+`
+
+// GetAvailableSiblings returns the scans of the direct subfolders of parent
+// which are older than minAge, have not been upserted during the last hold,
+// and have not yet been delivered to the target.
+func (store *datastore) GetAvailableSiblings(target string, parent string, minAge time.Duration, hold time.Duration) ([]autoscan.Scan, error) {
+	t := now()
+	prefix := strings.TrimSuffix(parent, "/") + "/"
+
+	rows, err := store.Query(sqlGetAvailableSiblings, t.Add(-1*minAge), t.Add(-1*hold), target, target, t, target, t, prefix, prefix, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("get siblings: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+
+	scans := make([]autoscan.Scan, 0)
+	for rows.Next() {
+		scan := autoscan.Scan{}
+		var targets string
+		err = rows.Scan(&scan.Folder, &scan.Priority, &scan.Event, &targets, &scan.CheckExists, &scan.Trigger, &scan.Time)
+		if err != nil {
+			return nil, fmt.Errorf("get siblings: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		scan.Targets = splitTargets(targets)
+		scans = append(scans, scan)
+	}
+
+	return scans, rows.Err()
+}
+
+const sqlGetScanByID = `
+SELECT folder, priority, event, targets, check_exists, trigger, time FROM scan
+WHERE id = ?
+`
+
+// GetScanByID returns the queued scan with the given ID,
+// or autoscan.ErrNoScans when the scan is not queued.
+func (store *datastore) GetScanByID(id string) (autoscan.Scan, error) {
+	row := store.QueryRow(sqlGetScanByID, id)
+
+	scan := autoscan.Scan{}
+	var targets string
+	err := row.Scan(&scan.Folder, &scan.Priority, &scan.Event, &targets, &scan.CheckExists, &scan.Trigger, &scan.Time)
+	scan.Targets = splitTargets(targets)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return scan, autoscan.ErrNoScans
+	case err != nil:
+		return scan, fmt.Errorf("get by id: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return scan, nil
+}
+
+const sqlCount = `
+SELECT COUNT(*) FROM scan
+`
+
+// Count returns the number of queued scans.
+func (store *datastore) Count() (int, error) {
+	var count int
+	if err := store.QueryRow(sqlCount).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return count, nil
+}
+
 const sqlGetAll = `
-SELECT folder, priority, time FROM scan
+SELECT folder, priority, event, targets, check_exists, trigger, time FROM scan
 `
 
 func (store *datastore) GetAll() (scans []autoscan.Scan, err error) {
@@ -110,11 +347,14 @@ func (store *datastore) GetAll() (scans []autoscan.Scan, err error) {
 	defer rows.Close()
 	for rows.Next() {
 		scan := autoscan.Scan{}
-		err = rows.Scan(&scan.Folder, &scan.Priority, &scan.Time)
+		var targets string
+		err = rows.Scan(&scan.Folder, &scan.Priority, &scan.Event, &targets, &scan.CheckExists, &scan.Trigger, &scan.Time)
 		if err != nil {
 			return scans, err
 		}
 
+		scan.Targets = splitTargets(targets)
+
 		scans = append(scans, scan)
 	}
 
@@ -125,13 +365,363 @@ const sqlDelete = `
 DELETE FROM scan WHERE folder=?
 `
 
+const sqlDeleteDelivered = `
+DELETE FROM delivered WHERE folder=?
+`
+
 func (store *datastore) Delete(scan autoscan.Scan) error {
 	_, err := store.Exec(sqlDelete, scan.Folder)
 	if err != nil {
 		return fmt.Errorf("delete: %s: %w", err, autoscan.ErrFatal)
 	}
 
+	_, err = store.Exec(sqlDeleteDelivered, scan.Folder)
+	if err != nil {
+		return fmt.Errorf("delete delivered: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	_, err = store.Exec(sqlResetRetry, scan.Folder)
+	if err != nil {
+		return fmt.Errorf("delete retry: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	_, err = store.Exec(sqlDeleteClaims, scan.Folder)
+	if err != nil {
+		return fmt.Errorf("delete claims: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// Only scans which have not been updated since they were retrieved are marked as delivered.
+const sqlDeliver = `
+INSERT OR IGNORE INTO delivered (folder, target)
+SELECT folder, ? FROM scan
+WHERE folder = ? AND julianday(time) = julianday(?)
+`
+
+const sqlGetDelivered = `
+SELECT target FROM delivered WHERE folder = ?
+`
+
+// Deliver marks the scan as delivered to the target, and releases the claim of the scan for the target.
+// The scan is deleted once it has been delivered to all the given targets it is meant for,
+// in which case Deliver returns true.
+func (store *datastore) Deliver(scan autoscan.Scan, target string, targets []string) (bool, error) {
+	tx, err := store.Begin()
+	if err != nil {
+		return false, fmt.Errorf("deliver: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	done, err := store.deliver(tx, scan, target, targets)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			panic(rollbackErr)
+		}
+
+		return false, fmt.Errorf("deliver: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("deliver: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return done, nil
+}
+
+func (store *datastore) deliver(tx *tx, scan autoscan.Scan, target string, targets []string) (bool, error) {
+	_, err := tx.Exec(sqlDeliver, target, scan.Folder, scan.Time)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(sqlReleaseClaim, scan.Folder, target)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := tx.Query(sqlGetDelivered, scan.Folder)
+	if err != nil {
+		return false, err
+	}
+
+	delivered := make(map[string]bool)
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			rows.Close()
+			return false, err
+		}
+
+		delivered[t] = true
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	for _, t := range targets {
+		if scan.ForTarget(t) && !delivered[t] {
+			return false, nil
+		}
+	}
+
+	// all targets received the scan
+	if _, err := tx.Exec(sqlDelete, scan.Folder); err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(sqlDeleteDelivered, scan.Folder)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(sqlResetRetry, scan.Folder)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(sqlDeleteClaims, scan.Folder)
+	return err == nil, err
+}
+
+const sqlGetAttempts = `
+SELECT attempts FROM retry WHERE folder = ? AND target = ?
+`
+
+// GetAttempts returns the number of failed attempts to deliver the scan to the target.
+func (store *datastore) GetAttempts(scan autoscan.Scan, target string) (int, error) {
+	var attempts int
+	err := store.QueryRow(sqlGetAttempts, scan.Folder, target).Scan(&attempts)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("get attempts: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return attempts, nil
+}
+
+const sqlRetry = `
+INSERT INTO retry (folder, target, attempts, retry_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (folder, target) DO UPDATE SET
+	attempts = excluded.attempts,
+	retry_at = excluded.retry_at
+`
+
+// Retry records a failed attempt to deliver the scan to the target,
+// and releases the claim of the scan for the target.
+// The scan is retried for the target after retryAt.
+func (store *datastore) Retry(scan autoscan.Scan, target string, attempts int, retryAt time.Time) error {
+	_, err := store.Exec(sqlRetry, scan.Folder, target, attempts, retryAt)
+	if err != nil {
+		return fmt.Errorf("retry: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	_, err = store.Exec(sqlReleaseClaim, scan.Folder, target)
+	if err != nil {
+		return fmt.Errorf("release claim: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+const sqlReschedule = `
+UPDATE retry SET retry_at = ? WHERE folder = ?
+`
+
+// Reschedule moves the next attempt of the scan for all targets to retryAt,
+// without resetting the number of attempts.
+func (store *datastore) Reschedule(scan autoscan.Scan, retryAt time.Time) error {
+	if _, err := store.Exec(sqlReschedule, retryAt, scan.Folder); err != nil {
+		return fmt.Errorf("reschedule: %s: %w", err, autoscan.ErrFatal)
+	}
+
 	return nil
 }
 
+const sqlGetRetrying = `
+SELECT DISTINCT folder FROM retry WHERE retry_at > ?
+`
+
+// GetRetrying returns the folders of the scans which are waiting to be retried for at least one target.
+func (store *datastore) GetRetrying() (map[string]bool, error) {
+	rows, err := store.Query(sqlGetRetrying, now())
+	if err != nil {
+		return nil, fmt.Errorf("get retrying: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+	folders := make(map[string]bool)
+	for rows.Next() {
+		var folder string
+		if err := rows.Scan(&folder); err != nil {
+			return nil, fmt.Errorf("get retrying: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		folders[folder] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get retrying: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return folders, nil
+}
+
+const sqlInsertDeadLetter = `
+INSERT INTO dead_letter (folder, target, priority, event, attempts, error, time)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+`
+
+const sqlDeleteRetry = `
+DELETE FROM retry WHERE folder = ? AND target = ?
+`
+
+// DeadLetter moves the scan of the target to the dead-letter queue.
+// The scan is no longer sent to the target, and is treated as delivered to the target.
+// It returns true when the scan has been delivered to all targets, as in Deliver.
+func (store *datastore) DeadLetter(scan autoscan.Scan, target string, attempts int, reason string, targets []string) (bool, error) {
+	tx, err := store.Begin()
+	if err != nil {
+		return false, fmt.Errorf("dead letter: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	done, err := store.deadLetter(tx, scan, target, attempts, reason, targets)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			panic(rollbackErr)
+		}
+
+		return false, fmt.Errorf("dead letter: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("dead letter: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return done, nil
+}
+
+func (store *datastore) deadLetter(tx *tx, scan autoscan.Scan, target string, attempts int, reason string, targets []string) (bool, error) {
+	_, err := tx.Exec(sqlInsertDeadLetter, scan.Folder, target, scan.Priority, scan.Event, attempts, reason, now())
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(sqlDeleteRetry, scan.Folder, target)
+	if err != nil {
+		return false, err
+	}
+
+	return store.deliver(tx, scan, target, targets)
+}
+
+const sqlGetFailed = `
+SELECT id, folder, target, priority, event, attempts, error, time FROM dead_letter
+ORDER BY id ASC
+`
+
+// GetFailed returns the scans in the dead-letter queue.
+func (store *datastore) GetFailed() ([]FailedScan, error) {
+	rows, err := store.Query(sqlGetFailed)
+	if err != nil {
+		return nil, fmt.Errorf("get failed: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+
+	failed := make([]FailedScan, 0)
+	for rows.Next() {
+		f := FailedScan{}
+		err = rows.Scan(&f.ID, &f.Folder, &f.Target, &f.Priority, &f.Event, &f.Attempts, &f.Error, &f.Time)
+		if err != nil {
+			return nil, fmt.Errorf("get failed: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		failed = append(failed, f)
+	}
+
+	return failed, rows.Err()
+}
+
+const sqlGetFailedByID = `
+SELECT folder, target, priority, event FROM dead_letter
+WHERE id = ?
+`
+
+const sqlDeleteFailed = `
+DELETE FROM dead_letter WHERE id = ?
+`
+
+// Requeue moves the scans with the given IDs from the dead-letter queue back to the queue,
+// limited to the target the scan failed for.
+// It returns the number of requeued scans.
+func (store *datastore) Requeue(ids []int64) (int, error) {
+	tx, err := store.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("requeue: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	requeued, err := store.requeue(tx, ids)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			panic(rollbackErr)
+		}
+
+		return 0, fmt.Errorf("requeue: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("requeue: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return requeued, nil
+}
+
+func (store *datastore) requeue(tx *tx, ids []int64) (int, error) {
+	requeued := 0
+
+	for _, id := range ids {
+		scan := autoscan.Scan{Time: now()}
+		var target string
+
+		err := tx.QueryRow(sqlGetFailedByID, id).Scan(&scan.Folder, &target, &scan.Priority, &scan.Event)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			continue
+		case err != nil:
+			return requeued, err
+		}
+
+		scan.Targets = []string{target}
+		if err := store.upsert(tx, scan); err != nil {
+			return requeued, err
+		}
+
+		if _, err := tx.Exec(sqlDeleteFailed, id); err != nil {
+			return requeued, err
+		}
+
+		requeued++
+	}
+
+	return requeued, nil
+}
+
+func joinTargets(targets []string) string {
+	return strings.Join(targets, ",")
+}
+
+func splitTargets(targets string) []string {
+	if targets == "" {
+		return nil
+	}
+
+	return strings.Split(targets, ",")
+}
+
 var now = time.Now
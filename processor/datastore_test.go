@@ -2,6 +2,9 @@ package processor
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -13,7 +16,7 @@ import (
 )
 
 const sqlGetScan = `
-SELECT folder, priority, time FROM scan
+SELECT folder, priority, event, targets, check_exists, trigger, time FROM scan
 WHERE folder = ?
 `
 
@@ -21,7 +24,9 @@ func (store *datastore) GetScan(folder string) (autoscan.Scan, error) {
 	row := store.QueryRow(sqlGetScan, folder)
 
 	scan := autoscan.Scan{}
-	err := row.Scan(&scan.Folder, &scan.Priority, &scan.Time)
+	var targets string
+	err := row.Scan(&scan.Folder, &scan.Priority, &scan.Event, &targets, &scan.CheckExists, &scan.Trigger, &scan.Time)
+	scan.Targets = splitTargets(targets)
 
 	return scan, err
 }
@@ -49,6 +54,21 @@ func TestUpsert(t *testing.T) {
 				Time:     time.Time{}.Add(1),
 			},
 		},
+		{
+			Name: "Existence is only checked when all scans requested the check",
+			Scans: []autoscan.Scan{
+				{
+					CheckExists: true,
+					Time:        time.Time{}.Add(1),
+				},
+				{
+					Time: time.Time{}.Add(2),
+				},
+			},
+			WantScan: autoscan.Scan{
+				Time: time.Time{}.Add(2),
+			},
+		},
 		{
 			Name: "Priority shall increase but not decrease",
 			Scans: []autoscan.Scan{
@@ -70,6 +90,76 @@ func TestUpsert(t *testing.T) {
 				Time:     time.Time{}.Add(3),
 			},
 		},
+		{
+			Name: "Modified when the events differ",
+			Scans: []autoscan.Scan{
+				{
+					Event: autoscan.EventRemoved,
+					Time:  time.Time{}.Add(1),
+				},
+				{
+					Event: autoscan.EventAdded,
+					Time:  time.Time{}.Add(2),
+				},
+				{
+					Event: autoscan.EventRemoved,
+					Time:  time.Time{}.Add(3),
+				},
+			},
+			WantScan: autoscan.Scan{
+				Event: autoscan.EventModified,
+				Time:  time.Time{}.Add(3),
+			},
+		},
+		{
+			Name: "Removed when all scans are removals",
+			Scans: []autoscan.Scan{
+				{
+					Event: autoscan.EventRemoved,
+					Time:  time.Time{}.Add(1),
+				},
+				{
+					Event: autoscan.EventRemoved,
+					Time:  time.Time{}.Add(2),
+				},
+			},
+			WantScan: autoscan.Scan{
+				Event: autoscan.EventRemoved,
+				Time:  time.Time{}.Add(2),
+			},
+		},
+		{
+			Name: "Targets are merged when all scans are limited",
+			Scans: []autoscan.Scan{
+				{
+					Targets: []string{"plex"},
+					Time:    time.Time{}.Add(1),
+				},
+				{
+					Targets: []string{"emby"},
+					Time:    time.Time{}.Add(2),
+				},
+			},
+			WantScan: autoscan.Scan{
+				Targets: []string{"plex", "emby"},
+				Time:    time.Time{}.Add(2),
+			},
+		},
+		{
+			Name: "Targets are not limited when one scan is not limited",
+			Scans: []autoscan.Scan{
+				{
+					Targets: []string{"plex"},
+					Time:    time.Time{}.Add(1),
+				},
+				{
+					Time: time.Time{}.Add(2),
+				},
+			},
+			WantScan: autoscan.Scan{
+				Time: time.Time{}.Add(2),
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -102,6 +192,9 @@ func TestGetAvailableScan(t *testing.T) {
 		Name      string
 		Now       time.Time
 		MinAge    time.Duration
+		Hold      time.Duration
+		Aging     time.Duration
+		Updated   time.Time
 		GiveScans []autoscan.Scan
 		WantErr   error
 		WantScan  autoscan.Scan
@@ -148,6 +241,67 @@ func TestGetAvailableScan(t *testing.T) {
 				Time:     testTime.Add(-6 * time.Minute),
 			},
 		},
+		{
+			Name:   "Retrieves the scan with the highest priority",
+			Now:    testTime,
+			MinAge: 5 * time.Minute,
+			GiveScans: []autoscan.Scan{
+				{Folder: "1", Priority: 1, Time: testTime.Add(-3 * time.Hour)},
+				{Folder: "2", Priority: 5, Time: testTime.Add(-6 * time.Minute)},
+			},
+			WantScan: autoscan.Scan{
+				Folder: "2", Priority: 5, Time: testTime.Add(-6 * time.Minute),
+			},
+		},
+		{
+			Name:   "Retrieves the oldest scan of equal priorities",
+			Now:    testTime,
+			MinAge: 5 * time.Minute,
+			GiveScans: []autoscan.Scan{
+				{Folder: "1", Priority: 5, Time: testTime.Add(-6 * time.Minute)},
+				{Folder: "2", Priority: 5, Time: testTime.Add(-7 * time.Minute)},
+			},
+			WantScan: autoscan.Scan{
+				Folder: "2", Priority: 5, Time: testTime.Add(-7 * time.Minute),
+			},
+		},
+		{
+			Name:   "Ages the priority of waiting scans",
+			Now:    testTime,
+			MinAge: 5 * time.Minute,
+			Aging:  30 * time.Minute,
+			GiveScans: []autoscan.Scan{
+				{Folder: "1", Priority: 1, Time: testTime.Add(-3 * time.Hour)},
+				{Folder: "2", Priority: 5, Time: testTime.Add(-6 * time.Minute)},
+			},
+			WantScan: autoscan.Scan{
+				Folder: "1", Priority: 1, Time: testTime.Add(-3 * time.Hour),
+			},
+		},
+		{
+			Name:    "Holds scans which were recently updated",
+			Now:     testTime,
+			MinAge:  5 * time.Minute,
+			Hold:    time.Minute,
+			Updated: testTime.Add(-30 * time.Second),
+			GiveScans: []autoscan.Scan{
+				{Folder: "1", Time: testTime.Add(-6 * time.Minute)},
+			},
+			WantErr: autoscan.ErrNoScans,
+		},
+		{
+			Name:    "Retrieves scans after the hold",
+			Now:     testTime,
+			MinAge:  5 * time.Minute,
+			Hold:    time.Minute,
+			Updated: testTime.Add(-2 * time.Minute),
+			GiveScans: []autoscan.Scan{
+				{Folder: "1", Time: testTime.Add(-6 * time.Minute)},
+			},
+			WantScan: autoscan.Scan{
+				Folder: "1", Time: testTime.Add(-6 * time.Minute),
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -157,6 +311,15 @@ func TestGetAvailableScan(t *testing.T) {
 				t.Fatal(err)
 			}
 
+			updated := tc.Updated
+			if updated.IsZero() {
+				updated = tc.Now
+			}
+
+			now = func() time.Time {
+				return updated
+			}
+
 			err = store.Upsert(tc.GiveScans)
 			if err != nil {
 				t.Fatal(err)
@@ -166,7 +329,7 @@ func TestGetAvailableScan(t *testing.T) {
 				return tc.Now
 			}
 
-			scan, err := store.GetAvailableScan(tc.MinAge)
+			scan, err := store.GetAvailableScan("plex", tc.MinAge, tc.Hold, tc.Aging)
 			if !errors.Is(err, tc.WantErr) {
 				t.Fatal(err)
 			}
@@ -233,3 +396,464 @@ func TestDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestGetScanByID(t *testing.T) {
+	type Test struct {
+		Name      string
+		GiveScans []autoscan.Scan
+		GiveID    string
+		WantErr   error
+		WantScan  autoscan.Scan
+	}
+
+	var testCases = []Test{
+		{
+			Name: "Retrieves the scan of the ID",
+			GiveScans: []autoscan.Scan{
+				{Folder: "1", Priority: 2},
+				{Folder: "2", Priority: 5},
+			},
+			GiveID:   autoscan.Scan{Folder: "2"}.ID(),
+			WantScan: autoscan.Scan{Folder: "2", Priority: 5},
+		},
+		{
+			Name: "Returns ErrNoScans when the scan is not queued",
+			GiveScans: []autoscan.Scan{
+				{Folder: "1"},
+			},
+			GiveID:  autoscan.Scan{Folder: "2"}.ID(),
+			WantErr: autoscan.ErrNoScans,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			store, err := newDatastore(":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = store.Upsert(tc.GiveScans)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			scan, err := store.GetScanByID(tc.GiveID)
+			if !errors.Is(err, tc.WantErr) {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(scan, tc.WantScan) {
+				t.Log(scan)
+				t.Errorf("Scan does not match")
+			}
+		})
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	type Delivery struct {
+		Scan   autoscan.Scan
+		Target string
+	}
+
+	type Test struct {
+		Name           string
+		GiveScans      []autoscan.Scan
+		GiveDeliveries []Delivery
+		GiveUpdates    []autoscan.Scan
+		Target         string
+		WantScans      []autoscan.Scan
+		WantAvailable  error
+	}
+
+	targets := []string{"plex:http://plex", "emby:http://emby"}
+
+	testTime := time.Now().UTC()
+	scan := autoscan.Scan{Folder: "1", Time: testTime.Add(-1 * time.Hour)}
+	update := autoscan.Scan{Folder: "1", Time: testTime.Add(-30 * time.Minute)}
+	plexOnly := autoscan.Scan{Folder: "1", Targets: []string{"plex"}, Time: testTime.Add(-1 * time.Hour)}
+
+	var testCases = []Test{
+		{
+			Name:           "Keeps the scan until all targets received it",
+			GiveScans:      []autoscan.Scan{scan},
+			GiveDeliveries: []Delivery{{scan, "plex:http://plex"}},
+			Target:         "emby:http://emby",
+			WantScans:      []autoscan.Scan{scan},
+		},
+		{
+			Name:           "Does not return scans delivered to the target",
+			GiveScans:      []autoscan.Scan{scan},
+			GiveDeliveries: []Delivery{{scan, "plex:http://plex"}},
+			Target:         "plex:http://plex",
+			WantScans:      []autoscan.Scan{scan},
+			WantAvailable:  autoscan.ErrNoScans,
+		},
+		{
+			Name:           "Deletes the scan once all targets received it",
+			GiveScans:      []autoscan.Scan{scan},
+			GiveDeliveries: []Delivery{{scan, "plex:http://plex"}, {scan, "emby:http://emby"}},
+			Target:         "plex:http://plex",
+			WantAvailable:  autoscan.ErrNoScans,
+		},
+		{
+			Name:           "Deletes the scan once the targets it is meant for received it",
+			GiveScans:      []autoscan.Scan{plexOnly},
+			GiveDeliveries: []Delivery{{plexOnly, "plex:http://plex"}},
+			Target:         "emby:http://emby",
+			WantAvailable:  autoscan.ErrNoScans,
+		},
+		{
+			Name:           "Delivers updated scans to all targets again",
+			GiveScans:      []autoscan.Scan{scan},
+			GiveDeliveries: []Delivery{{scan, "plex:http://plex"}},
+			GiveUpdates:    []autoscan.Scan{update},
+			Target:         "plex:http://plex",
+			WantScans:      []autoscan.Scan{update},
+		},
+		{
+			Name:           "Does not deliver scans updated after their retrieval",
+			GiveScans:      []autoscan.Scan{update},
+			GiveDeliveries: []Delivery{{scan, "plex:http://plex"}, {scan, "emby:http://emby"}},
+			Target:         "plex:http://plex",
+			WantScans:      []autoscan.Scan{update},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			store, err := newDatastore(":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = store.Upsert(tc.GiveScans)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, d := range tc.GiveDeliveries {
+				_, err = store.Deliver(d.Scan, d.Target, targets)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = store.Upsert(tc.GiveUpdates)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now = func() time.Time {
+				return testTime
+			}
+
+			_, err = store.GetAvailableScan(tc.Target, 0, 0, 0)
+			if !errors.Is(err, tc.WantAvailable) {
+				t.Errorf("Unexpected availability: %v", err)
+			}
+
+			scans, err := store.GetAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(scans, tc.WantScans) {
+				t.Log(scans)
+				t.Errorf("Scans do not match")
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	type Test struct {
+		Name          string
+		RetryAt       time.Duration
+		Target        string
+		WantAvailable error
+	}
+
+	testTime := time.Now().UTC()
+	scan := autoscan.Scan{Folder: "1", Time: testTime.Add(-1 * time.Hour)}
+
+	var testCases = []Test{
+		{
+			Name:          "Skips scans waiting to be retried",
+			RetryAt:       time.Minute,
+			Target:        "plex:http://plex",
+			WantAvailable: autoscan.ErrNoScans,
+		},
+		{
+			Name:    "Returns scans after their retry time",
+			RetryAt: -1 * time.Minute,
+			Target:  "plex:http://plex",
+		},
+		{
+			Name:    "Returns scans waiting to be retried by other targets",
+			RetryAt: time.Minute,
+			Target:  "emby:http://emby",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			store, err := newDatastore(":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = store.Upsert([]autoscan.Scan{scan})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = store.Retry(scan, "plex:http://plex", 3, testTime.Add(tc.RetryAt))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			attempts, err := store.GetAttempts(scan, "plex:http://plex")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if attempts != 3 {
+				t.Errorf("Attempts do not match: %d vs %d", attempts, 3)
+			}
+
+			now = func() time.Time {
+				return testTime
+			}
+
+			_, err = store.GetAvailableScan(tc.Target, 0, 0, 0)
+			if !errors.Is(err, tc.WantAvailable) {
+				t.Errorf("Unexpected availability: %v", err)
+			}
+		})
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	targets := []string{"plex:http://plex", "emby:http://emby"}
+	scan := autoscan.Scan{Folder: "1", Priority: 2, Time: testTime.Add(-1 * time.Hour)}
+
+	store, err := newDatastore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Upsert([]autoscan.Scan{scan})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Retry(scan, "plex:http://plex", 5, testTime.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.DeadLetter(scan, "plex:http://plex", 6, "target unavailable", targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the scan is no longer available to the failed target, but remains available to others
+	_, err = store.GetAvailableScan("plex:http://plex", 0, 0, 0)
+	if !errors.Is(err, autoscan.ErrNoScans) {
+		t.Errorf("Expected no scans for the failed target: %v", err)
+	}
+
+	_, err = store.GetAvailableScan("emby:http://emby", 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Expected scan for the other target: %v", err)
+	}
+
+	// the scan is completed once delivered to the other target
+	_, err = store.Deliver(scan, "emby:http://emby", targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.GetScanByID(scan.ID())
+	if !errors.Is(err, autoscan.ErrNoScans) {
+		t.Errorf("Expected scan to be completed: %v", err)
+	}
+
+	failed, err := store.GetFailed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []FailedScan{
+		{
+			ID:       1,
+			Folder:   "1",
+			Target:   "plex:http://plex",
+			Priority: 2,
+			Attempts: 6,
+			Error:    "target unavailable",
+			Time:     testTime,
+		},
+	}
+
+	if !reflect.DeepEqual(failed, want) {
+		t.Log(failed)
+		t.Log(want)
+		t.Fatal("Failed scans do not match")
+	}
+
+	requeued, err := store.Requeue([]int64{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requeued != 1 {
+		t.Errorf("Requeued scans do not match: %d vs %d", requeued, 1)
+	}
+
+	failed, err = store.GetFailed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failed) != 0 {
+		t.Errorf("Expected empty dead-letter queue: %v", failed)
+	}
+
+	// the requeued scan is only meant for the failed target
+	now = func() time.Time {
+		return testTime.Add(time.Minute)
+	}
+
+	requeuedScan, err := store.GetAvailableScan("plex:http://plex", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(requeuedScan.Targets, []string{"plex:http://plex"}) {
+		t.Errorf("Targets do not match: %v", requeuedScan.Targets)
+	}
+
+	attempts, err := store.GetAttempts(scan, "plex:http://plex")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 0 {
+		t.Errorf("Attempts do not match: %d vs %d", attempts, 0)
+	}
+}
+
+func TestGetAvailableSiblings(t *testing.T) {
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	store, err := newDatastore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scans := []autoscan.Scan{
+		{Folder: "/tv/Show/Season 1", Time: testTime.Add(-1 * time.Hour)},
+		{Folder: "/tv/Show/Season 2", Time: testTime.Add(-1 * time.Hour)},
+		{Folder: "/tv/Show/Season 2/Extras", Time: testTime.Add(-1 * time.Hour)},
+		{Folder: "/tv/Show/Season 3", Time: testTime.Add(-1 * time.Hour)},
+		{Folder: "/tv/Show/Season 4", Time: testTime},
+		{Folder: "/tv/Show 2/Season 1", Time: testTime.Add(-1 * time.Hour)},
+	}
+
+	err = store.Upsert(scans)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Deliver(scans[3], "plex:http://plex", []string{"plex:http://plex", "emby:http://emby"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	siblings, err := store.GetAvailableSiblings("plex:http://plex", "/tv/Show", time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []autoscan.Scan{scans[0], scans[1]}
+	if !reflect.DeepEqual(siblings, want) {
+		t.Log(siblings)
+		t.Log(want)
+		t.Errorf("Siblings do not match")
+	}
+}
+
+func TestJournalMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autoscan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	proc, err := New(Config{DatastorePath: filepath.Join(dir, "autoscan.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer proc.Close()
+
+	var mode string
+	if err := proc.store.(*datastore).QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+
+	if mode != "wal" {
+		t.Errorf("Journal mode does not match: %s vs wal", mode)
+	}
+}
+
+func TestEphemeral(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autoscan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "autoscan.db")
+	proc, err := New(Config{DatastorePath: path, Ephemeral: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer proc.Close()
+
+	if err := proc.Add(autoscan.Scan{Folder: "/tv/Westworld", Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := proc.store.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("Number of queued scans does not match: %d vs 1", count)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no database at %s: %v", path, err)
+	}
+
+	_, err = New(Config{Ephemeral: true, DatastoreDSN: "postgres://localhost/autoscan"})
+	if !errors.Is(err, autoscan.ErrFatal) {
+		t.Errorf("Expected a fatal error with a dsn: %v", err)
+	}
+}
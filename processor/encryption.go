@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+)
+
+// An encrypted bolt datastore encrypts its values with AES-GCM,
+// and replaces its keys, such as the folder of a scan, with their HMAC.
+// Both keys are derived from the encryption key of the config.
+func newBoltCipher(secret string) (cipher.AEAD, []byte, error) {
+	derive := func(purpose string) []byte {
+		h := hmac.New(sha256.New, []byte(secret))
+		h.Write([]byte(purpose))
+		return h.Sum(nil)
+	}
+
+	block, err := aes.NewCipher(derive("autoscan values"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aead, derive("autoscan keys"), nil
+}
+
+var errInvalidCiphertext = errors.New("invalid ciphertext")
+
+// encode returns the value as JSON, which is encrypted when the datastore is encrypted.
+// The nonce is prepended to the ciphertext.
+func (store *boltDatastore) encode(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || store.aead == nil {
+		return data, err
+	}
+
+	nonce := make([]byte, store.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return store.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (store *boltDatastore) decode(data []byte, v interface{}) error {
+	if store.aead != nil {
+		size := store.aead.NonceSize()
+		if len(data) < size {
+			return errInvalidCiphertext
+		}
+
+		var err error
+		data, err = store.aead.Open(nil, data[:size], data[size:], nil)
+		if err != nil {
+			return errInvalidCiphertext
+		}
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// key returns the key of the name, which is hashed when the datastore is encrypted.
+func (store *boltDatastore) key(name string) []byte {
+	if store.mac == nil {
+		return []byte(name)
+	}
+
+	h := hmac.New(sha256.New, store.mac)
+	h.Write([]byte(name))
+	return h.Sum(nil)
+}
+
+// Deliveries and retries are keyed by the folder and the target,
+// such that the entries of a folder share a prefix.
+// The hashed key of a folder has a fixed length, and needs no separator.
+func (store *boltDatastore) folderKey(folder string) []byte {
+	if store.mac == nil {
+		return []byte(folder + "\x00")
+	}
+
+	return store.key(folder)
+}
+
+func (store *boltDatastore) targetKey(folder string, target string) []byte {
+	return append(store.folderKey(folder), store.key(target)...)
+}
+
+// scanKey returns the key of the scan of a delivery or retry.
+func (store *boltDatastore) scanKey(k []byte) []byte {
+	if store.mac == nil {
+		return k[:bytes.IndexByte(k, 0)]
+	}
+
+	return k[:sha256.Size]
+}
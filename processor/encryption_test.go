@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestEncryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autoscan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	encrypted := filepath.Join(dir, "encrypted.bolt")
+	plain := filepath.Join(dir, "plain.bolt")
+
+	for path, key := range map[string]string{encrypted: "secret", plain: ""} {
+		store, err := newBoltDatastore(path, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Upsert([]autoscan.Scan{{Folder: "/tv/Westworld", Time: time.Now()}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.AddHistory(autoscan.Scan{Folder: "/tv/Westworld"}, "plex", StatusCompleted, 0, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		store.Close()
+	}
+
+	b, err := ioutil.ReadFile(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(b, []byte("Westworld")) {
+		t.Errorf("Encrypted datastore contains the folder")
+	}
+
+	type Test struct {
+		Name string
+		Path string
+		Key  string
+		Err  bool
+	}
+
+	var testCases = []Test{
+		{
+			Name: "Encryption key",
+			Path: encrypted,
+			Key:  "secret",
+		},
+		{
+			Name: "Invalid encryption key",
+			Path: encrypted,
+			Key:  "guess",
+			Err:  true,
+		},
+		{
+			Name: "Missing encryption key",
+			Path: encrypted,
+			Err:  true,
+		},
+		{
+			Name: "Datastore is not encrypted",
+			Path: plain,
+			Key:  "secret",
+			Err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			store, err := newBoltDatastore(tc.Path, tc.Key)
+			if (err != nil) != tc.Err {
+				t.Fatalf("Errors do not match: %v", err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			defer store.Close()
+
+			scans, err := store.GetAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(scans) != 1 || scans[0].Folder != "/tv/Westworld" {
+				t.Errorf("Scans do not match: %+v", scans)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// ScanEvent describes a step in the lifecycle of a scan.
+// The target is set for every step except queued.
+type ScanEvent struct {
+	Type   string    `json:"type"`
+	ID     string    `json:"id"`
+	Folder string    `json:"folder"`
+	Target string    `json:"target,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+const (
+	// ScanQueued indicates that the scan was added to the queue.
+	ScanQueued = "queued"
+
+	// ScanDispatched indicates that the scan is being sent to the target.
+	ScanDispatched = "dispatched"
+
+	// ScanDelivered indicates that the target is done with the scan,
+	// either because the target scanned the folder or because the scan was skipped for the target.
+	ScanDelivered = "delivered"
+
+	// ScanRetried indicates that the scan failed for the target and is retried later.
+	ScanRetried = "retrying"
+
+	// ScanFailed indicates that the scan failed for the target after the maximum number of retries.
+	ScanFailed = "failed"
+)
+
+// subscriberBuffer is the number of events buffered for a subscriber.
+// Events are dropped for subscribers which do not keep up.
+const subscriberBuffer = 256
+
+// Subscribe returns a channel which receives the events of the scans,
+// and a function which ends the subscription and closes the channel.
+func (p *Processor) Subscribe() (<-chan ScanEvent, func()) {
+	events := make(chan ScanEvent, subscriberBuffer)
+
+	p.subscribersLock.Lock()
+	p.subscribers[events] = struct{}{}
+	p.subscribersLock.Unlock()
+
+	return events, func() {
+		p.subscribersLock.Lock()
+		defer p.subscribersLock.Unlock()
+
+		if _, ok := p.subscribers[events]; ok {
+			delete(p.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// CloseSubscriptions ends the current subscriptions and closes their channels,
+// such that long-lived streams of the events do not hold up a shutdown.
+func (p *Processor) CloseSubscriptions() {
+	p.subscribersLock.Lock()
+	defer p.subscribersLock.Unlock()
+
+	for events := range p.subscribers {
+		delete(p.subscribers, events)
+		close(events)
+	}
+}
+
+// publish sends the event of the scan to the subscribers without blocking the processor.
+func (p *Processor) publish(typ string, scan autoscan.Scan, target string, reason error) {
+	p.subscribersLock.Lock()
+	defer p.subscribersLock.Unlock()
+
+	if len(p.subscribers) == 0 {
+		return
+	}
+
+	e := ScanEvent{
+		Type:   typ,
+		ID:     scan.ID(),
+		Folder: scan.Folder,
+		Target: target,
+		Time:   now(),
+	}
+
+	if reason != nil {
+		e.Error = reason.Error()
+	}
+
+	for events := range p.subscribers {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
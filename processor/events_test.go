@@ -0,0 +1,76 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestEvents(t *testing.T) {
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	proc, err := New(Config{DatastorePath: ":memory:", MaxRetries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, unsubscribe := proc.Subscribe()
+
+	scans := make([]autoscan.Scan, 0)
+	plex := namedTarget{recordingTarget{scans: &scans}, "plex:http://plex"}
+	emby := failingTarget{namedTarget{recordingTarget{scans: &scans}, "emby:http://emby"}}
+	targets := []autoscan.Target{plex, emby}
+
+	scan := autoscan.Scan{Folder: "/tv/Westworld", Time: testTime.Add(-1 * time.Hour)}
+	if err := proc.Add(scan); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proc.Process(plex, targets); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first failure is retried, the second failure exceeds the maximum number of retries
+	for i := 0; i < 2; i++ {
+		if err := proc.Process(emby, targets); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		testTime = testTime.Add(24 * time.Hour)
+	}
+
+	unsubscribe()
+	if err := proc.Add(autoscan.Scan{Folder: "/tv/Dexter", Time: testTime}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0)
+	for e := range events {
+		if e.ID != scan.ID() || e.Folder != scan.Folder {
+			t.Errorf("Unexpected scan: %+v", e)
+		}
+
+		got = append(got, e.Type+" "+e.Target+" "+e.Error)
+	}
+
+	want := []string{
+		"queued  ",
+		"dispatched plex:http://plex ",
+		"delivered plex:http://plex ",
+		"dispatched emby:http://emby ",
+		"retrying emby:http://emby scan failed",
+		"dispatched emby:http://emby ",
+		"failed emby:http://emby scan failed",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Log(got)
+		t.Log(want)
+		t.Errorf("Events do not match")
+	}
+}
@@ -0,0 +1,163 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// Export holds the queue, the dead-letter queue and the history of a datastore,
+// such that these can be imported into another datastore.
+type Export struct {
+	Scans   []QueuedScan   `json:"scans"`
+	Failed  []FailedScan   `json:"failed"`
+	History []HistoryEntry `json:"history"`
+}
+
+// QueuedScan is a scan in the queue.
+type QueuedScan struct {
+	Folder      string         `json:"folder"`
+	Priority    int            `json:"priority"`
+	Event       autoscan.Event `json:"event"`
+	Targets     []string       `json:"targets,omitempty"`
+	CheckExists bool           `json:"check_exists"`
+	Trigger     string         `json:"trigger"`
+	Time        time.Time      `json:"time"`
+}
+
+func queuedScan(s autoscan.Scan) QueuedScan {
+	return QueuedScan{
+		Folder:      s.Folder,
+		Priority:    s.Priority,
+		Event:       s.Event,
+		Targets:     s.Targets,
+		CheckExists: s.CheckExists,
+		Trigger:     s.Trigger,
+		Time:        s.Time,
+	}
+}
+
+// scan returns the queued scan as a scan of the given event,
+// or of EventAdded when the export has no event.
+func (s QueuedScan) scan() autoscan.Scan {
+	event := s.Event
+	if event == "" {
+		event = autoscan.EventAdded
+	}
+
+	return autoscan.Scan{
+		Folder:      s.Folder,
+		Priority:    s.Priority,
+		Event:       event,
+		Targets:     s.Targets,
+		CheckExists: s.CheckExists,
+		Trigger:     s.Trigger,
+		Time:        s.Time,
+	}
+}
+
+const sqlGetAllHistory = `
+SELECT id, scan_id, folder, trigger, target, status, queued_at, scanned_at, duration FROM history
+ORDER BY id ASC
+`
+
+// Export returns the queue, the dead-letter queue and the history of the datastore.
+func (store *datastore) Export() (Export, error) {
+	e := Export{
+		Scans:   make([]QueuedScan, 0),
+		History: make([]HistoryEntry, 0),
+	}
+
+	scans, err := store.GetAll()
+	if err != nil {
+		return e, fmt.Errorf("export: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	for _, s := range scans {
+		e.Scans = append(e.Scans, queuedScan(s))
+	}
+
+	if e.Failed, err = store.GetFailed(); err != nil {
+		return e, err
+	}
+
+	rows, err := store.Query(sqlGetAllHistory)
+	if err != nil {
+		return e, fmt.Errorf("export: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		h := HistoryEntry{}
+		err = rows.Scan(&h.ID, &h.ScanID, &h.Folder, &h.Trigger, &h.Target, &h.Status, &h.QueuedAt, &h.ScannedAt, &h.Duration)
+		if err != nil {
+			return e, fmt.Errorf("export: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		e.History = append(e.History, h)
+	}
+
+	return e, rows.Err()
+}
+
+// Import adds the exported scans to the queue, and the failed scans and history entries
+// to the dead-letter queue and history of the datastore.
+// Queued scans of the same folder are merged, as in Upsert.
+func (store *datastore) Import(e Export) error {
+	tx, err := store.Begin()
+	if err != nil {
+		return fmt.Errorf("import: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	if err := store.importExport(tx, e); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			panic(rollbackErr)
+		}
+
+		return fmt.Errorf("import: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("import: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+func (store *datastore) importExport(tx *tx, e Export) error {
+	for _, s := range e.Scans {
+		if err := store.upsert(tx, s.scan()); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range e.Failed {
+		_, err := tx.Exec(sqlInsertDeadLetter, f.Folder, f.Target, f.Priority, f.Event, f.Attempts, f.Error, f.Time)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, h := range e.History {
+		_, err := tx.Exec(sqlInsertHistory, h.ScanID, h.Folder, h.Trigger, h.Target, h.Status, h.QueuedAt, h.ScannedAt, h.Duration)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Export returns the queue, the dead-letter queue and the history of the processor.
+// The deliveries of queued scans are not exported,
+// such that imported scans are sent to all their targets again.
+func (p *Processor) Export() (Export, error) {
+	return p.store.Export()
+}
+
+// Import adds the scans, failed scans and history entries of an export to the processor.
+func (p *Processor) Import(e Export) error {
+	return p.store.Import(e)
+}
@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestExportImport(t *testing.T) {
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	source, err := New(Config{DatastorePath: ":memory:", HistoryRetention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = source.Add(
+		autoscan.Scan{Folder: "/tv/Westworld", Priority: 5, Event: autoscan.EventRemoved, Trigger: "sonarr", Time: testTime},
+		autoscan.Scan{Folder: "/tv/Wednesday", Targets: []string{"plex"}, CheckExists: true, Time: testTime},
+		autoscan.Scan{Folder: "/tv/Dexter", Time: testTime},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dexter := autoscan.Scan{Folder: "/tv/Dexter", Event: autoscan.EventAdded, Time: testTime}
+	if _, err := source.store.DeadLetter(dexter, "plex", 5, "unavailable", []string{"plex"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := source.store.AddHistory(dexter, "plex", StatusFailed, time.Second, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := source.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exported.Scans) != 2 || len(exported.Failed) != 1 || len(exported.History) != 1 {
+		t.Fatalf("Unexpected export: %+v", exported)
+	}
+
+	destination, err := New(Config{DatastorePath: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := destination.Import(exported); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := destination.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(imported, exported) {
+		t.Log(imported)
+		t.Log(exported)
+		t.Errorf("Imports do not match")
+	}
+}
@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"time"
+)
+
+// TargetAvailability is the outcome of the most recent availability check of a target,
+// or of its most recent scan.
+type TargetAvailability struct {
+	Target    string    `json:"target"`
+	Available bool      `json:"available"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func (p *Processor) recordAvailability(target string, err error) {
+	a := TargetAvailability{
+		Target:    target,
+		Available: err == nil,
+		CheckedAt: now(),
+	}
+
+	if err != nil {
+		a.Error = err.Error()
+	}
+
+	p.availabilityLock.Lock()
+	defer p.availabilityLock.Unlock()
+
+	p.availability[target] = a
+}
+
+// Availability returns the availability of the targets, in the order of the IDs.
+// Targets which have not been checked yet are unavailable, without a check time.
+func (p *Processor) Availability(ids ...string) []TargetAvailability {
+	p.availabilityLock.Lock()
+	defer p.availabilityLock.Unlock()
+
+	availability := make([]TargetAvailability, 0, len(ids))
+	for _, id := range ids {
+		a, ok := p.availability[id]
+		if !ok {
+			a = TargetAvailability{Target: id, Error: "not checked yet"}
+		}
+
+		availability = append(availability, a)
+	}
+
+	return availability
+}
+
+// Ping checks whether the datastore can be reached.
+func (p *Processor) Ping() error {
+	return p.store.Ping()
+}
@@ -0,0 +1,364 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+const (
+	// StatusCompleted indicates that the scan was sent to the targets.
+	StatusCompleted = "completed"
+
+	// StatusFailed indicates that a target failed to scan the folder
+	// after the maximum number of retries.
+	StatusFailed = "failed"
+
+	// StatusExpired indicates that the scan exceeded the maximum age
+	// before it could be sent to a target.
+	StatusExpired = "expired"
+
+	// StatusSimulated indicates that the scan was not sent to the target in a dry run.
+	StatusSimulated = "simulated"
+)
+
+// HistoryEntry records the outcome of a scan for a target.
+type HistoryEntry struct {
+	ID        int64         `json:"id"`
+	ScanID    string        `json:"scan_id"`
+	Folder    string        `json:"folder"`
+	Trigger   string        `json:"trigger"`
+	Target    string        `json:"target"`
+	Status    string        `json:"status"`
+	QueuedAt  time.Time     `json:"queued_at"`
+	ScannedAt time.Time     `json:"scanned_at"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Stats aggregates the history of the processor.
+type Stats struct {
+	ScansPerDay    []DayStats    `json:"scans_per_day"`
+	AverageLatency time.Duration `json:"average_latency"`
+	Targets        []TargetStats `json:"targets"`
+}
+
+// DayStats holds the number of completed scans of a day.
+type DayStats struct {
+	Date  string `json:"date"`
+	Scans int    `json:"scans"`
+}
+
+// TargetStats holds the outcomes of the scans of a target.
+type TargetStats struct {
+	Target          string        `json:"target"`
+	Completed       int           `json:"completed"`
+	Failed          int           `json:"failed"`
+	Expired         int           `json:"expired"`
+	AverageDuration time.Duration `json:"average_duration"`
+}
+
+const sqlInsertHistory = `
+INSERT INTO history (scan_id, folder, trigger, target, status, queued_at, scanned_at, duration)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+const sqlPruneHistory = `
+DELETE FROM history WHERE scanned_at < ?
+`
+
+// AddHistory records the outcome of the scan for the target
+// and removes the entries which are older than the retention period.
+func (store *datastore) AddHistory(scan autoscan.Scan, target string, status string, duration time.Duration, retention time.Duration) error {
+	t := now()
+
+	_, err := store.Exec(sqlInsertHistory, scan.ID(), scan.Folder, scan.Trigger, target, status, scan.Time, t, duration)
+	if err != nil {
+		return fmt.Errorf("add history: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	_, err = store.Exec(sqlPruneHistory, t.Add(-1*retention))
+	if err != nil {
+		return fmt.Errorf("prune history: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+const sqlGetHistory = `
+SELECT id, scan_id, folder, trigger, target, status, queued_at, scanned_at, duration FROM history
+ORDER BY id DESC
+LIMIT ?
+`
+
+// GetHistory returns the most recent history entries.
+func (store *datastore) GetHistory(limit int) ([]HistoryEntry, error) {
+	rows, err := store.Query(sqlGetHistory, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get history: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+
+	entries := make([]HistoryEntry, 0)
+	for rows.Next() {
+		e := HistoryEntry{}
+		err = rows.Scan(&e.ID, &e.ScanID, &e.Folder, &e.Trigger, &e.Target, &e.Status, &e.QueuedAt, &e.ScannedAt, &e.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("get history: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// The latest outcomes of a scan share the time at which the scan was queued.
+const sqlGetHistoryStatus = `
+SELECT status FROM history
+WHERE scan_id = ?
+	AND julianday(queued_at) = (SELECT MAX(julianday(queued_at)) FROM history WHERE scan_id = ?)
+`
+
+// GetHistoryStatus returns the status of the last time the scan with the given ID was processed,
+// or StatusUnknown when the scan has no history.
+// A scan failed when it failed for any of the targets.
+func (store *datastore) GetHistoryStatus(id string) (string, error) {
+	rows, err := store.Query(sqlGetHistoryStatus, id, id)
+	if err != nil {
+		return "", fmt.Errorf("get history status: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+
+	status := StatusUnknown
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return "", fmt.Errorf("get history status: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		status = mergeStatus(status, s)
+	}
+
+	return status, rows.Err()
+}
+
+// mergeStatus combines the statuses of the targets of a scan.
+func mergeStatus(status string, s string) string {
+	switch {
+	case s == StatusFailed:
+		return StatusFailed
+	case s == StatusExpired && status != StatusFailed:
+		return StatusExpired
+	case status == StatusUnknown:
+		return s
+	}
+
+	return status
+}
+
+const sqlStatsPerDay = `
+SELECT date(scanned_at), COUNT(DISTINCT scan_id) FROM history
+WHERE status = 'completed'
+GROUP BY date(scanned_at)
+ORDER BY date(scanned_at) ASC
+`
+
+const sqlStatsLatency = `
+SELECT COALESCE(AVG((julianday(scanned_at) - julianday(queued_at)) * 86400), 0) FROM history
+WHERE status = 'completed'
+`
+
+const sqlStatsTargets = `
+SELECT target,
+	SUM(status = 'completed'),
+	SUM(status = 'failed'),
+	SUM(status = 'expired'),
+	COALESCE(AVG(CASE WHEN status = 'completed' THEN duration END), 0)
+FROM history
+GROUP BY target
+ORDER BY target ASC
+`
+
+// GetStats aggregates the history entries.
+func (store *datastore) GetStats() (Stats, error) {
+	stats := Stats{
+		ScansPerDay: make([]DayStats, 0),
+		Targets:     make([]TargetStats, 0),
+	}
+
+	rows, err := store.Query(sqlStatsPerDay)
+	if err != nil {
+		return stats, fmt.Errorf("get stats: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	for rows.Next() {
+		d := DayStats{}
+		if err := rows.Scan(&d.Date, &d.Scans); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("get stats: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		stats.ScansPerDay = append(stats.ScansPerDay, d)
+	}
+
+	rows.Close()
+
+	var latency float64
+	if err := store.QueryRow(sqlStatsLatency).Scan(&latency); err != nil {
+		return stats, fmt.Errorf("get stats: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	stats.AverageLatency = time.Duration(latency * float64(time.Second))
+
+	rows, err = store.Query(sqlStatsTargets)
+	if err != nil {
+		return stats, fmt.Errorf("get stats: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		ts := TargetStats{}
+		var duration float64
+		if err := rows.Scan(&ts.Target, &ts.Completed, &ts.Failed, &ts.Expired, &duration); err != nil {
+			return stats, fmt.Errorf("get stats: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		ts.AverageDuration = time.Duration(duration)
+		stats.Targets = append(stats.Targets, ts)
+	}
+
+	return stats, rows.Err()
+}
+
+const sqlGetHistoryFoldersByID = `
+SELECT DISTINCT folder FROM history
+WHERE scan_id = ?
+`
+
+// A folder matches the prefix when it equals the prefix or is one of its subfolders.
+const sqlGetHistoryFoldersByPrefix = `
+SELECT DISTINCT folder FROM history
+WHERE folder = ? OR substr(folder, 1, length(?)) = ?
+ORDER BY folder ASC
+`
+
+// GetHistoryFolders returns the folders in the history with the given scan IDs,
+// or the folders within the path prefix when given.
+func (store *datastore) GetHistoryFolders(ids []string, prefix string) ([]string, error) {
+	folders := make([]string, 0)
+	seen := make(map[string]bool)
+
+	collect := func(query string, args ...interface{}) error {
+		rows, err := store.Query(query, args...)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var folder string
+			if err := rows.Scan(&folder); err != nil {
+				return err
+			}
+
+			if !seen[folder] {
+				seen[folder] = true
+				folders = append(folders, folder)
+			}
+		}
+
+		return rows.Err()
+	}
+
+	for _, id := range ids {
+		if err := collect(sqlGetHistoryFoldersByID, id); err != nil {
+			return nil, fmt.Errorf("get history folders: %s: %w", err, autoscan.ErrFatal)
+		}
+	}
+
+	if prefix != "" {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if err := collect(sqlGetHistoryFoldersByPrefix, prefix, prefix+"/", prefix+"/"); err != nil {
+			return nil, fmt.Errorf("get history folders: %s: %w", err, autoscan.ErrFatal)
+		}
+	}
+
+	return folders, nil
+}
+
+const sqlDeleteFailedByFolder = `
+DELETE FROM dead_letter WHERE folder = ?
+`
+
+// DeleteFailed removes the scans of the folder from the dead-letter queue.
+func (store *datastore) DeleteFailed(folder string) error {
+	if _, err := store.Exec(sqlDeleteFailedByFolder, folder); err != nil {
+		return fmt.Errorf("delete failed: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// record adds the outcome of the scan for the target to the history.
+// The history is disabled when the retention period is zero.
+func (p *Processor) record(scan autoscan.Scan, target autoscan.Target, status string, duration time.Duration) error {
+	if p.historyRetention <= 0 {
+		return nil
+	}
+
+	return p.store.AddHistory(scan, target.ID(), status, duration, p.historyRetention)
+}
+
+// History returns the most recent history entries.
+func (p *Processor) History(limit int) ([]HistoryEntry, error) {
+	return p.store.GetHistory(limit)
+}
+
+// Stats returns the statistics of the scans in the history.
+func (p *Processor) Stats() (Stats, error) {
+	return p.store.GetStats()
+}
+
+// RequeueHistory adds the folders in the history with the given scan IDs,
+// or within the path prefix, back to the queue.
+// The scans are limited to the given targets, or sent to all targets when none are given.
+// Failed scans of the folders are removed from the dead-letter queue.
+// It returns the requeued scans.
+func (p *Processor) RequeueHistory(ids []string, prefix string, targets []string) ([]autoscan.Scan, error) {
+	folders, err := p.store.GetHistoryFolders(ids, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	scans := make([]autoscan.Scan, 0, len(folders))
+	for _, folder := range folders {
+		scans = append(scans, autoscan.Scan{
+			Folder:  folder,
+			Targets: targets,
+			Trigger: "requeue",
+			Time:    now(),
+		})
+	}
+
+	if len(scans) == 0 {
+		return scans, nil
+	}
+
+	if err := p.Add(scans...); err != nil {
+		return nil, err
+	}
+
+	for _, folder := range folders {
+		if err := p.store.DeleteFailed(folder); err != nil {
+			return nil, err
+		}
+	}
+
+	return scans, nil
+}
@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestHistory(t *testing.T) {
+	testTime := time.Date(2020, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	store, err := newDatastore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movie := autoscan.Scan{Folder: "/Movies/Interstellar (2014)", Trigger: "radarr", Time: testTime.Add(-24*time.Hour - 10*time.Minute)}
+	show := autoscan.Scan{Folder: "/TV/Westworld/Season 1", Trigger: "sonarr", Time: testTime.Add(-30 * time.Minute)}
+	old := autoscan.Scan{Folder: "/TV/Old", Trigger: "sonarr", Time: testTime.Add(-72 * time.Hour)}
+
+	add := func(scan autoscan.Scan, at time.Time, target string, status string, duration time.Duration) {
+		now = func() time.Time {
+			return at
+		}
+
+		if err := store.AddHistory(scan, target, status, duration, 48*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add(old, testTime.Add(-71*time.Hour), "plex:http://plex", StatusCompleted, time.Second)
+	add(movie, testTime.Add(-24*time.Hour), "plex:http://plex", StatusCompleted, 2*time.Second)
+	add(movie, testTime.Add(-24*time.Hour), "emby:http://emby", StatusFailed, 0)
+	add(show, testTime, "plex:http://plex", StatusCompleted, 4*time.Second)
+
+	entries, err := store.GetHistory(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the entry of the old scan exceeded the retention period
+	if len(entries) != 3 {
+		t.Fatalf("Number of entries does not match: %d vs %d", len(entries), 3)
+	}
+
+	if entries[0].Folder != show.Folder || entries[0].Trigger != "sonarr" || entries[0].Duration != 4*time.Second {
+		t.Errorf("Most recent entry does not match: %+v", entries[0])
+	}
+
+	statuses := map[string]string{
+		movie.ID(): StatusFailed,
+		show.ID():  StatusCompleted,
+		old.ID():   StatusUnknown,
+	}
+
+	for id, want := range statuses {
+		status, err := store.GetHistoryStatus(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if status != want {
+			t.Errorf("Status of %s does not match: %s vs %s", id, status, want)
+		}
+	}
+
+	stats, err := store.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Stats{
+		ScansPerDay: []DayStats{
+			{Date: "2020-06-01", Scans: 1},
+			{Date: "2020-06-02", Scans: 1},
+		},
+		AverageLatency: 20 * time.Minute,
+		Targets: []TargetStats{
+			{Target: "emby:http://emby", Failed: 1},
+			{Target: "plex:http://plex", Completed: 2, AverageDuration: 3 * time.Second},
+		},
+	}
+
+	// latencies are computed with floating point days
+	stats.AverageLatency = stats.AverageLatency.Round(time.Second)
+
+	if !reflect.DeepEqual(stats, want) {
+		t.Logf("want: %+v", want)
+		t.Logf("got:  %+v", stats)
+		t.Errorf("Stats do not match")
+	}
+}
+
+func TestRequeueHistory(t *testing.T) {
+	testTime := time.Date(2020, 6, 2, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		return testTime
+	}
+
+	proc, err := New(Config{DatastorePath: ":memory:", HistoryRetention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scans := []autoscan.Scan{
+		{Folder: "/TV/Westworld/Season 1", Time: testTime},
+		{Folder: "/TV/Westworld/Season 2", Time: testTime},
+		{Folder: "/TV/Westworld 2/Season 1", Time: testTime},
+		{Folder: "/Movies/Interstellar (2014)", Time: testTime},
+	}
+
+	for _, scan := range scans {
+		if err := proc.store.AddHistory(scan, "plex:http://plex", StatusCompleted, 0, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = proc.store.DeadLetter(scans[0], "plex:http://plex", 6, "target unavailable", []string{"plex:http://plex"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requeued, err := proc.RequeueHistory([]string{scans[3].ID()}, "/TV/Westworld", []string{"plex"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []autoscan.Scan{
+		{Folder: "/Movies/Interstellar (2014)", Targets: []string{"plex"}, Trigger: "requeue", Time: testTime},
+		{Folder: "/TV/Westworld/Season 1", Targets: []string{"plex"}, Trigger: "requeue", Time: testTime},
+		{Folder: "/TV/Westworld/Season 2", Targets: []string{"plex"}, Trigger: "requeue", Time: testTime},
+	}
+
+	if !reflect.DeepEqual(requeued, want) {
+		t.Logf("want: %v", want)
+		t.Logf("got:  %v", requeued)
+		t.Errorf("Requeued scans do not match")
+	}
+
+	queued, err := proc.store.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queued) != 3 {
+		t.Errorf("Number of queued scans does not match: %d vs %d", len(queued), 3)
+	}
+
+	failed, err := proc.Failed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failed) != 0 {
+		t.Errorf("Expected requeued folders to be removed from the dead-letter queue: %v", failed)
+	}
+}
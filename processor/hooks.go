@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+	"github.com/rs/zerolog/log"
+)
+
+// preparedRetention is the time for which the processor remembers that a scan was prepared.
+// Scans which wait longer for a target are prepared again.
+const preparedRetention = 24 * time.Hour
+
+// prepare runs the pre-scan hooks for the scan,
+// unless the hooks already ran for the scan for another target.
+// A newer scan of the same folder is prepared again.
+// The hooks do not run in a dry run.
+func (p *Processor) prepare(scan autoscan.Scan) error {
+	if len(p.preScanHooks) == 0 || p.dryRun {
+		return nil
+	}
+
+	p.preparedLock.Lock()
+	t, ok := p.prepared[scan.Folder]
+	p.preparedLock.Unlock()
+
+	if ok && !t.Before(scan.Time) {
+		return nil
+	}
+
+	// the hooks run without the lock, such that a slow hook does not hold up the other targets,
+	// which may prepare the same scan concurrently
+	for _, hook := range p.preScanHooks {
+		if err := hook.PreScan(scan); err != nil {
+			return err
+		}
+	}
+
+	p.preparedLock.Lock()
+	defer p.preparedLock.Unlock()
+
+	// forget scans which were prepared a long time ago
+	for folder, t := range p.prepared {
+		if t.Before(now().Add(-1 * preparedRetention)) {
+			delete(p.prepared, folder)
+		}
+	}
+
+	if t, ok := p.prepared[scan.Folder]; !ok || t.Before(scan.Time) {
+		p.prepared[scan.Folder] = scan.Time
+	}
+
+	return nil
+}
+
+// deliver marks the scan as delivered to the target
+// and runs the post-scan hooks once all targets processed the scan.
+func (p *Processor) deliver(scan autoscan.Scan, target autoscan.Target, targets []string) error {
+	done, err := p.store.Deliver(scan, target.ID(), targets)
+	if err != nil {
+		return err
+	}
+
+	p.publish(ScanDelivered, scan, target.ID(), nil)
+	if !done {
+		return nil
+	}
+
+	return p.finish(scan)
+}
+
+// finish runs the post-scan hooks for the scan, except in a dry run.
+// Failing hooks do not affect the scan, which was already processed by all targets.
+func (p *Processor) finish(scan autoscan.Scan) error {
+	if len(p.postScanHooks) == 0 || p.dryRun {
+		return nil
+	}
+
+	failed, err := p.store.HasFailed(scan)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range p.postScanHooks {
+		if err := hook.PostScan(scan, failed); err != nil {
+			log.Warn().
+				Err(err).
+				Str("path", scan.Folder).
+				Msg("Post-scan hook failed")
+		}
+	}
+
+	return nil
+}
+
+// A scan failed when it was moved to the dead-letter queue for any target after it was queued.
+const sqlHasFailed = `
+SELECT EXISTS (SELECT 1 FROM dead_letter WHERE folder = ? AND julianday(time) >= julianday(?))
+`
+
+// HasFailed returns whether the scan was moved to the dead-letter queue of any target.
+func (store *datastore) HasFailed(scan autoscan.Scan) (bool, error) {
+	var failed bool
+	if err := store.QueryRow(sqlHasFailed, scan.Folder, scan.Time).Scan(&failed); err != nil {
+		return false, fmt.Errorf("has failed: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return failed, nil
+}
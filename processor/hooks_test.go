@@ -0,0 +1,257 @@
+package processor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+type mockHook struct {
+	folders *[]string
+	err     error
+}
+
+func (h mockHook) PreScan(scan autoscan.Scan) error {
+	*h.folders = append(*h.folders, scan.Folder)
+	return h.err
+}
+
+type namedTarget struct {
+	recordingTarget
+	id string
+}
+
+func (t namedTarget) ID() string { return t.id }
+
+func TestPreScanHooks(t *testing.T) {
+	type Test struct {
+		Name        string
+		Err         error
+		WantFolders []string
+		WantScans   int
+		WantQueued  int
+	}
+
+	var testCases = []Test{
+		{
+			Name:        "Runs the hooks once for every scan",
+			WantFolders: []string{"/tv/Westworld", "/tv/Wednesday"},
+			WantScans:   4,
+			WantQueued:  0,
+		},
+		{
+			Name:        "Retries the scans when a hook fails",
+			Err:         autoscan.ErrTargetUnavailable,
+			WantFolders: []string{"/tv/Westworld", "/tv/Wednesday", "/tv/Westworld", "/tv/Wednesday"},
+			WantScans:   0,
+			WantQueued:  2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testTime := time.Now().UTC()
+			now = func() time.Time {
+				return testTime
+			}
+
+			folders := make([]string, 0)
+			proc, err := New(Config{
+				DatastorePath: ":memory:",
+				MaxRetries:    5,
+				PreScanHooks:  []autoscan.PreScanHook{mockHook{folders: &folders, err: tc.Err}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = proc.Add(
+				autoscan.Scan{Folder: "/tv/Westworld", Time: testTime.Add(-2 * time.Hour)},
+				autoscan.Scan{Folder: "/tv/Wednesday", Time: testTime.Add(-1 * time.Hour)},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			scans := make([]autoscan.Scan, 0)
+			targets := []autoscan.Target{
+				namedTarget{recordingTarget{scans: &scans}, "plex:http://plex"},
+				namedTarget{recordingTarget{scans: &scans}, "emby:http://emby"},
+			}
+
+			for _, target := range targets {
+				for {
+					err := proc.Process(target, targets)
+					if errors.Is(err, autoscan.ErrNoScans) {
+						break
+					}
+
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if !reflect.DeepEqual(folders, tc.WantFolders) {
+				t.Errorf("Prepared folders do not match: %v vs %v", folders, tc.WantFolders)
+			}
+
+			if len(scans) != tc.WantScans {
+				t.Errorf("Number of scans does not match: %d vs %d", len(scans), tc.WantScans)
+			}
+
+			count, err := proc.store.Count()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if count != tc.WantQueued {
+				t.Errorf("Number of queued scans does not match: %d vs %d", count, tc.WantQueued)
+			}
+		})
+	}
+}
+
+type mockPostHook struct {
+	calls *[]string
+}
+
+func (h mockPostHook) PostScan(scan autoscan.Scan, failed bool) error {
+	status := StatusCompleted
+	if failed {
+		status = StatusFailed
+	}
+
+	*h.calls = append(*h.calls, scan.Folder+":"+status)
+	return nil
+}
+
+type failingTarget struct {
+	namedTarget
+}
+
+func (t failingTarget) Scan(autoscan.Scan) error {
+	return autoscan.ErrScanFailed
+}
+
+type unavailableTarget struct {
+	namedTarget
+}
+
+func (t unavailableTarget) Scan(autoscan.Scan) error {
+	return autoscan.ErrTargetUnavailable
+}
+
+type rejectingTarget struct {
+	namedTarget
+}
+
+func (t rejectingTarget) Scan(autoscan.Scan) error {
+	return autoscan.ErrScanRejected
+}
+
+// downTarget fails to scan because it went offline, which its availability check confirms.
+type downTarget struct {
+	failingTarget
+}
+
+func (t downTarget) Available() error {
+	return autoscan.ErrTargetUnavailable
+}
+
+func TestPostScanHooks(t *testing.T) {
+	type Test struct {
+		Name  string
+		Plex  autoscan.Target
+		Want  []string
+		Tries int
+	}
+
+	scans := make([]autoscan.Scan, 0)
+	plex := namedTarget{recordingTarget{scans: &scans}, "plex:http://plex"}
+	emby := namedTarget{recordingTarget{scans: &scans}, "emby:http://emby"}
+
+	var testCases = []Test{
+		{
+			Name:  "Runs the hooks once all targets completed the scan",
+			Plex:  plex,
+			Want:  []string{"/tv/Westworld:completed"},
+			Tries: 1,
+		},
+		{
+			Name:  "Runs the hooks once the scan failed for a target",
+			Plex:  failingTarget{plex},
+			Want:  []string{"/tv/Westworld:failed"},
+			Tries: 2,
+		},
+		{
+			Name:  "Runs the hooks once the target rejected the scan",
+			Plex:  rejectingTarget{plex},
+			Want:  []string{"/tv/Westworld:failed"},
+			Tries: 1,
+		},
+		{
+			Name:  "Does not fail the scan while the target is unavailable",
+			Plex:  unavailableTarget{plex},
+			Want:  []string{},
+			Tries: 3,
+		},
+		{
+			Name:  "Does not fail the scan while the target is offline",
+			Plex:  downTarget{failingTarget{plex}},
+			Want:  []string{},
+			Tries: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testTime := time.Now().UTC()
+			now = func() time.Time {
+				return testTime
+			}
+
+			calls := make([]string, 0)
+			proc, err := New(Config{
+				DatastorePath: ":memory:",
+				MaxRetries:    1,
+				PostScanHooks: []autoscan.PostScanHook{mockPostHook{calls: &calls}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = proc.Add(autoscan.Scan{Folder: "/tv/Westworld", Time: testTime.Add(-1 * time.Hour)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			targets := []autoscan.Target{emby, tc.Plex}
+
+			if err := proc.Process(emby, targets); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(calls) != 0 {
+				t.Fatalf("Expected no hooks before all targets processed the scan: %v", calls)
+			}
+
+			for i := 0; i < tc.Tries; i++ {
+				// skip the backoff of the previous attempt
+				testTime = testTime.Add(time.Hour)
+
+				err := proc.Process(tc.Plex, targets)
+				if errors.Is(err, autoscan.ErrFatal) {
+					t.Fatal(err)
+				}
+			}
+
+			if !reflect.DeepEqual(calls, tc.Want) {
+				t.Errorf("Hook calls do not match: %v vs %v", calls, tc.Want)
+			}
+		})
+	}
+}
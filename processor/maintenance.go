@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// MaintenanceResult describes the entries removed by the maintenance of the datastore.
+type MaintenanceResult struct {
+	History  int64         `json:"history"`
+	Failed   int64         `json:"failed"`
+	Orphans  int64         `json:"orphans"`
+	Duration time.Duration `json:"duration"`
+}
+
+const sqlPruneFailed = `
+DELETE FROM dead_letter WHERE time < ?
+`
+
+// Deliveries, retries and claims are orphaned when their scan is no longer queued.
+const sqlPruneDelivered = `
+DELETE FROM delivered WHERE folder NOT IN (SELECT folder FROM scan)
+`
+
+const sqlPruneRetry = `
+DELETE FROM retry WHERE folder NOT IN (SELECT folder FROM scan)
+`
+
+const sqlPruneClaim = `
+DELETE FROM claim WHERE folder NOT IN (SELECT folder FROM scan)
+`
+
+const sqlVacuum = `
+VACUUM
+`
+
+const sqlAnalyze = `
+ANALYZE
+`
+
+// Prune removes the history entries and failed scans which are older than their retention period,
+// and the deliveries, retries and claims of scans which are no longer queued.
+// Entries are kept when their retention period is zero.
+func (store *datastore) Prune(historyRetention time.Duration, failedRetention time.Duration) (MaintenanceResult, error) {
+	result := MaintenanceResult{}
+	t := now()
+
+	var err error
+	if historyRetention > 0 {
+		result.History, err = store.prune(sqlPruneHistory, t.Add(-1*historyRetention))
+		if err != nil {
+			return result, fmt.Errorf("prune history: %s: %w", err, autoscan.ErrFatal)
+		}
+	}
+
+	if failedRetention > 0 {
+		result.Failed, err = store.prune(sqlPruneFailed, t.Add(-1*failedRetention))
+		if err != nil {
+			return result, fmt.Errorf("prune failed: %s: %w", err, autoscan.ErrFatal)
+		}
+	}
+
+	for _, query := range []string{sqlPruneDelivered, sqlPruneRetry, sqlPruneClaim} {
+		orphans, err := store.prune(query)
+		if err != nil {
+			return result, fmt.Errorf("prune orphans: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		result.Orphans += orphans
+	}
+
+	return result, nil
+}
+
+func (store *datastore) prune(query string, args ...interface{}) (int64, error) {
+	res, err := store.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Vacuum reclaims the space of removed entries and updates the statistics of the query planner.
+func (store *datastore) Vacuum() error {
+	if _, err := store.Exec(sqlVacuum); err != nil {
+		return fmt.Errorf("vacuum: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	if _, err := store.Exec(sqlAnalyze); err != nil {
+		return fmt.Errorf("analyze: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// Maintain prunes and vacuums the datastore,
+// such that its size does not grow unbounded over time.
+func (p *Processor) Maintain() (MaintenanceResult, error) {
+	start := time.Now()
+
+	result, err := p.store.Prune(p.historyRetention, p.failedRetention)
+	if err != nil {
+		return result, err
+	}
+
+	if err := p.store.Vacuum(); err != nil {
+		return result, err
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
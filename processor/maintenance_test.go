@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestMaintain(t *testing.T) {
+	type Test struct {
+		Name             string
+		HistoryRetention time.Duration
+		FailedRetention  time.Duration
+		Want             MaintenanceResult
+	}
+
+	var testCases = []Test{
+		{
+			Name:             "Prunes entries older than their retention",
+			HistoryRetention: 24 * time.Hour,
+			FailedRetention:  24 * time.Hour,
+			Want:             MaintenanceResult{History: 1, Failed: 1, Orphans: 2},
+		},
+		{
+			Name: "Keeps entries without retention",
+			Want: MaintenanceResult{Orphans: 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testTime := time.Now().UTC()
+			now = func() time.Time {
+				return testTime.Add(-48 * time.Hour)
+			}
+
+			proc, err := New(Config{
+				DatastorePath:    ":memory:",
+				HistoryRetention: tc.HistoryRetention,
+				FailedRetention:  tc.FailedRetention,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			old := autoscan.Scan{Folder: "/tv/Westworld", Time: testTime.Add(-48 * time.Hour)}
+			if err := proc.store.AddHistory(old, "plex", StatusCompleted, time.Second, 7*24*time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := proc.store.Upsert([]autoscan.Scan{old}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := proc.store.DeadLetter(old, "plex", 5, "unavailable", []string{"plex"}); err != nil {
+				t.Fatal(err)
+			}
+
+			now = func() time.Time {
+				return testTime
+			}
+
+			recent := autoscan.Scan{Folder: "/tv/Wednesday", Time: testTime}
+			if err := proc.store.AddHistory(recent, "plex", StatusCompleted, time.Second, 7*24*time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			// deliveries and retries of a scan which is no longer queued
+			if _, err := proc.store.(*datastore).Exec("INSERT INTO delivered (folder, target) VALUES ('/tv/Dexter', 'plex')"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := proc.store.Retry(autoscan.Scan{Folder: "/tv/Dexter"}, "emby", 1, testTime); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := proc.Maintain()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result.Duration = 0
+			if result != tc.Want {
+				t.Errorf("Results do not match: %+v vs %+v", result, tc.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// The migrations of a database are named after their version, e.g. 0002_delivery.sql,
+// and are applied in order of their version.
+// Applied migrations must not be changed, new schema changes are added as a new migration.
+//
+//go:embed migrations
+var migrations embed.FS
+
+const sqlCreateMigration = `
+CREATE TABLE IF NOT EXISTS migration (
+	"version" INTEGER PRIMARY KEY,
+	"name" TEXT NOT NULL,
+	"applied_at" DATETIME NOT NULL
+)
+`
+
+const sqlGetMigrations = `
+SELECT version FROM migration
+`
+
+const sqlInsertMigration = `
+INSERT INTO migration (version, name, applied_at) VALUES (?, ?, ?)
+`
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations returns the migrations in the directory, ordered by version.
+func loadMigrations(dir string) ([]migration, error) {
+	entries, err := migrations.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := make([]migration, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version", name)
+		}
+
+		b, err := migrations.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		ms = append(ms, migration{version: version, name: name, sql: string(b)})
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].version < ms[j].version
+	})
+
+	for i := 1; i < len(ms); i++ {
+		if ms[i].version == ms[i-1].version {
+			return nil, fmt.Errorf("migrations %s and %s share a version", ms[i-1].name, ms[i].name)
+		}
+	}
+
+	return ms, nil
+}
+
+// migrate applies the migrations in the directory which have not yet been applied to the database.
+// Every migration is applied in its own transaction.
+func (store *datastore) migrate(dir string) error {
+	ms, err := loadMigrations(dir)
+	if err != nil {
+		return fmt.Errorf("migrate: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	if _, err := store.Exec(sqlCreateMigration); err != nil {
+		return fmt.Errorf("migrate: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	rows, err := store.Query(sqlGetMigrations)
+	if err != nil {
+		return fmt.Errorf("migrate: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("migrate: %s: %w", err, autoscan.ErrFatal)
+		}
+
+		applied[version] = true
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("migrate: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	for _, m := range ms {
+		if applied[m.version] {
+			continue
+		}
+
+		if err := store.apply(m); err != nil {
+			return fmt.Errorf("migrate %s: %s: %w", m.name, err, autoscan.ErrFatal)
+		}
+	}
+
+	return nil
+}
+
+func (store *datastore) apply(m migration) error {
+	tx, err := store.Begin()
+	if err != nil {
+		return err
+	}
+
+	// migrations are written for their database and are not translated
+	if _, err := tx.Tx.Exec(m.sql); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			panic(rollbackErr)
+		}
+
+		return err
+	}
+
+	if _, err := tx.Exec(sqlInsertMigration, m.version, m.name, now()); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			panic(rollbackErr)
+		}
+
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autoscan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "autoscan.db")
+	testTime := time.Now().UTC().Add(-1 * time.Hour)
+
+	// a database from before the migrations
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+CREATE TABLE scan (
+	"folder" TEXT NOT NULL,
+	"priority" INTEGER NOT NULL,
+	"time" DATETIME NOT NULL,
+	PRIMARY KEY(folder)
+);
+
+INSERT INTO scan (folder, priority, time) VALUES ('/tv/Westworld', 5, ?);
+`, testTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+
+	ms, err := loadMigrations("migrations/sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		store, err := newDatastore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		scan, err := store.GetAvailableScan("plex", 0, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := autoscan.Scan{
+			Folder:   "/tv/Westworld",
+			Priority: 5,
+			Event:    autoscan.EventAdded,
+			Time:     testTime,
+		}
+
+		if !reflect.DeepEqual(scan, want) {
+			t.Log(scan)
+			t.Log(want)
+			t.Errorf("Scan does not match")
+		}
+
+		var applied int
+		if err := store.QueryRow("SELECT COUNT(*) FROM migration").Scan(&applied); err != nil {
+			t.Fatal(err)
+		}
+
+		if applied != len(ms) {
+			t.Errorf("Number of applied migrations does not match: %d vs %d", applied, len(ms))
+		}
+
+		migrations, err := store.Migrations()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(migrations) != len(ms) {
+			t.Fatalf("Number of migrations does not match: %d vs %d", len(migrations), len(ms))
+		}
+
+		for i, m := range migrations {
+			if m.Version != ms[i].version || m.Name != ms[i].name || m.AppliedAt == nil {
+				t.Errorf("Migration %s does not match: %+v", ms[i].name, m)
+			}
+		}
+
+		store.Close()
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dir := range []string{"migrations/sqlite", "migrations/postgres"} {
+		ms, err := loadMigrations(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, m := range ms {
+			if m.version != i+1 {
+				t.Errorf("%s: version of %s does not match: %d vs %d", dir, m.name, m.version, i+1)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+CREATE TABLE IF NOT EXISTS scan (
+	"folder" TEXT NOT NULL,
+	"id" TEXT NOT NULL DEFAULT '',
+	"priority" INTEGER NOT NULL,
+	"event" TEXT NOT NULL DEFAULT 'added',
+	"targets" TEXT NOT NULL DEFAULT '',
+	"check_exists" BOOLEAN NOT NULL DEFAULT FALSE,
+	"trigger" TEXT NOT NULL DEFAULT '',
+	"time" TIMESTAMPTZ NOT NULL,
+	"updated" TIMESTAMPTZ NOT NULL DEFAULT '-infinity',
+	PRIMARY KEY(folder)
+);
+
+CREATE INDEX IF NOT EXISTS scan_id ON scan (id);
+
+CREATE TABLE IF NOT EXISTS delivered (
+	"folder" TEXT NOT NULL,
+	"target" TEXT NOT NULL,
+	PRIMARY KEY(folder, target)
+);
+
+CREATE TABLE IF NOT EXISTS retry (
+	"folder" TEXT NOT NULL,
+	"target" TEXT NOT NULL,
+	"attempts" INTEGER NOT NULL,
+	"retry_at" TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY(folder, target)
+);
+
+CREATE TABLE IF NOT EXISTS history (
+	"id" BIGSERIAL PRIMARY KEY,
+	"scan_id" TEXT NOT NULL,
+	"folder" TEXT NOT NULL,
+	"trigger" TEXT NOT NULL,
+	"target" TEXT NOT NULL,
+	"status" TEXT NOT NULL,
+	"queued_at" TIMESTAMPTZ NOT NULL,
+	"scanned_at" TIMESTAMPTZ NOT NULL,
+	"duration" BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS history_scan_id ON history (scan_id);
+CREATE INDEX IF NOT EXISTS history_scanned_at ON history (scanned_at);
+
+CREATE TABLE IF NOT EXISTS dead_letter (
+	"id" BIGSERIAL PRIMARY KEY,
+	"folder" TEXT NOT NULL,
+	"target" TEXT NOT NULL,
+	"priority" INTEGER NOT NULL,
+	"event" TEXT NOT NULL DEFAULT 'added',
+	"attempts" INTEGER NOT NULL,
+	"error" TEXT NOT NULL,
+	"time" TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS pause (
+	"id" INTEGER PRIMARY KEY CHECK (id = 1),
+	"time" TIMESTAMPTZ NOT NULL
+);
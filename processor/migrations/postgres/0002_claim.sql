@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS claim (
+	"folder" TEXT NOT NULL,
+	"target" TEXT NOT NULL,
+	"claimed_by" TEXT NOT NULL,
+	"expires_at" TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY(folder, target)
+);
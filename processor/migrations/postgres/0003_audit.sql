@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS audit (
+	"id" BIGSERIAL PRIMARY KEY,
+	"time" TIMESTAMPTZ NOT NULL,
+	"event" TEXT NOT NULL,
+	"trigger" TEXT NOT NULL,
+	"source_ip" TEXT NOT NULL,
+	"username" TEXT NOT NULL,
+	"method" TEXT NOT NULL,
+	"path" TEXT NOT NULL,
+	"status" INTEGER NOT NULL,
+	"scans" INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_time ON audit (time);
+CREATE INDEX IF NOT EXISTS audit_event ON audit (event);
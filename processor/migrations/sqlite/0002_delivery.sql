@@ -0,0 +1,53 @@
+ALTER TABLE scan ADD COLUMN "id" TEXT NOT NULL DEFAULT '';
+ALTER TABLE scan ADD COLUMN "event" TEXT NOT NULL DEFAULT 'added';
+ALTER TABLE scan ADD COLUMN "targets" TEXT NOT NULL DEFAULT '';
+ALTER TABLE scan ADD COLUMN "check_exists" BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE scan ADD COLUMN "trigger" TEXT NOT NULL DEFAULT '';
+ALTER TABLE scan ADD COLUMN "updated" DATETIME NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS scan_id ON scan (id);
+
+CREATE TABLE IF NOT EXISTS delivered (
+	"folder" TEXT NOT NULL,
+	"target" TEXT NOT NULL,
+	PRIMARY KEY(folder, target)
+);
+
+CREATE TABLE IF NOT EXISTS retry (
+	"folder" TEXT NOT NULL,
+	"target" TEXT NOT NULL,
+	"attempts" INTEGER NOT NULL,
+	"retry_at" DATETIME NOT NULL,
+	PRIMARY KEY(folder, target)
+);
+
+CREATE TABLE IF NOT EXISTS history (
+	"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+	"scan_id" TEXT NOT NULL,
+	"folder" TEXT NOT NULL,
+	"trigger" TEXT NOT NULL,
+	"target" TEXT NOT NULL,
+	"status" TEXT NOT NULL,
+	"queued_at" DATETIME NOT NULL,
+	"scanned_at" DATETIME NOT NULL,
+	"duration" INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS history_scan_id ON history (scan_id);
+CREATE INDEX IF NOT EXISTS history_scanned_at ON history (scanned_at);
+
+CREATE TABLE IF NOT EXISTS dead_letter (
+	"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+	"folder" TEXT NOT NULL,
+	"target" TEXT NOT NULL,
+	"priority" INTEGER NOT NULL,
+	"event" TEXT NOT NULL DEFAULT 'added',
+	"attempts" INTEGER NOT NULL,
+	"error" TEXT NOT NULL,
+	"time" DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS pause (
+	"id" INTEGER PRIMARY KEY CHECK (id = 1),
+	"time" DATETIME NOT NULL
+);
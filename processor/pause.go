@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// PauseStatus describes whether the processor is paused.
+type PauseStatus struct {
+	Paused bool       `json:"paused"`
+	Since  *time.Time `json:"since,omitempty"`
+}
+
+// Pausing twice keeps the time of the first pause.
+const sqlPause = `
+INSERT OR IGNORE INTO pause (id, time) VALUES (1, ?)
+`
+
+const sqlResume = `
+DELETE FROM pause
+`
+
+const sqlGetPause = `
+SELECT time FROM pause WHERE id = 1
+`
+
+// Pause records that the processor is paused.
+func (store *datastore) Pause() error {
+	if _, err := store.Exec(sqlPause, now()); err != nil {
+		return fmt.Errorf("pause: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// Resume removes the pause of the processor.
+func (store *datastore) Resume() error {
+	if _, err := store.Exec(sqlResume); err != nil {
+		return fmt.Errorf("resume: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// GetPause returns whether the processor is paused, and since when.
+func (store *datastore) GetPause() (PauseStatus, error) {
+	var since time.Time
+	err := store.QueryRow(sqlGetPause).Scan(&since)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return PauseStatus{}, nil
+	case err != nil:
+		return PauseStatus{}, fmt.Errorf("get pause: %s: %w", err, autoscan.ErrFatal)
+	}
+
+	return PauseStatus{Paused: true, Since: &since}, nil
+}
+
+// Pause stops sending scans to the targets until Resume is called.
+// Scans are still added to the queue while the processor is paused.
+// The pause is kept in the datastore, such that it outlasts a restart.
+func (p *Processor) Pause() error {
+	return p.store.Pause()
+}
+
+// Resume continues sending scans to the targets.
+func (p *Processor) Resume() error {
+	return p.store.Resume()
+}
+
+// PauseStatus returns whether the processor is paused.
+func (p *Processor) PauseStatus() (PauseStatus, error) {
+	return p.store.GetPause()
+}
@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestPause(t *testing.T) {
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	proc, err := New(Config{DatastorePath: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proc.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	// pausing again keeps the time of the first pause
+	now = func() time.Time {
+		return testTime.Add(time.Hour)
+	}
+
+	if err := proc.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := proc.PauseStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !status.Paused || status.Since == nil || !status.Since.Equal(testTime) {
+		t.Errorf("Pause status does not match: %v", status)
+	}
+
+	// scans are still queued while paused
+	if err := proc.Add(autoscan.Scan{Folder: "/tv/Westworld", Time: testTime.Add(-1 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	scans := make([]autoscan.Scan, 0)
+	target := recordingTarget{scans: &scans}
+	targets := []autoscan.Target{target}
+
+	if err := proc.Process(target, targets); !errors.Is(err, autoscan.ErrPaused) {
+		t.Fatalf("Expected the processor to be paused: %v", err)
+	}
+
+	if err := proc.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proc.Process(target, targets); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scans) != 1 {
+		t.Errorf("Expected the scan after resuming: %v", scans)
+	}
+
+	status, err = proc.PauseStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Paused {
+		t.Errorf("Expected the processor to be resumed: %v", status)
+	}
+}
@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	// postgres driver
+	_ "github.com/lib/pq"
+)
+
+func newPostgresDatastore(dsn string) (*datastore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &datastore{db, postgres{}}
+	if err := store.migrate(store.dialect.migrations()); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// postgres translates the queries of the datastore to PostgreSQL.
+// Queries which use functions specific to SQLite are replaced as a whole,
+// after which the placeholders are numbered.
+type postgres struct{}
+
+var postgresQueries = map[string]string{
+	sqlCreateMigration: `
+CREATE TABLE IF NOT EXISTS migration (
+	"version" INTEGER PRIMARY KEY,
+	"name" TEXT NOT NULL,
+	"applied_at" TIMESTAMPTZ NOT NULL
+)
+`,
+
+	sqlUpsert: `
+INSERT INTO scan (folder, id, priority, event, targets, check_exists, trigger, time, updated)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (folder) DO UPDATE SET
+	priority = GREATEST(excluded.priority, scan.priority),
+	event = CASE WHEN excluded.event = scan.event THEN scan.event ELSE 'modified' END,
+	check_exists = LEAST(excluded.check_exists, scan.check_exists),
+	trigger = excluded.trigger,
+	targets = CASE
+		WHEN excluded.targets = '' OR scan.targets = '' THEN ''
+		WHEN excluded.targets = scan.targets THEN scan.targets
+		ELSE scan.targets || ',' || excluded.targets
+	END,
+	time = excluded.time,
+	updated = excluded.updated
+`,
+
+	sqlGetAvailableScanAging: `
+SELECT folder, priority, event, targets, check_exists, trigger, time FROM scan
+WHERE time < ?
+	AND updated <= ?
+	AND folder NOT IN (SELECT folder FROM delivered WHERE target = ?)
+	AND folder NOT IN (SELECT folder FROM retry WHERE target = ? AND retry_at > ?)
+	AND folder NOT IN (SELECT folder FROM claim WHERE target = ? AND expires_at > ?)
+ORDER BY priority + FLOOR(EXTRACT(EPOCH FROM (?::timestamptz - time)) / ?::float8)::integer DESC, time ASC
+LIMIT 1
+`,
+
+	sqlGetAvailableSiblings: `
+SELECT folder, priority, event, targets, check_exists, trigger, time FROM scan
+WHERE time < ?
+	AND updated <= ?
+	AND folder NOT IN (SELECT folder FROM delivered WHERE target = ?)
+	AND folder NOT IN (SELECT folder FROM retry WHERE target = ? AND retry_at > ?)
+	AND folder NOT IN (SELECT folder FROM claim WHERE target = ? AND expires_at > ?)
+	AND substr(folder, 1, length(?::text)) = ?
+	AND strpos(substr(folder, length(?::text) + 1), '/') = 0
+ORDER BY folder ASC
+`,
+
+	sqlDeliver: `
+INSERT INTO delivered (folder, target)
+SELECT folder, ?::text FROM scan
+WHERE folder = ? AND time = ?
+ON CONFLICT DO NOTHING
+`,
+
+	sqlPause: `
+INSERT INTO pause (id, time) VALUES (1, ?)
+ON CONFLICT DO NOTHING
+`,
+
+	sqlHasFailed: `
+SELECT EXISTS (SELECT 1 FROM dead_letter WHERE folder = ? AND time >= ?)
+`,
+
+	sqlGetHistoryStatus: `
+SELECT status FROM history
+WHERE scan_id = ?
+	AND queued_at = (SELECT MAX(queued_at) FROM history WHERE scan_id = ?)
+`,
+
+	sqlStatsPerDay: `
+SELECT to_char(scanned_at, 'YYYY-MM-DD'), COUNT(DISTINCT scan_id) FROM history
+WHERE status = 'completed'
+GROUP BY 1
+ORDER BY 1 ASC
+`,
+
+	sqlStatsLatency: `
+SELECT COALESCE(AVG(EXTRACT(EPOCH FROM (scanned_at - queued_at))), 0)::float8 FROM history
+WHERE status = 'completed'
+`,
+
+	sqlStatsTargets: `
+SELECT target,
+	COUNT(*) FILTER (WHERE status = 'completed'),
+	COUNT(*) FILTER (WHERE status = 'failed'),
+	COUNT(*) FILTER (WHERE status = 'expired'),
+	COALESCE(AVG(CASE WHEN status = 'completed' THEN duration END), 0)::float8
+FROM history
+GROUP BY target
+ORDER BY target ASC
+`,
+
+	sqlGetHistoryFoldersByPrefix: `
+SELECT DISTINCT folder FROM history
+WHERE folder = ? OR substr(folder, 1, length(?::text)) = ?
+ORDER BY folder ASC
+`,
+
+	sqlIntegrityCheck: `
+SELECT 'ok'
+`,
+}
+
+func (postgres) migrations() string {
+	return "migrations/postgres"
+}
+
+func (postgres) query(q string) string {
+	if pq, ok := postgresQueries[q]; ok {
+		q = pq
+	}
+
+	// number the placeholders, as in $1, $2, ...
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
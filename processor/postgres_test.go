@@ -0,0 +1,151 @@
+package processor
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPostgresQuery(t *testing.T) {
+	type Test struct {
+		Name  string
+		Query string
+		Want  []string
+		Not   []string
+	}
+
+	var testCases = []Test{
+		{
+			Name:  "Numbers the placeholders",
+			Query: sqlRetry,
+			Want:  []string{"VALUES ($1, $2, $3, $4)"},
+			Not:   []string{"?"},
+		},
+		{
+			Name:  "Replaces queries with functions specific to SQLite",
+			Query: sqlGetAvailableScanAging,
+			Want:  []string{"EXTRACT(EPOCH FROM ($8::timestamptz - time)) / $9::float8"},
+			Not:   []string{"?", "julianday"},
+		},
+		{
+			Name:  "Replaces INSERT OR IGNORE",
+			Query: sqlDeliver,
+			Want:  []string{"SELECT folder, $1::text FROM scan", "ON CONFLICT DO NOTHING"},
+			Not:   []string{"OR IGNORE", "julianday"},
+		},
+		{
+			Name:  "Keeps queries without placeholders",
+			Query: sqlCount,
+			Want:  []string{sqlCount},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			q := postgres{}.query(tc.Query)
+
+			for _, want := range tc.Want {
+				if !strings.Contains(q, want) {
+					t.Errorf("Query does not contain %q:\n%s", want, q)
+				}
+			}
+
+			for _, not := range tc.Not {
+				if strings.Contains(q, not) {
+					t.Errorf("Query contains %q:\n%s", not, q)
+				}
+			}
+		})
+	}
+
+	// every replaced query keeps the placeholders of the original
+	for original, replaced := range postgresQueries {
+		if strings.Count(original, "?") != strings.Count(replaced, "?") {
+			t.Errorf("Placeholders do not match:\n%s\nvs\n%s", original, replaced)
+		}
+	}
+}
+
+// sqlQueries returns the queries of the datastore by the names of their sql constants.
+func sqlQueries(t *testing.T) map[string]string {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := make(map[string]string)
+	for _, file := range pkgs["processor"].Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if !strings.HasPrefix(name.Name, "sql") || i >= len(vs.Values) {
+						continue
+					}
+
+					lit, ok := vs.Values[i].(*ast.BasicLit)
+					if !ok || lit.Kind != token.STRING {
+						t.Fatalf("Query %s is not a string literal", name.Name)
+					}
+
+					q, err := strconv.Unquote(lit.Value)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					queries[name.Name] = q
+				}
+			}
+		}
+	}
+
+	return queries
+}
+
+// sqliteOnly matches the syntax and functions of SQLite which PostgreSQL does not know.
+var sqliteOnly = regexp.MustCompile(`(?i)\b(julianday|datetime|strftime|ifnull|group_concat|instr)\(|\bINSERT OR\b|\b(rowid|pragma|glob)\b|\b(max|min)\([^()]*,`)
+
+func TestPostgresQueries(t *testing.T) {
+	queries := sqlQueries(t)
+	if len(queries) == 0 {
+		t.Fatal("No queries found")
+	}
+
+	// every query which PostgreSQL does not understand as is has a query of its own
+	for name, q := range queries {
+		if _, ok := postgresQueries[q]; ok {
+			continue
+		}
+
+		if m := sqliteOnly.FindString(q); m != "" {
+			t.Errorf("Query %s uses %q of SQLite without a PostgreSQL query", name, m)
+		}
+	}
+
+	// every PostgreSQL query replaces a query of the datastore
+	originals := make(map[string]bool)
+	for _, q := range queries {
+		originals[q] = true
+	}
+
+	for original := range postgresQueries {
+		if !originals[original] {
+			t.Errorf("PostgreSQL query of an unknown query:\n%s", original)
+		}
+	}
+}
@@ -0,0 +1,674 @@
+package processor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+type mockTarget struct {
+	id      string
+	running *int32
+	max     *int32
+}
+
+func (t mockTarget) ID() string                          { return t.id }
+func (t mockTarget) Available() error                    { return nil }
+func (t mockTarget) Capabilities() autoscan.Capabilities { return autoscan.Capabilities{} }
+
+func (t mockTarget) Scan(autoscan.Scan) error {
+	running := atomic.AddInt32(t.running, 1)
+	defer atomic.AddInt32(t.running, -1)
+
+	for {
+		max := atomic.LoadInt32(t.max)
+		if running <= max || atomic.CompareAndSwapInt32(t.max, max, running) {
+			break
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	return nil
+}
+
+func TestWorkers(t *testing.T) {
+	type Test struct {
+		Name    string
+		Workers int
+		WantMax int32
+	}
+
+	var testCases = []Test{
+		{
+			Name:    "Targets scan at the same time",
+			Workers: 0,
+			WantMax: 3,
+		},
+		{
+			Name:    "Limits the number of targets scanning at the same time",
+			Workers: 1,
+			WantMax: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			proc, err := New(Config{DatastorePath: ":memory:", Workers: tc.Workers})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now = time.Now
+			err = proc.Add(autoscan.Scan{Folder: "1", Time: time.Now().Add(-1 * time.Hour)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var running, max int32
+			targets := make([]autoscan.Target, 0)
+			for _, id := range []string{"plex:1", "plex:2", "emby:1"} {
+				targets = append(targets, mockTarget{id: id, running: &running, max: &max})
+			}
+
+			wg := new(sync.WaitGroup)
+			for _, target := range targets {
+				wg.Add(1)
+				go func(target autoscan.Target) {
+					defer wg.Done()
+					if err := proc.Process(target, targets); err != nil {
+						t.Error(err)
+					}
+				}(target)
+			}
+
+			wg.Wait()
+
+			if max != tc.WantMax {
+				t.Errorf("Concurrent scans do not match: %d vs %d", max, tc.WantMax)
+			}
+		})
+	}
+}
+
+type recordingTarget struct {
+	scans    *[]autoscan.Scan
+	removals bool
+}
+
+func (t recordingTarget) ID() string       { return "plex:http://plex" }
+func (t recordingTarget) Available() error { return nil }
+
+func (t recordingTarget) Capabilities() autoscan.Capabilities {
+	return autoscan.Capabilities{Removals: t.removals}
+}
+
+func (t recordingTarget) Scan(scan autoscan.Scan) error {
+	*t.scans = append(*t.scans, scan)
+	return nil
+}
+
+func TestBatchSiblings(t *testing.T) {
+	type Test struct {
+		Name          string
+		BatchSiblings int
+		Scans         []autoscan.Scan
+		Want          []autoscan.Scan
+	}
+
+	testTime := time.Now().UTC()
+
+	var testCases = []Test{
+		{
+			Name:          "Combines sibling folders into their parent",
+			BatchSiblings: 2,
+			Scans: []autoscan.Scan{
+				{Folder: "/tv/Show/Season 1", Priority: 1, Event: autoscan.EventRemoved, Time: testTime.Add(-2 * time.Hour)},
+				{Folder: "/tv/Show/Season 2", Priority: 3, Time: testTime.Add(-1 * time.Hour)},
+			},
+			Want: []autoscan.Scan{
+				{Folder: "/tv/Show", Priority: 3, Event: autoscan.EventModified, Time: testTime.Add(-1 * time.Hour)},
+			},
+		},
+		{
+			Name:          "Does not combine fewer folders than the minimum",
+			BatchSiblings: 3,
+			Scans: []autoscan.Scan{
+				{Folder: "/tv/Show/Season 1", Time: testTime.Add(-2 * time.Hour)},
+				{Folder: "/tv/Show/Season 2", Time: testTime.Add(-1 * time.Hour)},
+			},
+			Want: []autoscan.Scan{
+				{Folder: "/tv/Show/Season 1", Event: autoscan.EventAdded, Time: testTime.Add(-2 * time.Hour)},
+				{Folder: "/tv/Show/Season 2", Event: autoscan.EventAdded, Time: testTime.Add(-1 * time.Hour)},
+			},
+		},
+		{
+			Name:          "Disabled by default",
+			BatchSiblings: 0,
+			Scans: []autoscan.Scan{
+				{Folder: "/tv/Show/Season 1", Time: testTime.Add(-2 * time.Hour)},
+				{Folder: "/tv/Show/Season 2", Time: testTime.Add(-1 * time.Hour)},
+			},
+			Want: []autoscan.Scan{
+				{Folder: "/tv/Show/Season 1", Event: autoscan.EventAdded, Time: testTime.Add(-2 * time.Hour)},
+				{Folder: "/tv/Show/Season 2", Event: autoscan.EventAdded, Time: testTime.Add(-1 * time.Hour)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			now = func() time.Time {
+				return testTime
+			}
+
+			proc, err := New(Config{DatastorePath: ":memory:", BatchSiblings: tc.BatchSiblings})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := proc.Add(tc.Scans...); err != nil {
+				t.Fatal(err)
+			}
+
+			scans := make([]autoscan.Scan, 0)
+			target := recordingTarget{scans: &scans}
+			targets := []autoscan.Target{target}
+
+			for {
+				err := proc.Process(target, targets)
+				if errors.Is(err, autoscan.ErrNoScans) {
+					break
+				}
+
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !reflect.DeepEqual(scans, tc.Want) {
+				t.Log(scans)
+				t.Log(tc.Want)
+				t.Errorf("Scans do not match")
+			}
+		})
+	}
+}
+
+func TestMaximumAge(t *testing.T) {
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	proc, err := New(Config{DatastorePath: ":memory:", MaximumAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = proc.Add(
+		autoscan.Scan{Folder: "/tv/Expired", Time: testTime.Add(-48 * time.Hour)},
+		autoscan.Scan{Folder: "/tv/Recent", Time: testTime.Add(-1 * time.Hour)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scans := make([]autoscan.Scan, 0)
+	target := recordingTarget{scans: &scans}
+	targets := []autoscan.Target{target}
+
+	for {
+		err := proc.Process(target, targets)
+		if errors.Is(err, autoscan.ErrNoScans) {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []autoscan.Scan{{Folder: "/tv/Recent", Event: autoscan.EventAdded, Time: testTime.Add(-1 * time.Hour)}}
+	if !reflect.DeepEqual(scans, want) {
+		t.Log(scans)
+		t.Log(want)
+		t.Errorf("Scans do not match")
+	}
+
+	statuses, err := proc.Status(autoscan.Scan{Folder: "/tv/Expired"}.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statuses[0].Status != StatusUnknown {
+		t.Errorf("Expected expired scan to be removed: %v", statuses[0])
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	folders := make([]string, 0)
+	proc, err := New(Config{
+		DatastorePath:    ":memory:",
+		HistoryRetention: time.Hour,
+		PreScanHooks:     []autoscan.PreScanHook{mockHook{folders: &folders}},
+		DryRun:           true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = proc.Add(autoscan.Scan{Folder: "/tv/Westworld", Time: testTime.Add(-1 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scans := make([]autoscan.Scan, 0)
+	target := recordingTarget{scans: &scans}
+	if err := proc.Process(target, []autoscan.Target{target}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scans) != 0 || len(folders) != 0 {
+		t.Errorf("Scan was sent in a dry run: %v %v", scans, folders)
+	}
+
+	entries, err := proc.History(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Status != StatusSimulated {
+		t.Errorf("History does not match: %+v", entries)
+	}
+
+	if err := proc.Process(target, []autoscan.Target{target}); !errors.Is(err, autoscan.ErrNoScans) {
+		t.Errorf("Simulated scan remains queued: %v", err)
+	}
+}
+
+func TestCheckExists(t *testing.T) {
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	dir, err := ioutil.TempDir("", "autoscan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	existing := filepath.Join(dir, "Existing")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := filepath.Join(dir, "Missing")
+	removed := filepath.Join(dir, "Removed")
+
+	proc, err := New(Config{DatastorePath: ":memory:", MaxRetries: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = proc.Add(
+		autoscan.Scan{Folder: existing, CheckExists: true, Time: testTime.Add(-3 * time.Hour)},
+		autoscan.Scan{Folder: missing, CheckExists: true, Time: testTime.Add(-2 * time.Hour)},
+		autoscan.Scan{Folder: removed, CheckExists: true, Event: autoscan.EventRemoved, Time: testTime.Add(-1 * time.Hour)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scans := make([]autoscan.Scan, 0)
+	target := recordingTarget{scans: &scans, removals: true}
+	targets := []autoscan.Target{target}
+
+	for {
+		err := proc.Process(target, targets)
+		if errors.Is(err, autoscan.ErrNoScans) {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(scans) != 2 || scans[0].Folder != existing || scans[1].Folder != removed {
+		t.Errorf("Expected scans of the existing and removed folders: %v", scans)
+	}
+
+	// the scan of the missing folder is retried later
+	attempts, err := proc.store.GetAttempts(autoscan.Scan{Folder: missing}, target.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 1 {
+		t.Errorf("Attempts do not match: %d vs %d", attempts, 1)
+	}
+}
+
+func TestRemovals(t *testing.T) {
+	type Test struct {
+		Name     string
+		Removals bool
+		Want     []string
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Sends removals to targets which handle them",
+			Removals: true,
+			Want:     []string{"/tv/Added", "/tv/Removed"},
+		},
+		{
+			Name:     "Skips removals for targets which do not handle them",
+			Removals: false,
+			Want:     []string{"/tv/Added"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testTime := time.Now().UTC()
+			now = func() time.Time {
+				return testTime
+			}
+
+			proc, err := New(Config{DatastorePath: ":memory:"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = proc.Add(
+				autoscan.Scan{Folder: "/tv/Added", Event: autoscan.EventAdded, Time: testTime.Add(-2 * time.Hour)},
+				autoscan.Scan{Folder: "/tv/Removed", Event: autoscan.EventRemoved, Time: testTime.Add(-1 * time.Hour)},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			scans := make([]autoscan.Scan, 0)
+			target := recordingTarget{scans: &scans, removals: tc.Removals}
+			targets := []autoscan.Target{target}
+
+			for {
+				err := proc.Process(target, targets)
+				if errors.Is(err, autoscan.ErrNoScans) {
+					break
+				}
+
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			folders := make([]string, 0)
+			for _, scan := range scans {
+				folders = append(folders, scan.Folder)
+			}
+
+			if !reflect.DeepEqual(folders, tc.Want) {
+				t.Errorf("Folders do not match: %v vs %v", folders, tc.Want)
+			}
+
+			// skipped removals are not retried
+			count, err := proc.store.Count()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if count != 0 {
+				t.Errorf("Expected all scans to be delivered: %d", count)
+			}
+		})
+	}
+}
+
+func TestSettleWait(t *testing.T) {
+	type Test struct {
+		Name      string
+		Write     bool
+		Folder    string
+		After     time.Duration
+		WantFirst time.Duration
+		Want      time.Duration
+	}
+
+	var testCases = []Test{
+		{
+			Name:      "Postponed for the remaining settle time",
+			After:     20 * time.Second,
+			WantFirst: time.Minute,
+			Want:      40 * time.Second,
+		},
+		{
+			Name:      "Settled when the newest file did not change",
+			After:     time.Minute,
+			WantFirst: time.Minute,
+			Want:      0,
+		},
+		{
+			Name:      "Postponed again when the newest file changed",
+			Write:     true,
+			After:     time.Minute,
+			WantFirst: time.Minute,
+			Want:      time.Minute,
+		},
+		{
+			Name:   "Settled when the folder cannot be read",
+			Folder: "missing",
+			After:  time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "autoscan")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer os.RemoveAll(dir)
+
+			file := filepath.Join(dir, "Episode.mkv")
+			if err := ioutil.WriteFile(file, []byte("a"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			testTime := time.Now()
+			now = func() time.Time {
+				return testTime
+			}
+
+			p := &Processor{settleTime: time.Minute, settling: make(map[string]fileState)}
+			folder := filepath.Join(dir, tc.Folder)
+
+			if got := p.settleWait(folder); got != tc.WantFirst {
+				t.Errorf("First wait does not match: %v vs %v", got, tc.WantFirst)
+			}
+
+			if tc.Write {
+				if err := ioutil.WriteFile(file, []byte("ab"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			testTime = testTime.Add(tc.After)
+			if got := p.settleWait(folder); got != tc.Want {
+				t.Errorf("Wait does not match: %v vs %v", got, tc.Want)
+			}
+		})
+	}
+}
+
+func TestMaxQueue(t *testing.T) {
+	testTime := time.Now().UTC()
+
+	proc, err := New(Config{DatastorePath: ":memory:", MaxQueue: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = proc.Add(autoscan.Scan{Folder: "1", Time: testTime}, autoscan.Scan{Folder: "2", Time: testTime})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = proc.Add(autoscan.Scan{Folder: "3", Time: testTime})
+	if !errors.Is(err, autoscan.ErrQueueFull) {
+		t.Errorf("Expected full queue: %v", err)
+	}
+}
+
+type slowTarget struct {
+	namedTarget
+	delay time.Duration
+	err   error
+}
+
+func (t slowTarget) Available() error {
+	time.Sleep(t.delay)
+	return t.err
+}
+
+func TestCheckAvailability(t *testing.T) {
+	type Test struct {
+		Name    string
+		Serial  bool
+		Targets []autoscan.Target
+		Err     string
+	}
+
+	scans := make([]autoscan.Scan, 0)
+	plex := namedTarget{recordingTarget{scans: &scans}, "plex:http://plex"}
+	emby := namedTarget{recordingTarget{scans: &scans}, "emby:http://emby"}
+
+	var testCases = []Test{
+		{
+			Name: "Available targets",
+			Targets: []autoscan.Target{
+				slowTarget{plex, 0, nil},
+				slowTarget{emby, 0, nil},
+			},
+		},
+		{
+			Name: "Names the unavailable target",
+			Targets: []autoscan.Target{
+				slowTarget{plex, 0, nil},
+				slowTarget{emby, 0, autoscan.ErrTargetUnavailable},
+			},
+			Err: "emby:http://emby: target unavailable",
+		},
+		{
+			Name:   "Times out targets which do not respond one after the other",
+			Serial: true,
+			Targets: []autoscan.Target{
+				slowTarget{plex, time.Second, nil},
+				slowTarget{emby, 0, nil},
+			},
+			Err: "plex:http://plex: no response within 50ms: target unavailable",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			proc, err := New(Config{
+				DatastorePath:       ":memory:",
+				AvailabilityTimeout: 50 * time.Millisecond,
+				AvailabilitySerial:  tc.Serial,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = proc.CheckAvailability(tc.Targets)
+			switch {
+			case tc.Err == "" && err != nil:
+				t.Errorf("Unexpected error: %v", err)
+			case tc.Err != "" && (err == nil || err.Error() != tc.Err):
+				t.Errorf("Errors do not match: %v vs %s", err, tc.Err)
+			case tc.Err != "" && !errors.Is(err, autoscan.ErrTargetUnavailable):
+				t.Errorf("Expected target unavailable: %v", err)
+			}
+		})
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	scans := make([]autoscan.Scan, 0)
+	plex := namedTarget{recordingTarget{scans: &scans}, "plex:http://plex"}
+	emby := namedTarget{recordingTarget{scans: &scans}, "emby:http://emby"}
+
+	proc, err := New(Config{DatastorePath: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	_ = proc.CheckAvailability([]autoscan.Target{
+		slowTarget{plex, 0, nil},
+		slowTarget{emby, 0, autoscan.ErrTargetUnavailable},
+	})
+
+	got := proc.Availability("plex:http://plex", "emby:http://emby", "plex:http://other")
+	want := []TargetAvailability{
+		{Target: "plex:http://plex", Available: true, CheckedAt: testTime},
+		{Target: "emby:http://emby", Error: "emby:http://emby: target unavailable", CheckedAt: testTime},
+		{Target: "plex:http://other", Error: "not checked yet"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Log(got)
+		t.Log(want)
+		t.Errorf("Availability does not match")
+	}
+
+	if err := proc.Ping(); err != nil {
+		t.Errorf("Unexpected ping error: %v", err)
+	}
+}
+
+func TestTriggerCounts(t *testing.T) {
+	testTime := time.Now().UTC()
+
+	proc, err := New(Config{DatastorePath: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = proc.Add(
+		autoscan.Scan{Folder: "1", Trigger: "sonarr", Time: testTime},
+		autoscan.Scan{Folder: "2", Trigger: "sonarr", Time: testTime},
+		autoscan.Scan{Folder: "3", Trigger: "radarr", Time: testTime},
+		autoscan.Scan{Folder: "4", Time: testTime},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{"sonarr": 2, "radarr": 1}
+	if got := proc.TriggerCounts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Trigger counts do not match: %v vs %v", got, want)
+	}
+}
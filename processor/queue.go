@@ -0,0 +1,162 @@
+package processor
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// QueueEntry is a scan in the queue with its ID.
+type QueueEntry struct {
+	ID string `json:"id"`
+	QueuedScan
+}
+
+// Queue returns the scans in the queue.
+func (p *Processor) Queue() ([]QueueEntry, error) {
+	scans, err := p.store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]QueueEntry, 0, len(scans))
+	for _, s := range scans {
+		entries = append(entries, QueueEntry{ID: s.ID(), QueuedScan: queuedScan(s)})
+	}
+
+	return entries, nil
+}
+
+// Flush removes all scans from the queue.
+// It returns the number of removed scans.
+func (p *Processor) Flush() (int, error) {
+	scans, err := p.store.GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, s := range scans {
+		if err := p.store.Delete(s); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(scans), nil
+}
+
+// RetryNow retries the queued scans of the given IDs for the targets which are waiting to retry them,
+// without waiting for their backoff to pass.
+// It returns the number of scans found in the queue.
+func (p *Processor) RetryNow(ids ...string) (int, error) {
+	return p.queued(ids, func(scan autoscan.Scan) error {
+		return p.store.Reschedule(scan, now())
+	})
+}
+
+// Remove removes the scans of the given IDs from the queue.
+// It returns the number of removed scans.
+func (p *Processor) Remove(ids ...string) (int, error) {
+	return p.queued(ids, p.store.Delete)
+}
+
+// StatusRetrying indicates that a queued scan is waiting for its backoff to pass for at least one target.
+const StatusRetrying = "retrying"
+
+// ScanEntry is a scan in the queue with its ID and its state, which is either queued or retrying.
+type ScanEntry struct {
+	QueueEntry
+	State string `json:"state"`
+}
+
+// ScanFilter selects scans in the queue by their state, the prefix of their folder and their trigger.
+// Empty fields select all scans.
+type ScanFilter struct {
+	State   string
+	Prefix  string
+	Trigger string
+}
+
+func (f ScanFilter) match(e ScanEntry) bool {
+	return (f.State == "" || f.State == e.State) &&
+		strings.HasPrefix(e.Folder, f.Prefix) &&
+		(f.Trigger == "" || f.Trigger == e.Trigger)
+}
+
+// Scans returns the scans in the queue which match the filter, in the order in which they were queued.
+func (p *Processor) Scans(f ScanFilter) ([]ScanEntry, error) {
+	scans, err := p.store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	retrying, err := p.store.GetRetrying()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ScanEntry, 0)
+	for _, s := range scans {
+		e := ScanEntry{
+			QueueEntry: QueueEntry{ID: s.ID(), QueuedScan: queuedScan(s)},
+			State:      StatusQueued,
+		}
+
+		if retrying[s.Folder] {
+			e.State = StatusRetrying
+		}
+
+		if f.match(e) {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].Folder < entries[j].Folder
+		}
+
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries, nil
+}
+
+// RemoveScans removes the scans in the queue which match the filter.
+// It returns the number of removed scans.
+func (p *Processor) RemoveScans(f ScanFilter) (int, error) {
+	entries, err := p.Scans(f)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+
+	return p.Remove(ids...)
+}
+
+// queued calls fn for the queued scans of the given IDs, and skips the IDs which are not queued.
+func (p *Processor) queued(ids []string, fn func(autoscan.Scan) error) (int, error) {
+	n := 0
+	for _, id := range ids {
+		scan, err := p.store.GetScanByID(id)
+		switch {
+		case errors.Is(err, autoscan.ErrNoScans):
+			continue
+		case err != nil:
+			return n, err
+		}
+
+		if err := fn(scan); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	return n, nil
+}
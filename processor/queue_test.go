@@ -0,0 +1,205 @@
+package processor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestQueue(t *testing.T) {
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	proc, err := New(Config{DatastorePath: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	westworld := autoscan.Scan{Folder: "/tv/Westworld", Time: testTime.Add(-1 * time.Hour)}
+	severance := autoscan.Scan{Folder: "/tv/Severance", Time: testTime.Add(-1 * time.Hour)}
+	if err := proc.Add(westworld, severance); err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err := proc.Queue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queue) != 2 {
+		t.Fatalf("Queue does not match: %+v", queue)
+	}
+
+	ids := map[string]string{}
+	for _, e := range queue {
+		ids[e.Folder] = e.ID
+	}
+
+	// retrying now ignores the backoff of the target
+	if err := proc.store.Retry(westworld, "plex:http://plex", 1, testTime.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	scan, err := proc.store.GetAvailableScan("plex:http://plex", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scan.Folder != severance.Folder {
+		t.Errorf("Available scan does not match: %v", scan.Folder)
+	}
+
+	retried, err := proc.RetryNow(ids[westworld.Folder], "unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if retried != 1 {
+		t.Errorf("Retried scans do not match: %d vs %d", retried, 1)
+	}
+
+	attempts, err := proc.store.GetAttempts(westworld, "plex:http://plex")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 1 {
+		t.Errorf("Attempts do not match: %d vs %d", attempts, 1)
+	}
+
+	removed, err := proc.Remove(ids[severance.Folder])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 1 {
+		t.Errorf("Removed scans do not match: %d vs %d", removed, 1)
+	}
+
+	scan, err = proc.store.GetAvailableScan("plex:http://plex", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scan.Folder != westworld.Folder {
+		t.Errorf("Available scan does not match: %v", scan.Folder)
+	}
+
+	flushed, err := proc.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if flushed != 1 {
+		t.Errorf("Flushed scans do not match: %d vs %d", flushed, 1)
+	}
+
+	if _, err := proc.store.GetAvailableScan("plex:http://plex", 0, 0, 0); !errors.Is(err, autoscan.ErrNoScans) {
+		t.Errorf("Queue was not flushed: %v", err)
+	}
+}
+
+func TestScans(t *testing.T) {
+	type Test struct {
+		Name   string
+		Filter ScanFilter
+		Want   []string
+	}
+
+	var testCases = []Test{
+		{
+			Name: "All scans in the order in which they were queued",
+			Want: []string{"/tv/Westworld", "/movies/Dune", "/tv/Severance"},
+		},
+		{
+			Name:   "Retrying scans",
+			Filter: ScanFilter{State: StatusRetrying},
+			Want:   []string{"/tv/Westworld"},
+		},
+		{
+			Name:   "Queued scans",
+			Filter: ScanFilter{State: StatusQueued},
+			Want:   []string{"/movies/Dune", "/tv/Severance"},
+		},
+		{
+			Name:   "Folder prefix",
+			Filter: ScanFilter{Prefix: "/tv/"},
+			Want:   []string{"/tv/Westworld", "/tv/Severance"},
+		},
+		{
+			Name:   "Trigger and folder prefix",
+			Filter: ScanFilter{Prefix: "/tv/", Trigger: "sonarr"},
+			Want:   []string{"/tv/Severance"},
+		},
+		{
+			Name:   "No matches",
+			Filter: ScanFilter{Trigger: "lidarr"},
+			Want:   []string{},
+		},
+	}
+
+	testTime := time.Now().UTC()
+	now = func() time.Time {
+		return testTime
+	}
+
+	proc, err := New(Config{DatastorePath: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	westworld := autoscan.Scan{Folder: "/tv/Westworld", Trigger: "manual", Time: testTime.Add(-3 * time.Hour)}
+	err = proc.Add(
+		westworld,
+		autoscan.Scan{Folder: "/movies/Dune", Trigger: "radarr", Time: testTime.Add(-2 * time.Hour)},
+		autoscan.Scan{Folder: "/tv/Severance", Trigger: "sonarr", Time: testTime.Add(-1 * time.Hour)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proc.store.Retry(westworld, "plex:http://plex", 1, testTime.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			scans, err := proc.Scans(tc.Filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			folders := make([]string, 0)
+			for _, s := range scans {
+				folders = append(folders, s.Folder)
+			}
+
+			if !reflect.DeepEqual(folders, tc.Want) {
+				t.Errorf("Scans do not match: %v vs %v", folders, tc.Want)
+			}
+		})
+	}
+
+	removed, err := proc.RemoveScans(ScanFilter{Prefix: "/tv/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 2 {
+		t.Errorf("Removed scans do not match: %d vs %d", removed, 2)
+	}
+
+	scans, err := proc.Scans(ScanFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scans) != 1 || scans[0].Folder != "/movies/Dune" {
+		t.Errorf("Remaining scans do not match: %+v", scans)
+	}
+}
@@ -0,0 +1,46 @@
+package autoscan
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLite configures the connections to the SQLite database,
+// which is shared by the processor and the bernard trigger.
+//
+// The journal mode defaults to WAL, such that reading the database does not block writing to it.
+// The busy timeout is the time a connection waits for a lock held by another connection,
+// which defaults to 10 seconds.
+// The synchronous mode defaults to NORMAL in WAL mode, and to FULL otherwise.
+type SQLite struct {
+	JournalMode string        `yaml:"journal-mode"`
+	BusyTimeout time.Duration `yaml:"busy-timeout"`
+	Synchronous string        `yaml:"synchronous"`
+}
+
+// DSN returns the data source name of the SQLite database at path.
+func (s SQLite) DSN(path string) string {
+	journalMode := s.JournalMode
+	if journalMode == "" {
+		journalMode = "wal"
+	}
+
+	busyTimeout := s.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = 10 * time.Second
+	}
+
+	params := []string{
+		"cache=shared",
+		"mode=rwc",
+		fmt.Sprintf("_busy_timeout=%d", busyTimeout.Milliseconds()),
+		"_journal_mode=" + journalMode,
+	}
+
+	if s.Synchronous != "" {
+		params = append(params, "_synchronous="+s.Synchronous)
+	}
+
+	return fmt.Sprintf("%s?%s", path, strings.Join(params, "&"))
+}
@@ -0,0 +1,39 @@
+package autoscan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	type Test struct {
+		Name   string
+		SQLite SQLite
+		Want   string
+	}
+
+	var testCases = []Test{
+		{
+			Name: "Defaults to WAL with a busy timeout",
+			Want: "autoscan.db?cache=shared&mode=rwc&_busy_timeout=10000&_journal_mode=wal",
+		},
+		{
+			Name: "Overrides the pragmas",
+			SQLite: SQLite{
+				JournalMode: "delete",
+				BusyTimeout: 30 * time.Second,
+				Synchronous: "full",
+			},
+			Want: "autoscan.db?cache=shared&mode=rwc&_busy_timeout=30000&_journal_mode=delete&_synchronous=full",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			dsn := tc.SQLite.DSN("autoscan.db")
+			if dsn != tc.Want {
+				t.Errorf("DSNs do not match: %s vs %s", dsn, tc.Want)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/cloudbox/autoscan"
 	"github.com/rs/zerolog"
@@ -17,20 +18,62 @@ type apiClient struct {
 	token   string
 }
 
-func newAPIClient(baseURL string, token string, log zerolog.Logger) apiClient {
+// newAPIClient creates a client for the API at the base URL.
+// Requests fail when they take longer than the timeout, unless the timeout is zero.
+func newAPIClient(baseURL string, token string, timeout time.Duration, log zerolog.Logger) apiClient {
 	return apiClient{
-		client:  &http.Client{},
+		client:  &http.Client{Timeout: timeout},
 		log:     log,
 		baseURL: baseURL,
 		token:   token,
 	}
 }
 
-func (c apiClient) do(req *http.Request) (*http.Response, error) {
+// send sends the request with the token.
+func (c apiClient) send(req *http.Request) (*http.Response, error) {
 	req.Header.Set("X-Emby-Token", c.token)
 	req.Header.Set("Accept", "application/json") // Force JSON Response.
 
-	res, err := c.client.Do(req)
+	return c.client.Do(req)
+}
+
+// failed logs the response of a failed request and closes it.
+func (c apiClient) failed(res *http.Response) {
+	c.log.Trace().
+		Stringer("request_url", res.Request.URL).
+		Int("response_status", res.StatusCode).
+		Msg("Request failed")
+
+	res.Body.Close()
+}
+
+// scan sends the scan request. Scans are retried after timeouts and server errors,
+// and rejected when Emby refuses them, e.g. of an invalid path.
+func (c apiClient) scan(req *http.Request) error {
+	res, err := c.send(req)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, autoscan.ErrScanFailed)
+	}
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		res.Body.Close()
+		return nil
+	}
+
+	c.failed(res)
+
+	switch {
+	case res.StatusCode == 401:
+		return fmt.Errorf("invalid emby token: %s: %w", res.Status, autoscan.ErrFatal)
+	case res.StatusCode == 408, res.StatusCode == 429, res.StatusCode >= 500:
+		return fmt.Errorf("%s: %w", res.Status, autoscan.ErrScanFailed)
+	default:
+		return fmt.Errorf("%s: %w", res.Status, autoscan.ErrScanRejected)
+	}
+}
+
+func (c apiClient) do(req *http.Request) (*http.Response, error) {
+	res, err := c.send(req)
 	if err != nil {
 		return nil, fmt.Errorf("%v: %w", err, autoscan.ErrTargetUnavailable)
 	}
@@ -39,13 +82,8 @@ func (c apiClient) do(req *http.Request) (*http.Response, error) {
 		return res, nil
 	}
 
-	c.log.Trace().
-		Stringer("request_url", res.Request.URL).
-		Int("response_status", res.StatusCode).
-		Msg("Request failed")
-
 	// statusCode not in the 2xx range, close response
-	res.Body.Close()
+	c.failed(res)
 
 	switch res.StatusCode {
 	case 401:
@@ -75,6 +113,35 @@ func (c apiClient) Available() error {
 	return nil
 }
 
+func (c apiClient) Version() (string, error) {
+	// create request
+	reqURL := autoscan.JoinURL(c.baseURL, "emby", "System", "Info")
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed creating version request: %v: %w", err, autoscan.ErrFatal)
+	}
+
+	// send request
+	res, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("version: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	// decode response
+	type Response struct {
+		Version string `json:"Version"`
+	}
+
+	resp := new(Response)
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return "", fmt.Errorf("failed decoding version response: %v: %w", err, autoscan.ErrFatal)
+	}
+
+	return resp.Version, nil
+}
+
 type library struct {
 	Name string
 	Path string
@@ -128,7 +195,15 @@ type scanRequest struct {
 	UpdateType string `json:"updateType"`
 }
 
-func (c apiClient) Scan(path string) error {
+func (c apiClient) Scan(path string, event autoscan.Event) error {
+	updateType := "Created"
+	switch event {
+	case autoscan.EventModified:
+		updateType = "Modified"
+	case autoscan.EventRemoved:
+		updateType = "Deleted"
+	}
+
 	// create request payload
 	type Payload struct {
 		Updates []scanRequest `json:"Updates"`
@@ -138,7 +213,7 @@ func (c apiClient) Scan(path string) error {
 		Updates: []scanRequest{
 			{
 				Path:       path,
-				UpdateType: "Created",
+				UpdateType: updateType,
 			},
 		},
 	}
@@ -158,11 +233,9 @@ func (c apiClient) Scan(path string) error {
 	req.Header.Set("Content-Type", "application/json")
 
 	// send request
-	res, err := c.do(req)
-	if err != nil {
+	if err := c.scan(req); err != nil {
 		return fmt.Errorf("scan: %w", err)
 	}
 
-	defer res.Body.Close()
 	return nil
 }
@@ -3,15 +3,21 @@ package emby
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/cloudbox/autoscan"
 	"github.com/rs/zerolog"
 )
 
+// TokenFile is read into Token when set, e.g. a Docker secret.
+// ScanDelay overrides the global scan-delay for the target when set.
 type Config struct {
 	URL       string             `yaml:"url"`
 	Token     string             `yaml:"token"`
+	TokenFile string             `yaml:"token-file"`
 	Rewrite   []autoscan.Rewrite `yaml:"rewrite"`
+	Timeout   time.Duration      `yaml:"timeout"`
+	ScanDelay *time.Duration     `yaml:"scan-delay"`
 	Verbosity string             `yaml:"verbosity"`
 }
 
@@ -36,7 +42,7 @@ func New(c Config) (autoscan.Target, error) {
 		return nil, err
 	}
 
-	api := newAPIClient(c.URL, c.Token, l)
+	api := newAPIClient(c.URL, c.Token, c.Timeout, l)
 
 	libraries, err := api.Libraries()
 	if err != nil {
@@ -58,10 +64,18 @@ func New(c Config) (autoscan.Target, error) {
 	}, nil
 }
 
+func (t target) ID() string {
+	return "emby:" + t.url
+}
+
 func (t target) Available() error {
 	return t.api.Available()
 }
 
+func (t target) Capabilities() autoscan.Capabilities {
+	return autoscan.Capabilities{Removals: true}
+}
+
 func (t target) Scan(scan autoscan.Scan) error {
 	// determine library for this scan
 	scanFolder := t.rewrite(scan.Folder)
@@ -78,12 +92,13 @@ func (t target) Scan(scan autoscan.Scan) error {
 	l := t.log.With().
 		Str("path", scanFolder).
 		Str("library", lib.Name).
+		Str("event", string(scan.Event)).
 		Logger()
 
 	// send scan request
 	l.Trace().Msg("Sending scan request")
 
-	if err := t.api.Scan(scanFolder); err != nil {
+	if err := t.api.Scan(scanFolder, scan.Event); err != nil {
 		return err
 	}
 
@@ -91,6 +106,33 @@ func (t target) Scan(scan autoscan.Scan) error {
 	return nil
 }
 
+// Describe returns the version and libraries of Emby.
+func (t target) Describe() (autoscan.TargetInfo, error) {
+	version, err := t.api.Version()
+	if err != nil {
+		return autoscan.TargetInfo{}, err
+	}
+
+	libraries := make([]autoscan.Library, 0, len(t.libraries))
+	for _, l := range t.libraries {
+		libraries = append(libraries, autoscan.Library{Name: l.Name, Path: l.Path})
+	}
+
+	return autoscan.TargetInfo{Version: version, Libraries: libraries}, nil
+}
+
+// Resolve returns the rewritten folder and the library in which it is scanned.
+func (t target) Resolve(folder string) (string, []autoscan.Library) {
+	scanFolder := t.rewrite(folder)
+
+	lib, err := t.getScanLibrary(scanFolder)
+	if err != nil {
+		return scanFolder, nil
+	}
+
+	return scanFolder, []autoscan.Library{{Name: lib.Name, Path: lib.Path}}
+}
+
 func (t target) getScanLibrary(folder string) (*library, error) {
 	for _, l := range t.libraries {
 		if strings.HasPrefix(folder, l.Path) {
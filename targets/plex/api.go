@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/cloudbox/autoscan"
 	"github.com/rs/zerolog"
@@ -18,20 +19,62 @@ type apiClient struct {
 	token   string
 }
 
-func newAPIClient(baseURL string, token string, log zerolog.Logger) *apiClient {
+// newAPIClient creates a client for the API at the base URL.
+// Requests fail when they take longer than the timeout, unless the timeout is zero.
+func newAPIClient(baseURL string, token string, timeout time.Duration, log zerolog.Logger) *apiClient {
 	return &apiClient{
-		client:  &http.Client{},
+		client:  &http.Client{Timeout: timeout},
 		log:     log,
 		baseURL: baseURL,
 		token:   token,
 	}
 }
 
-func (c apiClient) do(req *http.Request) (*http.Response, error) {
+// send sends the request with the token.
+func (c apiClient) send(req *http.Request) (*http.Response, error) {
 	req.Header.Set("X-Plex-Token", c.token)
 	req.Header.Set("Accept", "application/json") // Force JSON Response.
 
-	res, err := c.client.Do(req)
+	return c.client.Do(req)
+}
+
+// failed logs the response of a failed request and closes it.
+func (c apiClient) failed(res *http.Response) {
+	c.log.Trace().
+		Stringer("request_url", res.Request.URL).
+		Int("response_status", res.StatusCode).
+		Msg("Request failed")
+
+	res.Body.Close()
+}
+
+// scan sends the scan request. Scans are retried after timeouts and server errors,
+// and rejected when Plex refuses them, e.g. of a library which no longer exists.
+func (c apiClient) scan(req *http.Request) error {
+	res, err := c.send(req)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, autoscan.ErrScanFailed)
+	}
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		res.Body.Close()
+		return nil
+	}
+
+	c.failed(res)
+
+	switch {
+	case res.StatusCode == 401:
+		return fmt.Errorf("invalid plex token: %s: %w", res.Status, autoscan.ErrFatal)
+	case res.StatusCode == 408, res.StatusCode == 429, res.StatusCode >= 500:
+		return fmt.Errorf("%s: %w", res.Status, autoscan.ErrScanFailed)
+	default:
+		return fmt.Errorf("%s: %w", res.Status, autoscan.ErrScanRejected)
+	}
+}
+
+func (c apiClient) do(req *http.Request) (*http.Response, error) {
+	res, err := c.send(req)
 	if err != nil {
 		return nil, fmt.Errorf("%v: %w", err, autoscan.ErrTargetUnavailable)
 	}
@@ -40,13 +83,8 @@ func (c apiClient) do(req *http.Request) (*http.Response, error) {
 		return res, nil
 	}
 
-	c.log.Trace().
-		Stringer("request_url", res.Request.URL).
-		Int("response_status", res.StatusCode).
-		Msg("Request failed")
-
 	// statusCode not in the 2xx range, close response
-	res.Body.Close()
+	c.failed(res)
 
 	switch res.StatusCode {
 	case 401:
@@ -149,11 +187,9 @@ func (c apiClient) Scan(path string, libraryID int) error {
 	q.Add("path", path)
 	req.URL.RawQuery = q.Encode()
 
-	res, err := c.do(req)
-	if err != nil {
+	if err := c.scan(req); err != nil {
 		return fmt.Errorf("scan: %w", err)
 	}
 
-	res.Body.Close()
 	return nil
 }
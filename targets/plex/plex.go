@@ -4,15 +4,21 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cloudbox/autoscan"
 	"github.com/rs/zerolog"
 )
 
+// TokenFile is read into Token when set, e.g. a Docker secret.
+// ScanDelay overrides the global scan-delay for the target when set.
 type Config struct {
 	URL       string             `yaml:"url"`
 	Token     string             `yaml:"token"`
+	TokenFile string             `yaml:"token-file"`
 	Rewrite   []autoscan.Rewrite `yaml:"rewrite"`
+	Timeout   time.Duration      `yaml:"timeout"`
+	ScanDelay *time.Duration     `yaml:"scan-delay"`
 	Verbosity string             `yaml:"verbosity"`
 }
 
@@ -36,7 +42,7 @@ func New(c Config) (autoscan.Target, error) {
 		return nil, err
 	}
 
-	api := newAPIClient(c.URL, c.Token, l)
+	api := newAPIClient(c.URL, c.Token, c.Timeout, l)
 
 	version, err := api.Version()
 	if err != nil {
@@ -68,11 +74,20 @@ func New(c Config) (autoscan.Target, error) {
 	}, nil
 }
 
+func (t target) ID() string {
+	return "plex:" + t.url
+}
+
 func (t target) Available() error {
 	_, err := t.api.Version()
 	return err
 }
 
+// Plex notices removed files when scanning their folder.
+func (t target) Capabilities() autoscan.Capabilities {
+	return autoscan.Capabilities{Removals: true}
+}
+
 func (t target) Scan(scan autoscan.Scan) error {
 	// determine library for this scan
 	scanFolder := t.rewrite(scan.Folder)
@@ -91,6 +106,7 @@ func (t target) Scan(scan autoscan.Scan) error {
 		l := t.log.With().
 			Str("path", scanFolder).
 			Str("library", lib.Name).
+			Str("event", string(scan.Event)).
 			Logger()
 
 		l.Trace().Msg("Sending scan request")
@@ -105,6 +121,37 @@ func (t target) Scan(scan autoscan.Scan) error {
 	return nil
 }
 
+// Describe returns the version and libraries of Plex.
+func (t target) Describe() (autoscan.TargetInfo, error) {
+	version, err := t.api.Version()
+	if err != nil {
+		return autoscan.TargetInfo{}, err
+	}
+
+	return autoscan.TargetInfo{Version: version, Libraries: describeLibraries(t.libraries)}, nil
+}
+
+// Resolve returns the rewritten folder and the libraries in which it is scanned.
+func (t target) Resolve(folder string) (string, []autoscan.Library) {
+	scanFolder := t.rewrite(folder)
+
+	libs, err := t.getScanLibrary(scanFolder)
+	if err != nil {
+		return scanFolder, nil
+	}
+
+	return scanFolder, describeLibraries(libs)
+}
+
+func describeLibraries(libs []library) []autoscan.Library {
+	described := make([]autoscan.Library, 0, len(libs))
+	for _, l := range libs {
+		described = append(described, autoscan.Library{Name: l.Name, Path: l.Path})
+	}
+
+	return described
+}
+
 func (t target) getScanLibrary(folder string) ([]library, error) {
 	libraries := make([]library, 0)
 
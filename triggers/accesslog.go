@@ -0,0 +1,53 @@
+package triggers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+)
+
+// WithAccessLog logs every request to the route with the access logger,
+// of which failed requests are logged as warnings and server errors as errors.
+// The requests of a trigger are logged with the name of the trigger.
+func WithAccessLog(logger zerolog.Logger, route string, trigger string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if logger.GetLevel() == zerolog.Disabled {
+			return next
+		}
+
+		return hlog.AccessHandler(func(r *http.Request, status, size int, duration time.Duration) {
+			var e *zerolog.Event
+			switch {
+			case status >= 500:
+				e = logger.Error()
+			case status >= 400:
+				e = logger.Warn()
+			default:
+				e = logger.Info()
+			}
+
+			if id, ok := hlog.IDFromRequest(r); ok {
+				e = e.Str("request_id", id.String())
+			}
+
+			if trigger != "" {
+				e = e.Str("trigger", trigger)
+			}
+
+			if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+				e = e.Str("forwarded_for", forwarded)
+			}
+
+			e.Str("method", r.Method).
+				Str("route", route).
+				Str("path", r.URL.Path).
+				Str("ip", SourceIP(r)).
+				Int("status", status).
+				Int("size", size).
+				Dur("duration", duration).
+				Msg("Request")
+		})(next)
+	}
+}
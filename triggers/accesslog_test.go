@@ -0,0 +1,109 @@
+package triggers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestAccessLog(t *testing.T) {
+	type Test struct {
+		Name      string
+		Level     zerolog.Level
+		Trigger   string
+		Forwarded string
+		Status    int
+		Want      map[string]interface{}
+	}
+
+	var testCases = []Test{
+		{
+			Name:    "Logs the requests of a trigger",
+			Level:   zerolog.InfoLevel,
+			Trigger: "sonarr",
+			Status:  200,
+			Want: map[string]interface{}{
+				"level":   "info",
+				"trigger": "sonarr",
+				"method":  "POST",
+				"route":   "/triggers/sonarr",
+				"path":    "/triggers/sonarr",
+				"ip":      "192.0.2.1",
+				"status":  float64(200),
+			},
+		},
+		{
+			Name:      "Logs the forwarded for header",
+			Level:     zerolog.InfoLevel,
+			Forwarded: "203.0.113.7, 10.0.0.1",
+			Status:    200,
+			Want: map[string]interface{}{
+				"forwarded_for": "203.0.113.7, 10.0.0.1",
+				"ip":            "192.0.2.1",
+			},
+		},
+		{
+			Name:   "Logs failed requests as warnings",
+			Level:  zerolog.InfoLevel,
+			Status: 401,
+			Want: map[string]interface{}{
+				"level":  "warn",
+				"status": float64(401),
+			},
+		},
+		{
+			Name:   "Logs server errors as errors",
+			Level:  zerolog.WarnLevel,
+			Status: 500,
+			Want: map[string]interface{}{
+				"level":  "error",
+				"status": float64(500),
+			},
+		},
+		{
+			Name:   "Does not log below the level",
+			Level:  zerolog.WarnLevel,
+			Status: 200,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			logger := zerolog.New(buf).Level(tc.Level)
+
+			handler := WithAccessLog(logger, "/triggers/sonarr", tc.Trigger)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(tc.Status)
+			}))
+
+			req := httptest.NewRequest("POST", "/triggers/sonarr", nil)
+			if tc.Forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tc.Forwarded)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tc.Want == nil {
+				if buf.Len() != 0 {
+					t.Errorf("Request logged: %s", buf.String())
+				}
+				return
+			}
+
+			entry := make(map[string]interface{})
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("Could not decode log entry %q: %v", buf.String(), err)
+			}
+
+			for k, v := range tc.Want {
+				if entry[k] != v {
+					t.Errorf("%s does not match: %v vs %v", k, entry[k], v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package triggers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// The helpers below are shared by the webhooks of the Arrs: Sonarr, Radarr and Lidarr.
+
+func isEventType(e string, types []string) bool {
+	for _, t := range types {
+		if strings.EqualFold(e, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewEventFilter returns the enabled event types of the events option,
+// which must be of the event types of the trigger.
+func NewEventFilter(events []string, types ...string) (map[string]bool, error) {
+	filter := make(map[string]bool)
+	for _, e := range events {
+		if !isEventType(e, types) {
+			return nil, fmt.Errorf("unknown event type: %s", e)
+		}
+
+		filter[strings.ToLower(e)] = true
+	}
+
+	return filter, nil
+}
+
+// NewPriorities returns the priority of each event type,
+// falling back to the default priority for unmapped event types.
+func NewPriorities(priority int, priorities map[string]int, types ...string) (func(kind string) int, error) {
+	mapped := make(map[string]int)
+	for e, p := range priorities {
+		if !isEventType(e, types) {
+			return nil, fmt.Errorf("unknown event type in priorities: %s", e)
+		}
+
+		mapped[strings.ToLower(e)] = p
+	}
+
+	return func(kind string) int {
+		if p, ok := mapped[kind]; ok {
+			return p
+		}
+
+		return priority
+	}, nil
+}
+
+// MissingField responds to an event without a required field.
+// Strict triggers include the field in the response.
+func MissingField(rw http.ResponseWriter, l *zerolog.Logger, field string, strict bool) {
+	l.Error().Str("field", field).Msg("Required fields are missing")
+	if strict {
+		http.Error(rw, "missing field: "+field, http.StatusBadRequest)
+		return
+	}
+
+	rw.WriteHeader(http.StatusBadRequest)
+}
+
+// ScanList collects the unique folders of an event.
+// A folder is only a removal when all its paths were removed.
+type ScanList struct {
+	priority int
+	exists   bool
+	time     time.Time
+	scans    []autoscan.Scan
+	index    map[string]int
+}
+
+// NewScanList creates a list of the scans of an event at the time.
+func NewScanList(priority int, exists bool, t time.Time) *ScanList {
+	return &ScanList{
+		priority: priority,
+		exists:   exists,
+		time:     t,
+		scans:    make([]autoscan.Scan, 0),
+		index:    make(map[string]int),
+	}
+}
+
+// Add queues a scan of the folder for the event.
+// Different events for the same folder are merged into a modification.
+func (l *ScanList) Add(folder string, event autoscan.Event) {
+	if i, ok := l.index[folder]; ok {
+		l.scans[i].Event = l.scans[i].Event.Merge(event)
+		return
+	}
+
+	l.index[folder] = len(l.scans)
+	l.scans = append(l.scans, autoscan.Scan{
+		Folder:      folder,
+		Priority:    l.priority,
+		Event:       event,
+		CheckExists: l.exists,
+		Time:        l.time,
+	})
+}
+
+// Scans returns the scans in the order in which their folders were added.
+func (l *ScanList) Scans() []autoscan.Scan {
+	return l.scans
+}
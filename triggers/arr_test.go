@@ -0,0 +1,74 @@
+package triggers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestEventFilter(t *testing.T) {
+	filter, err := NewEventFilter([]string{"Download", "rename"}, "download", "upgrade", "rename")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"download": true, "rename": true}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("Filters do not match: %v vs %v", filter, want)
+	}
+
+	if _, err := NewEventFilter([]string{"retag"}, "download", "upgrade", "rename"); err == nil {
+		t.Error("Expected an error for an unknown event type")
+	}
+}
+
+func TestPriorities(t *testing.T) {
+	priority, err := NewPriorities(2, map[string]int{"Upgrade": 5}, "download", "upgrade")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := priority("upgrade"); p != 5 {
+		t.Errorf("Priorities do not match: %d vs %d", p, 5)
+	}
+
+	if p := priority("download"); p != 2 {
+		t.Errorf("Priorities do not match: %d vs %d", p, 2)
+	}
+
+	if _, err := NewPriorities(2, map[string]int{"retag": 5}, "download", "upgrade"); err == nil {
+		t.Error("Expected an error for an unknown event type")
+	}
+}
+
+func TestScanList(t *testing.T) {
+	testTime := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	scans := NewScanList(3, true, testTime)
+	scans.Add("/mnt/unionfs/Media/Movies/Interstellar (2014)", autoscan.EventAdded)
+	scans.Add("/mnt/unionfs/Media/Movies/Parasite (2019)", autoscan.EventRemoved)
+	scans.Add("/mnt/unionfs/Media/Movies/Interstellar (2014)", autoscan.EventRemoved)
+
+	want := []autoscan.Scan{
+		{
+			Folder:      "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+			Priority:    3,
+			Event:       autoscan.EventModified,
+			CheckExists: true,
+			Time:        testTime,
+		},
+		{
+			Folder:      "/mnt/unionfs/Media/Movies/Parasite (2019)",
+			Priority:    3,
+			Event:       autoscan.EventRemoved,
+			CheckExists: true,
+			Time:        testTime,
+		},
+	}
+
+	if !reflect.DeepEqual(scans.Scans(), want) {
+		t.Errorf("Scans do not match:\n%+v\nvs\n%+v", scans.Scans(), want)
+	}
+}
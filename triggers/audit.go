@@ -0,0 +1,89 @@
+package triggers
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+type scansKey struct{}
+
+// RecordScans adds the number of scans which a HTTP trigger added to the queue
+// to the audit entry of the request.
+func RecordScans(r *http.Request, scans int) {
+	if count, ok := r.Context().Value(scansKey{}).(*int64); ok {
+		atomic.AddInt64(count, int64(scans))
+	}
+}
+
+// WithAudit records the requests which failed to authenticate, the requests accepted by the trigger,
+// and the requests which change the state of autoscan through the API when the trigger is empty.
+// Requests which only read the API are not recorded.
+func WithAudit(trigger string, record func(processor.AuditEntry)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			var scans int64
+			r = r.WithContext(context.WithValue(r.Context(), scansKey{}, &scans))
+
+			aw := &auditWriter{ResponseWriter: rw, status: http.StatusOK}
+			next.ServeHTTP(aw, r)
+
+			e := processor.AuditEntry{
+				Trigger:  trigger,
+				SourceIP: SourceIP(r),
+				Method:   r.Method,
+				Path:     r.URL.Path,
+				Status:   aw.status,
+				Scans:    int(atomic.LoadInt64(&scans)),
+			}
+
+			// the username is recorded for failures as well, to tell guessing from misconfigured clients
+			e.Username, _, _ = r.BasicAuth()
+
+			switch {
+			case aw.status == http.StatusUnauthorized || aw.status == http.StatusForbidden:
+				e.Event = processor.AuditAuthFailure
+			case aw.status >= 400:
+				return
+			case trigger != "":
+				e.Event = processor.AuditWebhook
+			case r.Method != "GET" && r.Method != "HEAD" && r.Method != "OPTIONS":
+				e.Event = processor.AuditAdmin
+			default:
+				return
+			}
+
+			record(e)
+		})
+	}
+}
+
+// auditWriter records the status of the response.
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	if !w.wrote {
+		w.wrote = true
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush allows the events to be streamed.
+func (w *auditWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,119 @@
+package triggers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cloudbox/autoscan/processor"
+)
+
+func TestAudit(t *testing.T) {
+	type Test struct {
+		Name      string
+		Trigger   string
+		Method    string
+		URL       string
+		Username  string
+		Status    int
+		Scans     int
+		WantEntry *processor.AuditEntry
+	}
+
+	var testCases = []Test{
+		{
+			Name:    "Accepted webhook",
+			Trigger: "sonarr",
+			Method:  "POST",
+			URL:     "/triggers/sonarr",
+			Status:  200,
+			Scans:   2,
+			WantEntry: &processor.AuditEntry{
+				Event: processor.AuditWebhook, Trigger: "sonarr", SourceIP: "10.0.0.1",
+				Method: "POST", Path: "/triggers/sonarr", Status: 200, Scans: 2,
+			},
+		},
+		{
+			Name:     "Failed authentication",
+			Trigger:  "sonarr",
+			Method:   "POST",
+			URL:      "/triggers/sonarr",
+			Username: "obi-wan",
+			Status:   401,
+			WantEntry: &processor.AuditEntry{
+				Event: processor.AuditAuthFailure, Trigger: "sonarr", SourceIP: "10.0.0.1", Username: "obi-wan",
+				Method: "POST", Path: "/triggers/sonarr", Status: 401,
+			},
+		},
+		{
+			Name:     "Insufficient role",
+			Method:   "POST",
+			URL:      "/api/queue/flush",
+			Username: "sonarr",
+			Status:   403,
+			WantEntry: &processor.AuditEntry{
+				Event: processor.AuditAuthFailure, SourceIP: "10.0.0.1", Username: "sonarr",
+				Method: "POST", Path: "/api/queue/flush", Status: 403,
+			},
+		},
+		{
+			Name:     "Admin action",
+			Method:   "POST",
+			URL:      "/api/queue/flush",
+			Username: "hello there",
+			Status:   200,
+			WantEntry: &processor.AuditEntry{
+				Event: processor.AuditAdmin, SourceIP: "10.0.0.1", Username: "hello there",
+				Method: "POST", Path: "/api/queue/flush", Status: 200,
+			},
+		},
+		{
+			Name:     "Reading the API is not recorded",
+			Method:   "GET",
+			URL:      "/api/queue",
+			Username: "hello there",
+			Status:   200,
+		},
+		{
+			Name:    "Rejected webhook is not recorded",
+			Trigger: "sonarr",
+			Method:  "POST",
+			URL:     "/triggers/sonarr",
+			Status:  400,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var entry *processor.AuditEntry
+			record := func(e processor.AuditEntry) {
+				entry = &e
+			}
+
+			handler := WithAudit(tc.Trigger, record)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				RecordScans(r, tc.Scans)
+				rw.WriteHeader(tc.Status)
+			}))
+
+			req := httptest.NewRequest(tc.Method, tc.URL, nil)
+			req.RemoteAddr = "10.0.0.1:51234"
+			if tc.Username != "" {
+				req.SetBasicAuth(tc.Username, "general kenobi")
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.Status {
+				t.Errorf("Status codes do not match: %d vs %d", rr.Code, tc.Status)
+			}
+
+			if !reflect.DeepEqual(entry, tc.WantEntry) {
+				t.Logf("want: %+v", tc.WantEntry)
+				t.Logf("got:  %+v", entry)
+				t.Errorf("Audit entries do not match")
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package triggers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog/hlog"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// The roles of users. Admins can use the triggers and the API,
+// while the users of triggers can only use the triggers, such that webhook credentials cannot manage the queue.
+const (
+	RoleAdmin   = "admin"
+	RoleTrigger = "trigger"
+)
+
+// User is a user of basic authentication, of which the password is stored as a bcrypt hash.
+// The plaintext password is only set by the username and password of the authentication,
+// which predate the users.
+type User struct {
+	Username     string `yaml:"username"`
+	PasswordHash string `yaml:"password-hash"`
+	Password     string `yaml:"-"`
+
+	// Role is the role of the user, which is admin when empty
+	Role string `yaml:"role"`
+}
+
+// Admin returns whether the user has the admin role.
+func (u User) Admin() bool {
+	return u.Role == "" || u.Role == RoleAdmin
+}
+
+// Validate returns an error when the user has no username or an unknown role,
+// or when its password hash is not a bcrypt hash.
+func (u User) Validate() error {
+	if u.Username == "" {
+		return fmt.Errorf("user without username: %w", autoscan.ErrFatal)
+	}
+
+	if !u.Admin() && u.Role != RoleTrigger {
+		return fmt.Errorf("user %s: unknown role %s: %w", u.Username, u.Role, autoscan.ErrFatal)
+	}
+
+	if u.Password != "" {
+		return nil
+	}
+
+	if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+		return fmt.Errorf("user %s: invalid password-hash: %v: %w", u.Username, err, autoscan.ErrFatal)
+	}
+
+	return nil
+}
+
+// Credentials are the credentials with which clients authenticate,
+// which is either the username and password of a user with basic authentication, or the API key.
+// Only admins are allowed when the credentials require the admin role.
+type Credentials struct {
+	Users  []User
+	APIKey string
+	Admin  bool
+}
+
+// APIKeyHeader and APIKeyParam are the header and query parameter of the API key.
+const (
+	APIKeyHeader = "X-Api-Key"
+	APIKeyParam  = "apikey"
+)
+
+// dummyHash is verified for unknown users and users with a plaintext password,
+// such that they take as long to verify as users with a password hash.
+const dummyHash = "$2a$10$w1Xc8nVlCDvnh8JpZ43FSu8h1MAxjJfDFlwXY0LRGbXS.nuGijgVi"
+
+// authenticator verifies the credentials of requests.
+// The verified passwords are remembered by their SHA-256 hash,
+// such that bcrypt, which is slow by design, only verifies the first request of a client.
+type authenticator struct {
+	Credentials
+
+	mtx      sync.Mutex
+	verified map[[sha256.Size]byte]*User
+}
+
+// authenticate returns whether the request authenticates with the credentials,
+// of which the values are compared in constant time,
+// and whether the authenticated client has the role which the credentials require.
+func (a *authenticator) authenticate(r *http.Request) (valid bool, allowed bool) {
+	if a.APIKey != "" {
+		key := r.Header.Get(APIKeyHeader)
+		if key == "" {
+			key = r.URL.Query().Get(APIKeyParam)
+		}
+
+		if key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(a.APIKey)) == 1 {
+			return true, true
+		}
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok || len(a.Users) == 0 {
+		return false, false
+	}
+
+	user := a.verify(username, password)
+	if user == nil {
+		return false, false
+	}
+
+	return true, !a.Admin || user.Admin()
+}
+
+// verify returns the user of the username and password, or nil when they are invalid.
+func (a *authenticator) verify(username, password string) *User {
+	sum := sha256.Sum256([]byte(username + "\x00" + password))
+
+	a.mtx.Lock()
+	verified := a.verified[sum]
+	a.mtx.Unlock()
+
+	if verified != nil {
+		return verified
+	}
+
+	// every user is compared, such that the username is not revealed by the time it takes
+	var user *User
+	for i, u := range a.Users {
+		if subtle.ConstantTimeCompare([]byte(username), []byte(u.Username)) == 1 {
+			user = &a.Users[i]
+		}
+	}
+
+	var valid bool
+	switch {
+	case user == nil:
+		bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+		return nil
+	case user.Password != "":
+		bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+		valid = subtle.ConstantTimeCompare([]byte(password), []byte(user.Password)) == 1
+	default:
+		valid = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+	}
+
+	if !valid {
+		return nil
+	}
+
+	a.mtx.Lock()
+	a.verified[sum] = user
+	a.mtx.Unlock()
+
+	return user
+}
+
+func WithAuth(c Credentials) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		// Don't check for auth if users and API key are missing.
+		if len(c.Users) == 0 && c.APIKey == "" {
+			return next
+		}
+
+		a := &authenticator{Credentials: c, verified: make(map[[sha256.Size]byte]*User)}
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			l := hlog.FromRequest(r)
+
+			valid, allowed := a.authenticate(r)
+			switch {
+			case valid && allowed:
+				l.Trace().Msg("Successful authentication")
+				next.ServeHTTP(rw, r)
+				return
+			case valid:
+				l.Warn().Msg("Insufficient role")
+				rw.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			l.Warn().Msg("Invalid authentication")
+
+			// browsers prompt for the credentials, e.g. to open the dashboard
+			if len(c.Users) > 0 {
+				rw.Header().Set("WWW-Authenticate", `Basic realm="autoscan"`)
+			}
+
+			rw.WriteHeader(http.StatusUnauthorized)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package triggers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestAuth(t *testing.T) {
+	type Request struct {
+		URL      string
+		Username string
+		Password string
+		Header   string
+	}
+
+	type Test struct {
+		Name        string
+		Credentials Credentials
+		Request     Request
+		WantCode    int
+	}
+
+	// the password of the hash is general kenobi
+	users := []User{
+		{Username: "user", Password: "pass"},
+		{Username: "hello there", PasswordHash: "$2a$04$wCGx6cGVyMa.eOuiOHggSua6QSe2FAW4SYg/BDR3zdU0.Q7Gk7fj6"},
+		{Username: "sonarr", Password: "pass", Role: RoleTrigger},
+	}
+
+	basic := Credentials{Users: users}
+	withKey := Credentials{Users: users, APIKey: "secret"}
+	admin := Credentials{Users: users, Admin: true}
+
+	var testCases = []Test{
+		{
+			Name:        "Basic authentication",
+			Credentials: basic,
+			Request:     Request{URL: "/triggers/sonarr", Username: "user", Password: "pass"},
+			WantCode:    200,
+		},
+		{
+			Name:        "Password hash",
+			Credentials: basic,
+			Request:     Request{URL: "/triggers/sonarr", Username: "hello there", Password: "general kenobi"},
+			WantCode:    200,
+		},
+		{
+			Name:        "Invalid password of a password hash",
+			Credentials: basic,
+			Request:     Request{URL: "/triggers/sonarr", Username: "hello there", Password: "pass"},
+			WantCode:    401,
+		},
+		{
+			Name:        "Password of another user",
+			Credentials: basic,
+			Request:     Request{URL: "/triggers/sonarr", Username: "user", Password: "general kenobi"},
+			WantCode:    401,
+		},
+		{
+			Name:        "Unknown user",
+			Credentials: basic,
+			Request:     Request{URL: "/triggers/sonarr", Username: "other", Password: "pass"},
+			WantCode:    401,
+		},
+		{
+			Name:        "Invalid password",
+			Credentials: basic,
+			Request:     Request{URL: "/triggers/sonarr", Username: "user", Password: "other"},
+			WantCode:    401,
+		},
+		{
+			Name:        "API key in the header",
+			Credentials: withKey,
+			Request:     Request{URL: "/triggers/sonarr", Header: "secret"},
+			WantCode:    200,
+		},
+		{
+			Name:        "API key in the query",
+			Credentials: withKey,
+			Request:     Request{URL: "/triggers/sonarr?apikey=secret"},
+			WantCode:    200,
+		},
+		{
+			Name:        "Basic authentication next to the API key",
+			Credentials: withKey,
+			Request:     Request{URL: "/triggers/sonarr", Username: "user", Password: "pass"},
+			WantCode:    200,
+		},
+		{
+			Name:        "Invalid API key",
+			Credentials: withKey,
+			Request:     Request{URL: "/triggers/sonarr?apikey=other"},
+			WantCode:    401,
+		},
+		{
+			Name:        "API key without basic authentication",
+			Credentials: Credentials{APIKey: "secret"},
+			Request:     Request{URL: "/triggers/sonarr", Username: "user", Password: "pass"},
+			WantCode:    401,
+		},
+		{
+			Name:        "API key of another trigger",
+			Credentials: Credentials{APIKey: "secret"},
+			Request:     Request{URL: "/triggers/sonarr", Header: "secret2"},
+			WantCode:    401,
+		},
+		{
+			Name:     "No credentials",
+			Request:  Request{URL: "/triggers/sonarr"},
+			WantCode: 200,
+		},
+		{
+			Name:        "Trigger role on a trigger",
+			Credentials: basic,
+			Request:     Request{URL: "/triggers/sonarr", Username: "sonarr", Password: "pass"},
+			WantCode:    200,
+		},
+		{
+			Name:        "Trigger role on the API",
+			Credentials: admin,
+			Request:     Request{URL: "/api/queue", Username: "sonarr", Password: "pass"},
+			WantCode:    403,
+		},
+		{
+			Name:        "Admin role on the API",
+			Credentials: admin,
+			Request:     Request{URL: "/api/queue", Username: "user", Password: "pass"},
+			WantCode:    200,
+		},
+		{
+			Name:        "Invalid password of the trigger role on the API",
+			Credentials: admin,
+			Request:     Request{URL: "/api/queue", Username: "sonarr", Password: "secret"},
+			WantCode:    401,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			handler := WithAuth(tc.Credentials)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("POST", tc.Request.URL, nil)
+			if tc.Request.Username != "" {
+				req.SetBasicAuth(tc.Request.Username, tc.Request.Password)
+			}
+
+			if tc.Request.Header != "" {
+				req.Header.Set(APIKeyHeader, tc.Request.Header)
+			}
+
+			// the second request is verified by the remembered password
+			for i := 0; i < 2; i++ {
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+
+				if rr.Code != tc.WantCode {
+					t.Errorf("Request %d: status codes do not match: %d vs %d", i, rr.Code, tc.WantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateUser(t *testing.T) {
+	type Test struct {
+		Name string
+		User User
+		Err  error
+	}
+
+	var testCases = []Test{
+		{
+			Name: "Password hash",
+			User: User{Username: "user", PasswordHash: "$2a$04$wCGx6cGVyMa.eOuiOHggSua6QSe2FAW4SYg/BDR3zdU0.Q7Gk7fj6"},
+		},
+		{
+			Name: "Plaintext password",
+			User: User{Username: "user", Password: "pass"},
+		},
+		{
+			Name: "Plaintext password hash",
+			User: User{Username: "user", PasswordHash: "general kenobi"},
+			Err:  autoscan.ErrFatal,
+		},
+		{
+			Name: "Trigger role",
+			User: User{Username: "user", Password: "pass", Role: RoleTrigger},
+		},
+		{
+			Name: "Unknown role",
+			User: User{Username: "user", Password: "pass", Role: "owner"},
+			Err:  autoscan.ErrFatal,
+		},
+		{
+			Name: "No username",
+			User: User{PasswordHash: "$2a$04$wCGx6cGVyMa.eOuiOHggSua6QSe2FAW4SYg/BDR3zdU0.Q7Gk7fj6"},
+			Err:  autoscan.ErrFatal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.User.Validate()
+			if !errors.Is(err, tc.Err) {
+				t.Errorf("Errors do not match: %v vs %v", err, tc.Err)
+			}
+		})
+	}
+}
@@ -22,9 +22,12 @@ const (
 
 type Config struct {
 	AccountPath   string             `yaml:"account"`
+	OAuth         OAuthConfig        `yaml:"oauth"`
 	CronSchedule  string             `yaml:"cron"`
 	DatastorePath string             `yaml:"database"`
+	SQLite        autoscan.SQLite    `yaml:"-"`
 	Priority      int                `yaml:"priority"`
+	CheckExists   bool               `yaml:"check-exists"`
 	TimeOffset    time.Duration      `yaml:"time-offset"`
 	Verbosity     string             `yaml:"verbosity"`
 	Rewrite       []autoscan.Rewrite `yaml:"rewrite"`
@@ -44,28 +47,67 @@ func New(c Config) (autoscan.Trigger, error) {
 		Str("trigger", "bernard").
 		Logger()
 
-	const scope = "https://www.googleapis.com/auth/drive.readonly"
-	auth, err := stubbs.FromFile(c.AccountPath, []string{scope})
+	auth, account, err := newAuthenticator(c)
 	if err != nil {
 		return nil, fmt.Errorf("%v: %w", err, autoscan.ErrFatal)
 	}
 
-	store, err := sqlite.New(fmt.Sprintf("%s?%s", c.DatastorePath, "cache=shared&mode=rwc&_busy_timeout=5000"))
+	drives, err := newDrives(c)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := sqlite.New(c.SQLite.DSN(c.DatastorePath))
 	if err != nil {
 		return nil, fmt.Errorf("%v: %w", err, autoscan.ErrFatal)
 	}
 	store.DB.SetMaxOpenConns(1)
 
-	limiter, err := getRateLimiter(auth.Email())
+	limiter, err := getRateLimiter(account)
 	if err != nil {
 		return nil, fmt.Errorf("%v: %w", err, autoscan.ErrFatal)
 	}
 
 	bernard := lowe.New(auth, store,
+		lowe.WithClient(newQuotaClient(limiter, l)),
 		lowe.WithPreRequestHook(limiter.Wait),
 		lowe.WithSafeSleep(120*time.Second))
 
-	var drives []drive
+	trigger := func(callback autoscan.ProcessorFunc, stop <-chan struct{}) {
+		defer store.DB.Close()
+
+		d := daemon{
+			log:          l,
+			callback:     callback,
+			cronSchedule: c.CronSchedule,
+			priority:     c.Priority,
+			exists:       c.CheckExists,
+			drives:       drives,
+			bernard:      bernard,
+			store:        &bds{store},
+			limiter:      limiter,
+		}
+
+		// start job(s)
+		c, err := d.startAutoSync()
+		if err != nil {
+			l.Error().
+				Err(err).
+				Msg("Failed initialising cron jobs")
+			return
+		}
+
+		// running syncs finish before the datastore is closed
+		<-stop
+		<-c.Stop().Done()
+	}
+
+	return trigger, nil
+}
+
+// newDrives returns the drives of the config with their rewrites and filters.
+func newDrives(c Config) ([]drive, error) {
+	drives := make([]drive, 0, len(c.Drives))
 	for _, d := range c.Drives {
 		d := d
 
@@ -94,28 +136,49 @@ func New(c Config) (autoscan.Trigger, error) {
 		})
 	}
 
-	trigger := func(callback autoscan.ProcessorFunc) {
-		d := daemon{
-			log:          l,
-			callback:     callback,
-			cronSchedule: c.CronSchedule,
-			priority:     c.Priority,
-			drives:       drives,
-			bernard:      bernard,
-			store:        &bds{store},
-			limiter:      limiter,
+	return drives, nil
+}
+
+// Check validates the config without opening the datastore or contacting Google.
+func Check(c Config) error {
+	if _, _, err := newAuthenticator(c); err != nil {
+		return err
+	}
+
+	if _, err := cron.ParseStandard(c.CronSchedule); err != nil {
+		return fmt.Errorf("cron: %w", err)
+	}
+
+	if len(c.Drives) == 0 {
+		return errors.New("no drives configured")
+	}
+
+	_, err := newDrives(c)
+	return err
+}
+
+// newAuthenticator returns the authenticator for either a service account or
+// OAuth user credentials, along with the account for which rate limits are shared.
+func newAuthenticator(c Config) (lowe.Authenticator, string, error) {
+	if c.OAuth.RefreshToken != "" {
+		if c.AccountPath != "" {
+			return nil, "", errors.New("account and oauth cannot both be set")
 		}
 
-		// start job(s)
-		if err := d.startAutoSync(); err != nil {
-			l.Error().
-				Err(err).
-				Msg("Failed initialising cron jobs")
-			return
+		auth, err := newOAuth(c.OAuth)
+		if err != nil {
+			return nil, "", err
 		}
+
+		return auth, auth.Account(), nil
 	}
 
-	return trigger, nil
+	auth, err := stubbs.FromFile(c.AccountPath, []string{driveScope})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return auth, auth.Email(), nil
 }
 
 type drive struct {
@@ -129,6 +192,7 @@ type daemon struct {
 	callback     autoscan.ProcessorFunc
 	cronSchedule string
 	priority     int
+	exists       bool
 	drives       []drive
 	bernard      *lowe.Bernard
 	store        *bds
@@ -208,7 +272,7 @@ func newSyncJob(c *cron.Cron, log zerolog.Logger, job func() error) *syncJob {
 	}
 }
 
-func (d daemon) startAutoSync() error {
+func (d daemon) startAutoSync() (*cron.Cron, error) {
 	c := cron.New()
 
 	for _, drive := range d.drives {
@@ -222,7 +286,7 @@ func (d daemon) startAutoSync() error {
 		case errors.Is(err, ds.ErrFullSync):
 			fullSync = true
 		case err != nil:
-			return fmt.Errorf("%v: determining if full sync required: %v: %w",
+			return nil, fmt.Errorf("%v: determining if full sync required: %v: %w",
 				drive.ID, err, autoscan.ErrFatal)
 		}
 
@@ -277,7 +341,17 @@ func (d daemon) startAutoSync() error {
 					Interface("scans", task.scans).
 					Msg("Scans moving to processor")
 
+				// pause the sync until the processor accepts the scans again
 				err := d.callback(task.scans...)
+				for errors.Is(err, autoscan.ErrQueueFull) {
+					l.Warn().
+						Err(err).
+						Msgf("Processor queue is full, retrying in %s", autoscan.QueueFullRetry)
+
+					time.Sleep(autoscan.QueueFullRetry)
+					err = d.callback(task.scans...)
+				}
+
 				if err != nil {
 					return fmt.Errorf("%v: moving scans to processor: %v: %w",
 						drive.ID, err, autoscan.ErrFatal)
@@ -294,14 +368,14 @@ func (d daemon) startAutoSync() error {
 
 		id, err := c.AddJob(d.cronSchedule, cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(job))
 		if err != nil {
-			return fmt.Errorf("%v: creating auto sync job for drive: %w", drive.ID, err)
+			return nil, fmt.Errorf("%v: creating auto sync job for drive: %w", drive.ID, err)
 		}
 
 		job.jobID = id
 	}
 
 	c.Start()
-	return nil
+	return c, nil
 }
 
 type scanTask struct {
@@ -337,9 +411,11 @@ func (d daemon) getScanTask(drive *drive, paths *Paths) *scanTask {
 
 		// add scan task
 		task.scans = append(task.scans, autoscan.Scan{
-			Folder:   filepath.Clean(rewritten),
-			Priority: d.priority,
-			Time:     drive.ScanTime(),
+			Folder:      filepath.Clean(rewritten),
+			Priority:    d.priority,
+			Event:       autoscan.EventAdded,
+			CheckExists: d.exists,
+			Time:        drive.ScanTime(),
 		})
 
 		task.added++
@@ -363,9 +439,11 @@ func (d daemon) getScanTask(drive *drive, paths *Paths) *scanTask {
 		}
 
 		// add scan task
+		// removed folders are not expected to exist
 		task.scans = append(task.scans, autoscan.Scan{
 			Folder:   filepath.Clean(rewritten),
 			Priority: d.priority,
+			Event:    autoscan.EventRemoved,
 			Time:     drive.ScanTime(),
 		})
 
@@ -2,10 +2,13 @@ package bernard
 
 import (
 	"context"
-	"golang.org/x/sync/semaphore"
-	"golang.org/x/time/rate"
+	"math"
+	"math/rand"
 	"sync"
 	"time"
+
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -13,15 +16,36 @@ const (
 	requestLimit = 8
 	// how many drives can run at once (at the trigger level), e.g. 2 triggers, with 5 drives each.
 	syncLimit = 5
+
+	// lowest request rate the limiter will back off to when the quota is exceeded
+	minRequestLimit = 1
+	// maximum time all drives sharing an account will pause after hitting the quota
+	maxBackoff = 64 * time.Second
+	// how many successful requests are required before the request rate is increased again
+	recoverAfter = 50
 )
 
 type rateLimiter struct {
 	ctx context.Context
 	rl  *rate.Limiter
 	sem *semaphore.Weighted
+
+	mtx        sync.Mutex
+	backoffs   int
+	successes  int
+	pauseUntil time.Time
 }
 
 func (r *rateLimiter) Wait() {
+	// wait out any quota backoff shared by all drives of the account
+	r.mtx.Lock()
+	pause := time.Until(r.pauseUntil)
+	r.mtx.Unlock()
+
+	if pause > 0 {
+		time.Sleep(pause)
+	}
+
 	_ = r.rl.Wait(r.ctx)
 }
 
@@ -33,6 +57,59 @@ func (r *rateLimiter) Release(n int64) {
 	r.sem.Release(n)
 }
 
+// Backoff is called when Google reports that the quota has been exceeded.
+// It halves the request rate and pauses all requests for an exponentially
+// increasing (jittered) duration.
+func (r *rateLimiter) Backoff() time.Duration {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// halve request rate
+	limit := r.rl.Limit() / 2
+	if limit < minRequestLimit {
+		limit = minRequestLimit
+	}
+	r.rl.SetLimit(limit)
+
+	// exponential pause with jitter
+	pause := time.Duration(math.Exp2(float64(r.backoffs))) * time.Second
+	if pause > maxBackoff {
+		pause = maxBackoff
+	}
+	pause += time.Duration(rand.Int63n(int64(time.Second)))
+
+	r.backoffs++
+	r.successes = 0
+
+	// do not shorten a pause set by another drive
+	if until := time.Now().Add(pause); until.After(r.pauseUntil) {
+		r.pauseUntil = until
+	}
+
+	return pause
+}
+
+// Recover is called after every successful request.
+// The request rate slowly grows back to the default limit.
+func (r *rateLimiter) Recover() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.successes++
+	if r.successes < recoverAfter {
+		return
+	}
+
+	r.successes = 0
+	r.backoffs = 0
+
+	limit := r.rl.Limit() * 2
+	if limit > requestLimit {
+		limit = requestLimit
+	}
+	r.rl.SetLimit(limit)
+}
+
 func newRateLimiter() *rateLimiter {
 	return &rateLimiter{
 		ctx: context.Background(),
@@ -0,0 +1,147 @@
+package bernard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	driveScope    = "https://www.googleapis.com/auth/drive.readonly"
+	oauthAuthURL  = "https://accounts.google.com/o/oauth2/auth"
+	oauthTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+type OAuthConfig struct {
+	ClientID         string `yaml:"client-id"`
+	ClientSecret     string `yaml:"client-secret"`
+	ClientSecretFile string `yaml:"client-secret-file"`
+	RefreshToken     string `yaml:"refresh-token"`
+	RefreshTokenFile string `yaml:"refresh-token-file"`
+}
+
+// oauth authenticates as a regular Google user with a refresh token,
+// for personal accounts which cannot create service accounts with access to the drives.
+type oauth struct {
+	mtx    sync.Mutex
+	client *http.Client
+	config OAuthConfig
+
+	token string
+	exp   int64
+}
+
+func newOAuth(c OAuthConfig) (*oauth, error) {
+	if c.ClientID == "" || c.ClientSecret == "" || c.RefreshToken == "" {
+		return nil, errors.New("oauth requires a client-id, client-secret and refresh-token")
+	}
+
+	return &oauth{
+		client: &http.Client{Timeout: 15 * time.Second},
+		config: c,
+	}, nil
+}
+
+// AccessToken returns a new or cached (but not expired) access token
+// and the token's expiry time in UNIX.
+func (o *oauth) AccessToken() (string, int64, error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	// refresh 10 seconds before the token expires
+	if o.token != "" && time.Now().Unix() < o.exp-10 {
+		return o.token, o.exp, nil
+	}
+
+	resp, err := o.request(url.Values{
+		"client_id":     {o.config.ClientID},
+		"client_secret": {o.config.ClientSecret},
+		"refresh_token": {o.config.RefreshToken},
+		"grant_type":    {"refresh_token"},
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	o.token = resp.AccessToken
+	o.exp = time.Now().Unix() + resp.ExpiresIn
+	return o.token, o.exp, nil
+}
+
+// Account identifies the account for which the rate limits are shared.
+func (o *oauth) Account() string {
+	return "oauth:" + o.config.ClientID + ":" + o.config.RefreshToken
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Error        string `json:"error"`
+	Description  string `json:"error_description"`
+}
+
+func (o *oauth) request(form url.Values) (*tokenResponse, error) {
+	res, err := o.client.PostForm(oauthTokenURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("requesting oauth token: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	resp := new(tokenResponse)
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return nil, fmt.Errorf("decoding oauth token response: %v: %s", err, res.Status)
+	}
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("requesting oauth token: %s: %s", resp.Error, resp.Description)
+	}
+
+	return resp, nil
+}
+
+// OAuthURL returns the URL a user must visit to grant autoscan read-only access to Google Drive.
+// After granting access, the user is redirected to the redirectURL with the authorisation code.
+func OAuthURL(clientID string, redirectURL string) string {
+	q := url.Values{
+		"client_id":     {clientID},
+		"redirect_uri":  {redirectURL},
+		"response_type": {"code"},
+		"scope":         {driveScope},
+		"access_type":   {"offline"},
+		"prompt":        {"consent"},
+	}
+
+	return oauthAuthURL + "?" + q.Encode()
+}
+
+// OAuthExchange exchanges an authorisation code for a refresh token.
+func OAuthExchange(clientID string, clientSecret string, redirectURL string, code string) (string, error) {
+	o := &oauth{
+		client: &http.Client{Timeout: 15 * time.Second},
+		config: OAuthConfig{ClientID: clientID, ClientSecret: clientSecret},
+	}
+
+	resp, err := o.request(url.Values{
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"redirect_uri":  {redirectURL},
+		"code":          {strings.TrimSpace(code)},
+		"grant_type":    {"authorization_code"},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if resp.RefreshToken == "" {
+		return "", errors.New("no refresh token was returned, revoke autoscan's access and try again")
+	}
+
+	return resp.RefreshToken, nil
+}
@@ -0,0 +1,99 @@
+package bernard
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// quotaTransport inspects every response of the Google Drive API
+// and adapts the shared rate limiter of the account accordingly.
+type quotaTransport struct {
+	next    http.RoundTripper
+	limiter *rateLimiter
+	log     zerolog.Logger
+}
+
+func newQuotaClient(limiter *rateLimiter, log zerolog.Logger) *http.Client {
+	return &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &quotaTransport{
+			next:    http.DefaultTransport,
+			limiter: limiter,
+			log:     log,
+		},
+	}
+}
+
+func (t *quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		t.limiter.Recover()
+	case res.StatusCode == 429:
+		t.backoff(res.StatusCode, "tooManyRequests")
+	case res.StatusCode == 403:
+		reason, err := rateLimitReason(res)
+		if err != nil {
+			return nil, err
+		}
+
+		if reason != "" {
+			t.backoff(res.StatusCode, reason)
+		}
+	}
+
+	return res, nil
+}
+
+func (t *quotaTransport) backoff(status int, reason string) {
+	pause := t.limiter.Backoff()
+
+	t.log.Debug().
+		Int("status", status).
+		Str("reason", reason).
+		Dur("pause", pause).
+		Float64("request_limit", float64(t.limiter.rl.Limit())).
+		Msg("Drive quota exceeded, backing off")
+}
+
+// rateLimitReason returns the reason of a rate limit error or
+// an empty string for any other 403 error.
+// The response body is restored so bernard can decode it.
+func rateLimitReason(res *http.Response) (string, error) {
+	b, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	type Response struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+
+	resp := new(Response)
+	if err := json.Unmarshal(b, resp); err != nil || len(resp.Error.Errors) == 0 {
+		return "", nil
+	}
+
+	switch reason := resp.Error.Errors[0].Reason; reason {
+	case "userRateLimitExceeded", "rateLimitExceeded":
+		return reason, nil
+	default:
+		return "", nil
+	}
+}
@@ -0,0 +1,86 @@
+package triggers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS allows browsers to call the API from pages of other origins.
+// An allowed origin of * allows all origins.
+// The methods default to GET, POST and DELETE, and the headers to Authorization and Content-Type.
+// Credentials allows browsers to send the credentials of the user, e.g. for basic authentication.
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowed-origins"`
+	AllowedMethods   []string      `yaml:"allowed-methods"`
+	AllowedHeaders   []string      `yaml:"allowed-headers"`
+	AllowCredentials bool          `yaml:"allow-credentials"`
+	MaxAge           time.Duration `yaml:"max-age"`
+}
+
+func (c CORS) allowed(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithCORS adds the CORS headers to the responses to requests of allowed origins,
+// and responds to their preflight requests without passing them on, as preflight requests are not authenticated.
+// Requests of other origins are passed on without CORS headers, such that browsers block their responses.
+func WithCORS(c CORS) func(http.Handler) http.Handler {
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "POST", "DELETE"}
+	}
+
+	headers := c.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Authorization", "Content-Type"}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(c.AllowedOrigins) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			rw.Header().Add("Vary", "Origin")
+
+			if origin == "" || !c.allowed(origin) {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			// credentials cannot be sent to any origin
+			allowOrigin := origin
+			if !c.AllowCredentials && c.allowed("*") {
+				allowOrigin = "*"
+			}
+
+			rw.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			if c.AllowCredentials {
+				rw.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method != "OPTIONS" || r.Header.Get("Access-Control-Request-Method") == "" {
+				rw.Header().Set("Access-Control-Expose-Headers", "Retry-After")
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			rw.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			rw.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			if c.MaxAge > 0 {
+				rw.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+			}
+
+			rw.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package triggers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	type Test struct {
+		Name          string
+		CORS          CORS
+		Method        string
+		Origin        string
+		RequestMethod string
+		WantCode      int
+		WantHeaders   map[string]string
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Passes on the requests of allowed origins",
+			CORS:     CORS{AllowedOrigins: []string{"https://dashboard.example.com"}},
+			Method:   "GET",
+			Origin:   "https://dashboard.example.com",
+			WantCode: 200,
+			WantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "https://dashboard.example.com",
+				"Access-Control-Expose-Headers": "Retry-After",
+				"Vary":                          "Origin",
+			},
+		},
+		{
+			Name:     "Does not add headers to the requests of other origins",
+			CORS:     CORS{AllowedOrigins: []string{"https://dashboard.example.com"}},
+			Method:   "GET",
+			Origin:   "https://evil.example.com",
+			WantCode: 200,
+			WantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+				"Vary":                        "Origin",
+			},
+		},
+		{
+			Name:          "Responds to preflight requests",
+			CORS:          CORS{AllowedOrigins: []string{"https://dashboard.example.com"}, MaxAge: time.Hour},
+			Method:        "OPTIONS",
+			Origin:        "https://dashboard.example.com",
+			RequestMethod: "DELETE",
+			WantCode:      204,
+			WantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://dashboard.example.com",
+				"Access-Control-Allow-Methods": "GET, POST, DELETE",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type",
+				"Access-Control-Max-Age":       "3600",
+			},
+		},
+		{
+			Name: "Responds to preflight requests with the configured methods and headers",
+			CORS: CORS{
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{"GET"},
+				AllowedHeaders: []string{"Authorization"},
+			},
+			Method:        "OPTIONS",
+			Origin:        "https://dashboard.example.com",
+			RequestMethod: "GET",
+			WantCode:      204,
+			WantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "Authorization",
+				"Access-Control-Max-Age":       "",
+			},
+		},
+		{
+			Name:     "Allows credentials of any origin",
+			CORS:     CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			Method:   "GET",
+			Origin:   "https://dashboard.example.com",
+			WantCode: 200,
+			WantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://dashboard.example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			Name:     "Passes on the requests without origin",
+			CORS:     CORS{AllowedOrigins: []string{"*"}},
+			Method:   "OPTIONS",
+			WantCode: 200,
+			WantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		},
+		{
+			Name:     "Does nothing without allowed origins",
+			Method:   "GET",
+			Origin:   "https://dashboard.example.com",
+			WantCode: 200,
+			WantHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+				"Vary":                        "",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			handler := WithCORS(tc.CORS)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tc.Method, "/api/status", nil)
+			if tc.Origin != "" {
+				req.Header.Set("Origin", tc.Origin)
+			}
+
+			if tc.RequestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tc.RequestMethod)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.WantCode {
+				t.Errorf("Status codes do not match: %d vs %d", rr.Code, tc.WantCode)
+			}
+
+			for k, v := range tc.WantHeaders {
+				if got := rr.Header().Get(k); got != v {
+					t.Errorf("%s does not match: %q vs %q", k, got, v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package triggers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// WithDeduplication responds to the repeated deliveries of a request within the window with the response to the request,
+// without passing them on, such that the retries of a webhook or a proxy do not queue the scans again.
+// Requests are identical when their method, URL and body are,
+// and are only remembered once they have been handled successfully, such that failed requests can be retried.
+func WithDeduplication(window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if window <= 0 {
+			return next
+		}
+
+		d := &deduplicator{window: window, seen: make(map[string]*response)}
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			l := hlog.FromRequest(r)
+
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				l.Error().
+					Err(err).
+					Msg("Failed reading request body")
+
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			hash := requestHash(r, body)
+			if res := d.response(hash); res != nil {
+				l.Info().
+					Str("hash", hash[:16]).
+					Msg("Duplicate request ignored")
+
+				// the headers of the request, such as its ID, are kept
+				for k, v := range res.header {
+					if _, ok := rw.Header()[k]; !ok {
+						rw.Header()[k] = v
+					}
+				}
+
+				rw.WriteHeader(res.status)
+				rw.Write(res.body.Bytes())
+				return
+			}
+
+			res := &response{ResponseWriter: rw, status: http.StatusOK}
+			next.ServeHTTP(res, r)
+
+			if res.status < 400 {
+				res.ResponseWriter = nil
+				res.header = rw.Header().Clone()
+				d.remember(hash, res)
+			}
+		})
+	}
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type deduplicator struct {
+	window time.Duration
+
+	mtx   sync.Mutex
+	seen  map[string]*response
+	swept time.Time
+}
+
+// response returns the response to the request when it was handled within the window.
+func (d *deduplicator) response(hash string) *response {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	res, ok := d.seen[hash]
+	if !ok || now().Sub(res.time) >= d.window {
+		return nil
+	}
+
+	return res
+}
+
+// remember remembers the response to the request, and forgets the requests beyond the window.
+func (d *deduplicator) remember(hash string, res *response) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	t := now()
+	if t.Sub(d.swept) > d.window {
+		for k, v := range d.seen {
+			if t.Sub(v.time) >= d.window {
+				delete(d.seen, k)
+			}
+		}
+
+		d.swept = t
+	}
+
+	res.time = t
+	d.seen[hash] = res
+}
+
+// response records the response to a request while writing it.
+type response struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+	time   time.Time
+}
+
+func (w *response) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *response) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,119 @@
+package triggers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeduplication(t *testing.T) {
+	type Request struct {
+		URL       string
+		Body      string
+		After     time.Duration
+		Status    int
+		WantCalls int
+	}
+
+	type Test struct {
+		Name     string
+		Window   time.Duration
+		Requests []Request
+	}
+
+	var testCases = []Test{
+		{
+			Name:   "Ignores identical requests within the window",
+			Window: time.Minute,
+			Requests: []Request{
+				{URL: "/triggers/sonarr", Body: `{"eventType":"Download"}`, WantCalls: 1},
+				{URL: "/triggers/sonarr", Body: `{"eventType":"Download"}`, WantCalls: 1},
+				{URL: "/triggers/sonarr", Body: `{"eventType":"Rename"}`, WantCalls: 2},
+				{URL: "/triggers/sonarr", Body: `{"eventType":"Download"}`, After: time.Minute, WantCalls: 3},
+			},
+		},
+		{
+			Name:   "Compares the query",
+			Window: time.Minute,
+			Requests: []Request{
+				{URL: "/triggers/manual?dir=/tv/a", WantCalls: 1},
+				{URL: "/triggers/manual?dir=/tv/b", WantCalls: 2},
+				{URL: "/triggers/manual?dir=/tv/a", WantCalls: 2},
+			},
+		},
+		{
+			Name:   "Passes on the retries of failed requests",
+			Window: time.Minute,
+			Requests: []Request{
+				{URL: "/triggers/sonarr", Body: `{}`, Status: 500, WantCalls: 1},
+				{URL: "/triggers/sonarr", Body: `{}`, WantCalls: 2},
+				{URL: "/triggers/sonarr", Body: `{}`, WantCalls: 2},
+			},
+		},
+		{
+			Name: "Does not deduplicate without window",
+			Requests: []Request{
+				{URL: "/triggers/sonarr", Body: `{}`, WantCalls: 1},
+				{URL: "/triggers/sonarr", Body: `{}`, WantCalls: 2},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testTime := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+			now = func() time.Time {
+				return testTime
+			}
+			defer func() { now = time.Now }()
+
+			calls := 0
+			status := 0
+			handler := WithDeduplication(tc.Window)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				calls++
+				if status != 0 {
+					rw.WriteHeader(status)
+				}
+
+				rw.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(rw, `{"call":%d}`, calls)
+			}))
+
+			responses := make(map[string]string)
+			for i, req := range tc.Requests {
+				testTime = testTime.Add(req.After)
+				status = req.Status
+				previous := calls
+
+				rw := httptest.NewRecorder()
+				handler.ServeHTTP(rw, httptest.NewRequest("POST", req.URL, strings.NewReader(req.Body)))
+
+				if calls != req.WantCalls {
+					t.Errorf("Request %d: calls do not match: %d vs %d", i, calls, req.WantCalls)
+				}
+
+				if req.Status != 0 {
+					continue
+				}
+
+				if rw.Code != 200 {
+					t.Errorf("Request %d: status codes do not match: %d vs %d", i, rw.Code, 200)
+				}
+
+				// duplicates receive the response to the request
+				key := req.URL + req.Body
+				if calls != previous {
+					responses[key] = fmt.Sprintf(`{"call":%d}`, calls)
+				}
+
+				want := responses[key]
+				if rw.Body.String() != want || rw.Header().Get("Content-Type") != "application/json" {
+					t.Errorf("Request %d: responses do not match: %s vs %s", i, rw.Body.String(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package inotify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"github.com/cloudbox/autoscan"
+	"golang.org/x/sys/unix"
+)
+
+// startFanotify monitors the mount points of all paths with fanotify.
+//
+// Unlike inotify, fanotify does not require a watch per directory.
+// However, mount marks only report files which have been written to,
+// removals and renames are not reported.
+func (d *daemon) startFanotify() error {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC, unix.O_RDONLY|unix.O_LARGEFILE|unix.O_CLOEXEC)
+	if err != nil {
+		if errors.Is(err, unix.EPERM) {
+			return fmt.Errorf("fanotify requires the CAP_SYS_ADMIN capability: %w", err)
+		}
+
+		return fmt.Errorf("create fanotify: %w", err)
+	}
+
+	for _, p := range d.paths {
+		if err := unix.FanotifyMark(fd, unix.FAN_MARK_ADD|unix.FAN_MARK_MOUNT, unix.FAN_CLOSE_WRITE, unix.AT_FDCWD, p.Path); err != nil {
+			_ = unix.Close(fd)
+			return fmt.Errorf("watch mount: %v: %w", p.Path, err)
+		}
+
+		d.log.Trace().
+			Str("path", p.Path).
+			Msg("Watching mount")
+	}
+
+	go d.fanotifyWorker(fd)
+	return nil
+}
+
+func (d *daemon) fanotifyWorker(fd int) {
+	defer unix.Close(fd)
+
+	buf := make([]byte, 64*1024)
+	metaSize := int(unsafe.Sizeof(unix.FanotifyEventMetadata{}))
+
+	// reads wait for events with a timeout, such that the worker notices when it is stopped
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+
+		ready, err := unix.Poll(fds, 1000)
+		if err == nil && ready == 0 {
+			continue
+		}
+
+		n := 0
+		if err == nil {
+			n, err = unix.Read(fd, buf)
+		}
+
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+
+			d.log.Error().
+				Err(err).
+				Msg("Failed receiving fanotify events, stopped watching")
+			return
+		}
+
+		for offset := 0; offset+metaSize <= n; {
+			meta := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[offset]))
+			if meta.Vers != unix.FANOTIFY_METADATA_VERSION || meta.Event_len < uint32(metaSize) {
+				d.log.Error().Msg("Unsupported fanotify event, stopped watching")
+				return
+			}
+
+			offset += int(meta.Event_len)
+
+			if meta.Mask&unix.FAN_Q_OVERFLOW != 0 {
+				d.log.Warn().Msg("Fanotify event queue overflowed, events have been lost")
+				continue
+			}
+
+			if meta.Fd < 0 {
+				continue
+			}
+
+			// retrieve the path of the file from its descriptor
+			name, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", meta.Fd))
+			_ = unix.Close(int(meta.Fd))
+			if err != nil {
+				continue
+			}
+
+			// the mount may contain more than the configured paths
+			if !d.watching(name) {
+				continue
+			}
+
+			d.log.Trace().
+				Str("path", name).
+				Msg("Fanotify event")
+
+			d.queuePath(name, false, autoscan.EventModified)
+		}
+	}
+}
+
+func (d *daemon) watching(name string) bool {
+	for _, p := range d.paths {
+		if hasPathPrefix(name, p.Path) {
+			return true
+		}
+	}
+
+	return false
+}
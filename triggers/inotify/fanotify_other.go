@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package inotify
+
+import (
+	"errors"
+)
+
+func (d *daemon) startFanotify() error {
+	return errors.New("fanotify is only supported on Linux")
+}
@@ -1,28 +1,36 @@
 package inotify
 
 import (
+	"errors"
 	"fmt"
 	"github.com/cloudbox/autoscan"
 	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
 type Config struct {
 	Priority  int                `yaml:"priority"`
+	Backend   string             `yaml:"backend"`
+	Debounce  time.Duration      `yaml:"debounce"`
+	Interval  time.Duration      `yaml:"poll-interval"`
 	Verbosity string             `yaml:"verbosity"`
 	Rewrite   []autoscan.Rewrite `yaml:"rewrite"`
 	Include   []string           `yaml:"include"`
 	Exclude   []string           `yaml:"exclude"`
+	InGlobs   []string           `yaml:"include-globs"`
+	ExGlobs   []string           `yaml:"exclude-globs"`
 	Paths     []struct {
 		Path    string             `yaml:"path"`
 		Rewrite []autoscan.Rewrite `yaml:"rewrite"`
 		Include []string           `yaml:"include"`
 		Exclude []string           `yaml:"exclude"`
+		InGlobs []string           `yaml:"include-globs"`
+		ExGlobs []string           `yaml:"exclude-globs"`
 	} `yaml:"paths"`
 }
 
@@ -30,14 +38,18 @@ type daemon struct {
 	callback autoscan.ProcessorFunc
 	paths    []path
 	watcher  *fsnotify.Watcher
+	events   chan fsnotify.Event
+	poller   *poller
 	queue    *queue
 	log      zerolog.Logger
+	stop     <-chan struct{}
 }
 
 type path struct {
 	Path     string
 	Rewriter autoscan.Rewriter
 	Allowed  autoscan.Filterer
+	Globbed  autoscan.Filterer
 }
 
 func New(c Config) (autoscan.Trigger, error) {
@@ -45,6 +57,12 @@ func New(c Config) (autoscan.Trigger, error) {
 		Str("trigger", "inotify").
 		Logger()
 
+	switch c.Backend {
+	case "", "inotify", "fanotify":
+	default:
+		return nil, fmt.Errorf("unknown backend: %v", c.Backend)
+	}
+
 	var paths []path
 	for _, p := range c.Paths {
 		p := p
@@ -59,28 +77,54 @@ func New(c Config) (autoscan.Trigger, error) {
 			return nil, err
 		}
 
+		globber, err := autoscan.NewGlobFilterer(append(p.InGlobs, c.InGlobs...), append(p.ExGlobs, c.ExGlobs...))
+		if err != nil {
+			return nil, err
+		}
+
 		paths = append(paths, path{
-			Path:     p.Path,
+			Path:     filepath.Clean(p.Path),
 			Rewriter: rewriter,
 			Allowed:  filterer,
+			Globbed:  globber,
 		})
 	}
 
-	trigger := func(callback autoscan.ProcessorFunc) {
+	debounce := c.Debounce
+	if debounce <= 0 {
+		debounce = 10 * time.Second
+	}
+
+	interval := c.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	trigger := func(callback autoscan.ProcessorFunc, stop <-chan struct{}) {
 		d := daemon{
 			log:      l,
 			callback: callback,
 			paths:    paths,
-			queue:    newQueue(callback, l, c.Priority),
+			events:   make(chan fsnotify.Event),
+			poller:   newPoller(interval, l, stop),
+			queue:    newQueue(callback, l, c.Priority, debounce, stop),
+			stop:     stop,
 		}
 
 		// start job(s)
-		if err := d.startMonitoring(); err != nil {
+		start := d.startDefault
+		if c.Backend == "fanotify" {
+			start = d.startFanotify
+		}
+
+		if err := start(); err != nil {
 			l.Error().
 				Err(err).
 				Msg("Failed initialising jobs")
 			return
 		}
+
+		<-stop
 	}
 
 	return trigger, nil
@@ -109,13 +153,33 @@ func (d *daemon) startMonitoring() error {
 }
 
 func (d *daemon) walkFunc(path string, fi os.FileInfo, err error) error {
+	if err != nil {
+		return err
+	}
+
 	// ignore non-directory
 	if !fi.Mode().IsDir() {
 		return nil
 	}
 
+	// directory is already being polled
+	if d.poller.polled(path) {
+		return filepath.SkipDir
+	}
+
 	if err := d.watcher.Add(path); err != nil {
-		return fmt.Errorf("watch directory: %v: %w", path, err)
+		if !errors.Is(err, syscall.ENOSPC) {
+			return fmt.Errorf("watch directory: %v: %w", path, err)
+		}
+
+		// watch limit reached, poll this tree instead
+		d.log.Warn().
+			Str("path", path).
+			Stringer("interval", d.poller.interval).
+			Msg("Inotify watch limit reached, polling directory tree instead (increase fs.inotify.max_user_watches)")
+
+		d.poller.add(path)
+		return filepath.SkipDir
 	}
 
 	d.log.Trace().
@@ -127,7 +191,7 @@ func (d *daemon) walkFunc(path string, fi os.FileInfo, err error) error {
 
 func (d *daemon) getPathObject(path string) (*path, error) {
 	for _, p := range d.paths {
-		if strings.HasPrefix(path, p.Path) {
+		if hasPathPrefix(path, p.Path) {
 			return &p, nil
 		}
 	}
@@ -136,81 +200,130 @@ func (d *daemon) getPathObject(path string) (*path, error) {
 }
 
 func (d *daemon) worker() {
-	// close watcher
-	defer d.watcher.Close()
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+
+	if d.watcher != nil {
+		// close watcher
+		defer d.watcher.Close()
+
+		events = d.watcher.Events
+		errs = d.watcher.Errors
+	}
 
 	// process events
 	for {
 		select {
-		case event := <-d.watcher.Events:
-			// new filesystem event
-			d.log.Trace().
-				Interface("event", event).
-				Msg("Filesystem event")
-
-			switch {
-			case event.Op&fsnotify.Create == fsnotify.Create:
-				// create
-				fi, err := os.Stat(event.Name)
-				if err != nil {
-					d.log.Error().
-						Err(err).
-						Str("path", event.Name).
-						Msg("Failed retrieving filesystem info")
-					continue
-				}
-
-				// watch new directories
-				if fi.IsDir() {
-					if err := filepath.Walk(event.Name, d.walkFunc); err != nil {
-						d.log.Error().
-							Err(err).
-							Str("path", event.Name).
-							Msg("Failed watching new directory")
-					}
-
-					continue
-				}
-
-			case event.Op&fsnotify.Rename == fsnotify.Rename, event.Op&fsnotify.Remove == fsnotify.Remove:
-				// renamed / removed
-			default:
-				// ignore this event
-				continue
+		case event := <-events:
+			d.handleEvent(event)
+
+		case event := <-d.events:
+			d.handleEvent(event)
+
+		case event := <-d.poller.events:
+			d.handleEvent(event)
+
+		case err := <-errs:
+			d.log.Error().
+				Err(err).
+				Msg("Failed receiving filesystem events")
+
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (d *daemon) handleEvent(event fsnotify.Event) {
+	// new filesystem event
+	d.log.Trace().
+		Interface("event", event).
+		Msg("Filesystem event")
+
+	switch {
+	case event.Op&fsnotify.Create == fsnotify.Create:
+		// create
+		fi, err := os.Stat(event.Name)
+		if err != nil {
+			d.log.Error().
+				Err(err).
+				Str("path", event.Name).
+				Msg("Failed retrieving filesystem info")
+			return
+		}
+
+		// watch new directories
+		if fi.IsDir() {
+			if d.watcher == nil {
+				// directory is watched recursively
+				d.queuePath(event.Name, true, autoscan.EventAdded)
+				return
 			}
 
-			// get path object
-			p, err := d.getPathObject(event.Name)
-			if err != nil {
+			if err := filepath.Walk(event.Name, d.walkFunc); err != nil {
 				d.log.Error().
 					Err(err).
 					Str("path", event.Name).
-					Msg("Failed determining path object")
-				continue
+					Msg("Failed watching new directory")
 			}
 
-			// rewrite
-			rewritten := p.Rewriter(event.Name)
+			// the directory may already contain files (e.g. moved into place),
+			// which were created before the watch was added.
+			d.queuePath(event.Name, true, autoscan.EventAdded)
+			return
+		}
 
-			// filter
-			if !p.Allowed(rewritten) {
-				continue
-			}
+		d.queuePath(event.Name, false, autoscan.EventAdded)
 
-			// get directory where path has an extension
-			if filepath.Ext(rewritten) != "" {
-				// there was most likely a file extension, use the directory
-				rewritten = filepath.Dir(rewritten)
-			}
+	case event.Op&fsnotify.Write == fsnotify.Write:
+		// written, extends the debounce window of the directory
+		d.queuePath(event.Name, false, autoscan.EventModified)
 
-			// move to queue
-			d.queue.inputs <- rewritten
+	case event.Op&fsnotify.Rename == fsnotify.Rename, event.Op&fsnotify.Remove == fsnotify.Remove:
+		// deleted / moved out
+		d.queuePath(event.Name, false, autoscan.EventRemoved)
+	}
+}
 
-		case err := <-d.watcher.Errors:
-			d.log.Error().
-				Err(err).
-				Msg("Failed receiving filesystem events")
-		}
+// queuePath rewrites and filters the path of an event and moves it to the queue.
+func (d *daemon) queuePath(name string, isDir bool, event autoscan.Event) {
+	// get path object
+	p, err := d.getPathObject(name)
+	if err != nil {
+		d.log.Error().
+			Err(err).
+			Str("path", name).
+			Msg("Failed determining path object")
+		return
+	}
+
+	// rewrite
+	rewritten := p.Rewriter(name)
+
+	// filter
+	if !p.Allowed(rewritten) {
+		return
+	}
+
+	globPath := rewritten
+	if isDir {
+		globPath += "/"
+	}
+
+	if !p.Globbed(globPath) {
+		return
+	}
+
+	// get directory where path has an extension
+	if !isDir && filepath.Ext(rewritten) != "" {
+		// there was most likely a file extension, use the directory
+		rewritten = filepath.Dir(rewritten)
+	}
+
+	// move to queue
+	select {
+	case d.queue.inputs <- queueInput{path: rewritten, event: event}:
+	case <-d.stop:
 	}
 }
 
@@ -218,19 +331,36 @@ type queue struct {
 	callback autoscan.ProcessorFunc
 	log      zerolog.Logger
 	priority int
-	inputs   chan string
-	scans    map[string]time.Time
+	debounce time.Duration
+	inputs   chan queueInput
+	scans    map[string]*queuedScan
 	lock     *sync.Mutex
+	stop     <-chan struct{}
+
+	// paused delays moving scans to the processor while its queue is full
+	paused time.Time
+}
+
+type queueInput struct {
+	path  string
+	event autoscan.Event
+}
+
+type queuedScan struct {
+	time  time.Time
+	event autoscan.Event
 }
 
-func newQueue(cb autoscan.ProcessorFunc, log zerolog.Logger, priority int) *queue {
+func newQueue(cb autoscan.ProcessorFunc, log zerolog.Logger, priority int, debounce time.Duration, stop <-chan struct{}) *queue {
 	q := &queue{
 		callback: cb,
 		log:      log,
 		priority: priority,
-		inputs:   make(chan string),
-		scans:    make(map[string]time.Time),
+		debounce: debounce,
+		inputs:   make(chan queueInput),
+		scans:    make(map[string]*queuedScan),
 		lock:     &sync.Mutex{},
+		stop:     stop,
 	}
 
 	go q.worker()
@@ -238,49 +368,75 @@ func newQueue(cb autoscan.ProcessorFunc, log zerolog.Logger, priority int) *queu
 	return q
 }
 
-func (q *queue) add(path string) {
+func (q *queue) add(input queueInput) {
 	// acquire lock
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	// queue scan task
-	q.scans[path] = time.Now().Add(10 * time.Second)
+	// queue scan task, every new event for the path restarts the debounce window
+	scan, ok := q.scans[input.path]
+	if !ok {
+		scan = &queuedScan{event: input.event}
+		q.scans[input.path] = scan
+	}
+
+	scan.time = time.Now().Add(q.debounce)
+
+	// the scan is only a removal when all events were removals
+	scan.event = scan.event.Merge(input.event)
 }
 
 func (q *queue) worker() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case path, ok := <-q.inputs:
+		case input, ok := <-q.inputs:
 			if !ok {
 				// channel closed
 				return
 			}
 
 			// add path to queue
-			q.add(path)
+			q.add(input)
 
-		default:
+		case <-ticker.C:
 			// process queue
 			q.process()
+
+		case <-q.stop:
+			// the debounce window of the remaining scans is cut short
+			q.flush()
+			return
 		}
 	}
 }
 
+// flush moves all queued scans to the processor.
+func (q *queue) flush() {
+	q.lock.Lock()
+	for _, s := range q.scans {
+		s.time = time.Time{}
+	}
+	q.lock.Unlock()
+
+	q.process()
+}
+
 func (q *queue) process() {
 	// acquire lock
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	// sleep if no scans queued
-	if len(q.scans) == 0 {
-		time.Sleep(100 * time.Millisecond)
+	if time.Now().Before(q.paused) {
 		return
 	}
 
 	// move scans to processor
-	for p, t := range q.scans {
-		// time has not elapsed
-		if time.Now().Before(t) {
+	for p, s := range q.scans {
+		// debounce window has not elapsed
+		if time.Now().Before(s.time) {
 			continue
 		}
 
@@ -288,9 +444,20 @@ func (q *queue) process() {
 		err := q.callback(autoscan.Scan{
 			Folder:   filepath.Clean(p),
 			Priority: q.priority,
+			Event:    s.event,
 			Time:     time.Now(),
 		})
 
+		if errors.Is(err, autoscan.ErrQueueFull) {
+			// pause until the processor accepts scans again
+			q.log.Warn().
+				Err(err).
+				Msgf("Processor queue is full, retrying in %s", autoscan.QueueFullRetry)
+
+			q.paused = time.Now().Add(autoscan.QueueFullRetry)
+			return
+		}
+
 		if err != nil {
 			q.log.Error().
 				Err(err).
@@ -299,6 +466,7 @@ func (q *queue) process() {
 		} else {
 			q.log.Info().
 				Str("path", p).
+				Str("event", string(s.event)).
 				Msg("Scan moved to processor")
 		}
 
@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package inotify
+
+import (
+	"strings"
+)
+
+// hasPathPrefix reports whether the path is within prefix.
+func hasPathPrefix(path string, prefix string) bool {
+	return strings.HasPrefix(path, prefix)
+}
@@ -0,0 +1,11 @@
+package inotify
+
+import (
+	"strings"
+)
+
+// hasPathPrefix reports whether the path is within prefix.
+// Windows paths are case-insensitive, e.g. `c:\Media` equals `C:\media`.
+func hasPathPrefix(path string, prefix string) bool {
+	return len(path) >= len(prefix) && strings.EqualFold(path[:len(prefix)], prefix)
+}
@@ -0,0 +1,155 @@
+package inotify
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// poller watches directory trees by comparing periodic snapshots of the file system.
+// It is used for trees which could not be watched with inotify,
+// e.g. when the kernel watch limit has been reached.
+type poller struct {
+	interval time.Duration
+	events   chan fsnotify.Event
+	log      zerolog.Logger
+	stop     <-chan struct{}
+
+	roots map[string]snapshot
+	lock  *sync.Mutex
+}
+
+type fileState struct {
+	dir     bool
+	size    int64
+	modTime time.Time
+}
+
+type snapshot map[string]fileState
+
+func newPoller(interval time.Duration, log zerolog.Logger, stop <-chan struct{}) *poller {
+	p := &poller{
+		interval: interval,
+		events:   make(chan fsnotify.Event),
+		log:      log,
+		stop:     stop,
+		roots:    make(map[string]snapshot),
+		lock:     &sync.Mutex{},
+	}
+
+	go p.worker()
+
+	return p
+}
+
+// add starts polling the tree of the given root directory.
+func (p *poller) add(root string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.roots[root]; ok {
+		return
+	}
+
+	p.roots[root] = p.snapshot(root)
+}
+
+// polled returns whether the path is part of a polled tree.
+func (p *poller) polled(path string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for root := range p.roots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *poller) snapshot(root string) snapshot {
+	s := make(snapshot)
+
+	_ = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// files can disappear while walking
+			return nil
+		}
+
+		s[path] = fileState{
+			dir:     fi.IsDir(),
+			size:    fi.Size(),
+			modTime: fi.ModTime(),
+		}
+
+		return nil
+	})
+
+	return s
+}
+
+func (p *poller) worker() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+
+		for _, event := range p.poll() {
+			select {
+			case p.events <- event:
+			case <-p.stop:
+				return
+			}
+		}
+	}
+}
+
+// poll compares a new snapshot of every root with the previous one
+// and translates the differences into filesystem events.
+func (p *poller) poll() []fsnotify.Event {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	events := make([]fsnotify.Event, 0)
+
+	for root, old := range p.roots {
+		current := p.snapshot(root)
+
+		for path, state := range current {
+			prev, ok := old[path]
+			switch {
+			case !ok:
+				events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+			case !state.dir && (state.size != prev.size || !state.modTime.Equal(prev.modTime)):
+				events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Write})
+			}
+		}
+
+		for path := range old {
+			if _, ok := current[path]; !ok {
+				events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+			}
+		}
+
+		p.roots[root] = current
+	}
+
+	if len(events) > 0 {
+		p.log.Trace().
+			Int("events", len(events)).
+			Msg("Polled filesystem changes")
+	}
+
+	return events
+}
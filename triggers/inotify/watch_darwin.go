@@ -0,0 +1,87 @@
+//go:build darwin && cgo
+// +build darwin,cgo
+
+package inotify
+
+import (
+	"os"
+	"time"
+
+	"github.com/fsnotify/fsevents"
+	"github.com/fsnotify/fsnotify"
+)
+
+// startDefault watches all paths with a single FSEvents stream,
+// which reports changes for entire directory trees.
+func (d *daemon) startDefault() error {
+	paths := make([]string, 0, len(d.paths))
+	for _, p := range d.paths {
+		paths = append(paths, p.Path)
+
+		d.log.Trace().
+			Str("path", p.Path).
+			Msg("Watching directory tree")
+	}
+
+	es := &fsevents.EventStream{
+		Paths:   paths,
+		Latency: 500 * time.Millisecond,
+		Flags:   fsevents.FileEvents | fsevents.WatchRoot,
+	}
+
+	es.Start()
+
+	go func() {
+		defer es.Stop()
+
+		for {
+			var events []fsevents.Event
+			select {
+			case events = <-es.Events:
+			case <-d.stop:
+				return
+			}
+
+			for _, event := range events {
+				if op, ok := fseventOp(event); ok {
+					select {
+					case d.events <- fsnotify.Event{Name: event.Path, Op: op}:
+					case <-d.stop:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	// poller events are still processed by the worker
+	go d.worker()
+
+	return nil
+}
+
+// fseventOp translates the flags of an event into a single operation.
+// FSEvents may combine multiple flags into one event, e.g. when a file
+// was created and removed within the latency window.
+func fseventOp(event fsevents.Event) (fsnotify.Op, bool) {
+	flags := event.Flags
+
+	switch {
+	case flags&(fsevents.ItemRemoved|fsevents.ItemRenamed) != 0:
+		if _, err := os.Lstat(event.Path); os.IsNotExist(err) {
+			// removed or moved out
+			return fsnotify.Remove, true
+		}
+
+		// moved in or re-created
+		return fsnotify.Create, true
+
+	case flags&fsevents.ItemCreated != 0:
+		return fsnotify.Create, true
+
+	case flags&(fsevents.ItemModified|fsevents.ItemInodeMetaMod) != 0:
+		return fsnotify.Write, true
+	}
+
+	return 0, false
+}
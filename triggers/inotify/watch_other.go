@@ -0,0 +1,9 @@
+//go:build !windows && !(darwin && cgo)
+// +build !windows
+// +build !darwin !cgo
+
+package inotify
+
+func (d *daemon) startDefault() error {
+	return d.startMonitoring()
+}
@@ -0,0 +1,123 @@
+package inotify
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// startDefault watches every path with a single recursive ReadDirectoryChangesW handle,
+// instead of a watch per directory.
+func (d *daemon) startDefault() error {
+	for _, p := range d.paths {
+		handle, err := openDirectory(p.Path)
+		if err != nil {
+			return fmt.Errorf("watch directory: %v: %w", p.Path, err)
+		}
+
+		d.log.Trace().
+			Str("path", p.Path).
+			Msg("Watching directory tree")
+
+		go d.readDirectoryChanges(handle, p.Path)
+	}
+
+	// poller events are still processed by the worker
+	go d.worker()
+
+	return nil
+}
+
+func openDirectory(path string) (syscall.Handle, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+
+	return syscall.CreateFile(p,
+		syscall.FILE_LIST_DIRECTORY,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS,
+		0)
+}
+
+const changeMask = syscall.FILE_NOTIFY_CHANGE_FILE_NAME |
+	syscall.FILE_NOTIFY_CHANGE_DIR_NAME |
+	syscall.FILE_NOTIFY_CHANGE_SIZE |
+	syscall.FILE_NOTIFY_CHANGE_LAST_WRITE
+
+func (d *daemon) readDirectoryChanges(handle syscall.Handle, root string) {
+	defer syscall.CloseHandle(handle)
+
+	// stopping the daemon cancels the pending read
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-d.stop:
+			_ = syscall.CancelIoEx(handle, nil)
+		case <-done:
+		}
+	}()
+
+	buf := make([]byte, 64*1024)
+
+	for {
+		var n uint32
+		err := syscall.ReadDirectoryChanges(handle, &buf[0], uint32(len(buf)), true, changeMask, &n, nil, 0)
+		if err != nil {
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+
+			d.log.Error().
+				Err(err).
+				Str("path", root).
+				Msg("Failed receiving filesystem events, stopped watching")
+			return
+		}
+
+		// buffer overflowed, changes have been lost
+		if n == 0 {
+			d.log.Warn().
+				Str("path", root).
+				Msg("Filesystem event buffer overflowed, events have been lost")
+			continue
+		}
+
+		for offset := uint32(0); ; {
+			info := (*syscall.FileNotifyInformation)(unsafe.Pointer(&buf[offset]))
+			name := syscall.UTF16ToString((*[32768]uint16)(unsafe.Pointer(&info.FileName))[:info.FileNameLength/2])
+
+			if op, ok := changeOps[info.Action]; ok {
+				select {
+				case d.events <- fsnotify.Event{Name: filepath.Join(root, name), Op: op}:
+				case <-d.stop:
+					return
+				}
+			}
+
+			if info.NextEntryOffset == 0 {
+				break
+			}
+
+			offset += info.NextEntryOffset
+		}
+	}
+}
+
+var changeOps = map[uint32]fsnotify.Op{
+	syscall.FILE_ACTION_ADDED:            fsnotify.Create,
+	syscall.FILE_ACTION_REMOVED:          fsnotify.Remove,
+	syscall.FILE_ACTION_MODIFIED:         fsnotify.Write,
+	syscall.FILE_ACTION_RENAMED_OLD_NAME: fsnotify.Rename,
+	syscall.FILE_ACTION_RENAMED_NEW_NAME: fsnotify.Create,
+}
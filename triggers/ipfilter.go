@@ -0,0 +1,46 @@
+package triggers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// IPFilter filters requests by their source IP with addresses and CIDR ranges.
+// Denied addresses are rejected, and only allowed addresses are accepted when addresses are allowed.
+type IPFilter struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// WithIPFilter rejects the requests of filtered source IPs with 403 Forbidden.
+// The source IP is that of the client of a trusted proxy.
+func WithIPFilter(f IPFilter) (func(http.Handler) http.Handler, error) {
+	allow, err := ParseNetworks(f.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+
+	deny, err := ParseNetworks(f.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(allow) == 0 && len(deny) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ip := SourceIP(r)
+			if contains(deny, ip) || (len(allow) > 0 && !contains(allow, ip)) {
+				hlog.FromRequest(r).Warn().Msg("Source IP not allowed")
+				rw.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}, nil
+}
@@ -0,0 +1,96 @@
+package triggers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	type Test struct {
+		Name     string
+		Filter   IPFilter
+		Proxies  []string
+		IP       string
+		Header   string
+		WantCode int
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Allowed range",
+			Filter:   IPFilter{Allow: []string{"172.17.0.0/16"}},
+			IP:       "172.17.0.5",
+			WantCode: 200,
+		},
+		{
+			Name:     "Outside the allowed ranges",
+			Filter:   IPFilter{Allow: []string{"172.17.0.0/16", "10.0.0.1"}},
+			IP:       "10.0.0.2",
+			WantCode: 403,
+		},
+		{
+			Name:     "Denied address within an allowed range",
+			Filter:   IPFilter{Allow: []string{"172.17.0.0/16"}, Deny: []string{"172.17.0.66"}},
+			IP:       "172.17.0.66",
+			WantCode: 403,
+		},
+		{
+			Name:     "Not denied without allowed ranges",
+			Filter:   IPFilter{Deny: []string{"172.17.0.66"}},
+			IP:       "172.17.0.5",
+			WantCode: 200,
+		},
+		{
+			Name:     "Client of a trusted proxy",
+			Filter:   IPFilter{Allow: []string{"172.17.0.5"}},
+			Proxies:  []string{"10.0.0.1"},
+			IP:       "10.0.0.1",
+			Header:   "172.17.0.5",
+			WantCode: 200,
+		},
+		{
+			Name:     "Client of an untrusted proxy",
+			Filter:   IPFilter{Allow: []string{"172.17.0.5"}},
+			IP:       "10.0.0.1",
+			Header:   "172.17.0.5",
+			WantCode: 403,
+		},
+		{
+			Name:     "No filter",
+			IP:       "10.0.0.1",
+			WantCode: 200,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			filter, err := WithIPFilter(tc.Filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			proxies, err := ParseNetworks(tc.Proxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler := WithTrustedProxies(proxies)(filter(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest("POST", "/triggers/sonarr", nil)
+			req.RemoteAddr = tc.IP + ":51234"
+			if tc.Header != "" {
+				req.Header.Set("X-Forwarded-For", tc.Header)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.WantCode {
+				t.Errorf("Status codes do not match: %d vs %d", rr.Code, tc.WantCode)
+			}
+		})
+	}
+}
@@ -2,20 +2,34 @@ package lidarr
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/triggers"
 	"github.com/rs/zerolog/hlog"
 )
 
 type Config struct {
-	Name      string             `yaml:"name"`
-	Priority  int                `yaml:"priority"`
-	Rewrite   []autoscan.Rewrite `yaml:"rewrite"`
-	Verbosity string             `yaml:"verbosity"`
+	Name        string             `yaml:"name"`
+	Priority    int                `yaml:"priority"`
+	Priorities  map[string]int     `yaml:"priorities"`
+	Rewrite     []autoscan.Rewrite `yaml:"rewrite"`
+	Events      []string           `yaml:"events"`
+	Strict      bool               `yaml:"strict"`
+	CheckExists bool               `yaml:"check-exists"`
+	Verbosity   string             `yaml:"verbosity"`
+
+	// APIKey authenticates the requests to the trigger, next to the authentication of autoscan
+	APIKey     string `yaml:"api-key"`
+	APIKeyFile string `yaml:"api-key-file"`
+
+	// Signature verifies the HMAC signature of the request bodies
+	Signature triggers.Signature `yaml:"signature"`
 }
 
 // New creates an autoscan-compatible HTTP Trigger for Lidarr webhooks.
@@ -25,11 +39,24 @@ func New(c Config) (autoscan.HTTPTrigger, error) {
 		return nil, err
 	}
 
+	events, err := triggers.NewEventFilter(c.Events, eventTypes...)
+	if err != nil {
+		return nil, err
+	}
+
+	priorities, err := triggers.NewPriorities(c.Priority, c.Priorities, eventTypes...)
+	if err != nil {
+		return nil, err
+	}
+
 	trigger := func(callback autoscan.ProcessorFunc) http.Handler {
 		return handler{
 			callback: callback,
-			priority: c.Priority,
+			priority: priorities,
 			rewrite:  rewriter,
+			events:   events,
+			strict:   c.Strict,
+			exists:   c.CheckExists,
 		}
 	}
 
@@ -37,9 +64,12 @@ func New(c Config) (autoscan.HTTPTrigger, error) {
 }
 
 type handler struct {
-	priority int
+	priority func(kind string) int
 	rewrite  autoscan.Rewriter
 	callback autoscan.ProcessorFunc
+	events   map[string]bool
+	strict   bool
+	exists   bool
 }
 
 type lidarrEvent struct {
@@ -49,6 +79,52 @@ type lidarrEvent struct {
 	Files []struct {
 		Path string
 	} `json:"trackFiles"`
+
+	File struct {
+		Path string
+	} `json:"trackFile"`
+
+	Artist struct {
+		Path string
+	} `json:"artist"`
+
+	RenamedFiles []struct {
+		Path         string
+		PreviousPath string
+	} `json:"renamedTrackFiles"`
+}
+
+// event types which can be enabled with the events option
+const (
+	eventDownload = "download"
+	eventUpgrade  = "upgrade"
+	eventRename   = "rename"
+	eventRetag    = "retag"
+)
+
+// kind returns the event type of the event, or an empty string for unsupported events.
+func (event *lidarrEvent) kind() string {
+	switch {
+	case strings.EqualFold(event.Type, "Download") && event.Upgrade:
+		return eventUpgrade
+	case strings.EqualFold(event.Type, "Download"):
+		return eventDownload
+	case strings.EqualFold(event.Type, "Rename"):
+		return eventRename
+	case strings.EqualFold(event.Type, "Retag"):
+		return eventRetag
+	default:
+		return ""
+	}
+}
+
+// eventTypes are the event types of the trigger
+var eventTypes = []string{eventDownload, eventUpgrade, eventRename, eventRetag}
+
+// enabled returns whether the event type is enabled.
+// All event types are enabled when no events were configured.
+func (h handler) enabled(kind string) bool {
+	return len(h.events) == 0 || h.events[kind]
 }
 
 func (h handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
@@ -57,7 +133,16 @@ func (h handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	event := new(lidarrEvent)
 	err = json.NewDecoder(r.Body).Decode(event)
-	if err != nil {
+	typeErr := new(json.UnmarshalTypeError)
+	switch {
+	case errors.As(err, &typeErr) && !h.strict:
+		// the decoder skips fields of an unexpected type
+		l.Warn().Err(err).Str("field", typeErr.Field).Msg("Ignoring field of unexpected type")
+	case errors.As(err, &typeErr):
+		l.Error().Err(err).Str("field", typeErr.Field).Msg("Invalid field")
+		http.Error(rw, "invalid field: "+typeErr.Field, http.StatusBadRequest)
+		return
+	case err != nil:
 		l.Error().Err(err).Msg("Failed decoding request")
 		rw.WriteHeader(http.StatusBadRequest)
 		return
@@ -71,41 +156,87 @@ func (h handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !strings.EqualFold(event.Type, "Download") || len(event.Files) == 0 {
-		l.Error().Msg("Required fields are missing")
-		rw.WriteHeader(http.StatusBadRequest)
+	if kind := event.kind(); kind != "" && !h.enabled(kind) {
+		l.Debug().Str("event", kind).Msg("Ignoring disabled event type")
+		rw.WriteHeader(http.StatusOK)
 		return
 	}
 
-	unique := make(map[string]bool)
-	scans := make([]autoscan.Scan, 0)
+	scans := triggers.NewScanList(h.priority(event.kind()), h.exists, now())
+
+	switch {
+	case strings.EqualFold(event.Type, "Download"):
+		// upgrades replace the files of existing media
+		downloaded := autoscan.EventAdded
+		if event.Upgrade {
+			downloaded = autoscan.EventModified
+		}
+
+		if len(event.Files) == 0 {
+			triggers.MissingField(rw, l, "trackFiles", h.strict)
+			return
+		}
+
+		for _, f := range event.Files {
+			scans.Add(path.Dir(h.rewrite(f.Path)), downloaded)
+		}
+
+	case strings.EqualFold(event.Type, "Retag"):
+		if event.File.Path == "" {
+			triggers.MissingField(rw, l, "trackFile.path", h.strict)
+			return
+		}
+
+		scans.Add(path.Dir(h.rewrite(event.File.Path)), autoscan.EventModified)
 
-	for _, f := range event.Files {
-		folderPath := path.Dir(h.rewrite(f.Path))
-		if _, ok := unique[folderPath]; ok {
-			continue
+	case strings.EqualFold(event.Type, "Rename"):
+		if event.Artist.Path == "" {
+			triggers.MissingField(rw, l, "artist.path", h.strict)
+			return
 		}
 
-		// add scan
-		unique[folderPath] = true
-		scans = append(scans, autoscan.Scan{
-			Folder:   folderPath,
-			Priority: h.priority,
-			Time:     now(),
-		})
+		// older versions of Lidarr do not include the renamed files
+		if len(event.RenamedFiles) == 0 {
+			scans.Add(path.Clean(h.rewrite(event.Artist.Path)), autoscan.EventModified)
+			break
+		}
+
+		for _, f := range event.RenamedFiles {
+			if f.PreviousPath != "" {
+				scans.Add(path.Dir(h.rewrite(f.PreviousPath)), autoscan.EventRemoved)
+			}
+
+			scans.Add(path.Dir(h.rewrite(f.Path)), autoscan.EventAdded)
+		}
+
+	default:
+		l.Error().Str("event", event.Type).Msg("Unsupported event type")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
-	err = h.callback(scans...)
-	if err != nil {
+	err = h.callback(scans.Scans()...)
+	switch {
+	case errors.Is(err, autoscan.ErrQueueFull):
+		l.Warn().Err(err).Msg("Processor queue is full, rejecting scans")
+		rw.Header().Set("Retry-After", strconv.Itoa(int(autoscan.QueueFullRetry.Seconds())))
+		rw.WriteHeader(http.StatusTooManyRequests)
+		return
+	case err != nil:
 		l.Error().Err(err).Msg("Processor could not process scans")
 		rw.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	triggers.RecordScans(r, len(scans.Scans()))
+
 	rw.WriteHeader(http.StatusOK)
-	l.Info().
-		Str("path", scans[0].Folder).
-		Msg("Scan moved to processor")
+	for _, scan := range scans.Scans() {
+		l.Info().
+			Str("path", scan.Folder).
+			Str("event", string(scan.Event)).
+			Msg("Scan moved to processor")
+	}
 }
 
 var now = time.Now
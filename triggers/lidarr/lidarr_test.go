@@ -55,6 +55,7 @@ func TestHandler(t *testing.T) {
 				Scans: []autoscan.Scan{{
 					Folder:   "/mnt/unionfs/Media/Music/Marshmello/Joytime III (2019)",
 					Priority: 5,
+					Event:    autoscan.EventAdded,
 					Time:     currentTime,
 				}},
 			},
@@ -71,15 +72,126 @@ func TestHandler(t *testing.T) {
 					{
 						Folder:   "/mnt/unionfs/Media/Music/blink‐182/California (2016)/CD 01",
 						Priority: 5,
+						Event:    autoscan.EventAdded,
 						Time:     currentTime,
 					},
 					{
 						Folder:   "/mnt/unionfs/Media/Music/blink‐182/California (2016)/CD 02",
 						Priority: 5,
+						Event:    autoscan.EventAdded,
 						Time:     currentTime,
 					}},
 			},
 		},
+		{
+			"Returns scan of the album folder on Retag",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/retag.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{{
+					Folder:   "/mnt/unionfs/Media/Music/Marshmello/Joytime III (2019)",
+					Priority: 5,
+					Event:    autoscan.EventModified,
+					Time:     currentTime,
+				}},
+			},
+		},
+		{
+			"Returns removal of the previous folder and scan of the new folder on Rename",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/rename.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Music/Marshmello/Joytime III",
+						Priority: 5,
+						Event:    autoscan.EventRemoved,
+						Time:     currentTime,
+					},
+					{
+						Folder:   "/mnt/unionfs/Media/Music/Marshmello/Joytime III (2019)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns scan of the artist folder on Rename without renamed files",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/rename_artist.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{{
+					Folder:   "/mnt/unionfs/Media/Music/Marshmello",
+					Priority: 5,
+					Event:    autoscan.EventModified,
+					Time:     currentTime,
+				}},
+			},
+		},
+		{
+			"Returns bad request on unsupported event type",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/unsupported.json",
+			},
+			Expected{
+				StatusCode: 400,
+			},
+		},
+		{
+			"Returns 200 on disabled Retag event without emitting a scan",
+			Given{
+				Config: Config{
+					Name:     "lidarr",
+					Priority: 5,
+					Events:   []string{"download", "upgrade"},
+				},
+				Fixture: "testdata/retag.json",
+			},
+			Expected{
+				StatusCode: 200,
+			},
+		},
+		{
+			"Uses the priority of the event type",
+			Given{
+				Config: Config{
+					Name:     "lidarr",
+					Priority: 5,
+					Priorities: map[string]int{
+						"download": 3,
+						"rename":   1,
+					},
+					Rewrite: []autoscan.Rewrite{{
+						From: "/Music/*",
+						To:   "/mnt/unionfs/Media/Music/$1",
+					}},
+				},
+				Fixture: "testdata/rename_artist.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Music/Marshmello",
+						Priority: 1,
+						Event:    autoscan.EventModified,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
 		{
 			"Returns bad request on invalid JSON",
 			Given{
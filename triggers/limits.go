@@ -0,0 +1,229 @@
+package triggers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// DefaultMaxBodySize is the maximum size of the body of a request in bytes,
+// which is far beyond the size of the payloads of the webhooks.
+const DefaultMaxBodySize = 1 << 20
+
+// Limits limits the size of the body of the requests to a route,
+// the time in which clients must send the body and the time in which the route must handle the request.
+// The maximum body size defaults to DefaultMaxBodySize,
+// the body and the handler are not timed out without their timeout.
+type Limits struct {
+	MaxBodySize    int64         `yaml:"max-body-size"`
+	Timeout        time.Duration `yaml:"timeout"`
+	HandlerTimeout time.Duration `yaml:"handler-timeout"`
+}
+
+func (l Limits) maxBodySize() int64 {
+	if l.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
+	}
+
+	return l.MaxBodySize
+}
+
+var (
+	errBodyTooLarge = errors.New("request body too large")
+	errBodyTimeout  = errors.New("request body timed out")
+)
+
+// WithLimits reads the body of the request before passing it on,
+// and rejects bodies beyond the maximum size with 413 Request Entity Too Large,
+// and bodies which are not received within the timeout with 408 Request Timeout.
+// Requests which are not handled within the handler timeout are answered with 408 Request Timeout as well.
+func WithLimits(limits Limits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limits.HandlerTimeout > 0 {
+			next = withHandlerTimeout(next, limits.HandlerTimeout)
+		}
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			l := hlog.FromRequest(r)
+
+			body, err := readBody(r, limits)
+			switch {
+			case errors.Is(err, errBodyTooLarge):
+				l.Warn().
+					Int64("max_body_size", limits.maxBodySize()).
+					Msg("Request body too large")
+
+				rw.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			case errors.Is(err, errBodyTimeout):
+				l.Warn().
+					Stringer("timeout", limits.Timeout).
+					Msg("Request body timed out")
+
+				// the connection cannot be reused, as the body has not been read
+				rw.Header().Set("Connection", "close")
+				rw.WriteHeader(http.StatusRequestTimeout)
+				return
+			case err != nil:
+				l.Error().
+					Err(err).
+					Msg("Failed reading request body")
+
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// readBody reads the body of the request up to the maximum size within the timeout.
+func readBody(r *http.Request, limits Limits) ([]byte, error) {
+	max := limits.maxBodySize()
+	if r.ContentLength > max {
+		return nil, errBodyTooLarge
+	}
+
+	read := func() ([]byte, error) {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(len(body)) > max {
+			return nil, errBodyTooLarge
+		}
+
+		return body, nil
+	}
+
+	if limits.Timeout <= 0 {
+		return read()
+	}
+
+	type result struct {
+		body []byte
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		body, err := read()
+		done <- result{body, err}
+	}()
+
+	timer := time.NewTimer(limits.Timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		return res.body, res.err
+	case <-timer.C:
+		// closing the body stops the reader at its next read,
+		// the close itself may wait for a pending read of the server.
+		go r.Body.Close()
+		return nil, errBodyTimeout
+	}
+}
+
+// withHandlerTimeout answers the requests which are not handled within the timeout with 408 Request Timeout.
+// The context of the request is cancelled at the timeout, the response of the handler is discarded after it.
+func withHandlerTimeout(next http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.mtx.Lock()
+			defer tw.mtx.Unlock()
+
+			for k, v := range tw.header {
+				rw.Header()[k] = v
+			}
+
+			if tw.code == 0 {
+				tw.code = http.StatusOK
+			}
+
+			rw.WriteHeader(tw.code)
+			rw.Write(tw.body.Bytes())
+		case <-ctx.Done():
+			tw.mtx.Lock()
+			defer tw.mtx.Unlock()
+
+			tw.timedOut = true
+
+			hlog.FromRequest(r).Warn().
+				Stringer("timeout", timeout).
+				Msg("Request handler timed out")
+
+			rw.WriteHeader(http.StatusRequestTimeout)
+		}
+	})
+}
+
+// timeoutWriter buffers the response of a handler until it is handled within the timeout.
+type timeoutWriter struct {
+	mtx      sync.Mutex
+	header   http.Header
+	code     int
+	body     bytes.Buffer
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	return w.body.Write(b)
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.timedOut || w.code != 0 {
+		return
+	}
+
+	w.code = code
+}
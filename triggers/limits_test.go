@@ -0,0 +1,165 @@
+package triggers
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowReader sends its body after the delay.
+type slowReader struct {
+	delay time.Duration
+	body  io.Reader
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	return r.body.Read(p)
+}
+
+// blockingBody blocks its reads until it is closed.
+type blockingBody struct {
+	closed chan struct{}
+}
+
+func (b *blockingBody) Read(p []byte) (int, error) {
+	<-b.closed
+	return 0, io.ErrClosedPipe
+}
+
+func (b *blockingBody) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestLimits(t *testing.T) {
+	type Test struct {
+		Name     string
+		Limits   Limits
+		Body     io.Reader
+		Delay    time.Duration
+		WantCode int
+		WantBody string
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Passes on the body",
+			Limits:   Limits{MaxBodySize: 16},
+			Body:     strings.NewReader(`{"path":"/tv"}`),
+			WantCode: 200,
+			WantBody: `{"path":"/tv"}`,
+		},
+		{
+			Name:     "Rejects bodies beyond the maximum size",
+			Limits:   Limits{MaxBodySize: 8},
+			Body:     strings.NewReader(`{"path":"/tv"}`),
+			WantCode: 413,
+		},
+		{
+			Name:     "Rejects bodies of unknown length beyond the maximum size",
+			Limits:   Limits{MaxBodySize: 8},
+			Body:     &slowReader{body: strings.NewReader(`{"path":"/tv"}`)},
+			WantCode: 413,
+		},
+		{
+			Name:     "Maximum size defaults to DefaultMaxBodySize",
+			Body:     strings.NewReader(strings.Repeat("a", DefaultMaxBodySize+1)),
+			WantCode: 413,
+		},
+		{
+			Name:     "Rejects bodies which are not received within the timeout",
+			Limits:   Limits{Timeout: 10 * time.Millisecond},
+			Body:     &slowReader{delay: 100 * time.Millisecond, body: strings.NewReader(`{}`)},
+			WantCode: 408,
+		},
+		{
+			Name:     "Passes on the body received within the timeout",
+			Limits:   Limits{Timeout: time.Second},
+			Body:     &slowReader{delay: time.Millisecond, body: strings.NewReader(`{}`)},
+			WantCode: 200,
+			WantBody: `{}`,
+		},
+		{
+			Name:     "Answers requests which are not handled within the handler timeout",
+			Limits:   Limits{HandlerTimeout: 10 * time.Millisecond},
+			Body:     strings.NewReader(`{}`),
+			Delay:    time.Second,
+			WantCode: 408,
+		},
+		{
+			Name:     "Passes on the response handled within the handler timeout",
+			Limits:   Limits{HandlerTimeout: time.Second},
+			Body:     strings.NewReader(`{}`),
+			Delay:    time.Millisecond,
+			WantCode: 200,
+			WantBody: `{}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			delay := tc.Delay
+			body := make(chan string, 1)
+			handler := WithLimits(tc.Limits)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(delay):
+				case <-r.Context().Done():
+					return
+				}
+
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+				}
+
+				body <- string(b)
+			}))
+
+			req := httptest.NewRequest("POST", "/triggers/sonarr", tc.Body)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.WantCode {
+				t.Errorf("Status codes do not match: %d vs %d", rr.Code, tc.WantCode)
+			}
+
+			var got string
+			select {
+			case got = <-body:
+			default:
+			}
+
+			if got != tc.WantBody {
+				t.Errorf("Bodies do not match: %q vs %q", got, tc.WantBody)
+			}
+		})
+	}
+}
+
+func TestLimitsClosesBody(t *testing.T) {
+	handler := WithLimits(Limits{Timeout: 10 * time.Millisecond})(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		t.Error("Handler called after the body timed out")
+	}))
+
+	body := &blockingBody{closed: make(chan struct{})}
+	req := httptest.NewRequest("POST", "/triggers/sonarr", nil)
+	req.Body = body
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != 408 {
+		t.Errorf("Status codes do not match: %d vs %d", rr.Code, 408)
+	}
+
+	select {
+	case <-body.closed:
+	case <-time.After(time.Second):
+		t.Error("Expected the body to be closed after the timeout")
+	}
+}
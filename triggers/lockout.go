@@ -0,0 +1,203 @@
+package triggers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// Lockout throttles the source IPs which fail to authenticate.
+// Every failure is answered after a delay, which doubles with every failure up to the maximum delay,
+// and a source IP is banned once it failed the maximum number of times.
+// The failures of a source IP are forgotten once it has not failed for the duration of the ban.
+// A lockout without maximum failures does not throttle.
+type Lockout struct {
+	MaxFailures int           `yaml:"max-failures"`
+	Delay       time.Duration `yaml:"delay"`
+	MaxDelay    time.Duration `yaml:"max-delay"`
+	Ban         time.Duration `yaml:"ban"`
+}
+
+func (l Lockout) enabled() bool {
+	return l.MaxFailures > 0
+}
+
+// delay returns the delay of the response to the failure.
+func (l Lockout) delay(failures int) time.Duration {
+	if l.Delay <= 0 {
+		return 0
+	}
+
+	delay := float64(l.Delay) * math.Pow(2, float64(failures-1))
+	if l.MaxDelay > 0 && delay > float64(l.MaxDelay) {
+		return l.MaxDelay
+	}
+
+	return time.Duration(delay)
+}
+
+// LockoutState holds the failures and bans of the source IPs,
+// such that they are kept when the lockout is replaced, e.g. when the config is reloaded.
+type LockoutState struct {
+	mtx sync.Mutex
+	ips map[string]*ipFailures
+}
+
+// NewLockoutState creates the state of a lockout without failures.
+func NewLockoutState() *LockoutState {
+	return &LockoutState{ips: make(map[string]*ipFailures)}
+}
+
+// WithLockout delays the responses to failed authentication, which is any 401 Unauthorized,
+// and rejects the requests of banned source IPs with 429 Too Many Requests.
+// Every handler wrapped by the middleware shares the failures of the state, such that they count for every route.
+func WithLockout(l Lockout, state *LockoutState) func(http.Handler) http.Handler {
+	lo := &lockout{Lockout: l, LockoutState: state}
+
+	return func(next http.Handler) http.Handler {
+		if !l.enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ip := SourceIP(r)
+
+			if until := lo.banned(ip); until > 0 {
+				hlog.FromRequest(r).Warn().
+					Dur("retry_after", until).
+					Msg("Source IP banned")
+
+				rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(until.Seconds()))))
+				rw.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(&lockoutWriter{ResponseWriter: rw, r: r, lockout: lo, ip: ip}, r)
+		})
+	}
+}
+
+type ipFailures struct {
+	count  int
+	last   time.Time
+	banned time.Time
+}
+
+type lockout struct {
+	Lockout
+	*LockoutState
+}
+
+// banned returns how long the source IP remains banned.
+func (lo *lockout) banned(ip string) time.Duration {
+	lo.mtx.Lock()
+	defer lo.mtx.Unlock()
+
+	f, ok := lo.ips[ip]
+	if !ok {
+		return 0
+	}
+
+	return f.banned.Sub(now())
+}
+
+// fail records a failure of the source IP, and returns the delay of its response
+// and whether the source IP is banned by the failure.
+func (lo *lockout) fail(ip string) (failures int, delay time.Duration, banned bool) {
+	lo.mtx.Lock()
+	defer lo.mtx.Unlock()
+
+	t := now()
+	lo.prune(t)
+
+	f, ok := lo.ips[ip]
+	if !ok {
+		f = &ipFailures{}
+		lo.ips[ip] = f
+	}
+
+	f.count++
+	f.last = t
+
+	if f.count >= lo.MaxFailures {
+		f.count = 0
+		f.banned = t.Add(lo.Ban)
+		return lo.MaxFailures, 0, true
+	}
+
+	return f.count, lo.delay(f.count), false
+}
+
+// prune forgets the source IPs which have not failed for the duration of the ban.
+func (lo *lockout) prune(t time.Time) {
+	for ip, f := range lo.ips {
+		if t.Sub(f.last) > lo.Ban && t.After(f.banned) {
+			delete(lo.ips, ip)
+		}
+	}
+}
+
+// lockoutWriter records the failures of the source IP, and delays their responses.
+type lockoutWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	lockout *lockout
+	ip      string
+	wrote   bool
+}
+
+func (w *lockoutWriter) WriteHeader(status int) {
+	if w.wrote {
+		return
+	}
+
+	w.wrote = true
+	if status == http.StatusUnauthorized {
+		w.fail()
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *lockoutWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush allows the events to be streamed.
+func (w *lockoutWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *lockoutWriter) fail() {
+	l := hlog.FromRequest(w.r)
+
+	failures, delay, banned := w.lockout.fail(w.ip)
+	if banned {
+		l.Warn().
+			Str("event", "lockout").
+			Int("failures", failures).
+			Dur("ban", w.lockout.Ban).
+			Msg("Source IP banned after failed authentication")
+		return
+	}
+
+	l.Debug().
+		Int("failures", failures).
+		Dur("delay", delay).
+		Msg("Delaying failed authentication")
+
+	select {
+	case <-time.After(delay):
+	case <-w.r.Context().Done():
+	}
+}
@@ -0,0 +1,187 @@
+package triggers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	type Request struct {
+		IP        string
+		Valid     bool
+		After     time.Duration
+		WantCode  int
+		WantRetry string
+	}
+
+	type Test struct {
+		Name     string
+		Lockout  Lockout
+		Requests []Request
+	}
+
+	var testCases = []Test{
+		{
+			Name:    "Bans after the maximum failures",
+			Lockout: Lockout{MaxFailures: 3, Ban: time.Minute},
+			Requests: []Request{
+				{IP: "10.0.0.1", WantCode: 401},
+				{IP: "10.0.0.1", WantCode: 401},
+				{IP: "10.0.0.1", WantCode: 401},
+				{IP: "10.0.0.1", Valid: true, WantCode: 429, WantRetry: "60"},
+				{IP: "10.0.0.1", Valid: true, After: 30 * time.Second, WantCode: 429, WantRetry: "30"},
+				{IP: "10.0.0.2", Valid: true, WantCode: 200},
+				{IP: "10.0.0.1", Valid: true, After: 30 * time.Second, WantCode: 200},
+			},
+		},
+		{
+			Name:    "Failures are forgotten after the ban",
+			Lockout: Lockout{MaxFailures: 3, Ban: time.Minute},
+			Requests: []Request{
+				{IP: "10.0.0.1", WantCode: 401},
+				{IP: "10.0.0.1", WantCode: 401},
+				{IP: "10.0.0.1", After: 2 * time.Minute, WantCode: 401},
+				{IP: "10.0.0.1", WantCode: 401},
+				{IP: "10.0.0.1", Valid: true, WantCode: 200},
+			},
+		},
+		{
+			Name:    "Successful requests do not reset the failures",
+			Lockout: Lockout{MaxFailures: 2, Ban: time.Minute},
+			Requests: []Request{
+				{IP: "10.0.0.1", WantCode: 401},
+				{IP: "10.0.0.1", Valid: true, WantCode: 200},
+				{IP: "10.0.0.1", WantCode: 401},
+				{IP: "10.0.0.1", Valid: true, WantCode: 429, WantRetry: "60"},
+			},
+		},
+		{
+			Name: "Does not ban without maximum failures",
+			Requests: []Request{
+				{IP: "10.0.0.1", WantCode: 401},
+				{IP: "10.0.0.1", WantCode: 401},
+				{IP: "10.0.0.1", Valid: true, WantCode: 200},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testTime := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+			now = func() time.Time {
+				return testTime
+			}
+
+			handler := WithLockout(tc.Lockout, NewLockoutState())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(APIKeyHeader) != "secret" {
+					rw.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				rw.WriteHeader(http.StatusOK)
+			}))
+
+			for i, req := range tc.Requests {
+				testTime = testTime.Add(req.After)
+
+				r := httptest.NewRequest("POST", "/triggers/sonarr", nil)
+				r.RemoteAddr = req.IP + ":51234"
+				if req.Valid {
+					r.Header.Set(APIKeyHeader, "secret")
+				}
+
+				rw := httptest.NewRecorder()
+				handler.ServeHTTP(rw, r)
+
+				if rw.Code != req.WantCode {
+					t.Errorf("Request %d: status codes do not match: %d vs %d", i, rw.Code, req.WantCode)
+				}
+
+				if retry := rw.Header().Get("Retry-After"); retry != req.WantRetry {
+					t.Errorf("Request %d: Retry-After does not match: %q vs %q", i, retry, req.WantRetry)
+				}
+			}
+		})
+	}
+}
+
+func TestLockoutDelay(t *testing.T) {
+	type Test struct {
+		Name     string
+		Lockout  Lockout
+		Failures int
+		Want     time.Duration
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "First failure",
+			Lockout:  Lockout{Delay: time.Second},
+			Failures: 1,
+			Want:     time.Second,
+		},
+		{
+			Name:     "Doubles with every failure",
+			Lockout:  Lockout{Delay: time.Second},
+			Failures: 4,
+			Want:     8 * time.Second,
+		},
+		{
+			Name:     "Maximum delay",
+			Lockout:  Lockout{Delay: time.Second, MaxDelay: 5 * time.Second},
+			Failures: 4,
+			Want:     5 * time.Second,
+		},
+		{
+			Name:     "No delay",
+			Failures: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			if delay := tc.Lockout.delay(tc.Failures); delay != tc.Want {
+				t.Errorf("Delays do not match: %v vs %v", delay, tc.Want)
+			}
+		})
+	}
+}
+
+func TestLockoutState(t *testing.T) {
+	testTime := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		return testTime
+	}
+
+	unauthorized := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusUnauthorized)
+	})
+
+	serve := func(handler http.Handler) int {
+		r := httptest.NewRequest("POST", "/triggers/sonarr", nil)
+		r.RemoteAddr = "10.0.0.1:51234"
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		return rw.Code
+	}
+
+	// the ban of the lockout is kept by the lockout which replaces it, as on a reload
+	state := NewLockoutState()
+	handler := WithLockout(Lockout{MaxFailures: 2, Ban: time.Minute}, state)(unauthorized)
+	serve(handler)
+	serve(handler)
+
+	reloaded := WithLockout(Lockout{MaxFailures: 5, Ban: time.Minute}, state)(unauthorized)
+	if code := serve(reloaded); code != http.StatusTooManyRequests {
+		t.Errorf("Status codes do not match: %d vs %d", code, http.StatusTooManyRequests)
+	}
+
+	// another state starts without failures
+	other := WithLockout(Lockout{MaxFailures: 5, Ban: time.Minute}, NewLockoutState())(unauthorized)
+	if code := serve(other); code != http.StatusUnauthorized {
+		t.Errorf("Status codes do not match: %d vs %d", code, http.StatusUnauthorized)
+	}
+}
@@ -1,20 +1,49 @@
 package manual
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/triggers"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 )
 
 type Config struct {
-	Rewrite   []autoscan.Rewrite `yaml:"rewrite"`
-	Priority  int                `yaml:"priority"`
-	Verbosity string             `yaml:"verbosity"`
+	Rewrite     []autoscan.Rewrite `yaml:"rewrite"`
+	Priority    int                `yaml:"priority"`
+	GlobRoots   []string           `yaml:"glob-roots"`
+	MaxPaths    int                `yaml:"max-paths"`
+	CheckExists bool               `yaml:"check-exists"`
+	Verbosity   string             `yaml:"verbosity"`
+
+	// APIKey authenticates the requests to the trigger, next to the authentication of autoscan
+	APIKey     string `yaml:"api-key"`
+	APIKeyFile string `yaml:"api-key-file"`
+
+	// Signature verifies the HMAC signature of the request bodies
+	Signature triggers.Signature `yaml:"signature"`
+
+	// BasePath prefixes the status URL of the response
+	BasePath string `yaml:"-"`
 }
 
+// defaultMaxPaths limits the number of paths of a newline-delimited request.
+const defaultMaxPaths = 10000
+
+var errTooManyPaths = errors.New("too many paths")
+
 // New creates an autoscan-compatible HTTP Trigger for manual webhooks.
 func New(c Config) (autoscan.HTTPTrigger, error) {
 	rewriter, err := autoscan.NewRewriter(c.Rewrite)
@@ -22,11 +51,19 @@ func New(c Config) (autoscan.HTTPTrigger, error) {
 		return nil, err
 	}
 
+	if c.MaxPaths == 0 {
+		c.MaxPaths = defaultMaxPaths
+	}
+
 	trigger := func(callback autoscan.ProcessorFunc) http.Handler {
 		return handler{
-			callback: callback,
-			priority: c.Priority,
-			rewrite:  rewriter,
+			callback:  callback,
+			priority:  c.Priority,
+			rewrite:   rewriter,
+			globRoots: cleanRoots(c.GlobRoots),
+			maxPaths:  c.MaxPaths,
+			exists:    c.CheckExists,
+			basePath:  c.BasePath,
 		}
 	}
 
@@ -34,21 +71,182 @@ func New(c Config) (autoscan.HTTPTrigger, error) {
 }
 
 type handler struct {
-	priority int
-	rewrite  autoscan.Rewriter
-	callback autoscan.ProcessorFunc
+	priority  int
+	rewrite   autoscan.Rewriter
+	globRoots []string
+	maxPaths  int
+	exists    bool
+	basePath  string
+	callback  autoscan.ProcessorFunc
+}
+
+func cleanRoots(roots []string) []string {
+	cleaned := make([]string, 0, len(roots))
+	for _, root := range roots {
+		cleaned = append(cleaned, filepath.Clean(root))
+	}
+
+	return cleaned
+}
+
+// expand expands a glob pattern to the matching directories on the autoscan host.
+// Patterns are only expanded when glob roots are configured,
+// otherwise the folder is returned as-is.
+func (h handler) expand(folder string) ([]string, error) {
+	if len(h.globRoots) == 0 || !strings.ContainsAny(folder, "*?[") {
+		return []string{folder}, nil
+	}
+
+	pattern := filepath.Clean(folder)
+	if !h.withinRoots(pattern) {
+		return nil, fmt.Errorf("glob is not within the glob roots: %s", folder)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob: %s: %w", folder, err)
+	}
+
+	dirs := make([]string, 0)
+	for _, match := range matches {
+		fi, err := os.Stat(match)
+		if err != nil || !fi.IsDir() {
+			continue
+		}
+
+		dirs = append(dirs, match)
+	}
+
+	return dirs, nil
+}
+
+func (h handler) withinRoots(pattern string) bool {
+	for _, root := range h.globRoots {
+		if strings.HasPrefix(pattern, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// request is the JSON body of a manual scan request.
+// Priority overrides the priority of the trigger when set.
+// Event describes the change of the paths, which defaults to added.
+// Rewrite overrides the rewrite rules of the trigger when set.
+type request struct {
+	Paths    []string          `json:"paths"`
+	Priority *int              `json:"priority"`
+	Targets  []string          `json:"targets"`
+	Event    string            `json:"event"`
+	Rewrite  *autoscan.Rewrite `json:"rewrite"`
+
+	event   autoscan.Event
+	rewrite autoscan.Rewriter
+	bulk    bool
+}
+
+// parseRequest parses the directories and options of the request
+// from either the JSON body or the query string.
+func (h handler) parseRequest(r *http.Request) (*request, error) {
+	req := new(request)
+
+	switch contentType := r.Header.Get("Content-Type"); {
+	case strings.HasPrefix(contentType, "application/json"):
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			return nil, fmt.Errorf("decoding JSON body: %w", err)
+		}
+
+	case strings.HasPrefix(contentType, "text/plain"):
+		// newline-delimited paths, e.g. piped from find
+		req.bulk = true
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			if len(req.Paths) == h.maxPaths {
+				return nil, fmt.Errorf("more than %d paths: %w", h.maxPaths, errTooManyPaths)
+			}
+
+			req.Paths = append(req.Paths, line)
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading body: %w", err)
+		}
+	}
+
+	query := r.URL.Query()
+	req.Paths = append(req.Paths, query["dir"]...)
+	req.Targets = append(req.Targets, query["target"]...)
+
+	if req.Priority == nil {
+		req.Priority = &h.priority
+	}
+
+	if t := query.Get("type"); t != "" {
+		req.Event = t
+	}
+
+	event, err := autoscan.ParseEvent(req.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	req.event = event
+
+	if from := query.Get("from"); from != "" {
+		req.Rewrite = &autoscan.Rewrite{From: from, To: query.Get("to")}
+	}
+
+	req.rewrite = h.rewrite
+	if req.Rewrite != nil {
+		rewriter, err := autoscan.NewRewriter([]autoscan.Rewrite{*req.Rewrite})
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite: %w", err)
+		}
+
+		req.rewrite = rewriter
+	}
+
+	return req, nil
+}
+
+// statusPath is the path of the scan status endpoint of the autoscan API.
+const statusPath = "/api/scans/status"
+
+type response struct {
+	Scans     []responseScan `json:"scans"`
+	StatusURL string         `json:"status_url"`
+}
+
+type responseScan struct {
+	ID     string `json:"id"`
+	Folder string `json:"folder"`
 }
 
 func (h handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	var err error
 	rlog := hlog.FromRequest(r)
 
-	query := r.URL.Query()
-	directories := query["dir"]
+	req, err := h.parseRequest(r)
+	switch {
+	case errors.Is(err, errTooManyPaths):
+		rlog.Error().Err(err).Msg("Request exceeds the maximum number of paths")
+		rw.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		rlog.Error().Err(err).Msg("Failed parsing request")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-	rlog.Trace().Interface("dirs", directories).Msg("Received directories")
+	rlog.Trace().Interface("dirs", req.Paths).Msg("Received directories")
 
-	if len(directories) == 0 {
+	if len(req.Paths) == 0 {
 		rlog.Error().Msg("Manual webhook should receive at least one directory")
 		rw.WriteHeader(http.StatusBadRequest)
 		return
@@ -56,30 +254,111 @@ func (h handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	scans := make([]autoscan.Scan, 0)
 
-	for _, dir := range directories {
-		// Rewrite the path based on the provided rewriter.
-		folderPath := h.rewrite(path.Clean(dir))
+	for _, dir := range req.Paths {
+		// Rewrite the path based on the rewriter of the request.
+		folderPath := req.rewrite(path.Clean(dir))
+
+		folders, err := h.expand(folderPath)
+		if err != nil {
+			rlog.Error().Err(err).Msg("Failed expanding glob")
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if len(folders) == 0 {
+			rlog.Warn().Str("glob", folderPath).Msg("Glob did not match any directories")
+		}
+
+		for _, folder := range folders {
+			scans = append(scans, autoscan.Scan{
+				Folder:      folder,
+				Priority:    *req.Priority,
+				Event:       req.event,
+				Targets:     req.Targets,
+				CheckExists: h.exists,
+				Time:        now(),
+			})
+		}
+	}
 
-		scans = append(scans, autoscan.Scan{
-			Folder:   folderPath,
-			Priority: h.priority,
-			Time:     now(),
-		})
+	if len(scans) == 0 {
+		rlog.Error().Msg("Manual webhook did not match any directories")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
 	err = h.callback(scans...)
-	if err != nil {
+	switch {
+	case errors.Is(err, autoscan.ErrQueueFull):
+		rlog.Warn().Err(err).Msg("Processor queue is full, rejecting scans")
+		rw.Header().Set("Retry-After", strconv.Itoa(int(autoscan.QueueFullRetry.Seconds())))
+		rw.WriteHeader(http.StatusTooManyRequests)
+		return
+	case err != nil:
 		rlog.Error().Err(err).Msg("Processor could not process scans")
 		rw.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	triggers.RecordScans(r, len(scans))
+
+	if req.bulk {
+		h.respondSummary(rw, rlog, req, scans)
+		return
+	}
+
+	// respond with the IDs of the scans and the URL at which their status can be polled
+	resp := response{Scans: make([]responseScan, 0, len(scans))}
+	ids := url.Values{}
+	for _, scan := range scans {
+		resp.Scans = append(resp.Scans, responseScan{ID: scan.ID(), Folder: scan.Folder})
+		ids.Add("id", scan.ID())
+	}
+
+	resp.StatusURL = h.basePath + statusPath + "?" + ids.Encode()
+
+	rw.Header().Set("Content-Type", "application/json")
 	rw.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+
 	for _, scan := range scans {
 		rlog.Info().
 			Str("path", scan.Folder).
+			Str("id", scan.ID()).
+			Str("event", string(scan.Event)).
+			Strs("targets", scan.Targets).
 			Msg("Scan moved to processor")
 	}
 }
 
+type summary struct {
+	Paths int `json:"paths"`
+	Scans int `json:"scans"`
+}
+
+// respondSummary responds to newline-delimited requests with a summary,
+// as listing every scan of a large backfill is of little use.
+func (h handler) respondSummary(rw http.ResponseWriter, rlog *zerolog.Logger, req *request, scans []autoscan.Scan) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(rw).Encode(summary{Paths: len(req.Paths), Scans: len(scans)}); err != nil {
+		rlog.Error().Err(err).Msg("Failed encoding response")
+	}
+
+	for _, scan := range scans {
+		rlog.Debug().
+			Str("path", scan.Folder).
+			Str("id", scan.ID()).
+			Str("event", string(scan.Event)).
+			Msg("Scan moved to processor")
+	}
+
+	rlog.Info().
+		Int("paths", len(req.Paths)).
+		Int("scans", len(scans)).
+		Msg("Scans moved to processor")
+}
+
 var now = time.Now
@@ -2,10 +2,14 @@ package manual
 
 import (
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,13 +18,16 @@ import (
 
 func TestHandler(t *testing.T) {
 	type Given struct {
-		Config Config
-		Query  url.Values
+		Config      Config
+		Query       url.Values
+		Body        string
+		ContentType string
 	}
 
 	type Expected struct {
 		Scans      []autoscan.Scan
 		StatusCode int
+		Body       string
 	}
 
 	type Test struct {
@@ -37,6 +44,24 @@ func TestHandler(t *testing.T) {
 		}},
 	}
 
+	// directories for glob expansion
+	root, err := ioutil.TempDir("", "autoscan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"Westworld/Season 01", "Westworld/Season 02", "Wednesday/Season 01", "Friends/Season 01"} {
+		if err := os.MkdirAll(filepath.Join(root, "TV", dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	globConfig := Config{
+		Priority:  5,
+		GlobRoots: []string{filepath.Join(root, "TV")},
+	}
+
 	currentTime := time.Now()
 	now = func() time.Time {
 		return currentTime
@@ -72,14 +97,300 @@ func TestHandler(t *testing.T) {
 					{
 						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
 						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Parasite (2019)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns 200 when given a JSON body with options",
+			Given{
+				Config: standardConfig,
+				Body: `{
+					"paths": ["/Movies/Interstellar (2014)", "/Movies/Parasite (2019)"],
+					"priority": 10,
+					"targets": ["plex"]
+				}`,
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 10,
+						Event:    autoscan.EventAdded,
+						Targets:  []string{"plex"},
 						Time:     currentTime,
 					},
 					{
 						Folder:   "/mnt/unionfs/Media/Movies/Parasite (2019)",
+						Priority: 10,
+						Event:    autoscan.EventAdded,
+						Targets:  []string{"plex"},
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns the scan IDs and status URL",
+			Given{
+				Config: standardConfig,
+				Query: url.Values{
+					"dir": []string{"/Movies/Interstellar (2014)"},
+				},
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
 						Priority: 5,
+						Event:    autoscan.EventAdded,
 						Time:     currentTime,
 					},
 				},
+				Body: `{"scans":[{"id":"612a3e0983890f06","folder":"/mnt/unionfs/Media/Movies/Interstellar (2014)"}],` +
+					`"status_url":"/api/scans/status?id=612a3e0983890f06"}`,
+			},
+		},
+		{
+			"Prefixes the status URL with the base path",
+			Given{
+				Config: Config{
+					Priority: 5,
+					Rewrite:  standardConfig.Rewrite,
+					BasePath: "/autoscan",
+				},
+				Query: url.Values{
+					"dir": []string{"/Movies/Interstellar (2014)"},
+				},
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+				Body: `{"scans":[{"id":"612a3e0983890f06","folder":"/mnt/unionfs/Media/Movies/Interstellar (2014)"}],` +
+					`"status_url":"/autoscan/api/scans/status?id=612a3e0983890f06"}`,
+			},
+		},
+		{
+			"Expands globs within the glob roots",
+			Given{
+				Config: globConfig,
+				Query: url.Values{
+					"dir": []string{filepath.Join(root, "TV", "W*", "Season 01")},
+				},
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   filepath.Join(root, "TV", "Wednesday", "Season 01"),
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+					{
+						Folder:   filepath.Join(root, "TV", "Westworld", "Season 01"),
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns bad request on globs outside of the glob roots",
+			Given{
+				Config: globConfig,
+				Query: url.Values{
+					"dir": []string{filepath.Join(root, "*", "Westworld")},
+				},
+			},
+			Expected{
+				StatusCode: 400,
+			},
+		},
+		{
+			"Returns bad request when globs do not match any directories",
+			Given{
+				Config: globConfig,
+				Query: url.Values{
+					"dir": []string{filepath.Join(root, "TV", "Westworld", "Season 03*")},
+				},
+			},
+			Expected{
+				StatusCode: 400,
+			},
+		},
+		{
+			"Overrides the rewrite rules with the rewrite of the query",
+			Given{
+				Config: standardConfig,
+				Query: url.Values{
+					"dir":  []string{"/data/Movies/Interstellar (2014)"},
+					"from": []string{"^/data/"},
+					"to":   []string{"/mnt/unionfs/Media/"},
+				},
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Overrides the rewrite rules with the rewrite of the JSON body",
+			Given{
+				Config: standardConfig,
+				Body:   `{"paths": ["/data/Movies/Interstellar (2014)"], "rewrite": {"from": "^/data/", "to": "/mnt/unionfs/Media/"}}`,
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns bad request on invalid rewrite",
+			Given{
+				Config: standardConfig,
+				Query: url.Values{
+					"dir":  []string{"/data/Movies/Interstellar (2014)"},
+					"from": []string{"^/data/("},
+					"to":   []string{"/mnt/unionfs/Media/"},
+				},
+			},
+			Expected{
+				StatusCode: 400,
+			},
+		},
+		{
+			"Returns a summary of newline-delimited paths",
+			Given{
+				Config:      standardConfig,
+				ContentType: "text/plain",
+				Body:        "/Movies/Interstellar (2014)\n\n/Movies/Parasite (2019)\n",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Parasite (2019)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+				Body: `{"paths":2,"scans":2}`,
+			},
+		},
+		{
+			"Returns request entity too large when exceeding the maximum paths",
+			Given{
+				Config: Config{
+					Priority: 5,
+					MaxPaths: 1,
+				},
+				ContentType: "text/plain",
+				Body:        "/Movies/Interstellar (2014)\n/Movies/Parasite (2019)\n",
+			},
+			Expected{
+				StatusCode: 413,
+			},
+		},
+		{
+			"Returns removals when the type is removed",
+			Given{
+				Config: standardConfig,
+				Query: url.Values{
+					"dir":  []string{"/Movies/Interstellar (2014)"},
+					"type": []string{"removed"},
+				},
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventRemoved,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns removals when the JSON event is removed",
+			Given{
+				Config: standardConfig,
+				Body:   `{"paths": ["/Movies/Interstellar (2014)"], "event": "removed"}`,
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventRemoved,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns bad request on unknown event",
+			Given{
+				Config: standardConfig,
+				Query: url.Values{
+					"dir":  []string{"/Movies/Interstellar (2014)"},
+					"type": []string{"deleted"},
+				},
+			},
+			Expected{
+				StatusCode: 400,
+			},
+		},
+		{
+			"Returns bad request on invalid JSON",
+			Given{
+				Config: standardConfig,
+				Body:   `{"paths": "/Movies/Interstellar (2014)"}`,
+			},
+			Expected{
+				StatusCode: 400,
 			},
 		},
 	}
@@ -105,12 +416,18 @@ func TestHandler(t *testing.T) {
 			server := httptest.NewServer(trigger(callback))
 			defer server.Close()
 
-			req, err := http.NewRequest("POST", server.URL, nil)
+			req, err := http.NewRequest("POST", server.URL, strings.NewReader(tc.Given.Body))
 			if err != nil {
 				t.Fatalf("Failed creating request: %v", err)
 			}
 
 			req.URL.RawQuery = tc.Given.Query.Encode()
+			switch {
+			case tc.Given.ContentType != "":
+				req.Header.Set("Content-Type", tc.Given.ContentType)
+			case tc.Given.Body != "":
+				req.Header.Set("Content-Type", "application/json")
+			}
 
 			res, err := http.DefaultClient.Do(req)
 			if err != nil {
@@ -121,6 +438,21 @@ func TestHandler(t *testing.T) {
 			if res.StatusCode != tc.Expected.StatusCode {
 				t.Errorf("Status codes do not match: %d vs %d", res.StatusCode, tc.Expected.StatusCode)
 			}
+
+			if tc.Expected.Body == "" {
+				return
+			}
+
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("Failed reading body: %v", err)
+			}
+
+			if strings.TrimSpace(string(body)) != tc.Expected.Body {
+				t.Logf("want: %s", tc.Expected.Body)
+				t.Logf("got:  %s", body)
+				t.Errorf("Bodies do not match")
+			}
 		})
 	}
 }
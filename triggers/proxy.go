@@ -0,0 +1,138 @@
+package triggers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// ParseNetworks parses IP addresses and CIDR ranges, of which an address is a network of its own.
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %q: %w", v, autoscan.ErrFatal)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range: %q: %w", v, autoscan.ErrFatal)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+func contains(networks []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, n := range networks {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type contextKey int
+
+const sourceIPKey contextKey = iota
+
+// WithTrustedProxies takes the source IP of requests of the trusted proxies from the headers of the proxies.
+// The source IP is the last address of X-Forwarded-For which is not of a trusted proxy,
+// or the address of X-Real-IP when the proxy does not send X-Forwarded-For.
+// The headers of other clients are ignored, as any client can send them.
+func WithTrustedProxies(proxies []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(proxies) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if ip := forwardedIP(r, proxies); ip != "" {
+				r = r.WithContext(context.WithValue(r.Context(), sourceIPKey, ip))
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// forwardedIP returns the address of the client of the trusted proxy of the request,
+// or nothing when the request was not forwarded by a trusted proxy.
+func forwardedIP(r *http.Request, proxies []*net.IPNet) string {
+	ip := remoteIP(r)
+	if !contains(proxies, ip) {
+		return ""
+	}
+
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, v := range strings.Split(header, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(v))
+		}
+	}
+
+	if len(forwarded) == 0 {
+		if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(real) != nil {
+			return real
+		}
+
+		return ""
+	}
+
+	// every proxy appends the address of its client
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if net.ParseIP(forwarded[i]) == nil {
+			return ip
+		}
+
+		ip = forwarded[i]
+		if !contains(proxies, ip) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// SourceIP returns the IP address of the client of the request,
+// which is that of the client of a trusted proxy.
+func SourceIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(sourceIPKey).(string); ok {
+		return ip
+	}
+
+	return remoteIP(r)
+}
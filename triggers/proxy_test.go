@@ -0,0 +1,154 @@
+package triggers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestParseNetworks(t *testing.T) {
+	type Test struct {
+		Name   string
+		Values []string
+		Want   []string
+		Err    error
+	}
+
+	var testCases = []Test{
+		{
+			Name:   "Addresses and ranges",
+			Values: []string{"10.0.0.0/8", "172.17.0.1", "fd00::/8", "::1"},
+			Want:   []string{"10.0.0.0/8", "172.17.0.1/32", "fd00::/8", "::1/128"},
+		},
+		{
+			Name:   "Invalid address",
+			Values: []string{"localhost"},
+			Err:    autoscan.ErrFatal,
+		},
+		{
+			Name:   "Invalid range",
+			Values: []string{"10.0.0.0/33"},
+			Err:    autoscan.ErrFatal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			networks, err := ParseNetworks(tc.Values)
+			if !errors.Is(err, tc.Err) {
+				t.Fatalf("Errors do not match: %v vs %v", err, tc.Err)
+			}
+
+			if len(networks) != len(tc.Want) {
+				t.Fatalf("Networks do not match: %v vs %v", networks, tc.Want)
+			}
+
+			for i, n := range networks {
+				if n.String() != tc.Want[i] {
+					t.Errorf("Networks do not match: %v vs %v", n, tc.Want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	type Test struct {
+		Name       string
+		Proxies    []string
+		RemoteAddr string
+		Forwarded  []string
+		RealIP     string
+		Want       string
+	}
+
+	var testCases = []Test{
+		{
+			Name:       "Remote address without trusted proxies",
+			RemoteAddr: "10.0.0.2:51234",
+			Forwarded:  []string{"203.0.113.7"},
+			Want:       "10.0.0.2",
+		},
+		{
+			Name:       "Ignores the headers of untrusted clients",
+			Proxies:    []string{"10.0.0.1"},
+			RemoteAddr: "10.0.0.2:51234",
+			Forwarded:  []string{"203.0.113.7"},
+			RealIP:     "203.0.113.8",
+			Want:       "10.0.0.2",
+		},
+		{
+			Name:       "Forwarded for by a trusted proxy",
+			Proxies:    []string{"10.0.0.1"},
+			RemoteAddr: "10.0.0.1:51234",
+			Forwarded:  []string{"203.0.113.7"},
+			Want:       "203.0.113.7",
+		},
+		{
+			Name:       "Skips the trusted proxies of the chain",
+			Proxies:    []string{"10.0.0.0/8"},
+			RemoteAddr: "10.0.0.1:51234",
+			Forwarded:  []string{"198.51.100.1, 203.0.113.7", "10.0.0.5"},
+			Want:       "203.0.113.7",
+		},
+		{
+			Name:       "Leftmost address when the chain is trusted",
+			Proxies:    []string{"10.0.0.0/8"},
+			RemoteAddr: "10.0.0.1:51234",
+			Forwarded:  []string{"10.0.0.6, 10.0.0.5"},
+			Want:       "10.0.0.6",
+		},
+		{
+			Name:       "Stops at invalid addresses",
+			Proxies:    []string{"10.0.0.0/8"},
+			RemoteAddr: "10.0.0.1:51234",
+			Forwarded:  []string{"unknown, 10.0.0.5"},
+			Want:       "10.0.0.5",
+		},
+		{
+			Name:       "Real IP without forwarded for",
+			Proxies:    []string{"::1"},
+			RemoteAddr: "[::1]:51234",
+			RealIP:     "203.0.113.7",
+			Want:       "203.0.113.7",
+		},
+		{
+			Name:       "Remote address without headers",
+			Proxies:    []string{"10.0.0.1"},
+			RemoteAddr: "10.0.0.1:51234",
+			Want:       "10.0.0.1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			proxies, err := ParseNetworks(tc.Proxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ip string
+			handler := WithTrustedProxies(proxies)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				ip = SourceIP(r)
+			}))
+
+			req := httptest.NewRequest("POST", "/triggers/sonarr", nil)
+			req.RemoteAddr = tc.RemoteAddr
+			for _, v := range tc.Forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+
+			if tc.RealIP != "" {
+				req.Header.Set("X-Real-IP", tc.RealIP)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if ip != tc.Want {
+				t.Errorf("Source IPs do not match: %s vs %s", ip, tc.Want)
+			}
+		})
+	}
+}
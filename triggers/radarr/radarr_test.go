@@ -2,6 +2,7 @@ package radarr
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -43,6 +44,16 @@ func TestHandler(t *testing.T) {
 		return currentTime
 	}
 
+	api := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "api-key" || r.URL.Path != "/api/v3/moviefile/5" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = rw.Write([]byte(`{"path": "/Movies/Interstellar (2014) {tmdb-157336}/Interstellar.2014.UHD.BluRay.2160p.REMUX.mkv"}`))
+	}))
+	defer api.Close()
+
 	var testCases = []Test{
 		{
 			"Returns IMDb if both TMDb and IMDb are given",
@@ -56,6 +67,7 @@ func TestHandler(t *testing.T) {
 					{
 						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
 						Priority: 5,
+						Event:    autoscan.EventAdded,
 						Time:     currentTime,
 					},
 				},
@@ -80,6 +92,254 @@ func TestHandler(t *testing.T) {
 					{
 						Folder:   "/Media/Movies/Parasite (2019)",
 						Priority: 3,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns removal of the movie folder on MovieFileDelete",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/movie_file_delete.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventRemoved,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns removal of the movie folder on MovieDelete",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/movie_delete.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventRemoved,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns a single scan on upgrade within the same folder",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/upgrade.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventModified,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns removal of the previous folder and scan of the new folder on Rename",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/rename.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2015)",
+						Priority: 5,
+						Event:    autoscan.EventRemoved,
+						Time:     currentTime,
+					},
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns scan of the movie folder on Rename without renamed files",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/rename_movie.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventModified,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns 200 on disabled MovieDelete event without emitting a scan",
+			Given{
+				Config: Config{
+					Name:     "radarr",
+					Priority: 5,
+					Events:   []string{"download", "upgrade", "rename"},
+				},
+				Fixture: "testdata/movie_delete.json",
+			},
+			Expected{
+				StatusCode: 200,
+			},
+		},
+		{
+			"Uses the priority of the event type",
+			Given{
+				Config: Config{
+					Name:     "radarr",
+					Priority: 5,
+					Priorities: map[string]int{
+						"download": 3,
+						"rename":   1,
+					},
+					Rewrite: []autoscan.Rewrite{{
+						From: "/Movies/*",
+						To:   "/mnt/unionfs/Media/Movies/$1",
+					}},
+				},
+				Fixture: "testdata/rename_movie.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 1,
+						Event:    autoscan.EventModified,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Uses the full path of the movie file of newer versions",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/v5.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Ignores fields of an unexpected type",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/type_mismatch.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns bad request on fields of an unexpected type in strict mode",
+			Given{
+				Config: Config{
+					Name:     "radarr",
+					Priority: 5,
+					Strict:   true,
+				},
+				Fixture: "testdata/type_mismatch.json",
+			},
+			Expected{
+				StatusCode: 400,
+			},
+		},
+		{
+			"Uses the path of the imported file returned by the API",
+			Given{
+				Config: Config{
+					Name:     "radarr",
+					Priority: 5,
+					Rewrite: []autoscan.Rewrite{{
+						From: "/Movies/*",
+						To:   "/mnt/unionfs/Media/Movies/$1",
+					}},
+					Verify: VerifyConfig{
+						URL:    api.URL,
+						APIKey: "api-key",
+					},
+				},
+				Fixture: "testdata/verify.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014) {tmdb-157336}",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Falls back to the path of the event when verification fails",
+			Given{
+				Config: Config{
+					Name:     "radarr",
+					Priority: 5,
+					Rewrite: []autoscan.Rewrite{{
+						From: "/Movies/*",
+						To:   "/mnt/unionfs/Media/Movies/$1",
+					}},
+					Verify: VerifyConfig{
+						URL:    api.URL,
+						APIKey: "invalid",
+					},
+				},
+				Fixture: "testdata/verify.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/Movies/Interstellar (2014)",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
 						Time:     currentTime,
 					},
 				},
@@ -145,3 +405,36 @@ func TestHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestQueueFull(t *testing.T) {
+	callback := func(scans ...autoscan.Scan) error {
+		return fmt.Errorf("100 scans queued: %w", autoscan.ErrQueueFull)
+	}
+
+	trigger, err := New(Config{Name: "radarr"})
+	if err != nil {
+		t.Fatalf("Could not create Radarr Trigger: %v", err)
+	}
+
+	server := httptest.NewServer(trigger(callback))
+	defer server.Close()
+
+	request, err := os.Open("testdata/interstellar.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.Post(server.URL, "application/json", request)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Status codes do not match: %d vs %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+
+	if res.Header.Get("Retry-After") != "60" {
+		t.Errorf("Retry-After does not match: %q vs %q", res.Header.Get("Retry-After"), "60")
+	}
+}
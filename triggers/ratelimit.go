@@ -0,0 +1,158 @@
+package triggers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit limits requests to a number of requests per minute,
+// of which the burst may be sent at once. The burst defaults to the requests per minute.
+// A limit without requests does not limit.
+type RateLimit struct {
+	Requests int `yaml:"requests"`
+	Burst    int `yaml:"burst"`
+}
+
+func (l RateLimit) enabled() bool {
+	return l.Requests > 0
+}
+
+func (l RateLimit) burst() int {
+	if l.Burst <= 0 {
+		return l.Requests
+	}
+
+	return l.Burst
+}
+
+func (l RateLimit) limiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(float64(l.Requests)/60), l.burst())
+}
+
+// refill returns the time in which the burst is replenished.
+func (l RateLimit) refill() time.Duration {
+	return time.Duration(l.burst()) * time.Minute / time.Duration(l.Requests)
+}
+
+// RateLimits limits the requests to a route, and the requests of every source IP to the route.
+type RateLimits struct {
+	Route RateLimit `yaml:"route"`
+	IP    RateLimit `yaml:"ip"`
+}
+
+// idleLimiter is the minimum time after which the limiter of a source IP without requests is removed.
+// The limiter is kept until its burst has been replenished.
+const idleLimiter = 10 * time.Minute
+
+var now = time.Now
+
+// WithRateLimit rejects requests beyond the rate limits with 429 Too Many Requests,
+// and tells the client when to retry with the Retry-After header.
+// Every handler wrapped by the middleware has its own limits.
+func WithRateLimit(limits RateLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !limits.Route.enabled() && !limits.IP.enabled() {
+			return next
+		}
+
+		l := &rateLimiter{limits: limits, ips: make(map[string]*ipLimiter), idle: idleLimiter}
+		if limits.Route.enabled() {
+			l.route = limits.Route.limiter()
+		}
+
+		if limits.IP.enabled() && limits.IP.refill() > l.idle {
+			l.idle = limits.IP.refill()
+		}
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			delay := l.reserve(SourceIP(r))
+			if delay <= 0 {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			hlog.FromRequest(r).Warn().
+				Stringer("retry_after", delay).
+				Msg("Rate limit exceeded")
+
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			rw.WriteHeader(http.StatusTooManyRequests)
+		})
+	}
+}
+
+type rateLimiter struct {
+	limits RateLimits
+	route  *rate.Limiter
+
+	mtx   sync.Mutex
+	ips   map[string]*ipLimiter
+	idle  time.Duration
+	swept time.Time
+}
+
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// reserve takes a token of the source IP and of the route,
+// and returns the time to wait when either limit is exceeded, in which case no tokens are taken.
+func (l *rateLimiter) reserve(ip string) time.Duration {
+	t := now()
+
+	var reservations []*rate.Reservation
+	if l.limits.IP.enabled() {
+		reservations = append(reservations, l.ip(ip, t).ReserveN(t, 1))
+	}
+
+	if l.route != nil {
+		reservations = append(reservations, l.route.ReserveN(t, 1))
+	}
+
+	var delay time.Duration
+	for _, r := range reservations {
+		if d := r.DelayFrom(t); d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 {
+		for _, r := range reservations {
+			r.CancelAt(t)
+		}
+	}
+
+	return delay
+}
+
+// ip returns the limiter of the source IP, and removes the limiters of idle source IPs.
+func (l *rateLimiter) ip(ip string, t time.Time) *rate.Limiter {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if t.Sub(l.swept) > l.idle {
+		for k, v := range l.ips {
+			if t.Sub(v.lastSeen) > l.idle {
+				delete(l.ips, k)
+			}
+		}
+
+		l.swept = t
+	}
+
+	v, ok := l.ips[ip]
+	if !ok {
+		v = &ipLimiter{limiter: l.limits.IP.limiter()}
+		l.ips[ip] = v
+	}
+
+	v.lastSeen = t
+	return v.limiter
+}
@@ -0,0 +1,106 @@
+package triggers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	type Request struct {
+		IP        string
+		After     time.Duration
+		WantCode  int
+		WantRetry string
+	}
+
+	type Test struct {
+		Name     string
+		Limits   RateLimits
+		Requests []Request
+	}
+
+	var testCases = []Test{
+		{
+			Name:   "Limits every source IP",
+			Limits: RateLimits{IP: RateLimit{Requests: 60, Burst: 2}},
+			Requests: []Request{
+				{IP: "10.0.0.1", WantCode: 200},
+				{IP: "10.0.0.1", WantCode: 200},
+				{IP: "10.0.0.1", WantCode: 429, WantRetry: "1"},
+				{IP: "10.0.0.2", WantCode: 200},
+				{IP: "10.0.0.1", After: time.Second, WantCode: 200},
+			},
+		},
+		{
+			Name:   "Limits the route",
+			Limits: RateLimits{Route: RateLimit{Requests: 6, Burst: 1}},
+			Requests: []Request{
+				{IP: "10.0.0.1", WantCode: 200},
+				{IP: "10.0.0.2", WantCode: 429, WantRetry: "10"},
+				{IP: "10.0.0.2", After: 5 * time.Second, WantCode: 429, WantRetry: "5"},
+				{IP: "10.0.0.2", After: 5 * time.Second, WantCode: 200},
+			},
+		},
+		{
+			Name: "Does not take a token of the route when the source IP is limited",
+			Limits: RateLimits{
+				Route: RateLimit{Requests: 60, Burst: 2},
+				IP:    RateLimit{Requests: 60, Burst: 1},
+			},
+			Requests: []Request{
+				{IP: "10.0.0.1", WantCode: 200},
+				{IP: "10.0.0.1", WantCode: 429, WantRetry: "1"},
+				{IP: "10.0.0.2", WantCode: 200},
+				{IP: "10.0.0.3", WantCode: 429, WantRetry: "1"},
+			},
+		},
+		{
+			Name:   "Burst defaults to the requests per minute",
+			Limits: RateLimits{IP: RateLimit{Requests: 2}},
+			Requests: []Request{
+				{IP: "10.0.0.1", WantCode: 200},
+				{IP: "10.0.0.1", WantCode: 200},
+				{IP: "10.0.0.1", WantCode: 429, WantRetry: "30"},
+			},
+		},
+		{
+			Name: "Does not limit without requests",
+			Requests: []Request{
+				{IP: "10.0.0.1", WantCode: 200},
+				{IP: "10.0.0.1", WantCode: 200},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testTime := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+			now = func() time.Time {
+				return testTime
+			}
+
+			handler := WithRateLimit(tc.Limits)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+
+			for i, req := range tc.Requests {
+				testTime = testTime.Add(req.After)
+
+				r := httptest.NewRequest("POST", "/triggers/sonarr", nil)
+				r.RemoteAddr = req.IP + ":51234"
+				rw := httptest.NewRecorder()
+				handler.ServeHTTP(rw, r)
+
+				if rw.Code != req.WantCode {
+					t.Errorf("Request %d: status codes do not match: %d vs %d", i, rw.Code, req.WantCode)
+				}
+
+				if retry := rw.Header().Get("Retry-After"); retry != req.WantRetry {
+					t.Errorf("Request %d: Retry-After does not match: %q vs %q", i, retry, req.WantRetry)
+				}
+			}
+		})
+	}
+}
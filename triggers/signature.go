@@ -0,0 +1,212 @@
+package triggers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/cloudbox/autoscan"
+)
+
+const (
+	// DefaultSignatureHeader is the header of the signature when none is configured.
+	DefaultSignatureHeader = "X-Signature"
+
+	// TimestampHeader is the header of the time at which the request was signed, in seconds since the Unix epoch.
+	TimestampHeader = "X-Signature-Timestamp"
+
+	// DefaultMaxSkew is the maximum difference between the timestamp of a request and the time it is received
+	// when none is configured.
+	DefaultMaxSkew = 5 * time.Minute
+)
+
+// Signature is the shared secret with which the requests to a trigger are signed.
+// The signature is the hex encoded HMAC of the timestamp, method, path, query and body of the request,
+// optionally prefixed with the algorithm, e.g. sha256=<hex>.
+// Requests of which the timestamp differs more than the maximum skew from the time they are received are rejected,
+// as are requests of which the signature was already received.
+type Signature struct {
+	Secret     string        `yaml:"secret"`
+	SecretFile string        `yaml:"secret-file"`
+	Header     string        `yaml:"header"`
+	Algorithm  string        `yaml:"algorithm"`
+	MaxSkew    time.Duration `yaml:"max-skew"`
+}
+
+var signatureAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// resolve returns the algorithm, hash and header of the signature,
+// defaulting to sha256 and DefaultSignatureHeader.
+func (s Signature) resolve() (string, func() hash.Hash, string, error) {
+	algorithm := strings.ToLower(s.Algorithm)
+	if algorithm == "" {
+		algorithm = "sha256"
+	}
+
+	newHash, ok := signatureAlgorithms[algorithm]
+	if !ok {
+		return "", nil, "", fmt.Errorf("unknown algorithm %s: %w", s.Algorithm, autoscan.ErrFatal)
+	}
+
+	header := s.Header
+	if header == "" {
+		header = DefaultSignatureHeader
+	}
+
+	return algorithm, newHash, header, nil
+}
+
+// mac returns the HMAC of the signed parts of a request.
+func (s Signature) mac(newHash func() hash.Hash, timestamp string, method string, path string, query string, body []byte) []byte {
+	mac := hmac.New(newHash, []byte(s.Secret))
+	mac.Write([]byte(timestamp + "\n" + method + "\n" + path + "\n" + query + "\n"))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Sign sets the timestamp and signature headers with which WithSignature accepts the request.
+// The path is the path of the trigger without the base path of autoscan.
+func (s Signature) Sign(req *http.Request, path string, body []byte) error {
+	algorithm, newHash, header, err := s.resolve()
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	sum := s.mac(newHash, timestamp, req.Method, path, req.URL.RawQuery, body)
+
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(header, algorithm+"="+hex.EncodeToString(sum))
+	return nil
+}
+
+// WithSignature rejects the requests which are not signed with the secret with 401 Unauthorized,
+// as well as the requests of which the timestamp is outside of the maximum skew and replayed requests.
+// The algorithm defaults to sha256, the header to DefaultSignatureHeader and the maximum skew to DefaultMaxSkew.
+// The body is read, and should therefore be limited by WithLimits first.
+func WithSignature(s Signature) (func(http.Handler) http.Handler, error) {
+	algorithm, newHash, header, err := s.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	maxSkew := s.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxSkew
+	}
+
+	replays := &replayCache{seen: make(map[string]time.Time)}
+
+	return func(next http.Handler) http.Handler {
+		if s.Secret == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			l := hlog.FromRequest(r)
+
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				l.Error().
+					Err(err).
+					Msg("Failed reading request body")
+
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			timestamp := r.Header.Get(TimestampHeader)
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				l.Warn().
+					Str("header", TimestampHeader).
+					Msg("Missing or malformed signature timestamp")
+
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			signed := time.Unix(seconds, 0)
+			if skew := now().Sub(signed); skew > maxSkew || skew < -maxSkew {
+				l.Warn().
+					Time("timestamp", signed).
+					Msg("Signature timestamp outside of the maximum skew")
+
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			// the algorithm prefix is optional
+			signature := strings.TrimPrefix(r.Header.Get(header), algorithm+"=")
+			got, err := hex.DecodeString(signature)
+			if err != nil || signature == "" {
+				l.Warn().
+					Str("header", header).
+					Msg("Missing or malformed signature")
+
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if !hmac.Equal(got, s.mac(newHash, timestamp, r.Method, r.URL.Path, r.URL.RawQuery, body)) {
+				l.Warn().Msg("Invalid signature")
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			// a signature is valid until the timestamp exceeds the maximum skew
+			if !replays.add(hex.EncodeToString(got), signed.Add(maxSkew)) {
+				l.Warn().Msg("Replayed signature")
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}, nil
+}
+
+// replayCache remembers the signatures of the accepted requests until they expire.
+type replayCache struct {
+	mtx  sync.Mutex
+	seen map[string]time.Time
+}
+
+// add records the signature, and returns false when the signature was already recorded.
+func (c *replayCache) add(signature string, expires time.Time) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	t := now()
+	for sig, exp := range c.seen {
+		if t.After(exp) {
+			delete(c.seen, sig)
+		}
+	}
+
+	if _, ok := c.seen[signature]; ok {
+		return false
+	}
+
+	c.seen[signature] = expires
+	return true
+}
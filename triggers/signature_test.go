@@ -0,0 +1,221 @@
+package triggers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+func TestSignature(t *testing.T) {
+	type Test struct {
+		Name      string
+		Signature Signature
+		Signer    *Signature
+		Tamper    func(r *http.Request)
+		Age       time.Duration
+		Replay    bool
+		WantCode  int
+	}
+
+	var testCases = []Test{
+		{
+			Name:      "Valid signature",
+			Signature: Signature{Secret: "general kenobi"},
+			WantCode:  200,
+		},
+		{
+			Name:      "Valid signature without the algorithm",
+			Signature: Signature{Secret: "general kenobi"},
+			Tamper: func(r *http.Request) {
+				r.Header.Set(DefaultSignatureHeader, strings.TrimPrefix(r.Header.Get(DefaultSignatureHeader), "sha256="))
+			},
+			WantCode: 200,
+		},
+		{
+			Name:      "Header and algorithm",
+			Signature: Signature{Secret: "general kenobi", Header: "X-Hub-Signature", Algorithm: "sha1"},
+			WantCode:  200,
+		},
+		{
+			Name:      "SHA-512",
+			Signature: Signature{Secret: "general kenobi", Algorithm: "SHA512"},
+			WantCode:  200,
+		},
+		{
+			Name:      "Signature of another algorithm",
+			Signature: Signature{Secret: "general kenobi", Algorithm: "sha512"},
+			Signer:    &Signature{Secret: "general kenobi"},
+			WantCode:  401,
+		},
+		{
+			Name:      "Signature of another secret",
+			Signature: Signature{Secret: "hello there"},
+			Signer:    &Signature{Secret: "general kenobi"},
+			WantCode:  401,
+		},
+		{
+			Name:      "Signature in another header",
+			Signature: Signature{Secret: "general kenobi"},
+			Signer:    &Signature{Secret: "general kenobi", Header: "X-Hub-Signature-256"},
+			WantCode:  401,
+		},
+		{
+			Name:      "Malformed signature",
+			Signature: Signature{Secret: "general kenobi"},
+			Tamper: func(r *http.Request) {
+				r.Header.Set(DefaultSignatureHeader, "general kenobi")
+			},
+			WantCode: 401,
+		},
+		{
+			Name:      "Tampered query",
+			Signature: Signature{Secret: "general kenobi"},
+			Tamper: func(r *http.Request) {
+				r.URL.RawQuery = "dir=%2Fmnt%2Funionfs%2FMedia%2FMovies"
+			},
+			WantCode: 401,
+		},
+		{
+			Name:      "Tampered path",
+			Signature: Signature{Secret: "general kenobi"},
+			Tamper: func(r *http.Request) {
+				r.URL.Path = "/triggers/radarr"
+			},
+			WantCode: 401,
+		},
+		{
+			Name:      "Tampered timestamp",
+			Signature: Signature{Secret: "general kenobi"},
+			Tamper: func(r *http.Request) {
+				r.Header.Set(TimestampHeader, "1591012801")
+			},
+			WantCode: 401,
+		},
+		{
+			Name:      "Missing timestamp",
+			Signature: Signature{Secret: "general kenobi"},
+			Tamper: func(r *http.Request) {
+				r.Header.Del(TimestampHeader)
+			},
+			WantCode: 401,
+		},
+		{
+			Name:      "Timestamp within the maximum skew",
+			Signature: Signature{Secret: "general kenobi"},
+			Age:       4 * time.Minute,
+			WantCode:  200,
+		},
+		{
+			Name:      "Timestamp outside of the maximum skew",
+			Signature: Signature{Secret: "general kenobi"},
+			Age:       6 * time.Minute,
+			WantCode:  401,
+		},
+		{
+			Name:      "Timestamp outside of the configured maximum skew",
+			Signature: Signature{Secret: "general kenobi", MaxSkew: time.Minute},
+			Age:       2 * time.Minute,
+			WantCode:  401,
+		},
+		{
+			Name:      "Replayed request",
+			Signature: Signature{Secret: "general kenobi"},
+			Replay:    true,
+			WantCode:  401,
+		},
+		{
+			Name:     "No secret",
+			WantCode: 200,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testTime := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+			now = func() time.Time {
+				return testTime
+			}
+
+			signature, err := WithSignature(tc.Signature)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler := signature(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				// the body is passed on
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil || string(b) != `{"eventType": "Test"}` {
+					t.Errorf("Bodies do not match: %q", b)
+				}
+
+				rw.WriteHeader(http.StatusOK)
+			}))
+
+			signer := tc.Signature
+			if tc.Signer != nil {
+				signer = *tc.Signer
+			}
+
+			body := `{"eventType": "Test"}`
+			req := httptest.NewRequest("POST", "/triggers/manual?dir=%2Fmnt%2Funionfs%2FMedia%2FTV", strings.NewReader(body))
+			if signer.Secret != "" {
+				if err := signer.Sign(req, req.URL.Path, []byte(body)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tc.Tamper != nil {
+				tc.Tamper(req)
+			}
+
+			testTime = testTime.Add(tc.Age)
+
+			if tc.Replay {
+				replayed := req.Clone(req.Context())
+				replayed.Body = ioutil.NopCloser(strings.NewReader(body))
+				handler.ServeHTTP(httptest.NewRecorder(), replayed)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.WantCode {
+				t.Errorf("Status codes do not match: %d vs %d", rr.Code, tc.WantCode)
+			}
+		})
+	}
+}
+
+func TestSignatureFormat(t *testing.T) {
+	now = func() time.Time {
+		return time.Unix(1591012800, 0)
+	}
+
+	req := httptest.NewRequest("POST", "/triggers/manual?dir=%2Fmnt%2Funionfs%2FMedia%2FTV", nil)
+	if err := (Signature{Secret: "general kenobi"}).Sign(req, "/triggers/manual", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// printf '1591012800\nPOST\n/triggers/manual\ndir=%%2Fmnt%%2Funionfs%%2FMedia%%2FTV\n' | openssl dgst -sha256 -hmac "general kenobi"
+	want := "sha256=56cde728dcdee7207f77383c7ee022d450ea9903a4b82096cea0c2ed73b7e4de"
+	if got := req.Header.Get(DefaultSignatureHeader); got != want {
+		t.Errorf("Signatures do not match: %s vs %s", got, want)
+	}
+
+	if got := req.Header.Get(TimestampHeader); got != "1591012800" {
+		t.Errorf("Timestamps do not match: %s vs %s", got, "1591012800")
+	}
+}
+
+func TestSignatureAlgorithm(t *testing.T) {
+	_, err := WithSignature(Signature{Secret: "general kenobi", Algorithm: "md5"})
+	if !errors.Is(err, autoscan.ErrFatal) {
+		t.Errorf("Errors do not match: %v vs %v", err, autoscan.ErrFatal)
+	}
+}
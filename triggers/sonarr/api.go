@@ -0,0 +1,76 @@
+package sonarr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudbox/autoscan"
+)
+
+// VerifyConfig configures the Sonarr API which is used to verify
+// the path of imported files before they are scanned.
+type VerifyConfig struct {
+	URL        string `yaml:"url"`
+	APIKey     string `yaml:"api-key"`
+	APIKeyFile string `yaml:"api-key-file"`
+}
+
+type apiClient struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+func newAPIClient(c VerifyConfig) *apiClient {
+	if c.URL == "" {
+		return nil
+	}
+
+	return &apiClient{
+		client:  &http.Client{Timeout: 10 * time.Second},
+		baseURL: c.URL,
+		apiKey:  c.APIKey,
+	}
+}
+
+// FilePath returns the path of the episode file as known by Sonarr,
+// which includes its remote path mappings.
+func (c apiClient) FilePath(id int) (string, error) {
+	reqURL := autoscan.JoinURL(c.baseURL, "api", "v3", "episodefile", strconv.Itoa(id))
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed creating episode file request: %w", err)
+	}
+
+	req.Header.Set("X-Api-Key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("episode file: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return "", fmt.Errorf("episode file: %s", res.Status)
+	}
+
+	type Response struct {
+		Path string `json:"path"`
+	}
+
+	resp := new(Response)
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return "", fmt.Errorf("failed decoding episode file response: %w", err)
+	}
+
+	if resp.Path == "" {
+		return "", fmt.Errorf("episode file %d has no path", id)
+	}
+
+	return resp.Path, nil
+}
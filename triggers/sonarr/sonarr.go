@@ -2,20 +2,36 @@ package sonarr
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cloudbox/autoscan"
+	"github.com/cloudbox/autoscan/triggers"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 )
 
 type Config struct {
-	Name      string             `yaml:"name"`
-	Priority  int                `yaml:"priority"`
-	Rewrite   []autoscan.Rewrite `yaml:"rewrite"`
-	Verbosity string             `yaml:"verbosity"`
+	Name        string             `yaml:"name"`
+	Priority    int                `yaml:"priority"`
+	Priorities  map[string]int     `yaml:"priorities"`
+	Rewrite     []autoscan.Rewrite `yaml:"rewrite"`
+	Events      []string           `yaml:"events"`
+	Strict      bool               `yaml:"strict"`
+	CheckExists bool               `yaml:"check-exists"`
+	Verify      VerifyConfig       `yaml:"verify"`
+	Verbosity   string             `yaml:"verbosity"`
+
+	// APIKey authenticates the requests to the trigger, next to the authentication of autoscan
+	APIKey     string `yaml:"api-key"`
+	APIKeyFile string `yaml:"api-key-file"`
+
+	// Signature verifies the HMAC signature of the request bodies
+	Signature triggers.Signature `yaml:"signature"`
 }
 
 // New creates an autoscan-compatible HTTP Trigger for Sonarr webhooks.
@@ -25,11 +41,25 @@ func New(c Config) (autoscan.HTTPTrigger, error) {
 		return nil, err
 	}
 
+	events, err := triggers.NewEventFilter(c.Events, eventTypes...)
+	if err != nil {
+		return nil, err
+	}
+
+	priorities, err := triggers.NewPriorities(c.Priority, c.Priorities, eventTypes...)
+	if err != nil {
+		return nil, err
+	}
+
 	trigger := func(callback autoscan.ProcessorFunc) http.Handler {
 		return handler{
 			callback: callback,
-			priority: c.Priority,
+			priority: priorities,
 			rewrite:  rewriter,
+			events:   events,
+			strict:   c.Strict,
+			exists:   c.CheckExists,
+			api:      newAPIClient(c.Verify),
 		}
 	}
 
@@ -37,9 +67,13 @@ func New(c Config) (autoscan.HTTPTrigger, error) {
 }
 
 type handler struct {
-	priority int
+	priority func(kind string) int
 	rewrite  autoscan.Rewriter
 	callback autoscan.ProcessorFunc
+	events   map[string]bool
+	strict   bool
+	exists   bool
+	api      *apiClient
 }
 
 type sonarrEvent struct {
@@ -47,12 +81,55 @@ type sonarrEvent struct {
 	Upgrade bool   `json:"isUpgrade"`
 
 	File struct {
+		ID           int
 		RelativePath string
+		Path         string
 	} `json:"episodeFile"`
 
 	Series struct {
 		Path string
 	} `json:"series"`
+
+	DeletedFiles deletedFiles `json:"deletedFiles"`
+
+	RenamedFiles []struct {
+		PreviousPath string
+		RelativePath string
+		Path         string
+	} `json:"renamedEpisodeFiles"`
+}
+
+// event types which can be enabled with the events option
+const (
+	eventDownload = "download"
+	eventUpgrade  = "upgrade"
+	eventRename   = "rename"
+	eventDelete   = "delete"
+)
+
+// kind returns the event type of the event, or an empty string for unsupported events.
+func (event *sonarrEvent) kind() string {
+	switch {
+	case strings.EqualFold(event.Type, "Download") && event.Upgrade:
+		return eventUpgrade
+	case strings.EqualFold(event.Type, "Download"):
+		return eventDownload
+	case strings.EqualFold(event.Type, "Rename"):
+		return eventRename
+	case strings.EqualFold(event.Type, "EpisodeFileDelete"), strings.EqualFold(event.Type, "SeriesDelete"):
+		return eventDelete
+	default:
+		return ""
+	}
+}
+
+// eventTypes are the event types of the trigger
+var eventTypes = []string{eventDownload, eventUpgrade, eventRename, eventDelete}
+
+// enabled returns whether the event type is enabled.
+// All event types are enabled when no events were configured.
+func (h handler) enabled(kind string) bool {
+	return len(h.events) == 0 || h.events[kind]
 }
 
 func (h handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
@@ -61,7 +138,16 @@ func (h handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	event := new(sonarrEvent)
 	err = json.NewDecoder(r.Body).Decode(event)
-	if err != nil {
+	typeErr := new(json.UnmarshalTypeError)
+	switch {
+	case errors.As(err, &typeErr) && !h.strict:
+		// the decoder skips fields of an unexpected type
+		rlog.Warn().Err(err).Str("field", typeErr.Field).Msg("Ignoring field of unexpected type")
+	case errors.As(err, &typeErr):
+		rlog.Error().Err(err).Str("field", typeErr.Field).Msg("Invalid field")
+		http.Error(rw, "invalid field: "+typeErr.Field, http.StatusBadRequest)
+		return
+	case err != nil:
 		rlog.Error().Err(err).Msg("Failed decoding request")
 		rw.WriteHeader(http.StatusBadRequest)
 		return
@@ -75,32 +161,158 @@ func (h handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !strings.EqualFold(event.Type, "Download") || event.File.RelativePath == "" || event.Series.Path == "" {
-		rlog.Error().Msg("Required fields are missing")
-		rw.WriteHeader(http.StatusBadRequest)
+	if kind := event.kind(); kind != "" && !h.enabled(kind) {
+		rlog.Debug().Str("event", kind).Msg("Ignoring disabled event type")
+		rw.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// Rewrite the path based on the provided rewriter.
-	folderPath := path.Dir(h.rewrite(path.Join(event.Series.Path, event.File.RelativePath)))
+	scans := triggers.NewScanList(h.priority(event.kind()), h.exists, now())
+
+	switch {
+	case strings.EqualFold(event.Type, "Download"):
+		// upgrades replace the files of existing media
+		downloaded := autoscan.EventAdded
+		if event.Upgrade {
+			downloaded = autoscan.EventModified
+		}
+
+		filePath := event.filePath()
+		if filePath == "" {
+			triggers.MissingField(rw, rlog, "episodeFile.relativePath", h.strict)
+			return
+		}
+
+		// remote path mappings might have moved the file after the event was sent
+		if h.api != nil && event.File.ID != 0 {
+			filePath = h.verify(rlog, event.File.ID, filePath)
+		}
+
+		// Rewrite the path based on the provided rewriter.
+		scans.Add(path.Dir(h.rewrite(filePath)), downloaded)
+
+		// upgrades replace existing files, which might live in another folder
+		for _, f := range event.DeletedFiles {
+			if f.Path != "" {
+				scans.Add(path.Dir(h.rewrite(f.Path)), autoscan.EventRemoved)
+			}
+		}
+
+	case strings.EqualFold(event.Type, "EpisodeFileDelete"):
+		filePath := event.filePath()
+		if filePath == "" {
+			triggers.MissingField(rw, rlog, "episodeFile.relativePath", h.strict)
+			return
+		}
 
-	scan := autoscan.Scan{
-		Folder:   folderPath,
-		Priority: h.priority,
-		Time:     now(),
+		scans.Add(path.Dir(h.rewrite(filePath)), autoscan.EventRemoved)
+
+	case strings.EqualFold(event.Type, "SeriesDelete"):
+		if event.Series.Path == "" {
+			triggers.MissingField(rw, rlog, "series.path", h.strict)
+			return
+		}
+
+		scans.Add(path.Clean(h.rewrite(event.Series.Path)), autoscan.EventRemoved)
+
+	case strings.EqualFold(event.Type, "Rename"):
+		if event.Series.Path == "" {
+			triggers.MissingField(rw, rlog, "series.path", h.strict)
+			return
+		}
+
+		// older versions of Sonarr do not include the renamed files
+		if len(event.RenamedFiles) == 0 {
+			scans.Add(path.Clean(h.rewrite(event.Series.Path)), autoscan.EventModified)
+			break
+		}
+
+		for _, f := range event.RenamedFiles {
+			if f.PreviousPath != "" {
+				scans.Add(path.Dir(h.rewrite(f.PreviousPath)), autoscan.EventRemoved)
+			}
+
+			newPath := f.Path
+			if newPath == "" {
+				newPath = path.Join(event.Series.Path, f.RelativePath)
+			}
+
+			scans.Add(path.Dir(h.rewrite(newPath)), autoscan.EventAdded)
+		}
+
+	default:
+		rlog.Error().Str("event", event.Type).Msg("Unsupported event type")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
-	err = h.callback(scan)
-	if err != nil {
+	// all scans of the event are added to the processor at once
+	err = h.callback(scans.Scans()...)
+	switch {
+	case errors.Is(err, autoscan.ErrQueueFull):
+		rlog.Warn().Err(err).Msg("Processor queue is full, rejecting scans")
+		rw.Header().Set("Retry-After", strconv.Itoa(int(autoscan.QueueFullRetry.Seconds())))
+		rw.WriteHeader(http.StatusTooManyRequests)
+		return
+	case err != nil:
 		rlog.Error().Err(err).Msg("Processor could not process scan")
 		rw.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	triggers.RecordScans(r, len(scans.Scans()))
+
 	rw.WriteHeader(http.StatusOK)
-	rlog.Info().
-		Str("path", folderPath).
-		Msg("Scan moved to processor")
+	for _, scan := range scans.Scans() {
+		rlog.Info().
+			Str("path", scan.Folder).
+			Str("event", string(scan.Event)).
+			Msg("Scan moved to processor")
+	}
+}
+
+// filePath returns the path of the episode file.
+// Newer versions of Sonarr only include the full path of the file.
+func (event *sonarrEvent) filePath() string {
+	if event.File.RelativePath != "" && event.Series.Path != "" {
+		return path.Join(event.Series.Path, event.File.RelativePath)
+	}
+
+	return event.File.Path
+}
+
+// verify returns the path of the imported file as known by Sonarr,
+// or the path of the event when the path could not be verified.
+func (h handler) verify(rlog *zerolog.Logger, id int, eventPath string) string {
+	apiPath, err := h.api.FilePath(id)
+	if err != nil {
+		rlog.Warn().Err(err).Msg("Failed verifying path of the imported file")
+		return eventPath
+	}
+
+	if apiPath != eventPath {
+		rlog.Debug().
+			Str("event_path", eventPath).
+			Str("api_path", apiPath).
+			Msg("Path of the imported file differs from the event")
+	}
+
+	return apiPath
+}
+
+// deletedFiles holds the files replaced by an upgrade.
+// Delete events use the same field as a boolean, which is ignored.
+type deletedFiles []struct {
+	Path string
+}
+
+func (d *deletedFiles) UnmarshalJSON(b []byte) error {
+	if len(b) == 0 || b[0] != '[' {
+		return nil
+	}
+
+	type files deletedFiles
+	return json.Unmarshal(b, (*files)(d))
 }
 
 var now = time.Now
@@ -43,6 +43,16 @@ func TestHandler(t *testing.T) {
 		return currentTime
 	}
 
+	api := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "api-key" || r.URL.Path != "/api/v3/episodefile/10" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = rw.Write([]byte(`{"path": "/TV/Westworld/Season 01/Westworld.S01E01.The.Original.2160p.TrueHD.Atmos.7.1.HEVC.REMUX.mkv"}`))
+	}))
+	defer api.Close()
+
 	var testCases = []Test{
 		{
 			"Scan has all the correct fields",
@@ -56,6 +66,242 @@ func TestHandler(t *testing.T) {
 					{
 						Folder:   "/mnt/unionfs/Media/TV/Westworld/Season 1",
 						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns removal of the replaced file's folder on upgrade",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/upgrade.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld/Season 1",
+						Priority: 5,
+						Event:    autoscan.EventModified,
+						Time:     currentTime,
+					},
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld/Season 01",
+						Priority: 5,
+						Event:    autoscan.EventRemoved,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns removal of the season folder on EpisodeFileDelete",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/episode_file_delete.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld/Season 1",
+						Priority: 5,
+						Event:    autoscan.EventRemoved,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns removal of the series folder on SeriesDelete",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/series_delete.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld",
+						Priority: 5,
+						Event:    autoscan.EventRemoved,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns removal of previous folders and scan of new folders on Rename",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/rename.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld/Season 01",
+						Priority: 5,
+						Event:    autoscan.EventRemoved,
+						Time:     currentTime,
+					},
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld/Season 1",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns scan of the series folder on Rename without renamed files",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/rename_series.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld",
+						Priority: 5,
+						Event:    autoscan.EventModified,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns 200 on disabled SeriesDelete event without emitting a scan",
+			Given{
+				Config: Config{
+					Name:     "sonarr",
+					Priority: 5,
+					Events:   []string{"download", "upgrade", "rename"},
+				},
+				Fixture: "testdata/series_delete.json",
+			},
+			Expected{
+				StatusCode: 200,
+			},
+		},
+		{
+			"Uses the priority of the event type",
+			Given{
+				Config: Config{
+					Name:     "sonarr",
+					Priority: 5,
+					Priorities: map[string]int{
+						"download": 3,
+						"rename":   1,
+					},
+					Rewrite: []autoscan.Rewrite{{
+						From: "/TV/*",
+						To:   "/mnt/unionfs/Media/TV/$1",
+					}},
+				},
+				Fixture: "testdata/rename_series.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld",
+						Priority: 1,
+						Event:    autoscan.EventModified,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Uses the full path of the episode file of newer versions",
+			Given{
+				Config:  standardConfig,
+				Fixture: "testdata/v4.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld/Season 1",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Returns bad request on missing fields in strict mode",
+			Given{
+				Config: Config{
+					Name:     "sonarr",
+					Priority: 5,
+					Strict:   true,
+				},
+				Fixture: "testdata/missing_series.json",
+			},
+			Expected{
+				StatusCode: 400,
+			},
+		},
+		{
+			"Uses the path of the imported file returned by the API",
+			Given{
+				Config: Config{
+					Name:     "sonarr",
+					Priority: 5,
+					Rewrite: []autoscan.Rewrite{{
+						From: "/TV/*",
+						To:   "/mnt/unionfs/Media/TV/$1",
+					}},
+					Verify: VerifyConfig{
+						URL:    api.URL,
+						APIKey: "api-key",
+					},
+				},
+				Fixture: "testdata/verify.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld/Season 01",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
+						Time:     currentTime,
+					},
+				},
+			},
+		},
+		{
+			"Falls back to the path of the event when verification fails",
+			Given{
+				Config: Config{
+					Name:     "sonarr",
+					Priority: 5,
+					Rewrite: []autoscan.Rewrite{{
+						From: "/TV/*",
+						To:   "/mnt/unionfs/Media/TV/$1",
+					}},
+					Verify: VerifyConfig{
+						URL:    api.URL,
+						APIKey: "invalid",
+					},
+				},
+				Fixture: "testdata/verify.json",
+			},
+			Expected{
+				StatusCode: 200,
+				Scans: []autoscan.Scan{
+					{
+						Folder:   "/mnt/unionfs/Media/TV/Westworld/Season 1",
+						Priority: 5,
+						Event:    autoscan.EventAdded,
 						Time:     currentTime,
 					},
 				},
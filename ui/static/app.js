@@ -0,0 +1,212 @@
+'use strict';
+
+// The API is requested relative to the dashboard, such that it works behind a base path.
+const api = '../api';
+
+const $ = (id) => document.getElementById(id);
+
+async function request(method, path, body) {
+  const res = await fetch(api + path, {
+    method: method,
+    headers: body ? { 'Content-Type': 'application/json' } : {},
+    body: body ? JSON.stringify(body) : undefined,
+  });
+
+  const json = (res.headers.get('Content-Type') || '').startsWith('application/json');
+  const data = json ? await res.json() : null;
+  if (!res.ok) {
+    const err = new Error(res.status + ' ' + res.statusText);
+    err.data = data;
+    throw err;
+  }
+
+  return data;
+}
+
+function cell(text, className) {
+  const td = document.createElement('td');
+  td.textContent = text;
+  if (className) {
+    td.className = className;
+  }
+
+  return td;
+}
+
+function badge(text, className) {
+  const td = document.createElement('td');
+  const span = document.createElement('span');
+  span.className = 'badge ' + (className || text);
+  span.textContent = text;
+  td.appendChild(span);
+  return td;
+}
+
+function action(label, fn) {
+  const button = document.createElement('button');
+  button.className = 'link';
+  button.textContent = label;
+  button.addEventListener('click', () => fn().then(refresh).catch(showError));
+  return button;
+}
+
+function row(tbody, cells) {
+  const tr = document.createElement('tr');
+  cells.forEach((c) => tr.appendChild(c));
+  tbody.appendChild(tr);
+}
+
+function empty(tbody, columns, text) {
+  const td = cell(text, 'muted');
+  td.colSpan = columns;
+  row(tbody, [td]);
+}
+
+function time(value) {
+  if (!value || value.startsWith('0001-')) {
+    return '';
+  }
+
+  return new Date(value).toLocaleString();
+}
+
+// durations of the API are in nanoseconds
+function duration(ns) {
+  return (ns / 1e9).toFixed(1) + 's';
+}
+
+function showMessage(text, error) {
+  const p = $('message');
+  p.textContent = text;
+  p.className = error ? 'error' : '';
+}
+
+function showError(err) {
+  let text = err.message;
+  if (Array.isArray(err.data)) {
+    text += ': ' + err.data.filter((r) => r.error).map((r) => r.folder + ': ' + r.error).join(', ');
+  }
+
+  showMessage(text, true);
+}
+
+async function loadStatus() {
+  const status = await request('GET', '/status');
+  $('server').textContent = status.version + ', up ' + status.uptime;
+  $('paused').hidden = !status.paused;
+
+  const tbody = $('targets');
+  tbody.replaceChildren();
+  status.targets.forEach((t) => {
+    row(tbody, [
+      cell(t.target),
+      t.available ? badge('available', 'ok') : badge(t.error || 'unavailable', 'unavailable'),
+      cell(time(t.checked_at), 'muted'),
+    ]);
+  });
+
+  if (status.targets.length === 0) {
+    empty(tbody, 3, 'No targets configured');
+  }
+}
+
+async function loadQueue() {
+  const params = new URLSearchParams(new FormData($('filter')));
+  params.set('limit', '100');
+
+  const page = await request('GET', '/scans?' + params.toString());
+  $('total').textContent = page.total + ' scans';
+
+  const tbody = $('queue');
+  tbody.replaceChildren();
+  page.scans.forEach((s) => {
+    const actions = document.createElement('td');
+    if (s.state === 'retrying') {
+      actions.appendChild(action('retry', () => request('POST', '/queue/retry?id=' + encodeURIComponent(s.id))));
+    }
+
+    actions.appendChild(action('delete', () => request('DELETE', '/scans/' + encodeURIComponent(s.id))));
+    row(tbody, [
+      cell(s.folder, 'folder'),
+      cell(s.trigger),
+      cell(s.priority),
+      cell(time(s.time), 'muted'),
+      badge(s.state),
+      actions,
+    ]);
+  });
+
+  if (page.scans.length === 0) {
+    empty(tbody, 6, 'No scans queued');
+  }
+}
+
+async function loadHistory() {
+  const entries = await request('GET', '/history?limit=25');
+
+  const tbody = $('history');
+  tbody.replaceChildren();
+  (entries || []).forEach((e) => {
+    row(tbody, [
+      cell(e.folder, 'folder'),
+      cell(e.target),
+      cell(e.trigger),
+      badge(e.status),
+      cell(time(e.scanned_at), 'muted'),
+      cell(duration(e.duration), 'muted'),
+    ]);
+  });
+
+  if (!entries || entries.length === 0) {
+    empty(tbody, 6, 'No scans processed yet');
+  }
+}
+
+function refresh() {
+  return Promise.all([loadStatus(), loadQueue(), loadHistory()]).catch(showError);
+}
+
+$('submit').addEventListener('submit', (e) => {
+  e.preventDefault();
+  const form = new FormData(e.target);
+  const scan = {
+    folder: form.get('folder'),
+    priority: parseInt(form.get('priority'), 10) || 0,
+    event: form.get('event'),
+  };
+
+  request('POST', '/scans', [scan])
+    .then((results) => {
+      showMessage('Queued ' + results[0].folder);
+      e.target.reset();
+    })
+    .then(refresh)
+    .catch(showError);
+});
+
+$('filter').addEventListener('submit', (e) => {
+  e.preventDefault();
+  loadQueue().catch(showError);
+});
+
+$('retry-all').addEventListener('click', () => {
+  request('POST', '/queue/retry?all=true').then(refresh).catch(showError);
+});
+
+// the dashboard refreshes on the events of the scans, at most once a second,
+// and polls in case the event stream is unavailable
+let pending = null;
+const events = new EventSource(api + '/events');
+['queued', 'delivered', 'retrying', 'failed'].forEach((type) => {
+  events.addEventListener(type, () => {
+    if (!pending) {
+      pending = setTimeout(() => {
+        pending = null;
+        refresh();
+      }, 1000);
+    }
+  });
+});
+
+setInterval(refresh, 30000);
+refresh();
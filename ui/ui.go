@@ -0,0 +1,51 @@
+// Package ui serves the web dashboard of autoscan,
+// a single page which is built into the binary and talks to the autoscan API.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Path is the path at which the dashboard is served.
+// The dashboard calls the API relative to this path, such that it also works behind a base path.
+const Path = "/ui/"
+
+//go:embed static
+var static embed.FS
+
+// New creates the HTTP handler of the dashboard,
+// which should be added to the autoscan router at Path with the authentication of the API.
+// Requests of the root path are redirected to the dashboard.
+func New() http.Handler {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, http.StripPrefix(Path, http.FileServer(http.FS(files))))
+	mux.HandleFunc(Path[:len(Path)-1], func(rw http.ResponseWriter, r *http.Request) {
+		redirect(rw, Path[1:])
+	})
+
+	mux.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(rw, r)
+			return
+		}
+
+		redirect(rw, Path[1:])
+	})
+
+	return mux
+}
+
+// redirect redirects to the location relative to the requested path.
+// Unlike http.Redirect, the location is not resolved against the path,
+// which lacks the base path that autoscan may be served at.
+func redirect(rw http.ResponseWriter, location string) {
+	rw.Header().Set("Location", location)
+	rw.WriteHeader(http.StatusFound)
+}
@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	type Test struct {
+		Name         string
+		Path         string
+		WantCode     int
+		WantLocation string
+		WantBody     string
+	}
+
+	var testCases = []Test{
+		{
+			Name:     "Serves the dashboard",
+			Path:     "/ui/",
+			WantCode: 200,
+			WantBody: "<title>autoscan</title>",
+		},
+		{
+			Name:     "Serves the assets",
+			Path:     "/ui/app.js",
+			WantCode: 200,
+			WantBody: "const api = '../api';",
+		},
+		{
+			Name:         "Redirects the root relative to the base path",
+			Path:         "/",
+			WantCode:     302,
+			WantLocation: "ui/",
+		},
+		{
+			Name:         "Redirects the dashboard without a slash",
+			Path:         "/ui",
+			WantCode:     302,
+			WantLocation: "ui/",
+		},
+		{
+			Name:     "Returns not found for other paths",
+			Path:     "/unknown",
+			WantCode: 404,
+		},
+	}
+
+	server := httptest.NewServer(New())
+	defer server.Close()
+
+	client := server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			res, err := client.Get(server.URL + tc.Path)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer res.Body.Close()
+			if res.StatusCode != tc.WantCode {
+				t.Fatalf("Status codes do not match: %d vs %d", res.StatusCode, tc.WantCode)
+			}
+
+			if location := res.Header.Get("Location"); location != tc.WantLocation {
+				t.Errorf("Locations do not match: %q vs %q", location, tc.WantLocation)
+			}
+
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(string(body), tc.WantBody) {
+				t.Errorf("Body does not contain %q", tc.WantBody)
+			}
+		})
+	}
+}